@@ -5,25 +5,88 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 	"net/http"
+	"net/url"
+	"time"
 )
 
 type TokenUser struct {
-	ID       uint   `json:"id"`
-	Email    string `json:"email"`
-	UserRole string `json:"user_role"`
+	ID       uint     `json:"id"`
+	Email    string   `json:"email"`
+	UserRole string   `json:"user_role"`
+	Roles    []string `json:"roles"`
+}
+
+// TokenPair is a short lived access token and the single use refresh token that renews it
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// Device is where a sign in or a token renewal came from, it is shown in the user's session list
+type Device struct {
+	IP        string
+	UserAgent string
+}
+
+// Session is a device the user is signed in on
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
 }
 
 type AuthClient struct {
 	BaseURL string
+	// Tokens authenticate this service on the auth service's internal routes
+	Tokens *auth.ServiceTokenSource
 }
 
-func NewAuthClient(baseURL string) *AuthClient {
+func NewAuthClient(baseURL string, tokens *auth.ServiceTokenSource) *AuthClient {
 	return &AuthClient{
 		BaseURL: baseURL,
+		Tokens:  tokens,
 	}
 }
 
+// postInternal calls an internal route of the auth service with this service's token
+func (c *AuthClient) postInternal(path string, body []byte) (*http.Response, error) {
+	token, err := c.Tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/internal/auth/%s", c.BaseURL, path), bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+
+	return http.DefaultClient.Do(req)
+}
+
+var ErrPasswordRefused = errors.New("password does not meet the password policy")
+
+// PasswordPolicyError is a new password the auth service refused, Reason can be shown to the user
+type PasswordPolicyError struct {
+	Reason string
+}
+
+func (e PasswordPolicyError) Error() string {
+	return e.Reason
+}
+
+func (e PasswordPolicyError) Is(target error) bool {
+	return target == ErrPasswordRefused
+}
+
 func (c *AuthClient) CreateHashPassword(password string) (string, error) {
 	requestBody, err := json.Marshal(map[string]string{
 		"password": password,
@@ -32,16 +95,22 @@ func (c *AuthClient) CreateHashPassword(password string) (string, error) {
 		return "", err
 	}
 
-	resp, err := http.Post(
-		fmt.Sprintf("%s/auth/hash-password", c.BaseURL),
-		"application/json",
-		bytes.NewBuffer(requestBody),
-	)
+	resp, err := c.postInternal("hash-password", requestBody)
 	if err != nil {
 		return "", err
 	}
 	defer resp.Body.Close()
 
+	// a refused password comes back with the reason, it is shown to the user
+	if resp.StatusCode == http.StatusBadRequest {
+		var response struct {
+			Error string `json:"error"`
+		}
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil || response.Error == "" {
+			return "", ErrPasswordRefused
+		}
+		return "", PasswordPolicyError{Reason: response.Error}
+	}
 	if resp.StatusCode != http.StatusOK {
 		return "", fmt.Errorf("failed to hash password: %d", resp.StatusCode)
 	}
@@ -57,65 +126,311 @@ func (c *AuthClient) CreateHashPassword(password string) (string, error) {
 	return response.HashedPassword, nil
 }
 
-func (c *AuthClient) VerifyPassword(plainPassword, hashedPassword string) error {
+// VerifyPassword checks the password against its hash, needsRehash tells the caller to upgrade the
+// stored hash with RehashPassword while it still has the password
+func (c *AuthClient) VerifyPassword(plainPassword, hashedPassword string) (needsRehash bool, err error) {
 	requestBody, err := json.Marshal(map[string]string{
 		"plain_password":  plainPassword,
 		"hashed_password": hashedPassword,
 	})
+	if err != nil {
+		return false, err
+	}
+
+	resp, err := c.postInternal("verify-password", requestBody)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, errors.New("password verification failed")
+	}
+
+	var response struct {
+		NeedsRehash bool `json:"needs_rehash"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return false, err
+	}
+
+	return response.NeedsRehash, nil
+}
+
+// RehashPassword returns the hash of a password in use made with the current algorithm and cost
+func (c *AuthClient) RehashPassword(plainPassword, hashedPassword string) (string, error) {
+	requestBody, err := json.Marshal(map[string]string{
+		"plain_password":  plainPassword,
+		"hashed_password": hashedPassword,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := c.postInternal("rehash-password", requestBody)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", errors.New("failed to rehash password")
+	}
+
+	var response struct {
+		HashedPassword string `json:"hashed_password"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+
+	return response.HashedPassword, nil
+}
+
+func (c *AuthClient) GenerateToken(id uint, email, role string, roles []string, device Device) (string, error) {
+	tokens, err := c.GenerateTokens(id, email, role, roles, device)
+	if err != nil {
+		return "", err
+	}
+	return tokens.Token, nil
+}
+
+// GenerateTokens signs a user in with their primary role and any roles granted on top of it
+func (c *AuthClient) GenerateTokens(id uint, email, role string, roles []string, device Device) (TokenPair, error) {
+	requestBody, err := json.Marshal(map[string]interface{}{
+		"id":         id,
+		"email":      email,
+		"role":       role,
+		"roles":      roles,
+		"ip":         device.IP,
+		"user_agent": device.UserAgent,
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	resp, err := c.postInternal("generate-token", requestBody)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return TokenPair{}, fmt.Errorf("failed to generate token: %d", resp.StatusCode)
+	}
+
+	var response TokenPair
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return TokenPair{}, err
+	}
+
+	return response, nil
+}
+
+func (c *AuthClient) RefreshToken(refreshToken string, device Device) (TokenPair, error) {
+	requestBody, err := json.Marshal(map[string]string{
+		"refresh_token": refreshToken,
+		"ip":            device.IP,
+		"user_agent":    device.UserAgent,
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	resp, err := c.postInternal("refresh", requestBody)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return TokenPair{}, errors.New("token refresh failed")
+	}
+
+	var response TokenPair
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return TokenPair{}, err
+	}
+
+	return response, nil
+}
+
+// RevokeUser ends every session of the user and invalidates the tokens issued to them
+func (c *AuthClient) RevokeUser(userID uint) error {
+	requestBody, err := json.Marshal(map[string]uint{
+		"user_id": userID,
+	})
 	if err != nil {
 		return err
 	}
 
-	resp, err := http.Post(
-		fmt.Sprintf("%s/auth/verify-password", c.BaseURL),
-		"application/json",
-		bytes.NewBuffer(requestBody),
-	)
+	resp, err := c.postInternal("revoke-user", requestBody)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return errors.New("password verification failed")
+		return errors.New("could not revoke the user's tokens")
 	}
 
 	return nil
 }
 
-func (c *AuthClient) GenerateToken(id uint, email, role string) (string, error) {
+func (c *AuthClient) Logout(token, refreshToken string, allDevices bool) error {
 	requestBody, err := json.Marshal(map[string]interface{}{
-		"id":    id,
-		"email": email,
-		"role":  role,
+		"refresh_token": refreshToken,
+		"all_devices":   allDevices,
 	})
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	resp, err := http.Post(
-		fmt.Sprintf("%s/auth/generate-token", c.BaseURL),
-		"application/json",
-		bytes.NewBuffer(requestBody),
-	)
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/auth/logout", c.BaseURL), bytes.NewBuffer(requestBody))
 	if err != nil {
-		return "", err
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("logout failed")
+	}
+
+	return nil
+}
+
+// Sessions lists where the owner of the token is signed in
+func (c *AuthClient) Sessions(token string) ([]Session, error) {
+	resp, err := c.sendWithToken(http.MethodGet, "/auth/sessions", token)
+	if err != nil {
+		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("failed to generate token: %d", resp.StatusCode)
+		return nil, errors.New("failed to list sessions")
 	}
 
 	var response struct {
-		Token string `json:"token"`
+		Sessions []Session `json:"sessions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return response.Sessions, nil
+}
+
+var ErrSessionNotFound = errors.New("session not found")
+
+func (c *AuthClient) RevokeSession(token, id string) error {
+	resp, err := c.sendWithToken(http.MethodDelete, "/auth/sessions/"+url.PathEscape(id), token)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrSessionNotFound
 	}
+	if resp.StatusCode != http.StatusOK {
+		return errors.New("failed to revoke session")
+	}
+
+	return nil
+}
+
+// RevokeOtherSessions signs the owner of the token out of every session but the one the token belongs to
+func (c *AuthClient) RevokeOtherSessions(token string) (int, error) {
+	resp, err := c.sendWithToken(http.MethodDelete, "/auth/sessions", token)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
 
+	if resp.StatusCode != http.StatusOK {
+		return 0, errors.New("failed to revoke sessions")
+	}
+
+	var response struct {
+		Revoked int `json:"revoked"`
+	}
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
-		return "", err
+		return 0, err
+	}
+
+	return response.Revoked, nil
+}
+
+// Introspection is what the auth service knows about a token, an inactive token only has Active set
+type Introspection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope"`
+	ClientID  string   `json:"client_id"`
+	Username  string   `json:"username"`
+	TokenType string   `json:"token_type"`
+	Exp       int64    `json:"exp"`
+	Sub       string   `json:"sub"`
+	Aud       []string `json:"aud"`
+	Jti       string   `json:"jti"`
+	Roles     []string `json:"roles"`
+	SessionID string   `json:"sid"`
+}
+
+// Introspect asks the auth service whether a token is still active, unlike local verification it sees revoked sessions
+func (c *AuthClient) Introspect(token string) (Introspection, error) {
+	serviceToken, err := c.Tokens.Token()
+	if err != nil {
+		return Introspection{}, err
+	}
+
+	requestBody, err := json.Marshal(map[string]string{
+		"token": token,
+	})
+	if err != nil {
+		return Introspection{}, err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.BaseURL+"/auth/introspect", bytes.NewBuffer(requestBody))
+	if err != nil {
+		return Introspection{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", serviceToken)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return Introspection{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Introspection{}, errors.New("failed to introspect token")
+	}
+
+	var result Introspection
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Introspection{}, err
+	}
+
+	return result, nil
+}
+
+// sendWithToken calls a route of the auth service on behalf of the user the token belongs to
+func (c *AuthClient) sendWithToken(method, path, token string) (*http.Response, error) {
+	req, err := http.NewRequest(method, c.BaseURL+path, nil)
+	if err != nil {
+		return nil, err
 	}
+	req.Header.Set("Authorization", token)
 
-	return response.Token, nil
+	return http.DefaultClient.Do(req)
 }
 
 func (c *AuthClient) VerifyToken(token string) (*TokenUser, error) {
@@ -11,18 +11,61 @@ import (
 	"github.com/sharat789/zamazon-be-ms/auth/internal/api"
 	"github.com/sharat789/zamazon-be-ms/auth/internal/api/handlers"
 	"github.com/sharat789/zamazon-be-ms/auth/internal/config"
+	"github.com/sharat789/zamazon-be-ms/auth/internal/keys"
+	"github.com/sharat789/zamazon-be-ms/auth/internal/password"
 	"github.com/sharat789/zamazon-be-ms/auth/internal/service"
+	"github.com/sharat789/zamazon-be-ms/auth/internal/store"
 	"github.com/sharat789/zamazon-be-ms/metrics"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
 	"log"
 	"os"
+	"time"
 )
 
 func main() {
 	// Load configuration
 	cfg := config.LoadConfig()
 
+	// Initialize token store
+	tokenStore := store.NewMemoryStore()
+	if cfg.DSN != "" {
+		db, err := gorm.Open(postgres.Open(cfg.DSN), &gorm.Config{})
+		if err != nil {
+			log.Fatalf("db conn error %v", err)
+		}
+		tokenStore, err = store.NewPostgresStore(db)
+		if err != nil {
+			log.Fatalf("error on migration %v", err)
+		}
+	}
+
+	// Initialize signing keys, they stay published until the longest lived token they signed has expired
+	keyManager, err := keys.NewManager(tokenStore, cfg.SigningAlgorithm, cfg.SigningKeySecret, cfg.KeyRotationPeriod, cfg.KeyPublishAhead, max(cfg.AccessTokenTTL, cfg.ServiceTokenTTL))
+	if err != nil {
+		log.Fatalf("error loading signing keys %v", err)
+	}
+	keyManager.Start(time.Minute)
+
+	// Initialize password hashing
+	hasher, err := password.NewHasher(cfg.PasswordAlgorithm, cfg.BcryptCost, cfg.Argon2)
+	if err != nil {
+		log.Fatalf("error configuring password hashing %v", err)
+	}
+	policy := password.Policy{MinLength: cfg.PasswordMinLength, MaxLength: cfg.PasswordMaxLength}
+	if cfg.BreachedPasswordsDir != "" {
+		policy.Breached = password.RangeDir(cfg.BreachedPasswordsDir)
+	}
+
 	// Initialize auth service
-	authService := service.NewAuthService(cfg.JWTSecret)
+	authService := service.NewAuthService(keyManager, tokenStore, hasher, policy, service.TokenSettings{
+		Issuer:     cfg.TokenIssuer,
+		Audiences:  cfg.TokenAudiences,
+		AccessTTL:  cfg.AccessTokenTTL,
+		RefreshTTL: cfg.RefreshTokenTTL,
+		Clients:    cfg.ServiceClients,
+		ServiceTTL: cfg.ServiceTokenTTL,
+	})
 
 	// Create Fiber app
 	app := fiber.New()
@@ -2,9 +2,15 @@
 package handlers
 
 import (
+	"encoding/base64"
+	"errors"
 	"github.com/gofiber/fiber/v2"
 	"github.com/sharat789/zamazon-be-ms/auth/internal/service"
+	"github.com/sharat789/zamazon-be-ms/auth/internal/store"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
 )
 
 type AuthHandler struct {
@@ -35,6 +41,12 @@ func (h *AuthHandler) HashPassword(c *fiber.Ctx) error {
 	}
 
 	hashedPassword, err := h.authService.CreateHashPassword(req.Password)
+	if errors.Is(err, service.ErrHashingFailed) {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to hash password",
+			"error":   err.Error(),
+		})
+	}
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 			"message": "Failed to hash password",
@@ -61,7 +73,7 @@ func (h *AuthHandler) VerifyPassword(c *fiber.Ctx) error {
 		})
 	}
 
-	err := h.authService.VerifyPassword(req.PlainPassword, req.HashedPassword)
+	needsRehash, err := h.authService.VerifyPassword(req.PlainPassword, req.HashedPassword)
 	if err != nil {
 		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
 			"message": "Password verification failed",
@@ -70,18 +82,48 @@ func (h *AuthHandler) VerifyPassword(c *fiber.Ctx) error {
 	}
 
 	return c.Status(http.StatusOK).JSON(fiber.Map{
-		"message": "Password verified successfully",
+		"message":      "Password verified successfully",
+		"needs_rehash": needsRehash,
 	})
 }
 
-type GenerateTokenRequest struct {
-	ID    uint   `json:"id"`
-	Email string `json:"email"`
-	Role  string `json:"role"`
+// RehashPassword upgrades the hash of a password that was verified with needs_rehash set
+func (h *AuthHandler) RehashPassword(c *fiber.Ctx) error {
+	var req VerifyPasswordRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+
+	hashedPassword, err := h.authService.RehashPassword(req.PlainPassword, req.HashedPassword)
+	if errors.Is(err, service.ErrHashingFailed) {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"message": "Failed to hash password",
+			"error":   err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Password verification failed",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(HashPasswordResponse{
+		HashedPassword: hashedPassword,
+	})
 }
 
-type GenerateTokenResponse struct {
-	Token string `json:"token"`
+type GenerateTokenRequest struct {
+	ID    uint     `json:"id"`
+	Email string   `json:"email"`
+	Role  string   `json:"role"`
+	Roles []string `json:"roles"`
+	// IP and UserAgent describe the device the session is started on
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
 }
 
 func (h *AuthHandler) GenerateToken(c *fiber.Ctx) error {
@@ -93,7 +135,7 @@ func (h *AuthHandler) GenerateToken(c *fiber.Ctx) error {
 		})
 	}
 
-	token, err := h.authService.GenerateToken(req.ID, req.Email, req.Role)
+	token, err := h.authService.GenerateToken(req.ID, req.Email, req.Role, req.Roles, service.Device{IP: req.IP, UserAgent: req.UserAgent})
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
 			"message": "Failed to generate token",
@@ -101,11 +143,168 @@ func (h *AuthHandler) GenerateToken(c *fiber.Ctx) error {
 		})
 	}
 
-	return c.Status(http.StatusOK).JSON(GenerateTokenResponse{
-		Token: token,
+	return c.Status(http.StatusOK).JSON(token)
+}
+
+type RevokeUserRequest struct {
+	UserID uint `json:"user_id"`
+}
+
+// RevokeUser is called by the users service when an account must stop working on every service
+func (h *AuthHandler) RevokeUser(c *fiber.Ctx) error {
+	var req RevokeUserRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+
+	err := h.authService.RevokeUser(req.UserID)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Failed to revoke user tokens",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "User tokens revoked",
+	})
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	IP           string `json:"ip"`
+	UserAgent    string `json:"user_agent"`
+}
+
+func (h *AuthHandler) RefreshToken(c *fiber.Ctx) error {
+	var req RefreshTokenRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"message": "Invalid request",
+			"error":   err.Error(),
+		})
+	}
+
+	token, err := h.authService.RefreshToken(req.RefreshToken, service.Device{IP: req.IP, UserAgent: req.UserAgent})
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Failed to refresh token",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(token)
+}
+
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+	AllDevices   bool   `json:"all_devices"`
+}
+
+// Logout revokes the access token in the Authorization header
+func (h *AuthHandler) Logout(c *fiber.Ctx) error {
+	var req LogoutRequest
+	if len(c.Body()) > 0 {
+		if err := c.BodyParser(&req); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"message": "Invalid request",
+				"error":   err.Error(),
+			})
+		}
+	}
+
+	err := h.authService.Logout(c.Get("Authorization"), req.RefreshToken, req.AllDevices)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Logout failed",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Logged out successfully",
+	})
+}
+
+// Sessions lists the sessions of the user the access token in the Authorization header belongs to
+func (h *AuthHandler) Sessions(c *fiber.Ctx) error {
+	sessions, err := h.authService.Sessions(c.Get("Authorization"))
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Failed to list sessions",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"sessions": sessions,
+	})
+}
+
+func (h *AuthHandler) RevokeSession(c *fiber.Ctx) error {
+	err := h.authService.RevokeSession(c.Get("Authorization"), c.Params("id"))
+	if errors.Is(err, store.ErrSessionNotFound) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{
+			"message": "Session not found",
+		})
+	}
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Failed to revoke session",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Session revoked successfully",
+	})
+}
+
+// RevokeOtherSessions signs the user out everywhere except where the request came from
+func (h *AuthHandler) RevokeOtherSessions(c *fiber.Ctx) error {
+	revoked, err := h.authService.RevokeOtherSessions(c.Get("Authorization"))
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"message": "Failed to revoke sessions",
+			"error":   err.Error(),
+		})
+	}
+
+	return c.Status(http.StatusOK).JSON(fiber.Map{
+		"message": "Other sessions revoked successfully",
+		"revoked": revoked,
 	})
 }
 
+type IntrospectRequest struct {
+	Token         string `json:"token" form:"token"`
+	TokenTypeHint string `json:"token_type_hint" form:"token_type_hint"`
+}
+
+// Introspect is the RFC 7662 introspection endpoint, internal services ask it about tokens they were handed
+func (h *AuthHandler) Introspect(c *fiber.Ctx) error {
+	var req IntrospectRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error":             "invalid_request",
+			"error_description": err.Error(),
+		})
+	}
+
+	result, err := h.authService.Introspect(req.Token, req.TokenTypeHint)
+	if err != nil {
+		return c.Status(http.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "temporarily_unavailable",
+		})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(http.StatusOK).JSON(result)
+}
+
 type VerifyTokenRequest struct {
 	Token string `json:"token"`
 }
@@ -133,6 +332,14 @@ func (h *AuthHandler) VerifyToken(c *fiber.Ctx) error {
 	})
 }
 
+// jwksMaxAge must stay below the key publish ahead window so caches see a new key before it signs
+const jwksMaxAge = "public, max-age=300"
+
+func (h *AuthHandler) JWKS(c *fiber.Ctx) error {
+	c.Set(fiber.HeaderCacheControl, jwksMaxAge)
+	return c.Status(http.StatusOK).JSON(h.authService.Keys.JWKS(time.Now()))
+}
+
 func (h *AuthHandler) GenerateCode(c *fiber.Ctx) error {
 	code, err := h.authService.GenerateCode()
 	if err != nil {
@@ -198,3 +405,78 @@ func (h *AuthHandler) AuthMiddleware(c *fiber.Ctx) error {
 	c.Locals("user", user)
 	return c.Next()
 }
+
+type ClientCredentialsRequest struct {
+	GrantType    string `json:"grant_type" form:"grant_type"`
+	ClientID     string `json:"client_id" form:"client_id"`
+	ClientSecret string `json:"client_secret" form:"client_secret"`
+	Scope        string `json:"scope" form:"scope"`
+}
+
+// Token is the OAuth2 token endpoint, internal services get their service tokens here with the client credentials grant
+func (h *AuthHandler) Token(c *fiber.Ctx) error {
+	var req ClientCredentialsRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error":             "invalid_request",
+			"error_description": err.Error(),
+		})
+	}
+	if req.GrantType != "client_credentials" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": "unsupported_grant_type",
+		})
+	}
+
+	// clients may authenticate with HTTP basic instead of the body
+	if header := c.Get(fiber.HeaderAuthorization); strings.HasPrefix(header, "Basic ") {
+		decoded, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(header, "Basic "))
+		if err == nil {
+			id, secret, _ := strings.Cut(string(decoded), ":")
+			req.ClientID, _ = url.QueryUnescape(id)
+			req.ClientSecret, _ = url.QueryUnescape(secret)
+		}
+	}
+
+	token, err := h.authService.ClientCredentials(req.ClientID, req.ClientSecret, strings.Fields(req.Scope))
+	if errors.Is(err, service.ErrInvalidClient) {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+			"error": "invalid_client",
+		})
+	}
+	if errors.Is(err, service.ErrInvalidScope) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error":             "invalid_scope",
+			"error_description": err.Error(),
+		})
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error": "server_error",
+		})
+	}
+
+	c.Set(fiber.HeaderCacheControl, "no-store")
+	return c.Status(http.StatusOK).JSON(token)
+}
+
+// RequireScope only lets through internal services whose service token holds the scope
+func (h *AuthHandler) RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		caller, err := h.authService.VerifyServiceToken(c.Get("Authorization"))
+		if err != nil {
+			return c.Status(http.StatusUnauthorized).JSON(fiber.Map{
+				"message": "Authentication failed",
+				"error":   err.Error(),
+			})
+		}
+		if !caller.HasScope(scope) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{
+				"message": "Insufficient permissions",
+			})
+		}
+
+		c.Locals("service", &caller)
+		return c.Next()
+	}
+}
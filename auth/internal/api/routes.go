@@ -3,19 +3,38 @@ package api
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/sharat789/zamazon-be-ms/auth/internal/api/handlers"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 )
 
 func SetupRoutes(app *fiber.App, authHandler *handlers.AuthHandler) {
+	// Public keys other services verify tokens with
+	app.Get("/.well-known/jwks.json", authHandler.JWKS)
+
 	// Auth routes
 	authGroup := app.Group("/auth")
 
-	authGroup.Post("/hash-password", authHandler.HashPassword)
-	authGroup.Post("/verify-password", authHandler.VerifyPassword)
-	authGroup.Post("/generate-token", authHandler.GenerateToken)
+	authGroup.Post("/token", authHandler.Token)
 	authGroup.Post("/verify-token", authHandler.VerifyToken)
+	authGroup.Post("/logout", authHandler.Logout)
+	authGroup.Post("/introspect", authHandler.RequireScope(auth.SCOPE_AUTH_INTROSPECT), authHandler.Introspect)
+
+	// Sessions of the user the access token belongs to
+	authGroup.Get("/sessions", authHandler.Sessions)
+	authGroup.Delete("/sessions", authHandler.RevokeOtherSessions)
+	authGroup.Delete("/sessions/:id", authHandler.RevokeSession)
 	authGroup.Post("/authorize-by-role", authHandler.AuthorizeByRole)
 	authGroup.Get("/generate-code", authHandler.GenerateCode)
 
+	// Internal routes are only for other services, each needs a service token with its scope
+	internalGroup := app.Group("/internal/auth")
+
+	internalGroup.Post("/hash-password", authHandler.RequireScope(auth.SCOPE_AUTH_PASSWORDS), authHandler.HashPassword)
+	internalGroup.Post("/verify-password", authHandler.RequireScope(auth.SCOPE_AUTH_PASSWORDS), authHandler.VerifyPassword)
+	internalGroup.Post("/rehash-password", authHandler.RequireScope(auth.SCOPE_AUTH_PASSWORDS), authHandler.RehashPassword)
+	internalGroup.Post("/generate-token", authHandler.RequireScope(auth.SCOPE_AUTH_TOKENS), authHandler.GenerateToken)
+	internalGroup.Post("/refresh", authHandler.RequireScope(auth.SCOPE_AUTH_TOKENS), authHandler.RefreshToken)
+	internalGroup.Post("/revoke-user", authHandler.RequireScope(auth.SCOPE_AUTH_TOKENS), authHandler.RevokeUser)
+
 	// This endpoint can be used by other services to validate tokens
 	authGroup.Get("/validate", authHandler.AuthMiddleware, func(c *fiber.Ctx) error {
 		user := c.Locals("user")
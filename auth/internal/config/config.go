@@ -1,22 +1,138 @@
 package config
 
 import (
+	"github.com/sharat789/zamazon-be-ms/auth/internal/password"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 )
 
 type Config struct {
-	JWTSecret string
+	// SigningAlgorithm is EdDSA or RS256, tokens are signed with asymmetric keys so other services only need the public half
+	SigningAlgorithm string
+	// SigningKeySecret encrypts the private signing keys at rest
+	SigningKeySecret  string
+	KeyRotationPeriod time.Duration
+	KeyPublishAhead   time.Duration
+	// DSN is the database token state is kept in, tokens are only held in memory without one
+	DSN             string
+	TokenIssuer     string
+	TokenAudiences  []string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	// ServiceClients are the internal services that get service tokens, keyed by client id
+	ServiceClients  map[string]string
+	ServiceTokenTTL time.Duration
+	// PasswordAlgorithm is argon2id or bcrypt, hashes made with anything else are upgraded on sign in
+	PasswordAlgorithm string
+	BcryptCost        int
+	Argon2            password.Argon2Params
+	PasswordMinLength int
+	PasswordMaxLength int
+	// BreachedPasswordsDir holds the local breached password range files, the check is skipped without it
+	BreachedPasswordsDir string
 }
 
 func LoadConfig() *Config {
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if jwtSecret == "" {
-		log.Println("Warning: JWT_SECRET environment variable not set, using default")
-		jwtSecret = "qhx5shDMjBChXdPrxzFmCr+W09Dz4uPvUGUp66xCrCs="
+	signingAlgorithm := os.Getenv("SIGNING_ALGORITHM")
+	if signingAlgorithm == "" {
+		signingAlgorithm = "EdDSA"
+	}
+
+	// there is no default, a shared one would decrypt the private signing keys of every deployment
+	signingKeySecret := os.Getenv("SIGNING_KEY_SECRET")
+	if signingKeySecret == "" {
+		log.Fatal("SIGNING_KEY_SECRET environment variable not set, the private signing keys cannot be encrypted")
+	}
+
+	dsn := os.Getenv("DSN")
+	if dsn == "" {
+		log.Println("Warning: DSN environment variable not set, refresh tokens and revocations are kept in memory")
+	}
+
+	tokenIssuer := os.Getenv("TOKEN_ISSUER")
+	if tokenIssuer == "" {
+		tokenIssuer = auth.ISSUER
+	}
+	// services a user's access token is accepted by, each verifies it is in the audience
+	tokenAudiences := []string{auth.AUDIENCE_USERS, auth.AUDIENCE_TRANSACTIONS, auth.AUDIENCE_CATALOG}
+	if audiences := os.Getenv("TOKEN_AUDIENCES"); audiences != "" {
+		tokenAudiences = strings.Split(audiences, ",")
+	}
+
+	// internal services are given as id:secret pairs, no service can call internal routes without them
+	serviceClients := map[string]string{}
+	for _, client := range strings.Split(os.Getenv("SERVICE_CLIENTS"), ",") {
+		id, secret, ok := strings.Cut(strings.TrimSpace(client), ":")
+		if !ok || id == "" || secret == "" {
+			continue
+		}
+		serviceClients[id] = secret
+	}
+	if len(serviceClients) == 0 {
+		log.Println("Warning: SERVICE_CLIENTS environment variable not set, internal routes cannot be called")
+	}
+
+	passwordAlgorithm := os.Getenv("PASSWORD_HASH_ALGORITHM")
+	if passwordAlgorithm == "" {
+		passwordAlgorithm = password.ALG_ARGON2ID
+	}
+	argon2Params := password.DefaultArgon2Params
+	argon2Params.Memory = uint32(intEnv("ARGON2_MEMORY_KIB", int(argon2Params.Memory)))
+	argon2Params.Iterations = uint32(intEnv("ARGON2_ITERATIONS", int(argon2Params.Iterations)))
+	argon2Params.Parallelism = uint8(intEnv("ARGON2_PARALLELISM", int(argon2Params.Parallelism)))
+
+	breachedPasswordsDir := os.Getenv("BREACHED_PASSWORDS_DIR")
+	if breachedPasswordsDir == "" {
+		log.Println("Warning: BREACHED_PASSWORDS_DIR environment variable not set, new passwords are not checked against breached ones")
 	}
 
 	return &Config{
-		JWTSecret: jwtSecret,
+		SigningAlgorithm:     signingAlgorithm,
+		SigningKeySecret:     signingKeySecret,
+		KeyRotationPeriod:    durationEnv("KEY_ROTATION_PERIOD", 30*24*time.Hour),
+		KeyPublishAhead:      durationEnv("KEY_PUBLISH_AHEAD", time.Hour),
+		DSN:                  dsn,
+		TokenIssuer:          tokenIssuer,
+		TokenAudiences:       tokenAudiences,
+		AccessTokenTTL:       durationEnv("ACCESS_TOKEN_TTL", 15*time.Minute),
+		RefreshTokenTTL:      durationEnv("REFRESH_TOKEN_TTL", 30*24*time.Hour),
+		ServiceClients:       serviceClients,
+		ServiceTokenTTL:      durationEnv("SERVICE_TOKEN_TTL", 10*time.Minute),
+		PasswordAlgorithm:    passwordAlgorithm,
+		BcryptCost:           intEnv("BCRYPT_COST", 12),
+		Argon2:               argon2Params,
+		PasswordMinLength:    intEnv("PASSWORD_MIN_LENGTH", 8),
+		PasswordMaxLength:    intEnv("PASSWORD_MAX_LENGTH", 128),
+		BreachedPasswordsDir: breachedPasswordsDir,
+	}
+}
+
+func durationEnv(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil || d <= 0 {
+		log.Printf("Warning: invalid %s %q, using %s", key, value, fallback)
+		return fallback
+	}
+	return d
+}
+
+func intEnv(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil || n <= 0 {
+		log.Printf("Warning: invalid %s %q, using %d", key, value, fallback)
+		return fallback
 	}
+	return n
 }
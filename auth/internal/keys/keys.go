@@ -0,0 +1,311 @@
+package keys
+
+import (
+	"crypto"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/sharat789/zamazon-be-ms/auth/internal/store"
+	"log"
+	"math/big"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	ALG_EDDSA = "EdDSA"
+	ALG_RS256 = "RS256"
+)
+
+const rsaKeyBits = 2048
+
+// SigningKey signs tokens from NotBefore until RetiresAt and stays published until ExpiresAt,
+// by when every token it signed has expired
+type SigningKey struct {
+	KID        string
+	Algorithm  string
+	PrivateKey crypto.Signer
+	NotBefore  time.Time
+	RetiresAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// JWK is a public key as published in the key set
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+// Manager rotates the signing keys, each key signs for the rotation interval. The next key is
+// published ahead of time so verifiers caching the key set already know it when it starts signing
+type Manager struct {
+	store        store.KeyStore
+	algorithm    string
+	interval     time.Duration
+	publishAhead time.Duration
+	tokenTTL     time.Duration
+	aead         cipher.AEAD
+
+	mu   sync.RWMutex
+	keys []SigningKey
+}
+
+// NewManager loads or creates the signing keys, tokenTTL is the lifetime of the longest lived token they sign
+func NewManager(keyStore store.KeyStore, algorithm string, secret string, interval time.Duration, publishAhead time.Duration, tokenTTL time.Duration) (*Manager, error) {
+	if algorithm != ALG_EDDSA && algorithm != ALG_RS256 {
+		return nil, fmt.Errorf("unsupported signing algorithm: %s", algorithm)
+	}
+	if publishAhead >= interval {
+		return nil, errors.New("keys must be published ahead by less than the rotation interval")
+	}
+
+	// private keys are encrypted at rest with a key derived from the secret
+	sum := sha256.Sum256([]byte(secret))
+	block, err := aes.NewCipher(sum[:])
+	if err != nil {
+		return nil, err
+	}
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	m := &Manager{
+		store:        keyStore,
+		algorithm:    algorithm,
+		interval:     interval,
+		publishAhead: publishAhead,
+		tokenTTL:     tokenTTL,
+		aead:         aead,
+	}
+	return m, m.Rotate(time.Now())
+}
+
+// Start rotates keys in the background, it also picks up keys created by other instances
+func (m *Manager) Start(every time.Duration) {
+	go func() {
+		for range time.Tick(every) {
+			if err := m.Rotate(time.Now()); err != nil {
+				log.Printf("Error while rotating signing keys %v", err)
+			}
+		}
+	}()
+}
+
+// Rotate drops expired keys and makes sure there is a key signing now and, once it is due, a next key
+func (m *Manager) Rotate(now time.Time) error {
+	err := m.store.DeleteExpiredSigningKeys(now)
+	if err != nil {
+		return err
+	}
+	records, err := m.store.ListSigningKeys()
+	if err != nil {
+		return err
+	}
+
+	var keys []SigningKey
+	for _, record := range records {
+		key, err := m.decode(record)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", record.KID, err)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sortKeys(keys)
+
+	current, ok := signingKey(keys, now)
+	if !ok || !now.Before(current.RetiresAt) {
+		key, err := m.create(now)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+		current = key
+	}
+	if !now.Before(current.RetiresAt.Add(-m.publishAhead)) && !hasKeyFrom(keys, current.RetiresAt) {
+		key, err := m.create(current.RetiresAt)
+		if err != nil {
+			return err
+		}
+		keys = append(keys, key)
+	}
+	sortKeys(keys)
+
+	m.mu.Lock()
+	m.keys = keys
+	m.mu.Unlock()
+	return nil
+}
+
+// SigningKey is the key new tokens are signed with
+func (m *Manager) SigningKey(now time.Time) (SigningKey, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	key, ok := signingKey(m.keys, now)
+	if !ok {
+		return SigningKey{}, errors.New("no signing key available")
+	}
+	return key, nil
+}
+
+// PublicKey finds a published key by its id
+func (m *Manager) PublicKey(kid string, now time.Time) (crypto.PublicKey, string, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, key := range m.keys {
+		if key.KID == kid && now.Before(key.ExpiresAt) {
+			return key.PrivateKey.Public(), key.Algorithm, nil
+		}
+	}
+	return nil, "", fmt.Errorf("unknown signing key: %s", kid)
+}
+
+// JWKS publishes the public half of every key that is signing, retired but not expired, or about to sign
+func (m *Manager) JWKS(now time.Time) JWKS {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	set := JWKS{Keys: []JWK{}}
+	for _, key := range m.keys {
+		if !now.Before(key.ExpiresAt) {
+			continue
+		}
+		jwk := JWK{Kid: key.KID, Use: "sig", Alg: key.Algorithm}
+		switch pub := key.PrivateKey.Public().(type) {
+		case ed25519.PublicKey:
+			jwk.Kty, jwk.Crv, jwk.X = "OKP", "Ed25519", base64.RawURLEncoding.EncodeToString(pub)
+		case *rsa.PublicKey:
+			jwk.Kty = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(pub.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(pub.E)).Bytes())
+		}
+		set.Keys = append(set.Keys, jwk)
+	}
+	return set
+}
+
+func (m *Manager) create(notBefore time.Time) (SigningKey, error) {
+	var private crypto.Signer
+	var err error
+	if m.algorithm == ALG_RS256 {
+		private, err = rsa.GenerateKey(rand.Reader, rsaKeyBits)
+	} else {
+		_, private, err = ed25519.GenerateKey(rand.Reader)
+	}
+	if err != nil {
+		return SigningKey{}, err
+	}
+
+	kidBytes := make([]byte, 8)
+	if _, err := rand.Read(kidBytes); err != nil {
+		return SigningKey{}, err
+	}
+	key := SigningKey{
+		KID:        hex.EncodeToString(kidBytes),
+		Algorithm:  m.algorithm,
+		PrivateKey: private,
+		NotBefore:  notBefore,
+		RetiresAt:  notBefore.Add(m.interval),
+		ExpiresAt:  notBefore.Add(m.interval + m.tokenTTL),
+	}
+
+	encoded, err := x509.MarshalPKCS8PrivateKey(private)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	nonce := make([]byte, m.aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return SigningKey{}, err
+	}
+	err = m.store.SaveSigningKey(store.SigningKeyRecord{
+		KID:        key.KID,
+		Algorithm:  key.Algorithm,
+		PrivateKey: m.aead.Seal(nonce, nonce, encoded, []byte(key.KID)),
+		NotBefore:  key.NotBefore,
+		RetiresAt:  key.RetiresAt,
+		ExpiresAt:  key.ExpiresAt,
+	})
+	if err != nil {
+		return SigningKey{}, err
+	}
+	log.Printf("Created %s signing key %s, signing from %s", key.Algorithm, key.KID, key.NotBefore.Format(time.RFC3339))
+	return key, nil
+}
+
+func (m *Manager) decode(record store.SigningKeyRecord) (SigningKey, error) {
+	size := m.aead.NonceSize()
+	if len(record.PrivateKey) < size {
+		return SigningKey{}, errors.New("malformed key")
+	}
+	encoded, err := m.aead.Open(nil, record.PrivateKey[:size], record.PrivateKey[size:], []byte(record.KID))
+	if err != nil {
+		return SigningKey{}, errors.New("key cannot be decrypted with the configured secret")
+	}
+	private, err := x509.ParsePKCS8PrivateKey(encoded)
+	if err != nil {
+		return SigningKey{}, err
+	}
+	signer, ok := private.(crypto.Signer)
+	if !ok {
+		return SigningKey{}, errors.New("unsupported key type")
+	}
+	return SigningKey{
+		KID:        record.KID,
+		Algorithm:  record.Algorithm,
+		PrivateKey: signer,
+		NotBefore:  record.NotBefore,
+		RetiresAt:  record.RetiresAt,
+		ExpiresAt:  record.ExpiresAt,
+	}, nil
+}
+
+// signingKey picks the newest key that has started signing, keys are sorted oldest first
+func signingKey(keys []SigningKey, now time.Time) (SigningKey, bool) {
+	for i := len(keys) - 1; i >= 0; i-- {
+		if !keys[i].NotBefore.After(now) && now.Before(keys[i].ExpiresAt) {
+			return keys[i], true
+		}
+	}
+	return SigningKey{}, false
+}
+
+func hasKeyFrom(keys []SigningKey, at time.Time) bool {
+	for _, key := range keys {
+		if !key.NotBefore.Before(at) {
+			return true
+		}
+	}
+	return false
+}
+
+// sortKeys orders keys by when they start signing, instances racing to create a key agree on the kid
+func sortKeys(keys []SigningKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].NotBefore.Equal(keys[j].NotBefore) {
+			return keys[i].KID < keys[j].KID
+		}
+		return keys[i].NotBefore.Before(keys[j].NotBefore)
+	})
+}
@@ -0,0 +1,154 @@
+package password
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+)
+
+const (
+	ALG_ARGON2ID = "argon2id"
+	ALG_BCRYPT   = "bcrypt"
+)
+
+var (
+	ErrMismatch      = errors.New("password does not match")
+	ErrUnknownFormat = errors.New("unknown password hash format")
+)
+
+// bounds of the Argon2id parameters read from a stored hash, a hash outside them is refused rather
+// than letting a tampered row make a sign in allocate gigabytes or run for minutes
+const (
+	maxArgon2Memory     = 1024 * 1024
+	maxArgon2Iterations = 16
+	minArgon2SaltLength = 8
+	maxArgon2SaltLength = 64
+	minArgon2KeyLength  = 16
+	maxArgon2KeyLength  = 64
+)
+
+// Argon2Params are the cost of an Argon2id hash, Memory is in KiB
+type Argon2Params struct {
+	Memory      uint32
+	Iterations  uint32
+	Parallelism uint8
+	SaltLength  uint32
+	KeyLength   uint32
+}
+
+// DefaultArgon2Params are the OWASP minimum for Argon2id, they keep each hash to 19 MiB of memory
+var DefaultArgon2Params = Argon2Params{
+	Memory:      19 * 1024,
+	Iterations:  2,
+	Parallelism: 1,
+	SaltLength:  16,
+	KeyLength:   32,
+}
+
+// Hasher hashes new passwords with the configured algorithm and cost. Hashes carry the algorithm
+// and its parameters, bcrypt in its own format and Argon2id in the PHC format
+// $argon2id$v=19$m=19456,t=2,p=1$salt$hash, so older hashes keep verifying after the settings change
+type Hasher struct {
+	algorithm  string
+	bcryptCost int
+	argon2     Argon2Params
+}
+
+func NewHasher(algorithm string, bcryptCost int, argon2Params Argon2Params) (*Hasher, error) {
+	if algorithm != ALG_ARGON2ID && algorithm != ALG_BCRYPT {
+		return nil, fmt.Errorf("unsupported password hashing algorithm: %s", algorithm)
+	}
+	if bcryptCost < bcrypt.MinCost || bcryptCost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt cost must be between %d and %d", bcrypt.MinCost, bcrypt.MaxCost)
+	}
+	if argon2Params.Memory < 8*uint32(argon2Params.Parallelism) || argon2Params.Memory > maxArgon2Memory ||
+		argon2Params.Iterations < 1 || argon2Params.Iterations > maxArgon2Iterations || argon2Params.Parallelism < 1 ||
+		argon2Params.SaltLength < minArgon2SaltLength || argon2Params.SaltLength > maxArgon2SaltLength ||
+		argon2Params.KeyLength < minArgon2KeyLength || argon2Params.KeyLength > maxArgon2KeyLength {
+		return nil, errors.New("invalid argon2id parameters")
+	}
+	return &Hasher{algorithm: algorithm, bcryptCost: bcryptCost, argon2: argon2Params}, nil
+}
+
+func (h *Hasher) Hash(password string) (string, error) {
+	if h.algorithm == ALG_BCRYPT {
+		hash, err := bcrypt.GenerateFromPassword([]byte(password), h.bcryptCost)
+		if err != nil {
+			return "", err
+		}
+		return string(hash), nil
+	}
+
+	salt := make([]byte, h.argon2.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key := argon2.IDKey([]byte(password), salt, h.argon2.Iterations, h.argon2.Memory, h.argon2.Parallelism, h.argon2.KeyLength)
+	return fmt.Sprintf("$%s$v=%d$m=%d,t=%d,p=%d$%s$%s", ALG_ARGON2ID, argon2.Version, h.argon2.Memory, h.argon2.Iterations, h.argon2.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+// Verify checks the password against the hash, needsRehash is set when the hash was made with
+// another algorithm or a lower cost than new hashes get, it is only meaningful when the password matched
+func (h *Hasher) Verify(password string, encoded string) (needsRehash bool, err error) {
+	if strings.HasPrefix(encoded, "$"+ALG_ARGON2ID+"$") {
+		params, salt, key, err := decodeArgon2(encoded)
+		if err != nil {
+			return false, err
+		}
+		other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, uint32(len(key)))
+		if subtle.ConstantTimeCompare(key, other) != 1 {
+			return false, ErrMismatch
+		}
+		return h.algorithm != ALG_ARGON2ID || params.Memory < h.argon2.Memory || params.Iterations < h.argon2.Iterations ||
+			params.Parallelism < h.argon2.Parallelism || uint32(len(key)) < h.argon2.KeyLength, nil
+	}
+
+	cost, err := bcrypt.Cost([]byte(encoded))
+	if err != nil {
+		return false, ErrUnknownFormat
+	}
+	err = bcrypt.CompareHashAndPassword([]byte(encoded), []byte(password))
+	if err != nil {
+		return false, ErrMismatch
+	}
+	return h.algorithm != ALG_BCRYPT || cost < h.bcryptCost, nil
+}
+
+func decodeArgon2(encoded string) (Argon2Params, []byte, []byte, error) {
+	// the leading $ leaves an empty first part
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 6 {
+		return Argon2Params{}, nil, nil, ErrUnknownFormat
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return Argon2Params{}, nil, nil, ErrUnknownFormat
+	}
+	var params Argon2Params
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism); err != nil {
+		return Argon2Params{}, nil, nil, ErrUnknownFormat
+	}
+	// argon2.IDKey panics on zero iterations or parallelism
+	if params.Iterations < 1 || params.Iterations > maxArgon2Iterations || params.Parallelism < 1 ||
+		params.Memory < 8*uint32(params.Parallelism) || params.Memory > maxArgon2Memory {
+		return Argon2Params{}, nil, nil, ErrUnknownFormat
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil || len(salt) < minArgon2SaltLength || len(salt) > maxArgon2SaltLength {
+		return Argon2Params{}, nil, nil, ErrUnknownFormat
+	}
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) < minArgon2KeyLength || len(key) > maxArgon2KeyLength {
+		return Argon2Params{}, nil, nil, ErrUnknownFormat
+	}
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
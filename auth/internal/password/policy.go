@@ -0,0 +1,83 @@
+package password
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+)
+
+var ErrBreached = errors.New("this password has appeared in a data breach, please choose another one")
+
+// BreachedList tells whether a password is known from data breaches
+type BreachedList interface {
+	Contains(password string) (bool, error)
+}
+
+// RangeDir is a local copy of a k-anonymity breached password list, one file per five character
+// SHA-1 prefix named like 21BD1.txt, holding the rest of each hash as SUFFIX:COUNT lines.
+// Only the file of the password's prefix is read
+type RangeDir string
+
+func (d RangeDir) Contains(password string) (bool, error) {
+	sum := sha1.Sum([]byte(password))
+	hash := strings.ToUpper(hex.EncodeToString(sum[:]))
+	prefix, suffix := hash[:5], hash[5:]
+
+	file, err := os.Open(filepath.Join(string(d), prefix+".txt"))
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		candidate, _, _ := strings.Cut(strings.TrimSpace(scanner.Text()), ":")
+		if strings.EqualFold(candidate, suffix) {
+			return true, nil
+		}
+	}
+	return false, scanner.Err()
+}
+
+// Policy is what a new password must meet, it is checked at sign up and when the password is changed
+type Policy struct {
+	MinLength int
+	MaxLength int
+	// Breached is not checked when nil
+	Breached BreachedList
+}
+
+// Check returns the reason a password is refused, length is counted in characters rather than bytes
+func (p Policy) Check(password string) error {
+	length := utf8.RuneCountInString(password)
+	if length < p.MinLength {
+		return fmt.Errorf("length of the password must be at least %d characters", p.MinLength)
+	}
+	if p.MaxLength > 0 && length > p.MaxLength {
+		return fmt.Errorf("length of the password must be at most %d characters", p.MaxLength)
+	}
+
+	if p.Breached == nil {
+		return nil
+	}
+	breached, err := p.Breached.Contains(password)
+	if err != nil {
+		// the list is a safeguard, an unreadable copy should not stop people signing up
+		log.Printf("Error while checking the breached password list %v", err)
+		return nil
+	}
+	if breached {
+		return ErrBreached
+	}
+	return nil
+}
@@ -5,8 +5,13 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang-jwt/jwt/v4"
-	"golang.org/x/crypto/bcrypt"
+	"github.com/sharat789/zamazon-be-ms/auth/internal/keys"
+	"github.com/sharat789/zamazon-be-ms/auth/internal/password"
+	"github.com/sharat789/zamazon-be-ms/auth/internal/store"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
+	"log"
 	"math/rand"
+	"strconv"
 	"strings"
 	"time"
 )
@@ -14,106 +19,283 @@ import (
 const (
 	ROLE_SELLER = "seller"
 	ROLE_BUYER  = "buyer"
+	ROLE_ADMIN  = "admin"
 )
 
+func isKnownRole(role string) bool {
+	switch role {
+	case ROLE_SELLER, ROLE_BUYER, ROLE_ADMIN:
+		return true
+	}
+	return false
+}
+
+// tokens carry their issue time to the millisecond, a revocation then also covers the tokens issued
+// earlier in the same second without rejecting the ones issued after it
+func init() {
+	jwt.TimePrecision = time.Millisecond
+}
+
 type TokenUser struct {
-	ID       uint   `json:"id"`
-	Email    string `json:"email"`
-	UserRole string `json:"user_role"`
+	ID       uint     `json:"id"`
+	Email    string   `json:"email"`
+	UserRole string   `json:"user_role"`
+	Roles    []string `json:"roles"`
+}
+
+// userRoles puts the primary role first and drops duplicates, tokens always carry the primary role
+func userRoles(role string, roles []string) []string {
+	result := []string{role}
+	for _, r := range roles {
+		if r != role && !contains(result, r) {
+			result = append(result, r)
+		}
+	}
+	return result
 }
 
 type AuthService struct {
-	Secret string
+	Keys  *keys.Manager
+	Store store.Store
+	// Passwords hashes new passwords that meet the Policy
+	Passwords *password.Hasher
+	Policy    password.Policy
+	TokenSettings
 }
 
-func NewAuthService(secret string) *AuthService {
+// TokenSettings describe the access and refresh tokens the service hands out
+type TokenSettings struct {
+	Issuer string
+	// Audiences are the services a user's access token may be presented to
+	Audiences  []string
+	AccessTTL  time.Duration
+	RefreshTTL time.Duration
+	// Clients are the secrets of the internal services allowed the client credentials grant
+	Clients    map[string]string
+	ServiceTTL time.Duration
+}
+
+// clockSkew is how far apart the clocks of the services may drift
+const clockSkew = 30 * time.Second
+
+func NewAuthService(keyManager *keys.Manager, tokenStore store.Store, hasher *password.Hasher, policy password.Policy, settings TokenSettings) *AuthService {
 	return &AuthService{
-		Secret: secret,
+		Keys:          keyManager,
+		Store:         tokenStore,
+		Passwords:     hasher,
+		Policy:        policy,
+		TokenSettings: settings,
+	}
+}
+
+// ErrHashingFailed is a password that could not be hashed for reasons other than the policy
+var ErrHashingFailed = errors.New("hashing failed")
+
+// CreateHashPassword hashes a new password, one that does not meet the policy is refused
+func (a *AuthService) CreateHashPassword(plainPassword string) (string, error) {
+	err := a.Policy.Check(plainPassword)
+	if err != nil {
+		return "", err
+	}
+
+	hashPassword, err := a.Passwords.Hash(plainPassword)
+	if err != nil {
+		log.Printf("Error while hashing password %v", err)
+		return "", ErrHashingFailed
 	}
+
+	return hashPassword, nil
 }
 
-func (a *AuthService) CreateHashPassword(password string) (string, error) {
-	if len(password) < 8 {
-		return "", errors.New("length of the password must be at least 8 characters")
+// RehashPassword upgrades the hash of a password already in use to the current algorithm and cost,
+// the password is checked against the old hash first and the policy is not applied again
+func (a *AuthService) RehashPassword(plainPassword string, hashedPassword string) (string, error) {
+	needsRehash, err := a.VerifyPassword(plainPassword, hashedPassword)
+	if err != nil {
+		return "", err
+	}
+	if !needsRehash {
+		return hashedPassword, nil
 	}
 
-	hashPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	hashPassword, err := a.Passwords.Hash(plainPassword)
 	if err != nil {
-		return "", errors.New("hashing failed")
+		log.Printf("Error while hashing password %v", err)
+		return "", ErrHashingFailed
 	}
 
-	return string(hashPassword), nil
+	return hashPassword, nil
 }
 
-func (a *AuthService) GenerateToken(id uint, email string, role string) (string, error) {
+// GenerateToken signs a user in, the access token is short lived and the refresh token starts a new family
+// that is listed as a session of the device. role is the user's primary role and roles are any they hold besides it
+func (a *AuthService) GenerateToken(id uint, email string, role string, roles []string, device Device) (TokenPair, error) {
 	if id == 0 || email == "" || role == "" {
-		return "", errors.New("invalid user information for token generation")
+		return TokenPair{}, errors.New("invalid user information for token generation")
+	}
+	roles = userRoles(role, roles)
+	for _, r := range roles {
+		if !isKnownRole(r) {
+			return TokenPair{}, fmt.Errorf("unknown role: %s", r)
+		}
+	}
+
+	familyID, err := randomToken()
+	if err != nil {
+		return TokenPair{}, errors.New("unable to get signed token")
+	}
+	err = a.startSession(familyID, id, device)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	return a.issueTokens(TokenUser{ID: id, Email: email, UserRole: role, Roles: roles}, familyID)
+}
+
+func (a *AuthService) signAccessToken(user TokenUser, sessionID string, now time.Time) (string, error) {
+	jti, err := randomToken()
+	if err != nil {
+		return "", errors.New("unable to get signed token")
+	}
+	key, err := a.Keys.SigningKey(now)
+	if err != nil {
+		log.Printf("Error while signing token %v", err)
+		return "", errors.New("unable to get signed token")
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": id,
-		"email":   email,
-		"role":    role,
-		"expiry":  time.Now().Add(time.Hour * 24 * 15).Unix(),
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    a.Issuer,
+			Subject:   strconv.FormatUint(uint64(user.ID), 10),
+			Audience:  a.Audiences,
+			ExpiresAt: jwt.NewNumericDate(now.Add(a.AccessTTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        jti,
+		},
+		Email:     user.Email,
+		Role:      user.UserRole,
+		Roles:     user.Roles,
+		SessionID: sessionID,
 	})
 
-	tokenString, err := token.SignedString([]byte(a.Secret))
+	// the key id tells verifiers which published key to check the signature with
+	token.Header["kid"] = key.KID
+
+	tokenString, err := token.SignedString(key.PrivateKey)
 	if err != nil {
 		return "", errors.New("unable to get signed token")
 	}
 	return tokenString, nil
 }
 
-func (a *AuthService) VerifyPassword(plainPassword string, hashedPassword string) error {
-	if len(plainPassword) < 8 {
-		return errors.New("password length should be at least 8 characters long")
+// VerifyPassword reports whether the hash should be upgraded once the password has matched
+func (a *AuthService) VerifyPassword(plainPassword string, hashedPassword string) (bool, error) {
+	if plainPassword == "" {
+		return false, password.ErrMismatch
 	}
 
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(plainPassword))
+	needsRehash, err := a.Passwords.Verify(plainPassword, hashedPassword)
+	if errors.Is(err, password.ErrUnknownFormat) {
+		log.Printf("Error while verifying password %v", err)
+		return false, password.ErrMismatch
+	}
 	if err != nil {
-		return errors.New("password does not match")
+		return false, err
 	}
 
-	return nil
+	return needsRehash, nil
+}
+
+// accessClaims are the claims of an access token that revocation works with
+type accessClaims struct {
+	jti       string
+	sessionID string
+	expiresAt time.Time
 }
 
 func (a *AuthService) VerifyToken(tokenString string) (TokenUser, error) {
+	user, _, err := a.verifyAccessToken(tokenString)
+	return user, err
+}
+
+func (a *AuthService) verifyAccessToken(tokenString string) (TokenUser, accessClaims, error) {
 	tokenArray := strings.Split(tokenString, " ")
 	if len(tokenArray) != 2 {
-		return TokenUser{}, errors.New("invalid token format")
+		return TokenUser{}, accessClaims{}, errors.New("invalid token format")
 	}
 
 	if tokenArray[0] != "Bearer" {
-		return TokenUser{}, errors.New("invalid token type")
+		return TokenUser{}, accessClaims{}, errors.New("invalid token type")
 	}
 
-	t, err := jwt.Parse(tokenArray[1], func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-		}
-		return []byte(a.Secret), nil
-	})
+	claims, err := auth.ParseClaims(tokenArray[1], a.keyFunc)
+	if err != nil {
+		return TokenUser{}, accessClaims{}, fmt.Errorf("token parsing error: %v", err)
+	}
 
+	// the auth service accepts its tokens whichever service they were meant for
+	err = claims.Validate(time.Now(), clockSkew, a.Issuer, "")
 	if err != nil {
-		return TokenUser{}, fmt.Errorf("token parsing error: %v", err)
+		return TokenUser{}, accessClaims{}, err
 	}
+	tokenUser, err := claims.User()
+	if err != nil {
+		return TokenUser{}, accessClaims{}, err
+	}
+	user := TokenUser{ID: tokenUser.ID, Email: tokenUser.Email, UserRole: tokenUser.UserRole, Roles: tokenUser.Roles}
 
-	if claims, ok := t.Claims.(jwt.MapClaims); ok && t.Valid {
-		expiry, ok := claims["expiry"].(float64)
-		if !ok {
-			return TokenUser{}, errors.New("invalid expiry claim")
-		}
-		if time.Now().Unix() > int64(expiry) {
-			return TokenUser{}, errors.New("token has expired")
+	err = a.checkRevoked(user.ID, claims.ID, claims.SessionID, claims.IssuedAt.Time)
+	if err != nil {
+		return TokenUser{}, accessClaims{}, err
+	}
+	return user, accessClaims{jti: claims.ID, sessionID: claims.SessionID, expiresAt: claims.ExpiresAt.Time}, nil
+}
+
+// keyFunc finds the published key a token was signed with
+func (a *AuthService) keyFunc(token *jwt.Token) (interface{}, error) {
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, errors.New("missing key id")
+	}
+	publicKey, algorithm, err := a.Keys.PublicKey(kid, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	if token.Method.Alg() != algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return publicKey, nil
+}
+
+func (a *AuthService) checkRevoked(userID uint, jti string, sessionID string, issuedAt time.Time) error {
+	revoked, err := a.Store.IsTokenRevoked(jti)
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return errors.New("token has been revoked")
+	}
+
+	// tokens from before sessions were tracked carry no session
+	if sessionID != "" {
+		session, err := a.Store.FindSession(sessionID)
+		if err != nil && !errors.Is(err, store.ErrSessionNotFound) {
+			return err
 		}
-		user := TokenUser{
-			ID:       uint(claims["user_id"].(float64)),
-			Email:    claims["email"].(string),
-			UserRole: claims["role"].(string),
+		if err == nil && session.RevokedAt != nil {
+			return errors.New("session has been revoked")
 		}
-		return user, nil
 	}
 
-	return TokenUser{}, errors.New("token verification failed")
+	revokedBefore, err := a.Store.UserTokensRevokedBefore(userID)
+	if err != nil {
+		return err
+	}
+	// issue times are truncated to the millisecond, a token from the millisecond of the revocation is revoked too
+	if !issuedAt.After(revokedBefore.Truncate(time.Millisecond)) {
+		log.Printf("Rejected token issued before the revocation of user %d tokens", userID)
+		return errors.New("token has been revoked")
+	}
+	return nil
 }
 
 func (a *AuthService) GenerateCode() (string, error) {
@@ -135,7 +317,11 @@ func (a *AuthService) AuthorizeByRole(user TokenUser, requiredRole string) error
 		return errors.New("user not authenticated")
 	}
 
-	if user.UserRole != requiredRole {
+	if !isKnownRole(requiredRole) {
+		return fmt.Errorf("unknown role: %s", requiredRole)
+	}
+
+	if !contains(user.Roles, requiredRole) {
 		return errors.New("insufficient permissions")
 	}
 
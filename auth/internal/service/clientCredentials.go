@@ -0,0 +1,118 @@
+package service
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/golang-jwt/jwt/v4"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
+	"log"
+	"strings"
+	"time"
+)
+
+var (
+	ErrInvalidClient = errors.New("invalid client")
+	ErrInvalidScope  = errors.New("invalid scope")
+)
+
+// clientScopes are the scopes each internal service may ask for
+var clientScopes = map[string][]string{
+	"users":        {auth.SCOPE_AUTH_PASSWORDS, auth.SCOPE_AUTH_TOKENS, auth.SCOPE_AUTH_INTROSPECT, auth.SCOPE_CATALOG_STOCK, auth.SCOPE_TRANSACTIONS_REFUNDS, auth.SCOPE_TRANSACTIONS_EVENTS},
+	"transactions": {auth.SCOPE_USERS_ORDERS, auth.SCOPE_AUTH_INTROSPECT},
+	"catalog":      {auth.SCOPE_AUTH_INTROSPECT},
+}
+
+// ServiceToken is the access token an internal service presents on internal routes
+type ServiceToken struct {
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+	ExpiresIn   int64  `json:"expires_in"`
+	Scope       string `json:"scope"`
+}
+
+// ClientCredentials issues a service token for the requested scopes, all the client's scopes when none are asked for
+func (a *AuthService) ClientCredentials(clientID string, clientSecret string, requested []string) (ServiceToken, error) {
+	secret, ok := a.Clients[clientID]
+	if !ok || subtle.ConstantTimeCompare([]byte(secret), []byte(clientSecret)) != 1 {
+		return ServiceToken{}, ErrInvalidClient
+	}
+
+	allowed := clientScopes[clientID]
+	scopes := allowed
+	if len(requested) > 0 {
+		for _, scope := range requested {
+			if !contains(allowed, scope) {
+				return ServiceToken{}, fmt.Errorf("%w: %s", ErrInvalidScope, scope)
+			}
+		}
+		scopes = requested
+	}
+	if len(scopes) == 0 {
+		return ServiceToken{}, ErrInvalidScope
+	}
+
+	now := time.Now()
+	jti, err := randomToken()
+	if err != nil {
+		return ServiceToken{}, errors.New("unable to get signed token")
+	}
+	key, err := a.Keys.SigningKey(now)
+	if err != nil {
+		log.Printf("Error while signing service token %v", err)
+		return ServiceToken{}, errors.New("unable to get signed token")
+	}
+	scope := strings.Join(scopes, " ")
+	token := jwt.NewWithClaims(jwt.GetSigningMethod(key.Algorithm), auth.Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    a.Issuer,
+			Subject:   auth.ServiceSubject(clientID),
+			Audience:  auth.ScopeAudiences(scopes),
+			ExpiresAt: jwt.NewNumericDate(now.Add(a.ServiceTTL)),
+			NotBefore: jwt.NewNumericDate(now),
+			IssuedAt:  jwt.NewNumericDate(now),
+			ID:        jti,
+		},
+		ClientID: clientID,
+		Scope:    scope,
+	})
+	token.Header["kid"] = key.KID
+
+	tokenString, err := token.SignedString(key.PrivateKey)
+	if err != nil {
+		return ServiceToken{}, errors.New("unable to get signed token")
+	}
+	return ServiceToken{
+		AccessToken: tokenString,
+		TokenType:   "Bearer",
+		ExpiresIn:   int64(a.ServiceTTL.Seconds()),
+		Scope:       scope,
+	}, nil
+}
+
+// VerifyServiceToken checks a service token presented to the auth service's internal routes
+func (a *AuthService) VerifyServiceToken(tokenString string) (auth.ServiceCaller, error) {
+	tokenArray := strings.Split(tokenString, " ")
+	if len(tokenArray) != 2 || tokenArray[0] != "Bearer" {
+		return auth.ServiceCaller{}, errors.New("invalid token format")
+	}
+
+	claims, err := auth.ParseClaims(tokenArray[1], a.keyFunc)
+	if err != nil {
+		return auth.ServiceCaller{}, fmt.Errorf("token parsing error: %v", err)
+	}
+	err = claims.Validate(time.Now(), clockSkew, a.Issuer, auth.AUDIENCE_AUTH)
+	if err != nil {
+		return auth.ServiceCaller{}, err
+	}
+	return claims.Service()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
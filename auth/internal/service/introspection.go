@@ -0,0 +1,129 @@
+package service
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/auth/internal/store"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
+	"strconv"
+	"time"
+)
+
+// Introspection describes a token the way RFC 7662 does, an inactive token only has Active set
+type Introspection struct {
+	Active    bool     `json:"active"`
+	Scope     string   `json:"scope,omitempty"`
+	ClientID  string   `json:"client_id,omitempty"`
+	Username  string   `json:"username,omitempty"`
+	TokenType string   `json:"token_type,omitempty"`
+	Exp       int64    `json:"exp,omitempty"`
+	Iat       int64    `json:"iat,omitempty"`
+	Nbf       int64    `json:"nbf,omitempty"`
+	Sub       string   `json:"sub,omitempty"`
+	Aud       []string `json:"aud,omitempty"`
+	Iss       string   `json:"iss,omitempty"`
+	Jti       string   `json:"jti,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	SessionID string   `json:"sid,omitempty"`
+}
+
+// Introspect tells internal services whether a token is still good, unlike local verification it sees revocations.
+// The hint only decides which kind of token is tried first
+func (a *AuthService) Introspect(token string, tokenTypeHint string) (Introspection, error) {
+	if token == "" {
+		return Introspection{}, nil
+	}
+	if tokenTypeHint == "refresh_token" {
+		result, err := a.introspectRefreshToken(token)
+		if err != nil || result.Active {
+			return result, err
+		}
+		return a.introspectAccessToken(token)
+	}
+	result, err := a.introspectAccessToken(token)
+	if err != nil || result.Active {
+		return result, err
+	}
+	return a.introspectRefreshToken(token)
+}
+
+func (a *AuthService) introspectAccessToken(token string) (Introspection, error) {
+	claims, err := auth.ParseClaims(token, a.keyFunc)
+	if err != nil {
+		return Introspection{}, nil
+	}
+	if claims.Validate(time.Now(), clockSkew, a.Issuer, "") != nil {
+		return Introspection{}, nil
+	}
+
+	result := Introspection{
+		Active:    true,
+		TokenType: "Bearer",
+		Exp:       claims.ExpiresAt.Unix(),
+		Iat:       claims.IssuedAt.Unix(),
+		Nbf:       claims.NotBefore.Unix(),
+		Sub:       claims.Subject,
+		Aud:       claims.Audience,
+		Iss:       claims.Issuer,
+		Jti:       claims.ID,
+	}
+
+	if claims.ClientID != "" {
+		if _, err := claims.Service(); err != nil {
+			return Introspection{}, nil
+		}
+		result.ClientID = claims.ClientID
+		result.Scope = claims.Scope
+		return result, nil
+	}
+
+	user, err := claims.User()
+	if err != nil {
+		return Introspection{}, nil
+	}
+	err = a.checkRevoked(user.ID, claims.ID, claims.SessionID, claims.IssuedAt.Time)
+	if err != nil {
+		return Introspection{}, nil
+	}
+	result.Username = user.Email
+	result.Roles = user.Roles
+	result.SessionID = claims.SessionID
+	return result, nil
+}
+
+func (a *AuthService) introspectRefreshToken(token string) (Introspection, error) {
+	t, err := a.Store.FindRefreshToken(hashToken(token))
+	if errors.Is(err, store.ErrTokenNotFound) {
+		return Introspection{}, nil
+	}
+	if err != nil {
+		return Introspection{}, err
+	}
+	if t.UsedAt != nil || time.Now().After(t.ExpiresAt) {
+		return Introspection{}, nil
+	}
+
+	revokedBefore, err := a.Store.UserTokensRevokedBefore(t.UserID)
+	if err != nil {
+		return Introspection{}, err
+	}
+	if t.CreatedAt.Before(revokedBefore) {
+		return Introspection{}, nil
+	}
+	session, err := a.Store.FindSession(t.FamilyID)
+	if err != nil && !errors.Is(err, store.ErrSessionNotFound) {
+		return Introspection{}, err
+	}
+	if err == nil && session.RevokedAt != nil {
+		return Introspection{}, nil
+	}
+
+	return Introspection{
+		Active:    true,
+		Username:  t.Email,
+		Exp:       t.ExpiresAt.Unix(),
+		Iat:       t.CreatedAt.Unix(),
+		Sub:       strconv.FormatUint(uint64(t.UserID), 10),
+		Iss:       a.Issuer,
+		SessionID: t.FamilyID,
+	}, nil
+}
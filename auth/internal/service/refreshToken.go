@@ -0,0 +1,155 @@
+package service
+
+import (
+	crand "crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/auth/internal/store"
+	"log"
+	"strings"
+	"time"
+)
+
+var errInvalidRefreshToken = errors.New("invalid refresh token")
+
+// TokenPair is handed out on sign in and on every refresh
+type TokenPair struct {
+	Token        string `json:"token"`
+	RefreshToken string `json:"refresh_token"`
+	// ExpiresIn is the lifetime of the access token in seconds
+	ExpiresIn int64 `json:"expires_in"`
+}
+
+func (a *AuthService) issueTokens(user TokenUser, familyID string) (TokenPair, error) {
+	now := time.Now()
+	accessToken, err := a.signAccessToken(user, familyID, now)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	refreshToken, err := randomToken()
+	if err != nil {
+		return TokenPair{}, errors.New("unable to create refresh token")
+	}
+	err = a.Store.SaveRefreshToken(store.RefreshToken{
+		Hash:      hashToken(refreshToken),
+		FamilyID:  familyID,
+		UserID:    user.ID,
+		Email:     user.Email,
+		Role:      user.UserRole,
+		Roles:     strings.Join(user.Roles, " "),
+		ExpiresAt: now.Add(a.RefreshTTL),
+	})
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return TokenPair{
+		Token:        accessToken,
+		RefreshToken: refreshToken,
+		ExpiresIn:    int64(a.AccessTTL.Seconds()),
+	}, nil
+}
+
+// RefreshToken swaps a refresh token for a new pair, each refresh token works once.
+// A refresh token presented twice has been copied, so its family and the user's tokens are revoked
+func (a *AuthService) RefreshToken(refreshToken string, device Device) (TokenPair, error) {
+	if refreshToken == "" {
+		return TokenPair{}, errInvalidRefreshToken
+	}
+
+	now := time.Now()
+	t, err := a.Store.UseRefreshToken(hashToken(refreshToken), now)
+	if errors.Is(err, store.ErrTokenNotFound) {
+		return TokenPair{}, errInvalidRefreshToken
+	}
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	if t.UsedAt != nil {
+		log.Printf("Refresh token reuse detected for user %d, revoking its sessions", t.UserID)
+		if err := a.Store.RevokeFamily(t.FamilyID); err != nil {
+			return TokenPair{}, err
+		}
+		// access tokens minted from the stolen token are still out there
+		if err := a.Store.RevokeUserTokens(t.UserID, now); err != nil {
+			return TokenPair{}, err
+		}
+		if err := a.Store.RevokeUserSessions(t.UserID, now); err != nil {
+			return TokenPair{}, err
+		}
+		return TokenPair{}, errInvalidRefreshToken
+	}
+	if now.After(t.ExpiresAt) {
+		return TokenPair{}, errors.New("refresh token has expired")
+	}
+
+	revokedBefore, err := a.Store.UserTokensRevokedBefore(t.UserID)
+	if err != nil {
+		return TokenPair{}, err
+	}
+	if t.CreatedAt.Before(revokedBefore) {
+		return TokenPair{}, errInvalidRefreshToken
+	}
+
+	err = a.renewSession(t.FamilyID, t.UserID, device, now)
+	if err != nil {
+		return TokenPair{}, err
+	}
+
+	return a.issueTokens(TokenUser{ID: t.UserID, Email: t.Email, UserRole: t.Role, Roles: userRoles(t.Role, strings.Fields(t.Roles))}, t.FamilyID)
+}
+
+// Logout revokes the access token and the refresh token family it was issued with,
+// allDevices also revokes every token the user was issued until now
+func (a *AuthService) Logout(accessToken string, refreshToken string, allDevices bool) error {
+	user, claims, err := a.verifyAccessToken(accessToken)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	err = a.Store.RevokeToken(claims.jti, claims.expiresAt)
+	if err != nil {
+		return err
+	}
+
+	if claims.sessionID != "" {
+		if err := a.endSession(claims.sessionID, now); err != nil {
+			return err
+		}
+	}
+	if refreshToken != "" {
+		t, err := a.Store.FindRefreshToken(hashToken(refreshToken))
+		if err == nil && t.UserID == user.ID {
+			if err := a.endSession(t.FamilyID, now); err != nil {
+				return err
+			}
+		}
+	}
+
+	if allDevices {
+		if err := a.Store.RevokeUserSessions(user.ID, now); err != nil {
+			return err
+		}
+		return a.Store.RevokeUserTokens(user.ID, now)
+	}
+	return nil
+}
+
+func randomToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := crand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// hashToken is what refresh tokens are stored under so a leaked store cannot be replayed
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
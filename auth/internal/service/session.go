@@ -0,0 +1,149 @@
+package service
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/auth/internal/store"
+	"time"
+)
+
+// Device is where a sign in or a token renewal came from
+type Device struct {
+	IP        string
+	UserAgent string
+}
+
+// SessionInfo is a session as shown to its user
+type SessionInfo struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	// Current marks the session the request was made from
+	Current bool `json:"current"`
+}
+
+func (a *AuthService) startSession(id string, userID uint, device Device) error {
+	now := time.Now()
+	return a.Store.SaveSession(store.Session{
+		ID:         id,
+		UserID:     userID,
+		UserAgent:  device.UserAgent,
+		IP:         device.IP,
+		CreatedAt:  now,
+		LastSeenAt: now,
+		ExpiresAt:  now.Add(a.RefreshTTL),
+	})
+}
+
+// renewSession keeps the session alive as long as its refresh tokens, families from before sessions were tracked get one now
+func (a *AuthService) renewSession(id string, userID uint, device Device, now time.Time) error {
+	session, err := a.Store.FindSession(id)
+	if errors.Is(err, store.ErrSessionNotFound) {
+		return a.startSession(id, userID, device)
+	}
+	if err != nil {
+		return err
+	}
+	if session.RevokedAt != nil {
+		if err := a.Store.RevokeFamily(id); err != nil {
+			return err
+		}
+		return errInvalidRefreshToken
+	}
+	return a.Store.TouchSession(id, device.IP, device.UserAgent, now, now.Add(a.RefreshTTL))
+}
+
+// endSession stops the session from renewing, the auth service also rejects its access tokens from now on
+func (a *AuthService) endSession(id string, now time.Time) error {
+	err := a.Store.RevokeFamily(id)
+	if err != nil {
+		return err
+	}
+	return a.Store.RevokeSession(id, now)
+}
+
+// RevokeUser signs a user out everywhere when their account is suspended, deleted or loses a role.
+// Their refresh tokens stop working at once and their access tokens once they expire, or at once
+// wherever they are checked by the auth service
+func (a *AuthService) RevokeUser(userID uint) error {
+	if userID == 0 {
+		return errors.New("invalid user")
+	}
+
+	now := time.Now()
+	err := a.Store.RevokeUserSessions(userID, now)
+	if err != nil {
+		return err
+	}
+	return a.Store.RevokeUserTokens(userID, now)
+}
+
+// Sessions lists where the owner of the access token is signed in
+func (a *AuthService) Sessions(accessToken string) ([]SessionInfo, error) {
+	user, claims, err := a.verifyAccessToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+
+	sessions, err := a.Store.ListSessions(user.ID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	result := make([]SessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		result = append(result, SessionInfo{
+			ID:         session.ID,
+			UserAgent:  session.UserAgent,
+			IP:         session.IP,
+			CreatedAt:  session.CreatedAt,
+			LastSeenAt: session.LastSeenAt,
+			ExpiresAt:  session.ExpiresAt,
+			Current:    session.ID == claims.sessionID,
+		})
+	}
+	return result, nil
+}
+
+// RevokeSession signs the owner of the access token out of one of their sessions
+func (a *AuthService) RevokeSession(accessToken string, id string) error {
+	user, _, err := a.verifyAccessToken(accessToken)
+	if err != nil {
+		return err
+	}
+
+	session, err := a.Store.FindSession(id)
+	if errors.Is(err, store.ErrSessionNotFound) || (err == nil && (session.UserID != user.ID || session.RevokedAt != nil)) {
+		return store.ErrSessionNotFound
+	}
+	if err != nil {
+		return err
+	}
+	return a.endSession(id, time.Now())
+}
+
+// RevokeOtherSessions signs the owner of the access token out everywhere but the session it belongs to
+func (a *AuthService) RevokeOtherSessions(accessToken string) (int, error) {
+	user, claims, err := a.verifyAccessToken(accessToken)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	sessions, err := a.Store.ListSessions(user.ID, now)
+	if err != nil {
+		return 0, err
+	}
+	revoked := 0
+	for _, session := range sessions {
+		if session.ID == claims.sessionID {
+			continue
+		}
+		if err := a.endSession(session.ID, now); err != nil {
+			return revoked, err
+		}
+		revoked++
+	}
+	return revoked, nil
+}
@@ -0,0 +1,240 @@
+package store
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryStore keeps tokens in process, it is meant for local runs of a single instance
+type memoryStore struct {
+	mu          sync.Mutex
+	refresh     map[string]RefreshToken
+	revoked     map[string]time.Time
+	revocations map[uint]time.Time
+	keys        map[string]SigningKeyRecord
+	sessions    map[string]Session
+}
+
+func (s *memoryStore) SaveRefreshToken(t RefreshToken) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.prune(time.Now())
+	t.CreatedAt = time.Now()
+	s.refresh[t.Hash] = t
+	return nil
+}
+
+func (s *memoryStore) UseRefreshToken(hash string, at time.Time) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.refresh[hash]
+	if !ok {
+		return RefreshToken{}, ErrTokenNotFound
+	}
+	if t.UsedAt == nil {
+		used := t
+		used.UsedAt = &at
+		s.refresh[hash] = used
+	}
+	return t, nil
+}
+
+func (s *memoryStore) FindRefreshToken(hash string) (RefreshToken, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	t, ok := s.refresh[hash]
+	if !ok {
+		return RefreshToken{}, ErrTokenNotFound
+	}
+	return t, nil
+}
+
+func (s *memoryStore) RevokeFamily(familyID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for hash, t := range s.refresh {
+		if t.FamilyID == familyID {
+			delete(s.refresh, hash)
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) RevokeToken(jti string, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.revoked[jti] = expiresAt
+	return nil
+}
+
+func (s *memoryStore) IsTokenRevoked(jti string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	_, ok := s.revoked[jti]
+	return ok, nil
+}
+
+func (s *memoryStore) RevokeUserTokens(userID uint, before time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if before.After(s.revocations[userID]) {
+		s.revocations[userID] = before
+	}
+	return nil
+}
+
+func (s *memoryStore) UserTokensRevokedBefore(userID uint) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.revocations[userID], nil
+}
+
+func (s *memoryStore) SaveSigningKey(k SigningKeyRecord) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k.CreatedAt = time.Now()
+	s.keys[k.KID] = k
+	return nil
+}
+
+func (s *memoryStore) ListSigningKeys() ([]SigningKeyRecord, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]SigningKeyRecord, 0, len(s.keys))
+	for _, k := range s.keys {
+		keys = append(keys, k)
+	}
+	return keys, nil
+}
+
+func (s *memoryStore) DeleteExpiredSigningKeys(now time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for kid, k := range s.keys {
+		if now.After(k.ExpiresAt) {
+			delete(s.keys, kid)
+		}
+	}
+	return nil
+}
+
+func (s *memoryStore) SaveSession(session Session) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sessions[session.ID] = session
+	return nil
+}
+
+func (s *memoryStore) TouchSession(id string, ip string, userAgent string, at time.Time, expiresAt time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+	if ip != "" {
+		session.IP = ip
+	}
+	if userAgent != "" {
+		session.UserAgent = userAgent
+	}
+	session.LastSeenAt = at
+	session.ExpiresAt = expiresAt
+	s.sessions[id] = session
+	return nil
+}
+
+func (s *memoryStore) FindSession(id string) (Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if !ok {
+		return Session{}, ErrSessionNotFound
+	}
+	return session, nil
+}
+
+func (s *memoryStore) ListSessions(userID uint, now time.Time) ([]Session, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sessions := []Session{}
+	for _, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil && now.Before(session.ExpiresAt) {
+			sessions = append(sessions, session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+func (s *memoryStore) RevokeSession(id string, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	session, ok := s.sessions[id]
+	if ok && session.RevokedAt == nil {
+		session.RevokedAt = &at
+		s.sessions[id] = session
+	}
+	return nil
+}
+
+func (s *memoryStore) RevokeUserSessions(userID uint, at time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for id, session := range s.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &at
+			s.sessions[id] = session
+		}
+	}
+	return nil
+}
+
+// prune drops entries that can no longer be presented, the caller holds the lock
+func (s *memoryStore) prune(now time.Time) {
+	for hash, t := range s.refresh {
+		if now.After(t.ExpiresAt) {
+			delete(s.refresh, hash)
+		}
+	}
+	for jti, expiresAt := range s.revoked {
+		if now.After(expiresAt) {
+			delete(s.revoked, jti)
+		}
+	}
+	for id, session := range s.sessions {
+		if now.After(session.ExpiresAt) {
+			delete(s.sessions, id)
+		}
+	}
+}
+
+func NewMemoryStore() Store {
+	return &memoryStore{
+		refresh:     map[string]RefreshToken{},
+		revoked:     map[string]time.Time{},
+		revocations: map[uint]time.Time{},
+		keys:        map[string]SigningKeyRecord{},
+		sessions:    map[string]Session{},
+	}
+}
@@ -0,0 +1,222 @@
+package store
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+// postgresStore shares token state and signing keys between every instance of the auth service
+type postgresStore struct {
+	db *gorm.DB
+}
+
+func (s *postgresStore) SaveRefreshToken(t RefreshToken) error {
+	err := s.db.Create(&t).Error
+	if err != nil {
+		log.Printf("Error while saving refresh token %v", err)
+		return errors.New("could not save refresh token")
+	}
+	// expired tokens can no longer be presented so there is nothing left to detect
+	s.db.Where("expires_at < ?", time.Now()).Delete(&RefreshToken{})
+	return nil
+}
+
+func (s *postgresStore) UseRefreshToken(hash string, at time.Time) (RefreshToken, error) {
+	var t RefreshToken
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("hash = ?", hash).First(&t).Error
+		if err != nil {
+			return err
+		}
+		if t.UsedAt != nil {
+			return nil
+		}
+		return tx.Model(&RefreshToken{}).Where("hash = ?", hash).Update("used_at", at).Error
+	})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return RefreshToken{}, ErrTokenNotFound
+	}
+	if err != nil {
+		log.Printf("Error while using refresh token %v", err)
+		return RefreshToken{}, errors.New("could not use refresh token")
+	}
+	return t, nil
+}
+
+func (s *postgresStore) FindRefreshToken(hash string) (RefreshToken, error) {
+	var t RefreshToken
+	err := s.db.Where("hash = ?", hash).First(&t).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return RefreshToken{}, ErrTokenNotFound
+	}
+	if err != nil {
+		log.Printf("Error while finding refresh token %v", err)
+		return RefreshToken{}, errors.New("could not find refresh token")
+	}
+	return t, nil
+}
+
+func (s *postgresStore) RevokeFamily(familyID string) error {
+	err := s.db.Where("family_id = ?", familyID).Delete(&RefreshToken{}).Error
+	if err != nil {
+		log.Printf("Error while revoking refresh tokens %v", err)
+		return errors.New("could not revoke refresh tokens")
+	}
+	return nil
+}
+
+func (s *postgresStore) RevokeToken(jti string, expiresAt time.Time) error {
+	err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&RevokedToken{JTI: jti, ExpiresAt: expiresAt}).Error
+	if err != nil {
+		log.Printf("Error while revoking token %v", err)
+		return errors.New("could not revoke token")
+	}
+	s.db.Where("expires_at < ?", time.Now()).Delete(&RevokedToken{})
+	return nil
+}
+
+func (s *postgresStore) IsTokenRevoked(jti string) (bool, error) {
+	var count int64
+	err := s.db.Model(&RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	if err != nil {
+		log.Printf("Error while checking revoked token %v", err)
+		return false, errors.New("could not check token revocation")
+	}
+	return count > 0, nil
+}
+
+func (s *postgresStore) RevokeUserTokens(userID uint, before time.Time) error {
+	err := s.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"revoked_before": gorm.Expr("GREATEST(user_revocations.revoked_before, excluded.revoked_before)")}),
+	}).Create(&UserRevocation{UserID: userID, RevokedBefore: before}).Error
+	if err != nil {
+		log.Printf("Error while revoking user tokens %v", err)
+		return errors.New("could not revoke user tokens")
+	}
+	return nil
+}
+
+func (s *postgresStore) UserTokensRevokedBefore(userID uint) (time.Time, error) {
+	var revocation UserRevocation
+	err := s.db.Where("user_id = ?", userID).Limit(1).Find(&revocation).Error
+	if err != nil {
+		log.Printf("Error while finding user revocation %v", err)
+		return time.Time{}, errors.New("could not check token revocation")
+	}
+	return revocation.RevokedBefore, nil
+}
+
+func (s *postgresStore) SaveSigningKey(k SigningKeyRecord) error {
+	err := s.db.Create(&k).Error
+	if err != nil {
+		log.Printf("Error while saving signing key %v", err)
+		return errors.New("could not save signing key")
+	}
+	return nil
+}
+
+func (s *postgresStore) ListSigningKeys() ([]SigningKeyRecord, error) {
+	var keys []SigningKeyRecord
+	err := s.db.Order("not_before").Find(&keys).Error
+	if err != nil {
+		log.Printf("Error while listing signing keys %v", err)
+		return nil, errors.New("could not load signing keys")
+	}
+	return keys, nil
+}
+
+func (s *postgresStore) DeleteExpiredSigningKeys(now time.Time) error {
+	err := s.db.Where("expires_at < ?", now).Delete(&SigningKeyRecord{}).Error
+	if err != nil {
+		log.Printf("Error while deleting expired signing keys %v", err)
+		return errors.New("could not delete expired signing keys")
+	}
+	return nil
+}
+
+func (s *postgresStore) SaveSession(session Session) error {
+	err := s.db.Create(&session).Error
+	if err != nil {
+		log.Printf("Error while saving session %v", err)
+		return errors.New("could not save session")
+	}
+	// sessions past their last refresh token are gone for good
+	s.db.Where("expires_at < ?", time.Now()).Delete(&Session{})
+	return nil
+}
+
+func (s *postgresStore) TouchSession(id string, ip string, userAgent string, at time.Time, expiresAt time.Time) error {
+	updates := map[string]interface{}{
+		"last_seen_at": at,
+		"expires_at":   expiresAt,
+	}
+	if ip != "" {
+		updates["ip"] = ip
+	}
+	if userAgent != "" {
+		updates["user_agent"] = userAgent
+	}
+	result := s.db.Model(&Session{}).Where("id = ?", id).Updates(updates)
+	if result.Error != nil {
+		log.Printf("Error while updating session %v", result.Error)
+		return errors.New("could not update session")
+	}
+	if result.RowsAffected == 0 {
+		return ErrSessionNotFound
+	}
+	return nil
+}
+
+func (s *postgresStore) FindSession(id string) (Session, error) {
+	var session Session
+	err := s.db.Where("id = ?", id).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return Session{}, ErrSessionNotFound
+	}
+	if err != nil {
+		log.Printf("Error while finding session %v", err)
+		return Session{}, errors.New("could not find session")
+	}
+	return session, nil
+}
+
+func (s *postgresStore) ListSessions(userID uint, now time.Time) ([]Session, error) {
+	sessions := []Session{}
+	err := s.db.Where("user_id = ? AND revoked_at IS NULL AND expires_at > ?", userID, now).Order("last_seen_at desc").Find(&sessions).Error
+	if err != nil {
+		log.Printf("Error while listing sessions %v", err)
+		return nil, errors.New("could not list sessions")
+	}
+	return sessions, nil
+}
+
+func (s *postgresStore) RevokeSession(id string, at time.Time) error {
+	err := s.db.Model(&Session{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", at).Error
+	if err != nil {
+		log.Printf("Error while revoking session %v", err)
+		return errors.New("could not revoke session")
+	}
+	return nil
+}
+
+func (s *postgresStore) RevokeUserSessions(userID uint, at time.Time) error {
+	err := s.db.Model(&Session{}).Where("user_id = ? AND revoked_at IS NULL", userID).Update("revoked_at", at).Error
+	if err != nil {
+		log.Printf("Error while revoking sessions %v", err)
+		return errors.New("could not revoke sessions")
+	}
+	return nil
+}
+
+// NewPostgresStore migrates the token and key tables and returns a store backed by them
+func NewPostgresStore(db *gorm.DB) (Store, error) {
+	err := db.AutoMigrate(&RefreshToken{}, &RevokedToken{}, &UserRevocation{}, &SigningKeyRecord{}, &Session{})
+	if err != nil {
+		return nil, err
+	}
+	return &postgresStore{db}, nil
+}
@@ -0,0 +1,101 @@
+package store
+
+import (
+	"errors"
+	"time"
+)
+
+var (
+	ErrTokenNotFound   = errors.New("token not found")
+	ErrSessionNotFound = errors.New("session not found")
+)
+
+// RefreshToken is a single use refresh token, tokens rotated from the same sign in share a family
+type RefreshToken struct {
+	Hash     string `gorm:"primaryKey"`
+	FamilyID string `gorm:"index;not null"`
+	UserID   uint   `gorm:"index;not null"`
+	Email    string
+	Role     string
+	// Roles are space separated, the renewed access token carries the same roles
+	Roles     string
+	ExpiresAt time.Time
+	UsedAt    *time.Time
+	CreatedAt time.Time
+}
+
+// RevokedToken keeps a revoked access token id until the token would have expired anyway
+type RevokedToken struct {
+	JTI       string `gorm:"primaryKey"`
+	ExpiresAt time.Time
+}
+
+// UserRevocation rejects every token issued to the user before RevokedBefore
+type UserRevocation struct {
+	UserID        uint `gorm:"primaryKey"`
+	RevokedBefore time.Time
+}
+
+// TokenStore holds the state that lets otherwise stateless tokens be refreshed and revoked
+type TokenStore interface {
+	SaveRefreshToken(t RefreshToken) error
+	// UseRefreshToken marks the token used and returns it as it was, a UsedAt already set means it was replayed
+	UseRefreshToken(hash string, at time.Time) (RefreshToken, error)
+	FindRefreshToken(hash string) (RefreshToken, error)
+	RevokeFamily(familyID string) error
+
+	RevokeToken(jti string, expiresAt time.Time) error
+	IsTokenRevoked(jti string) (bool, error)
+	RevokeUserTokens(userID uint, before time.Time) error
+	UserTokensRevokedBefore(userID uint) (time.Time, error)
+}
+
+// Session is a sign in on one device, its id is the family id of the refresh tokens it renews with
+type Session struct {
+	ID        string `gorm:"primaryKey"`
+	UserID    uint   `gorm:"index;not null"`
+	UserAgent string
+	IP        string
+	CreatedAt time.Time
+	// LastSeenAt is when the session last signed in or renewed its access token
+	LastSeenAt time.Time
+	ExpiresAt  time.Time
+	RevokedAt  *time.Time
+}
+
+// SessionStore keeps track of where a user is signed in so they can sign out of other devices
+type SessionStore interface {
+	SaveSession(s Session) error
+	// TouchSession records a renewal, the ip and user agent are kept when the new ones are empty
+	TouchSession(id string, ip string, userAgent string, at time.Time, expiresAt time.Time) error
+	FindSession(id string) (Session, error)
+	// ListSessions are the sessions of the user that are neither revoked nor expired, most recently seen first
+	ListSessions(userID uint, now time.Time) ([]Session, error)
+	RevokeSession(id string, at time.Time) error
+	RevokeUserSessions(userID uint, at time.Time) error
+}
+
+// SigningKeyRecord is a token signing key as stored, the private key is encrypted
+type SigningKeyRecord struct {
+	KID        string `gorm:"primaryKey"`
+	Algorithm  string `gorm:"not null"`
+	PrivateKey []byte `gorm:"not null"`
+	NotBefore  time.Time
+	RetiresAt  time.Time
+	ExpiresAt  time.Time `gorm:"index"`
+	CreatedAt  time.Time
+}
+
+// KeyStore shares signing keys between instances so any of them can verify what another signed
+type KeyStore interface {
+	SaveSigningKey(k SigningKeyRecord) error
+	ListSigningKeys() ([]SigningKeyRecord, error)
+	DeleteExpiredSigningKeys(now time.Time) error
+}
+
+// Store is everything the auth service persists
+type Store interface {
+	TokenStore
+	KeyStore
+	SessionStore
+}
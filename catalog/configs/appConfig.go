@@ -10,6 +10,10 @@ type AppConfig struct {
 	Port           string
 	DataSourceName string
 	AppSecret      string
+	AuthURL        string
+	// ServiceClientID and ServiceClientSecret get the service token user tokens are introspected with
+	ServiceClientID     string
+	ServiceClientSecret string
 }
 
 func EnvSetup() (cfg AppConfig, err error) {
@@ -34,5 +38,20 @@ func EnvSetup() (cfg AppConfig, err error) {
 		return AppConfig{}, errors.New("app secret variable not found")
 	}
 
-	return AppConfig{Port: httpPort, DataSourceName: dsn, AppSecret: appSecret}, nil
+	authURL := os.Getenv("AUTH_URL")
+	if len(authURL) < 1 {
+		return AppConfig{}, errors.New("auth url variable not found")
+	}
+
+	serviceClientID := os.Getenv("SERVICE_CLIENT_ID")
+	if len(serviceClientID) < 1 {
+		serviceClientID = "catalog"
+	}
+	serviceClientSecret := os.Getenv("SERVICE_CLIENT_SECRET")
+	if len(serviceClientSecret) < 1 {
+		return AppConfig{}, errors.New("service client secret variable not found")
+	}
+
+	return AppConfig{Port: httpPort, DataSourceName: dsn, AppSecret: appSecret, AuthURL: authURL,
+		ServiceClientID: serviceClientID, ServiceClientSecret: serviceClientSecret}, nil
 }
@@ -7,6 +7,7 @@ import (
 	"github.com/sharat789/zamazon-be-ms/catalog/internal/dto"
 	"github.com/sharat789/zamazon-be-ms/catalog/internal/repository"
 	"github.com/sharat789/zamazon-be-ms/catalog/internal/service"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 	"strconv"
 )
 
@@ -33,17 +34,24 @@ func SetupCatalogRoutes(rh *rest.RestHandler) {
 
 	//private endpoints
 	sellerRoutes := app.Group("/seller")
-	sellerRoutes.Post("/categories", handler.CreateCategories)
-	sellerRoutes.Patch("/categories/:id", handler.EditCategory)
-	sellerRoutes.Delete("/categories/:id", handler.DeleteCategory)
+	categoryWrite := rh.Auth.RequirePermission(auth.PERM_CATALOG_CATEGORY_WRITE)
+	sellerRoutes.Post("/categories", categoryWrite, handler.CreateCategories)
+	sellerRoutes.Patch("/categories/:id", categoryWrite, handler.EditCategory)
+	sellerRoutes.Delete("/categories/:id", categoryWrite, handler.DeleteCategory)
 
+	productWrite := rh.Auth.RequirePermission(auth.PERM_CATALOG_PRODUCT_WRITE)
 	sellerRoutes.Get("/products", handler.GetProducts)
 	sellerRoutes.Get("/products/:id", handler.GetProductByID)
 	//sellerRoutes.Post("/products", handler.CreateProducts) //refactor to use user microservice
 	//sellerRoutes.Put("/products/:id", handler.EditProduct) //refactor to use user microservice
-	sellerRoutes.Patch("/products/:id", handler.UpdateStock)
-	sellerRoutes.Delete("/products/:id", handler.DeleteProduct)
-
+	sellerRoutes.Patch("/products/:id", productWrite, handler.UpdateStock)
+	sellerRoutes.Patch("/products/:id/tax-category", productWrite, handler.UpdateTaxCategory)
+	sellerRoutes.Patch("/products/:id/weight", productWrite, handler.UpdateWeight)
+	sellerRoutes.Delete("/products/:id", productWrite, handler.DeleteProduct)
+
+	//internal endpoints, the user service reserves and releases stock as orders are placed and returned
+	internalRoutes := app.Group("/internal")
+	internalRoutes.Post("/products/:id/stock", rh.Auth.RequireScope(auth.SCOPE_CATALOG_STOCK), handler.AdjustStock)
 }
 
 func (h CatalogHandler) GetCategories(ctx *fiber.Ctx) error {
@@ -176,6 +184,57 @@ func (h CatalogHandler) UpdateStock(ctx *fiber.Ctx) error {
 	return rest.SuccessResponse(ctx, "update stock", updatedProduct)
 }
 
+func (h CatalogHandler) AdjustStock(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.AdjustStockRequest{}
+	err := ctx.BodyParser(&req)
+
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, "adjust stock request is invalid")
+	}
+
+	updatedProduct, err := h.catalogService.AdjustProductStock(uint(id), req.Delta)
+
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "adjust stock", updatedProduct)
+}
+
+func (h CatalogHandler) UpdateTaxCategory(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.UpdateTaxCategoryRequest{}
+	err := ctx.BodyParser(&req)
+
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, "update tax category request is invalid")
+	}
+
+	updatedProduct, err := h.catalogService.UpdateProductTaxCategory(uint(id), req.TaxCategory)
+
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "update tax category", updatedProduct)
+}
+
+func (h CatalogHandler) UpdateWeight(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.UpdateWeightRequest{}
+	err := ctx.BodyParser(&req)
+
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, "update weight request is invalid")
+	}
+
+	updatedProduct, err := h.catalogService.UpdateProductWeight(uint(id), req.Weight)
+
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "update weight", updatedProduct)
+}
+
 func (h CatalogHandler) GetProductByID(ctx *fiber.Ctx) error {
 
 	id, _ := strconv.Atoi(ctx.Params("id"))
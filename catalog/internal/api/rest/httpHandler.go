@@ -3,6 +3,7 @@ package rest
 import (
 	"github.com/gofiber/fiber/v2"
 	"github.com/sharat789/zamazon-be-ms/catalog/configs"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 	"gorm.io/gorm"
 )
 
@@ -10,4 +11,6 @@ type RestHandler struct {
 	App    *fiber.App
 	DB     *gorm.DB
 	Config configs.AppConfig
+	// Auth verifies access tokens locally against the keys the auth service publishes
+	Auth *auth.Verifier
 }
@@ -9,6 +9,7 @@ import (
 	"github.com/sharat789/zamazon-be-ms/catalog/internal/api/rest"
 	"github.com/sharat789/zamazon-be-ms/catalog/internal/api/rest/handlers"
 	"github.com/sharat789/zamazon-be-ms/catalog/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 	"github.com/sharat789/zamazon-be-ms/metrics"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -43,11 +44,24 @@ func StartServer(cfg configs.AppConfig) {
 	})
 
 	app.Use(c)
-	//auth := helper.Auth{}
+	// sellers manage their listings on these routes, so tokens of revoked sessions and suspended
+	// users are turned away without waiting for them to expire
+	introspector := auth.NewIntrospector(cfg.AuthURL+"/auth/introspect",
+		auth.NewServiceTokenSource(cfg.AuthURL+"/auth/token", cfg.ServiceClientID, cfg.ServiceClientSecret, auth.SCOPE_AUTH_INTROSPECT))
+	verifier, err := auth.NewVerifier(auth.Config{
+		JWKSURL:      cfg.AuthURL + "/.well-known/jwks.json",
+		Issuer:       auth.ISSUER,
+		Audience:     auth.AUDIENCE_CATALOG,
+		Introspector: introspector,
+	})
+	if err != nil {
+		log.Fatalf("error setting up token verification %v", err)
+	}
 	rh := &rest.RestHandler{
-		app,
-		db,
-		cfg,
+		App:    app,
+		DB:     db,
+		Config: cfg,
+		Auth:   verifier,
 	}
 
 	SetupRoutes(rh)
@@ -10,7 +10,9 @@ type Product struct {
 	ImageURL    string  `json:"image_url"`
 	Price       float64 `json:"price"`
 	//UserID      uint      `json:"user_id"`  Will be refactored when User microservice would be used
-	Stock     uint      `json:"stock"`
-	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+	Stock       uint      `json:"stock"`
+	TaxCategory string    `json:"tax_category" gorm:"default:standard"`
+	Weight      float64   `json:"weight"`
+	CreatedAt   time.Time `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt   time.Time `json:"updated_at" gorm:"default:current_timestamp"`
 }
@@ -7,8 +7,22 @@ type CreateProductRequest struct {
 	CategoryID  uint    `json:"category_id"`
 	ImageURL    string  `json:"image_url"`
 	Stock       int     `json:"stock"`
+	TaxCategory string  `json:"tax_category"`
+	Weight      float64 `json:"weight"`
 }
 
 type UpdateStockRequest struct {
 	Stock int `json:"stock"`
 }
+
+type UpdateTaxCategoryRequest struct {
+	TaxCategory string `json:"tax_category"`
+}
+
+type UpdateWeightRequest struct {
+	Weight float64 `json:"weight"`
+}
+
+type AdjustStockRequest struct {
+	Delta int `json:"delta"`
+}
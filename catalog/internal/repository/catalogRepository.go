@@ -20,6 +20,7 @@ type CatalogRepository interface {
 	FindSellerProducts(id uint) ([]*domain.Product, error)
 	EditProduct(e *domain.Product) (*domain.Product, error)
 	DeleteProduct(e *domain.Product) error
+	AdjustProductStock(id uint, delta int) (*domain.Product, error)
 }
 
 type catalogRepository struct {
@@ -92,6 +93,22 @@ func (c catalogRepository) DeleteProduct(e *domain.Product) error {
 	return nil
 }
 
+// AdjustProductStock applies delta atomically and refuses to take the stock below zero
+func (c catalogRepository) AdjustProductStock(id uint, delta int) (*domain.Product, error) {
+	result := c.db.Model(&domain.Product{}).
+		Where("id = ? AND stock + ? >= 0", id, delta).
+		Update("stock", gorm.Expr("stock + ?", delta))
+
+	if result.Error != nil {
+		log.Printf("db error: %v", result.Error)
+		return nil, errors.New("fail to adjust stock")
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New("insufficient stock")
+	}
+	return c.FindProductByID(id)
+}
+
 func (c catalogRepository) CreateCategory(e *domain.Category) error {
 	err := c.db.Create(&e).Error
 
@@ -191,3 +191,54 @@ func (s CatalogService) UpdateProductStock(e domain.Product) (*domain.Product, e
 	}
 	return editProduct, nil
 }
+
+func (s CatalogService) UpdateProductTaxCategory(id uint, taxCategory string) (*domain.Product, error) {
+	switch taxCategory {
+	case "standard", "reduced", "super_reduced", "zero":
+	default:
+		return nil, errors.New("tax category must be standard, reduced, super_reduced or zero")
+	}
+
+	product, err := s.Repo.FindProductByID(id)
+
+	if err != nil {
+		return nil, errors.New("product does not exist")
+	}
+
+	product.TaxCategory = taxCategory
+	editProduct, err := s.Repo.EditProduct(product)
+	if err != nil {
+		return nil, errors.New("could not update tax category")
+	}
+	return editProduct, nil
+}
+
+// UpdateProductWeight sets the shipping weight of a product in kilograms
+func (s CatalogService) UpdateProductWeight(id uint, weight float64) (*domain.Product, error) {
+	if weight < 0 {
+		return nil, errors.New("weight cannot be negative")
+	}
+
+	product, err := s.Repo.FindProductByID(id)
+
+	if err != nil {
+		return nil, errors.New("product does not exist")
+	}
+
+	product.Weight = weight
+	editProduct, err := s.Repo.EditProduct(product)
+	if err != nil {
+		return nil, errors.New("could not update weight")
+	}
+	return editProduct, nil
+}
+
+func (s CatalogService) AdjustProductStock(id uint, delta int) (*domain.Product, error) {
+	_, err := s.Repo.FindProductByID(id)
+
+	if err != nil {
+		return nil, errors.New("product does not exist")
+	}
+
+	return s.Repo.AdjustProductStock(id, delta)
+}
@@ -1,11 +1,20 @@
+// Package auth verifies access tokens issued by the auth service. Tokens are checked against the
+// published key set, which is cached and refreshed in the background, or against a shared secret
+// during local development. Local verification cannot see revocations, services that must not
+// serve revoked or suspended users configure an Introspector and a revocation then takes effect
+// once the cached result runs out instead of when the access token expires.
+//
+// Services calling each other's internal routes authenticate with service tokens, which the auth
+// service issues to registered clients with the client credentials grant and scopes to the routes
+// they may call.
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
 	"fmt"
-	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v4"
-	"golang.org/x/crypto/bcrypt"
 	"strings"
 	"time"
 )
@@ -14,153 +23,184 @@ import (
 const (
 	ROLE_SELLER = "seller"
 	ROLE_BUYER  = "buyer"
+	ROLE_ADMIN  = "admin"
+)
+
+func isKnownRole(role string) bool {
+	switch role {
+	case ROLE_SELLER, ROLE_BUYER, ROLE_ADMIN:
+		return true
+	}
+	return false
+}
+
+// ISSUER is the iss claim of tokens minted by the auth service
+const ISSUER = "zamazon-auth"
+
+// Audiences name the services a token may be presented to
+const (
+	AUDIENCE_AUTH         = "auth"
+	AUDIENCE_USERS        = "users"
+	AUDIENCE_TRANSACTIONS = "transactions"
+	AUDIENCE_CATALOG      = "catalog"
+)
+
+// Scopes of service tokens, the part before the colon is the service the scope is granted on
+const (
+	SCOPE_AUTH_PASSWORDS  = "auth:passwords"
+	SCOPE_AUTH_TOKENS     = "auth:tokens"
+	SCOPE_AUTH_INTROSPECT = "auth:introspect"
+	SCOPE_USERS_ORDERS    = "users:orders"
+	SCOPE_CATALOG_STOCK   = "catalog:stock"
+	// SCOPE_TRANSACTIONS_REFUNDS refunds payments, only once the users service has cancelled the order or received the return
+	SCOPE_TRANSACTIONS_REFUNDS = "transactions:refunds"
+	SCOPE_TRANSACTIONS_EVENTS  = "transactions:events"
+)
+
+var (
+	ErrMissingToken = errors.New("missing authorization token")
+	ErrInvalidToken = errors.New("invalid or expired token")
 )
 
 // TokenUser represents minimal user information needed for authentication
 type TokenUser struct {
-	ID       uint   `json:"id"`
-	Email    string `json:"email"`
-	UserRole string `json:"user_role"`
+	ID    uint   `json:"id"`
+	Email string `json:"email"`
+	// UserRole is the primary role, Roles holds it along with every role granted on top
+	UserRole    string   `json:"user_role"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
 }
 
-type Auth struct {
+type Config struct {
+	// JWKSURL is where the auth service publishes its public keys
+	JWKSURL string
+	// Secret verifies HS256 tokens when no key set is configured
 	Secret string
+	// Issuer is the iss claim tokens must carry, any issuer is accepted when empty
+	Issuer string
+	// Audience is the service the verifier runs in, tokens must list it in their aud claim
+	Audience string
+	// CacheTTL is how long a verified token is remembered, never past its expiry
+	CacheTTL time.Duration
+	// NegativeCacheTTL is how long a rejected token is remembered
+	NegativeCacheTTL time.Duration
+	// KeyRefresh is how often the key set is fetched again
+	KeyRefresh time.Duration
+	// Leeway allows for clock skew between services
+	Leeway time.Duration
+	// Introspector checks user tokens with the auth service after they verify locally, the answer is
+	// cached like the rest of the result and the token is rejected when the auth service cannot be asked
+	Introspector *Introspector
 }
 
-func SetupAuth(secret string) Auth {
-	return Auth{
-		secret,
-	}
+type Verifier struct {
+	cfg   Config
+	keys  *keySet
+	cache *tokenCache
 }
 
-func (a Auth) CreateHashPassword(p string) (string, error) {
-	if len(p) < 8 {
-		return "", errors.New("length of the password must be at least 8 characters")
+// NewVerifier needs either a key set url or a secret, defaults are filled in for everything else
+func NewVerifier(cfg Config) (*Verifier, error) {
+	if cfg.JWKSURL == "" && cfg.Secret == "" {
+		return nil, errors.New("a key set url or a secret is required to verify tokens")
 	}
-
-	hashPassword, err := bcrypt.GenerateFromPassword([]byte(p), bcrypt.DefaultCost)
-	if err != nil {
-		return "", errors.New("hashing failed")
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = time.Minute
 	}
-
-	return string(hashPassword), nil
-}
-
-func (a Auth) GenerateToken(id uint, email string, role string) (string, error) {
-	if id == 0 || email == "" || role == "" {
-		return "", errors.New("invalid user information for token generation")
+	if cfg.NegativeCacheTTL == 0 {
+		cfg.NegativeCacheTTL = 10 * time.Second
 	}
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": id,
-		"email":   email,
-		"role":    role,
-		"expiry":  time.Now().Add(time.Hour * 24 * 15).Unix(),
-	})
-
-	tokenString, err := token.SignedString([]byte(a.Secret))
-	if err != nil {
-		return "", errors.New("unable to get signed token")
+	if cfg.KeyRefresh == 0 {
+		cfg.KeyRefresh = 5 * time.Minute
 	}
-	return tokenString, nil
-}
-
-func (a Auth) VerifyPassword(plainPassword string, hashedPassword string) error {
-	if len(plainPassword) < 8 {
-		return errors.New("password length should be at least 8 characters long")
+	if cfg.Leeway == 0 {
+		cfg.Leeway = 30 * time.Second
 	}
 
-	err := bcrypt.CompareHashAndPassword([]byte(hashedPassword), []byte(plainPassword))
-	if err != nil {
-		return errors.New("password does not match")
+	v := &Verifier{cfg: cfg, cache: newTokenCache()}
+	if cfg.JWKSURL != "" {
+		v.keys = newKeySet(cfg.JWKSURL, cfg.KeyRefresh)
 	}
-
-	return nil
+	return v, nil
 }
 
-func (a Auth) VerifyToken(token string) (TokenUser, error) {
-	tokenArray := strings.Split(token, " ")
-	if len(tokenArray) != 2 {
-		return TokenUser{}, errors.New("invalid token format")
-	}
-
-	if tokenArray[0] != "Bearer" {
-		return TokenUser{}, errors.New("invalid token type")
+// VerifyToken checks an Authorization header value of the form "Bearer <token>"
+func (v *Verifier) VerifyToken(header string) (TokenUser, error) {
+	tokenArray := strings.Split(header, " ")
+	if len(tokenArray) != 2 || tokenArray[0] != "Bearer" || tokenArray[1] == "" {
+		return TokenUser{}, ErrMissingToken
 	}
-
 	tokenString := tokenArray[1]
 
-	t, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	now := time.Now()
+	sum := sha256.Sum256([]byte(tokenString))
+	cacheKey := hex.EncodeToString(sum[:])
+	if entry, ok := v.cache.get(cacheKey, now); ok {
+		if !entry.valid {
+			return TokenUser{}, ErrInvalidToken
 		}
-		return []byte(a.Secret), nil
-	})
+		return entry.user, nil
+	}
 
+	user, expiresAt, err := v.parse(tokenString, now)
 	if err != nil {
-		return TokenUser{}, fmt.Errorf("token parsing error: %v", err)
+		v.cache.put(cacheKey, cacheEntry{expiresAt: now.Add(v.cfg.NegativeCacheTTL)})
+		return TokenUser{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 	}
-
-	if claims, ok := t.Claims.(jwt.MapClaims); ok && t.Valid {
-		expiry, ok := claims["expiry"].(float64)
-		if !ok {
-			return TokenUser{}, errors.New("invalid expiry claim")
-		}
-		if time.Now().Unix() > int64(expiry) {
-			return TokenUser{}, errors.New("token has expired")
+	if v.cfg.Introspector != nil {
+		active, err := v.cfg.Introspector.Active(tokenString)
+		if err != nil {
+			return TokenUser{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
 		}
-		user := TokenUser{
-			ID:       uint(claims["user_id"].(float64)),
-			Email:    claims["email"].(string),
-			UserRole: claims["role"].(string),
+		if !active {
+			v.cache.put(cacheKey, cacheEntry{expiresAt: now.Add(v.cfg.NegativeCacheTTL)})
+			return TokenUser{}, fmt.Errorf("%w: token has been revoked", ErrInvalidToken)
 		}
-		return user, nil
 	}
 
-	return TokenUser{}, errors.New("token verification failed")
-}
-
-func (a Auth) AuthorizeUser(ctx *fiber.Ctx) error {
-	authHeader := ctx.Get("Authorization")
-
-	user, err := a.VerifyToken(authHeader)
-	if err == nil && user.ID > 0 {
-		ctx.Locals("user", user)
-		return ctx.Next()
-	} else {
-		return ctx.Status(401).JSON(&fiber.Map{
-			"message": "authorization failed",
-			"reason":  err,
-		})
+	cachedUntil := now.Add(v.cfg.CacheTTL)
+	if expiresAt.Before(cachedUntil) {
+		cachedUntil = expiresAt
 	}
+	v.cache.put(cacheKey, cacheEntry{user: user, valid: true, expiresAt: cachedUntil})
+	return user, nil
 }
 
-func (a Auth) GetCurrentUser(ctx *fiber.Ctx) TokenUser {
-	user := ctx.Locals("user")
-	return user.(TokenUser)
-}
-
-func (a Auth) GenerateCode() (string, error) {
-	// Assuming GenerateRandom is implemented elsewhere in the package
-	return GenerateRandom(8)
+func (v *Verifier) parse(tokenString string, now time.Time) (TokenUser, time.Time, error) {
+	claims, err := ParseClaims(tokenString, v.keyFunc)
+	if err != nil {
+		return TokenUser{}, time.Time{}, err
+	}
+	err = claims.Validate(now, v.cfg.Leeway, v.cfg.Issuer, v.cfg.Audience)
+	if err != nil {
+		return TokenUser{}, time.Time{}, err
+	}
+	user, err := claims.User()
+	if err != nil {
+		return TokenUser{}, time.Time{}, err
+	}
+	return user, claims.ExpiresAt.Time, nil
 }
 
-func (a Auth) AuthorizeByRole(ctx *fiber.Ctx, requiredRole string) error {
-	authHeader := ctx.Get("Authorization")
-
-	user, err := a.VerifyToken(authHeader)
+func (v *Verifier) keyFunc(token *jwt.Token) (interface{}, error) {
+	if v.keys == nil {
+		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return []byte(v.cfg.Secret), nil
+	}
 
+	kid, ok := token.Header["kid"].(string)
+	if !ok {
+		return nil, errors.New("missing key id")
+	}
+	key, err := v.keys.get(kid)
 	if err != nil {
-		return ctx.Status(401).JSON(&fiber.Map{
-			"message": "authorization failed",
-			"reason":  err,
-		})
-	} else if user.ID > 0 && user.UserRole == requiredRole {
-		ctx.Locals("user", user)
-		return ctx.Next()
-	} else {
-		return ctx.Status(401).JSON(&fiber.Map{
-			"message": "authorization failed",
-			"reason":  errors.New("insufficient permissions"),
-		})
+		return nil, err
+	}
+	if token.Method.Alg() != key.algorithm {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
 	}
+	return key.key, nil
 }
@@ -0,0 +1,54 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+// maxCachedTokens bounds the memory the cache can take
+const maxCachedTokens = 10000
+
+type cacheEntry struct {
+	user      TokenUser
+	valid     bool
+	expiresAt time.Time
+}
+
+// tokenCache remembers recent verification results, keyed by a hash of the token
+type tokenCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+func newTokenCache() *tokenCache {
+	return &tokenCache{entries: map[string]cacheEntry{}}
+}
+
+func (c *tokenCache) get(key string, now time.Time) (cacheEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || !now.Before(entry.expiresAt) {
+		return cacheEntry{}, false
+	}
+	return entry, true
+}
+
+func (c *tokenCache) put(key string, entry cacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if len(c.entries) >= maxCachedTokens {
+		now := time.Now()
+		for k, e := range c.entries {
+			if !now.Before(e.expiresAt) {
+				delete(c.entries, k)
+			}
+		}
+		if len(c.entries) >= maxCachedTokens {
+			c.entries = map[string]cacheEntry{}
+		}
+	}
+	c.entries[key] = entry
+}
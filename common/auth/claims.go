@@ -0,0 +1,130 @@
+package auth
+
+import (
+	"errors"
+	"github.com/golang-jwt/jwt/v4"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Claims are the claims of an access token, the subject is the id of the user it was issued to
+type Claims struct {
+	jwt.RegisteredClaims
+	Email string `json:"email,omitempty"`
+	Role  string `json:"role,omitempty"`
+	// Roles lists every role of the user, tokens without it only hold Role
+	Roles []string `json:"roles,omitempty"`
+	// SessionID is the sign in the access token belongs to
+	SessionID string `json:"sid,omitempty"`
+	// ClientID and Scope are only set on service tokens issued to internal callers
+	ClientID string `json:"client_id,omitempty"`
+	Scope    string `json:"scope,omitempty"`
+}
+
+// ParseClaims checks the signature and decodes the claims, they still have to be validated
+func ParseClaims(tokenString string, keyFunc jwt.Keyfunc) (*Claims, error) {
+	claims := &Claims{}
+	parser := jwt.NewParser(jwt.WithoutClaimsValidation())
+	_, err := parser.ParseWithClaims(tokenString, claims, keyFunc)
+	if err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// Validate checks the registered claims with leeway for clock skew between services,
+// an empty audience accepts a token meant for any service
+func (c *Claims) Validate(now time.Time, leeway time.Duration, issuer string, audience string) error {
+	if !c.VerifyExpiresAt(now.Add(-leeway), true) {
+		return errors.New("token has expired")
+	}
+	if !c.VerifyNotBefore(now.Add(leeway), false) {
+		return errors.New("token is not valid yet")
+	}
+	if !c.VerifyIssuedAt(now.Add(leeway), true) {
+		return errors.New("invalid iat claim")
+	}
+	if c.ID == "" {
+		return errors.New("invalid jti claim")
+	}
+	if issuer != "" && !c.VerifyIssuer(issuer, true) {
+		return errors.New("invalid iss claim")
+	}
+	if audience != "" && !c.VerifyAudience(audience, true) {
+		return errors.New("token is not meant for " + audience)
+	}
+	return nil
+}
+
+// ServiceCaller is the internal service a service token was issued to
+type ServiceCaller struct {
+	ClientID string
+	Scopes   []string
+}
+
+func (c ServiceCaller) HasScope(scope string) bool {
+	for _, s := range c.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+// Service is the internal caller of a service token
+func (c *Claims) Service() (ServiceCaller, error) {
+	if c.ClientID == "" || c.Subject != ServiceSubject(c.ClientID) {
+		return ServiceCaller{}, errors.New("not a service token")
+	}
+	return ServiceCaller{ClientID: c.ClientID, Scopes: strings.Fields(c.Scope)}, nil
+}
+
+// ServiceSubject keeps service subjects apart from user ids
+func ServiceSubject(clientID string) string {
+	return "service:" + clientID
+}
+
+// ScopeAudiences are the services a set of scopes is granted on
+func ScopeAudiences(scopes []string) []string {
+	var audiences []string
+	seen := map[string]bool{}
+	for _, scope := range scopes {
+		audience, _, _ := strings.Cut(scope, ":")
+		if !seen[audience] {
+			seen[audience] = true
+			audiences = append(audiences, audience)
+		}
+	}
+	return audiences
+}
+
+// User is who the token was issued to
+func (c *Claims) User() (TokenUser, error) {
+	id, err := strconv.ParseUint(c.Subject, 10, 64)
+	if err != nil || id == 0 {
+		return TokenUser{}, errors.New("invalid sub claim")
+	}
+	if c.Email == "" {
+		return TokenUser{}, errors.New("invalid email claim")
+	}
+	if !isKnownRole(c.Role) {
+		return TokenUser{}, errors.New("invalid role claim")
+	}
+	roles := c.Roles
+	if len(roles) == 0 {
+		roles = []string{c.Role}
+	}
+	for _, role := range roles {
+		if !isKnownRole(role) {
+			return TokenUser{}, errors.New("invalid roles claim")
+		}
+	}
+	return TokenUser{
+		ID:          uint(id),
+		Email:       c.Email,
+		UserRole:    c.Role,
+		Roles:       roles,
+		Permissions: PermissionsFor(roles),
+	}, nil
+}
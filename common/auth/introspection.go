@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Introspector asks the auth service whether a user token is still active, which local verification
+// cannot tell once the token, its session or its user has been revoked or suspended
+type Introspector struct {
+	url    string
+	tokens *ServiceTokenSource
+	client *http.Client
+}
+
+// NewIntrospector calls the introspection endpoint with service tokens holding SCOPE_AUTH_INTROSPECT
+func NewIntrospector(introspectURL string, tokens *ServiceTokenSource) *Introspector {
+	return &Introspector{
+		url:    introspectURL,
+		tokens: tokens,
+		client: &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Active is false for revoked tokens, an error means the auth service could not be asked
+func (i *Introspector) Active(token string) (bool, error) {
+	serviceToken, err := i.tokens.Token()
+	if err != nil {
+		return false, err
+	}
+
+	body, err := json.Marshal(map[string]string{
+		"token":           token,
+		"token_type_hint": "access_token",
+	})
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequest(http.MethodPost, i.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", serviceToken)
+
+	resp, err := i.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("failed to introspect token: %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Active bool `json:"active"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return false, err
+	}
+	return response.Active, nil
+}
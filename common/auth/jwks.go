@@ -0,0 +1,145 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// minKeyFetchInterval stops tokens with made up key ids from hammering the auth service
+const minKeyFetchInterval = 10 * time.Second
+
+type publicKey struct {
+	key       crypto.PublicKey
+	algorithm string
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Alg string `json:"alg"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+}
+
+// keySet caches the published keys. A new key id triggers a fetch, and the last good keys are
+// kept when the auth service cannot be reached
+type keySet struct {
+	url     string
+	refresh time.Duration
+	client  *http.Client
+
+	mu          sync.RWMutex
+	keys        map[string]publicKey
+	fetchedAt   time.Time
+	attemptedAt time.Time
+}
+
+func newKeySet(url string, refresh time.Duration) *keySet {
+	s := &keySet{
+		url:     url,
+		refresh: refresh,
+		client:  &http.Client{Timeout: 5 * time.Second},
+		keys:    map[string]publicKey{},
+	}
+	if err := s.fetch(); err != nil {
+		log.Printf("Could not load signing keys from %s, retrying on first use: %v", url, err)
+	}
+	return s
+}
+
+func (s *keySet) get(kid string) (publicKey, error) {
+	s.mu.RLock()
+	key, ok := s.keys[kid]
+	stale := time.Since(s.fetchedAt) > s.refresh
+	canFetch := time.Since(s.attemptedAt) > minKeyFetchInterval
+	s.mu.RUnlock()
+
+	if (!ok || stale) && canFetch {
+		if err := s.fetch(); err != nil {
+			log.Printf("Could not refresh signing keys from %s: %v", s.url, err)
+		}
+		s.mu.RLock()
+		key, ok = s.keys[kid]
+		s.mu.RUnlock()
+	}
+	if !ok {
+		return publicKey{}, fmt.Errorf("unknown signing key: %s", kid)
+	}
+	return key, nil
+}
+
+func (s *keySet) fetch() error {
+	s.mu.Lock()
+	s.attemptedAt = time.Now()
+	s.mu.Unlock()
+
+	resp, err := s.client.Get(s.url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("key set responded with %d", resp.StatusCode)
+	}
+
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return err
+	}
+
+	keys := map[string]publicKey{}
+	for _, k := range set.Keys {
+		key, err := parseJWK(k)
+		if err != nil {
+			log.Printf("Skipping signing key %s: %v", k.Kid, err)
+			continue
+		}
+		keys[k.Kid] = key
+	}
+
+	s.mu.Lock()
+	s.keys = keys
+	s.fetchedAt = time.Now()
+	s.mu.Unlock()
+	return nil
+}
+
+func parseJWK(k jwk) (publicKey, error) {
+	switch {
+	case k.Kty == "OKP" && k.Crv == "Ed25519":
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil || len(x) != ed25519.PublicKeySize {
+			return publicKey{}, errors.New("malformed Ed25519 key")
+		}
+		return publicKey{key: ed25519.PublicKey(x), algorithm: "EdDSA"}, nil
+	case k.Kty == "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return publicKey{}, errors.New("malformed RSA modulus")
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return publicKey{}, errors.New("malformed RSA exponent")
+		}
+		return publicKey{
+			key:       &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())},
+			algorithm: "RS256",
+		}, nil
+	}
+	return publicKey{}, fmt.Errorf("unsupported key type %s", k.Kty)
+}
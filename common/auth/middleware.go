@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"github.com/gofiber/fiber/v2"
+)
+
+// AuthorizeUser lets any signed in user through and stores them in the request context
+func (v *Verifier) AuthorizeUser() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := v.VerifyToken(c.Get(fiber.HeaderAuthorization))
+		if err != nil {
+			return unauthorized(c, err)
+		}
+
+		c.Locals("user", &user)
+		return c.Next()
+	}
+}
+
+// AuthorizeByRole only lets through users holding one of the roles
+func (v *Verifier) AuthorizeByRole(roles ...string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := v.VerifyToken(c.Get(fiber.HeaderAuthorization))
+		if err != nil {
+			return unauthorized(c, err)
+		}
+
+		for _, role := range roles {
+			if user.HasRole(role) {
+				c.Locals("user", &user)
+				return c.Next()
+			}
+		}
+		return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+			"message": "Insufficient permissions",
+		})
+	}
+}
+
+// RequirePermission only lets through users whose roles grant the permission
+func (v *Verifier) RequirePermission(permission string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		user, err := v.VerifyToken(c.Get(fiber.HeaderAuthorization))
+		if err != nil {
+			return unauthorized(c, err)
+		}
+		if !user.Can(permission) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "Insufficient permissions",
+			})
+		}
+
+		c.Locals("user", &user)
+		return c.Next()
+	}
+}
+
+// RequireScope only lets through internal callers whose service token holds the scope
+func (v *Verifier) RequireScope(scope string) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		caller, err := v.VerifyServiceToken(c.Get(fiber.HeaderAuthorization))
+		if err != nil {
+			return unauthorized(c, err)
+		}
+		if !caller.HasScope(scope) {
+			return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+				"message": "Insufficient permissions",
+			})
+		}
+
+		c.Locals("service", &caller)
+		return c.Next()
+	}
+}
+
+// CurrentUser is the user stored by the middleware, nil when the route is not protected
+func CurrentUser(c *fiber.Ctx) *TokenUser {
+	user, ok := c.Locals("user").(*TokenUser)
+	if !ok {
+		return nil
+	}
+	return user
+}
+
+func unauthorized(c *fiber.Ctx, err error) error {
+	message := "Invalid or expired token"
+	if err == ErrMissingToken {
+		message = "Missing authorization token"
+	}
+	return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
+		"message": message,
+	})
+}
@@ -0,0 +1,68 @@
+package auth
+
+import "sort"
+
+// Permissions are the rights roles grant, named after the service and resource they apply to
+const (
+	PERM_CATALOG_PRODUCT_WRITE  = "catalog:product:write"
+	PERM_CATALOG_CATEGORY_WRITE = "catalog:category:write"
+	PERM_ORDERS_FULFIL          = "orders:fulfil"
+	PERM_ORDERS_REFUND          = "orders:refund"
+	PERM_COUPONS_WRITE          = "coupons:write"
+	PERM_USERS_ADMIN            = "users:admin"
+)
+
+// rolePermissions is resolved when a token is verified, so changing it does not need new tokens.
+// They gate routes only, orders:refund lets a seller refund returns of their own order lines and
+// each refund is still checked against that line, the transactions service accepts no user refunds
+var rolePermissions = map[string][]string{
+	ROLE_BUYER: {},
+	ROLE_SELLER: {
+		PERM_CATALOG_PRODUCT_WRITE,
+		PERM_ORDERS_FULFIL,
+		PERM_ORDERS_REFUND,
+		PERM_COUPONS_WRITE,
+	},
+	ROLE_ADMIN: {
+		PERM_CATALOG_PRODUCT_WRITE,
+		PERM_CATALOG_CATEGORY_WRITE,
+		PERM_ORDERS_REFUND,
+		PERM_USERS_ADMIN,
+	},
+}
+
+// PermissionsFor is every permission held through any of the roles
+func PermissionsFor(roles []string) []string {
+	seen := map[string]bool{}
+	permissions := []string{}
+	for _, role := range roles {
+		for _, permission := range rolePermissions[role] {
+			if !seen[permission] {
+				seen[permission] = true
+				permissions = append(permissions, permission)
+			}
+		}
+	}
+	sort.Strings(permissions)
+	return permissions
+}
+
+// HasRole is true when the user holds the role, whether it is their primary role or not
+func (u TokenUser) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Can is true when one of the user's roles grants the permission
+func (u TokenUser) Can(permission string) bool {
+	for _, p := range u.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tokenRenewal is how long before expiry a service token is replaced
+const tokenRenewal = 30 * time.Second
+
+// ServiceTokenSource gets service tokens from the auth service with the client credentials grant
+// and reuses them until they are about to expire
+type ServiceTokenSource struct {
+	tokenURL     string
+	clientID     string
+	clientSecret string
+	scopes       []string
+	client       *http.Client
+
+	mu        sync.Mutex
+	token     string
+	expiresAt time.Time
+}
+
+func NewServiceTokenSource(tokenURL string, clientID string, clientSecret string, scopes ...string) *ServiceTokenSource {
+	return &ServiceTokenSource{
+		tokenURL:     tokenURL,
+		clientID:     clientID,
+		clientSecret: clientSecret,
+		scopes:       scopes,
+		client:       &http.Client{Timeout: 5 * time.Second},
+	}
+}
+
+// Token is an Authorization header value for calls to internal routes
+func (s *ServiceTokenSource) Token() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.token != "" && time.Now().Add(tokenRenewal).Before(s.expiresAt) {
+		return "Bearer " + s.token, nil
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "client_credentials")
+	form.Set("scope", strings.Join(s.scopes, " "))
+	req, err := http.NewRequest(http.MethodPost, s.tokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(url.QueryEscape(s.clientID), url.QueryEscape(s.clientSecret))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get service token: %d", resp.StatusCode)
+	}
+
+	var response struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int64  `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+	if response.AccessToken == "" {
+		return "", errors.New("auth service returned no service token")
+	}
+
+	s.token = response.AccessToken
+	s.expiresAt = time.Now().Add(time.Duration(response.ExpiresIn) * time.Second)
+	return "Bearer " + s.token, nil
+}
+
+// VerifyServiceToken checks a service token meant for this service, service tokens are not cached
+// as internal callers reuse them
+func (v *Verifier) VerifyServiceToken(header string) (ServiceCaller, error) {
+	tokenArray := strings.Split(header, " ")
+	if len(tokenArray) != 2 || tokenArray[0] != "Bearer" || tokenArray[1] == "" {
+		return ServiceCaller{}, ErrMissingToken
+	}
+
+	claims, err := ParseClaims(tokenArray[1], v.keyFunc)
+	if err != nil {
+		return ServiceCaller{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	err = claims.Validate(time.Now(), v.cfg.Leeway, v.cfg.Issuer, v.cfg.Audience)
+	if err != nil {
+		return ServiceCaller{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	caller, err := claims.Service()
+	if err != nil {
+		return ServiceCaller{}, fmt.Errorf("%w: %v", ErrInvalidToken, err)
+	}
+	return caller, nil
+}
@@ -5,7 +5,6 @@ go 1.23.4
 require (
 	github.com/gofiber/fiber/v2 v2.52.6
 	github.com/golang-jwt/jwt/v4 v4.5.1
-	golang.org/x/crypto v0.36.0
 )
 
 require (
@@ -52,12 +52,23 @@ func init() {
 // Complete mapping of all known routes based on your microservices
 var knownRoutes = map[string]string{
 	// Auth service routes
-	"/auth/hash-password":     "/auth/hash-password",
-	"/auth/verify-password":   "/auth/verify-password",
-	"/auth/generate-token":    "/auth/generate-token",
-	"/auth/verify-token":      "/auth/verify-token",
-	"/auth/authorize-by-role": "/auth/authorize-by-role",
-	"/auth/generate-code":     "/auth/generate-code",
+	"/auth/token":                    "/auth/token",
+	"/auth/verify-token":             "/auth/verify-token",
+	"/auth/authorize-by-role":        "/auth/authorize-by-role",
+	"/auth/generate-code":            "/auth/generate-code",
+	"/auth/logout":                   "/auth/logout",
+	"/auth/introspect":               "/auth/introspect",
+	"/auth/sessions":                 "/auth/sessions",
+	"/internal/auth/hash-password":   "/internal/auth/hash-password",
+	"/internal/auth/verify-password": "/internal/auth/verify-password",
+	"/internal/auth/rehash-password": "/internal/auth/rehash-password",
+	"/internal/auth/generate-token":  "/internal/auth/generate-token",
+	"/internal/auth/refresh":         "/internal/auth/refresh",
+	"/internal/auth/revoke-user":     "/internal/auth/revoke-user",
+	"/internal/orders":               "/internal/orders",
+	"/internal/refunds":              "/internal/refunds",
+	"/internal/events":               "/internal/events",
+	"/.well-known/jwks.json":         "/.well-known/jwks.json",
 
 	// Catalog service routes
 	"/health":     "/health",
@@ -71,14 +82,37 @@ var knownRoutes = map[string]string{
 	"/buyer/orders":   "/buyer/orders",
 
 	// Users service routes
-	"/users/register":   "/users/register",
-	"/users/login":      "/users/login",
-	"/users/health":     "/users/health",
-	"/users/verifyUser": "/users/verifyUser",
-	"/users/verify":     "/users/verify",
-	"/users/profile":    "/users/profile",
-	"/users/cart":       "/users/cart",
-	"/users/order":      "/users/order",
+	"/users/register":         "/users/register",
+	"/users/login":            "/users/login",
+	"/users/login/2fa":        "/users/login/2fa",
+	"/users/token/refresh":    "/users/token/refresh",
+	"/users/logout":           "/users/logout",
+	"/users/password":         "/users/password",
+	"/users/sessions":         "/users/sessions",
+	"/users/2fa":              "/users/2fa",
+	"/users/oauth":            "/users/oauth",
+	"/users/health":           "/users/health",
+	"/users/verifyUser":       "/users/verifyUser",
+	"/users/verify":           "/users/verify",
+	"/users/profile":          "/users/profile",
+	"/users/cart":             "/users/cart",
+	"/users/order":            "/users/order",
+	"/users/returns":          "/users/returns",
+	"/users/guest/cart":       "/users/guest/cart",
+	"/users/wishlists":        "/users/wishlists",
+	"/users/shipping-methods": "/users/shipping-methods",
+	"/users/account":          "/users/account",
+	"/users/account/export":   "/users/account/export",
+
+	// Users service seller routes
+	"/seller/orders":         "/seller/orders",
+	"/seller/orders/report":  "/seller/orders/report",
+	"/seller/orders/returns": "/seller/orders/returns",
+	"/seller/coupons":        "/seller/coupons",
+
+	// Users service admin routes
+	"/admin/users":            "/admin/users",
+	"/admin/shipping-methods": "/admin/shipping-methods",
 }
 
 // Path parameter patterns for normalization
@@ -88,6 +122,7 @@ var (
 	orderIdPattern    = regexp.MustCompile(`/order/([^/]+)(/|$)`)
 	categoryIdPattern = regexp.MustCompile(`/categories/([^/]+)(/|$)`)
 	productIdPattern2 = regexp.MustCompile(`/products/([^/]+)(/|$)`)
+	sessionIdPattern  = regexp.MustCompile(`/sessions/([^/]+)(/|$)`)
 )
 
 // NormalizePath normalizes the request path to reduce cardinality
@@ -108,11 +143,15 @@ func NormalizePath(path string) string {
 	path = orderIdPattern.ReplaceAllString(path, "/order/:id$2")
 	path = categoryIdPattern.ReplaceAllString(path, "/categories/:id$2")
 	path = productIdPattern2.ReplaceAllString(path, "/products/:id$2")
+	path = sessionIdPattern.ReplaceAllString(path, "/sessions/:id$2")
 
 	// Service-specific normalization
 	if strings.HasPrefix(path, "/users/cart/") {
 		return "/users/cart/:productId"
 	}
+	if strings.HasPrefix(path, "/users/wishlists/shared/") {
+		return "/users/wishlists/shared/:token"
+	}
 	if strings.HasPrefix(path, "/users/order/") {
 		return "/users/order/:id"
 	}
@@ -9,7 +9,6 @@ import (
 type AppConfig struct {
 	Port           string
 	DataSourceName string
-	JWTSecret      string
 	AppSecret      string
 	StripeSecret   string
 	PubKey         string
@@ -17,6 +16,10 @@ type AppConfig struct {
 	CancelURL      string
 	UserServiceURL string
 	AuthURL        string
+	// ServiceClientID and ServiceClientSecret get the service tokens orders are created in the user service
+	// and user tokens are introspected with
+	ServiceClientID     string
+	ServiceClientSecret string
 }
 
 func EnvSetup() (cfg AppConfig, err error) {
@@ -41,11 +44,6 @@ func EnvSetup() (cfg AppConfig, err error) {
 		return AppConfig{}, errors.New("app secret variable not found")
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if len(jwtSecret) < 1 {
-		return AppConfig{}, errors.New("jwt secret variable not found")
-	}
-
 	UserServiceURL := os.Getenv("USER_SERVICE_URL")
 	if len(UserServiceURL) < 1 {
 		return AppConfig{}, errors.New("user service URL not found")
@@ -55,13 +53,23 @@ func EnvSetup() (cfg AppConfig, err error) {
 	if len(AuthURL) < 1 {
 		return AppConfig{}, errors.New("auth service URL not found")
 	}
+
+	serviceClientID := os.Getenv("SERVICE_CLIENT_ID")
+	if len(serviceClientID) < 1 {
+		serviceClientID = "transactions"
+	}
+	serviceClientSecret := os.Getenv("SERVICE_CLIENT_SECRET")
+	if len(serviceClientSecret) < 1 {
+		return AppConfig{}, errors.New("service client secret variable not found")
+	}
 	return AppConfig{Port: httpPort, DataSourceName: dsn, AppSecret: appSecret,
-		JWTSecret:      jwtSecret,
-		StripeSecret:   os.Getenv("STRIPE_API_KEY"),
-		PubKey:         os.Getenv("STRIPE_PUB_KEY"),
-		SuccessURL:     os.Getenv("SUCCESS_URL"),
-		CancelURL:      os.Getenv("CANCEL_URL"),
-		UserServiceURL: UserServiceURL,
-		AuthURL:        AuthURL}, nil
+		StripeSecret:        os.Getenv("STRIPE_API_KEY"),
+		PubKey:              os.Getenv("STRIPE_PUB_KEY"),
+		SuccessURL:          os.Getenv("SUCCESS_URL"),
+		CancelURL:           os.Getenv("CANCEL_URL"),
+		UserServiceURL:      UserServiceURL,
+		AuthURL:             AuthURL,
+		ServiceClientID:     serviceClientID,
+		ServiceClientSecret: serviceClientSecret}, nil
 
 }
@@ -6,6 +6,7 @@ require (
 	github.com/gofiber/fiber/v2 v2.52.6
 	github.com/joho/godotenv v1.5.1
 	github.com/prometheus/client_golang v1.22.0
+	github.com/sharat789/zamazon-be-ms/common v0.0.0-00010101000000-000000000000
 	github.com/sharat789/zamazon-be-ms/metrics v0.0.0-00010101000000-000000000000
 	github.com/stripe/stripe-go/v78 v78.12.0
 	gorm.io/driver/postgres v1.5.11
@@ -14,10 +15,13 @@ require (
 
 replace github.com/sharat789/zamazon-be-ms/metrics => ../metrics
 
+replace github.com/sharat789/zamazon-be-ms/common => ../common
+
 require (
 	github.com/andybalholm/brotli v1.1.0 // indirect
 	github.com/beorn7/perks v1.0.1 // indirect
 	github.com/cespare/xxhash/v2 v2.3.0 // indirect
+	github.com/golang-jwt/jwt/v4 v4.5.1 // indirect
 	github.com/google/uuid v1.6.0 // indirect
 	github.com/jackc/pgpassfile v1.0.0 // indirect
 	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
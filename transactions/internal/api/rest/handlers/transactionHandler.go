@@ -6,10 +6,11 @@ import (
 	"errors"
 	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 	"github.com/sharat789/zamazon-be-ms/transactions/configs"
-	"github.com/sharat789/zamazon-be-ms/transactions/internal/api/middleware"
 	"github.com/sharat789/zamazon-be-ms/transactions/internal/api/rest"
 	"github.com/sharat789/zamazon-be-ms/transactions/internal/client"
+	"github.com/sharat789/zamazon-be-ms/transactions/internal/domain"
 	"github.com/sharat789/zamazon-be-ms/transactions/internal/dto"
 	"github.com/sharat789/zamazon-be-ms/transactions/internal/repository"
 	"github.com/sharat789/zamazon-be-ms/transactions/internal/service"
@@ -18,14 +19,25 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"net/url"
 )
 
+// errInvalidShipping is returned when the user service rejects the selected shipping method
+type errInvalidShipping struct {
+	message string
+}
+
+func (e errInvalidShipping) Error() string {
+	return e.message
+}
+
 type TransactionHandler struct {
 	transactionService service.TransactionService
 	paymentClient      payment.PaymentClient
 	Config             configs.AppConfig
 	userServiceURL     string
 	authClient         *client.AuthClient
+	serviceTokens      *auth.ServiceTokenSource
 }
 
 func initialiseTransactionService(db *gorm.DB, authClient *client.AuthClient) service.TransactionService {
@@ -43,16 +55,22 @@ func SetupTransactionRoutes(rh *rest.RestHandler, authClient *client.AuthClient)
 		rh.Config,
 		rh.Config.UserServiceURL,
 		authClient,
+		rh.ServiceTokens,
 	}
 	pubRoutes := app.Group("/buyer")
 	pubRoutes.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"status": "ok"})
 	})
-	secRoute := pubRoutes.Group("/", middleware.AuthorizeUser(authClient))
+	secRoute := pubRoutes.Group("/", rh.Auth.AuthorizeUser())
 	secRoute.Get("/verify", handler.VerifyPayment)
 	secRoute.Get("/checkout", handler.CreateCheckoutSession)
 	secRoute.Get("/orders", handler.GetOrders)
 	secRoute.Get("/order/:id", handler.GetOrder)
+
+	//internal endpoints are called by other services with a service token, never by users
+	internalRoutes := app.Group("/internal")
+	internalRoutes.Post("/refunds", rh.Auth.RequireScope(auth.SCOPE_TRANSACTIONS_REFUNDS), handler.RefundPayment)
+	internalRoutes.Post("/events", rh.Auth.RequireScope(auth.SCOPE_TRANSACTIONS_EVENTS), handler.ReceiveEvent)
 }
 
 // Helper method to call user service APIs
@@ -83,11 +101,11 @@ func (h *TransactionHandler) callUserService(method, endpoint string, body inter
 	return client.Do(req)
 }
 
-// FindCart calls the user service to get cart items
-func (h *TransactionHandler) findCart(token string) ([]dto.CartItem, float64, error) {
-	resp, err := h.callUserService("GET", fmt.Sprintf("/users/cart"), nil, token)
+// findCart calls the user service to get the cart, revalidated against the catalog and priced for the shipping method
+func (h *TransactionHandler) findCart(token string, shippingMethod string) (*dto.CartResponse, error) {
+	resp, err := h.callUserService("GET", "/users/cart?shipping_method="+url.QueryEscape(shippingMethod), nil, token)
 	if err != nil {
-		return nil, 0, err
+		return nil, err
 	}
 	defer func(Body io.ReadCloser) {
 		err := Body.Close()
@@ -96,36 +114,44 @@ func (h *TransactionHandler) findCart(token string) ([]dto.CartItem, float64, er
 		}
 	}(resp.Body)
 
+	if resp.StatusCode == http.StatusBadRequest {
+		var response struct {
+			Message string `json:"message"`
+		}
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		return nil, errInvalidShipping{response.Message}
+	}
 	if resp.StatusCode != http.StatusOK {
-		return nil, 0, errors.New("failed to get cart from user service")
+		return nil, errors.New("failed to get cart from user service")
 	}
 
 	var response struct {
-		Data    []dto.CartItem `json:"data"`
-		Message string         `json:"message"`
+		Data    dto.CartResponse `json:"data"`
+		Message string           `json:"message"`
 	}
 
 	err = json.NewDecoder(resp.Body).Decode(&response)
 	if err != nil {
-		return nil, 0, err
-	}
-
-	totalAmount := 0.0
-	for _, item := range response.Data {
-		totalAmount += float64(item.Quantity) * item.Price
+		return nil, err
 	}
 
-	return response.Data, totalAmount, nil
+	return &response.Data, nil
 }
 
-func (h *TransactionHandler) createOrder(request dto.CreateOrderRequest, token string) error {
+// createOrder records the paid order in the user service, only this service may create orders
+// so it calls the internal route with its own service token rather than the buyer's
+func (h *TransactionHandler) createOrder(request dto.CreateOrderRequest) error {
 	// Validate request before sending
 	if request.UserID == 0 || request.OrderRefNumber == "" || request.Amount <= 0 {
 		return fmt.Errorf("incomplete order request: userID=%d, orderRef=%s, amount=%.2f",
 			request.UserID, request.OrderRefNumber, request.Amount)
 	}
 
-	endpoint := "/users/order"
+	endpoint := "/internal/orders"
+	token, err := h.serviceTokens.Token()
+	if err != nil {
+		return err
+	}
 
 	// Debug log to see what we're sending
 	requestJSON, _ := json.Marshal(request)
@@ -153,37 +179,73 @@ func (h *TransactionHandler) CreateCheckoutSession(ctx *fiber.Ctx) error {
 	user := h.transactionService.GetCurrentUser(ctx)
 	token := ctx.Get("Authorization")
 
-	// Get cart items and total amount using user service HTTP call
-	cartItems, totalAmount, err := h.findCart(token)
+	shippingMethod := ctx.Query("shipping_method", "standard")
+
+	// Get the revalidated cart using user service HTTP call
+	cart, err := h.findCart(token, shippingMethod)
+	var shippingErr errInvalidShipping
+	if errors.As(err, &shippingErr) {
+		return rest.ErrorResponse(ctx, http.StatusBadRequest, shippingErr)
+	}
 	if err != nil {
 		log.Printf("Error while fetching cart items: %v", err)
 		return rest.InternalErrorResponse(ctx, errors.New("unable to fetch cart items"))
 	}
 
-	if len(cartItems) == 0 {
+	if len(cart.Items) == 0 {
 		return rest.ErrorResponse(ctx, 400, errors.New("cart is empty"))
 	}
 
+	if cart.HasUnavailableItems {
+		return rest.ErrorResponse(ctx, http.StatusConflict, errors.New("cart contains items that are out of stock or no longer available"))
+	}
+
+	if cart.RequiresAcknowledgement {
+		return rest.ErrorResponse(ctx, http.StatusConflict, errors.New("prices in the cart have changed, please review and acknowledge them"))
+	}
+
+	if cart.CouponError != "" {
+		return rest.ErrorResponse(ctx, http.StatusConflict, fmt.Errorf("coupon %s no longer applies: %s", cart.CouponCode, cart.CouponError))
+	}
+	totalAmount := cart.Total
+
 	// Generate order ID
 	orderId, err := h.authClient.GenerateCode()
 	if err != nil {
 		return rest.InternalErrorResponse(ctx, errors.New("could not generate order id"))
 	}
 
+	checkoutOrder := payment.CheckoutOrder{
+		UserId:     user.ID,
+		OrderId:    orderId,
+		Discount:   cart.Discount,
+		CouponCode: cart.CouponCode,
+		Shipping:   cart.ShippingCost,
+		Tax:        cart.Tax,
+	}
+	for _, item := range cart.Items {
+		checkoutOrder.Lines = append(checkoutOrder.Lines, payment.CheckoutLine{
+			Name:       item.Name,
+			UnitAmount: item.CurrentPrice,
+			Quantity:   int64(item.Quantity),
+		})
+	}
+
 	// Create checkout session with Stripe
-	checkoutSession, err := h.paymentClient.CreateCheckoutSession(totalAmount, user.ID, orderId)
+	checkoutSession, err := h.paymentClient.CreateCheckoutSession(checkoutOrder)
 	if err != nil {
 		return rest.ErrorResponse(ctx, 400, err)
 	}
 
 	// Store payment details in a pending state
 	err = h.transactionService.StoreCreatedPayment(dto.CreatePaymentRequest{
-		UserId:       user.ID,
-		Amount:       totalAmount,
-		OrderId:      orderId,
-		ClientSecret: "",
-		PaymentId:    checkoutSession.ID,
-		PaymentType:  "checkout",
+		UserId:         user.ID,
+		Amount:         totalAmount,
+		OrderId:        orderId,
+		ClientSecret:   "",
+		PaymentId:      checkoutSession.ID,
+		PaymentType:    "checkout",
+		ShippingMethod: cart.ShippingMethod,
 	})
 
 	if err != nil {
@@ -199,7 +261,6 @@ func (h *TransactionHandler) CreateCheckoutSession(ctx *fiber.Ctx) error {
 
 func (h *TransactionHandler) VerifyPayment(ctx *fiber.Ctx) error {
 	user := h.transactionService.GetCurrentUser(ctx)
-	token := ctx.Get("Authorization")
 	sessionId := ctx.Query("session_id")
 
 	// If session_id is provided, verify checkout session
@@ -229,10 +290,11 @@ func (h *TransactionHandler) VerifyPayment(ctx *fiber.Ctx) error {
 				OrderRefNumber: payment.OrderId,
 				PaymentID:      payment.PaymentId,
 				Amount:         payment.Amount,
+				ShippingMethod: payment.ShippingMethod,
 			}
 			log.Printf("Creating order: UserID=%d, OrderRef=%s, PaymentID=%s, Amount=%.2f",
 				user.ID, payment.OrderId, payment.PaymentId, payment.Amount)
-			err = h.createOrder(request, token)
+			err = h.createOrder(request)
 			if err != nil {
 				return rest.InternalErrorResponse(ctx, err)
 			}
@@ -308,3 +370,60 @@ func (h *TransactionHandler) GetOrder(ctx *fiber.Ctx) error {
 
 	return rest.SuccessResponse(ctx, "order", response["data"])
 }
+
+// ReceiveEvent handles the events the users service publishes with its service token
+func (h *TransactionHandler) ReceiveEvent(ctx *fiber.Ctx) error {
+	var event dto.Event
+	if err := ctx.BodyParser(&event); err != nil {
+		return rest.ErrorResponse(ctx, http.StatusBadRequest, errors.New("invalid event format"))
+	}
+
+	err := h.transactionService.HandleEvent(event)
+	if err != nil {
+		return rest.ErrorResponse(ctx, http.StatusBadRequest, err)
+	}
+	return rest.SuccessResponse(ctx, "event received", nil)
+}
+
+// RefundPayment is called by the users service once a cancellation or a return allows the refund
+func (h *TransactionHandler) RefundPayment(ctx *fiber.Ctx) error {
+	var request dto.RefundRequest
+	if err := ctx.BodyParser(&request); err != nil {
+		return rest.ErrorResponse(ctx, http.StatusBadRequest, errors.New("invalid request format"))
+	}
+	if request.IdempotencyKey == "" {
+		return rest.ErrorResponse(ctx, http.StatusBadRequest, errors.New("idempotency key is required"))
+	}
+
+	if refund, found := h.transactionService.FindIssuedRefund(request); found {
+		return rest.SuccessResponse(ctx, "refund already issued", refund)
+	}
+
+	payment, err := h.transactionService.ValidateRefund(request)
+	if err != nil {
+		return rest.ErrorResponse(ctx, http.StatusBadRequest, err)
+	}
+
+	stripeRefund, err := h.paymentClient.CreateRefund(payment.PaymentId, request.Amount, payment.OrderId, request.IdempotencyKey)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+
+	refund, err := h.transactionService.StoreRefund(payment, domain.Refund{
+		UserId:         payment.UserId,
+		PaymentId:      payment.PaymentId,
+		OrderId:        payment.OrderId,
+		RefundId:       stripeRefund.ID,
+		Amount:         request.Amount,
+		Reason:         request.Reason,
+		Status:         string(stripeRefund.Status),
+		CreatedBy:      request.RequestedBy,
+		IdempotencyKey: request.IdempotencyKey,
+	})
+	if err != nil {
+		log.Printf("Refund %s issued but could not be stored: %v", stripeRefund.ID, err)
+		return rest.InternalErrorResponse(ctx, errors.New("refund issued but could not be recorded"))
+	}
+
+	return rest.SuccessResponse(ctx, "refund issued", refund)
+}
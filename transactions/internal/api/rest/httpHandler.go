@@ -2,6 +2,7 @@ package rest
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 	"github.com/sharat789/zamazon-be-ms/transactions/configs"
 	"github.com/sharat789/zamazon-be-ms/transactions/pkg/payment"
 	"gorm.io/gorm"
@@ -12,4 +13,8 @@ type RestHandler struct {
 	DB            *gorm.DB
 	PaymentClient payment.PaymentClient
 	Config        configs.AppConfig
+	// Auth verifies access tokens locally against the keys the auth service publishes
+	Auth *auth.Verifier
+	// ServiceTokens authenticate this service on other services' internal routes
+	ServiceTokens *auth.ServiceTokenSource
 }
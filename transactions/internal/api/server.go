@@ -5,6 +5,7 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 	"github.com/sharat789/zamazon-be-ms/metrics"
 	"github.com/sharat789/zamazon-be-ms/transactions/configs"
 	"github.com/sharat789/zamazon-be-ms/transactions/internal/api/rest"
@@ -29,6 +30,7 @@ func StartServer(cfg configs.AppConfig) {
 	log.Println("db connected...")
 	err = db.AutoMigrate(
 		&domain.Payment{},
+		&domain.Refund{},
 	)
 
 	if err != nil {
@@ -46,11 +48,26 @@ func StartServer(cfg configs.AppConfig) {
 	app.Use(c)
 	paymentClient := payment.NewPaymentClient(cfg.StripeSecret, cfg.SuccessURL, cfg.CancelURL)
 	authClient := client.NewAuthClient(cfg.AuthURL)
+	// payments are taken and refunded on these routes, so tokens of revoked sessions and suspended
+	// users are turned away without waiting for them to expire
+	introspector := auth.NewIntrospector(cfg.AuthURL+"/auth/introspect",
+		auth.NewServiceTokenSource(cfg.AuthURL+"/auth/token", cfg.ServiceClientID, cfg.ServiceClientSecret, auth.SCOPE_AUTH_INTROSPECT))
+	verifier, err := auth.NewVerifier(auth.Config{
+		JWKSURL:      cfg.AuthURL + "/.well-known/jwks.json",
+		Issuer:       auth.ISSUER,
+		Audience:     auth.AUDIENCE_TRANSACTIONS,
+		Introspector: introspector,
+	})
+	if err != nil {
+		log.Fatalf("error setting up token verification %v", err)
+	}
 	rh := &rest.RestHandler{
-		app,
-		db,
-		paymentClient,
-		cfg,
+		App:           app,
+		DB:            db,
+		PaymentClient: paymentClient,
+		Config:        cfg,
+		Auth:          verifier,
+		ServiceTokens: auth.NewServiceTokenSource(cfg.AuthURL+"/auth/token", cfg.ServiceClientID, cfg.ServiceClientSecret, auth.SCOPE_USERS_ORDERS),
 	}
 
 	SetupRoutes(rh, authClient)
@@ -0,0 +1,19 @@
+package domain
+
+import "time"
+
+type Refund struct {
+	ID        uint    `json:"id" gorm:"PrimaryKey"`
+	UserId    uint    `json:"user_id"`
+	PaymentId string  `json:"payment_id" gorm:"index;"`
+	OrderId   string  `json:"order_id"`
+	RefundId  string  `json:"refund_id"`
+	Amount    float64 `json:"amount"`
+	Reason    string  `json:"reason"`
+	Status    string  `json:"status"`
+	CreatedBy uint    `json:"created_by"`
+	// IdempotencyKey is the key the users service sent with the refund request
+	IdempotencyKey string    `json:"idempotency_key" gorm:"index;"`
+	CreatedAt      time.Time `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt      time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+}
@@ -3,18 +3,36 @@ package dto
 import "time"
 
 type CartItem struct {
-	ID        uint      `json:"id"`
-	UserID    uint      `json:"user_id"`
-	ProductID uint      `json:"product_id"`
-	Name      string    `json:"name"`
-	ImageURL  string    `json:"image_url"`
-	SellerID  uint      `json:"seller_id"`
-	Price     float64   `json:"price"`
-	Quantity  int       `json:"qty"` // Note: JSON field is "qty"
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
+	ID           uint      `json:"id"`
+	UserID       uint      `json:"user_id"`
+	ProductID    uint      `json:"product_id"`
+	Name         string    `json:"name"`
+	ImageURL     string    `json:"image_url"`
+	SellerID     uint      `json:"seller_id"`
+	Price        float64   `json:"price"`
+	Quantity     int       `json:"qty"` // Note: JSON field is "qty"
+	CurrentPrice float64   `json:"current_price"`
+	PriceChanged bool      `json:"price_changed"`
+	OutOfStock   bool      `json:"out_of_stock"`
+	Unavailable  bool      `json:"unavailable"`
+	Discount     float64   `json:"discount"`
+	TaxRate      float64   `json:"tax_rate"`
+	Tax          float64   `json:"tax"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
 }
 
 type CartResponse struct {
-	Items []CartItem `json:"items"`
+	Items                   []CartItem `json:"items"`
+	Subtotal                float64    `json:"subtotal"`
+	Discount                float64    `json:"discount"`
+	ShippingMethod          string     `json:"shipping_method"`
+	ShippingCost            float64    `json:"shipping_cost"`
+	TotalExclTax            float64    `json:"total_excl_tax"`
+	Tax                     float64    `json:"tax"`
+	Total                   float64    `json:"total"`
+	CouponCode              string     `json:"coupon_code"`
+	CouponError             string     `json:"coupon_error"`
+	RequiresAcknowledgement bool       `json:"requires_acknowledgement"`
+	HasUnavailableItems     bool       `json:"has_unavailable_items"`
 }
@@ -5,4 +5,5 @@ type CreateOrderRequest struct {
 	OrderRefNumber string  `json:"order_ref_number"` // Changed from "order_id"
 	PaymentID      string  `json:"payment_id"`
 	Amount         float64 `json:"amount"`
+	ShippingMethod string  `json:"shipping_method"`
 }
@@ -0,0 +1,14 @@
+package dto
+
+import "time"
+
+const (
+	EVENT_USER_DELETED = "user.deleted"
+)
+
+// Event is published by the users service when something happens to an account
+type Event struct {
+	Type       string    `json:"type"`
+	UserID     uint      `json:"user_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
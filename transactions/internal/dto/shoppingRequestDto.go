@@ -6,10 +6,23 @@ type CreateCartRequest struct {
 }
 
 type CreatePaymentRequest struct {
-	UserId       uint    `json:"user_id"`
-	Amount       float64 `json:"amount"`
-	OrderId      string  `json:"order_id"`
-	ClientSecret string  `json:"client_secret"`
-	PaymentId    string  `json:"payment_id"`
-	PaymentType  string  `json:"payment_type,omitempty"` // "intent" or "checkout"
+	UserId         uint    `json:"user_id"`
+	Amount         float64 `json:"amount"`
+	OrderId        string  `json:"order_id"`
+	ClientSecret   string  `json:"client_secret"`
+	PaymentId      string  `json:"payment_id"`
+	PaymentType    string  `json:"payment_type,omitempty"` // "intent" or "checkout"
+	ShippingMethod string  `json:"shipping_method"`
+}
+
+type RefundRequest struct {
+	PaymentId      string  `json:"payment_id"`
+	OrderRefNumber string  `json:"order_ref_number"`
+	Amount         float64 `json:"amount"`
+	Reason         string  `json:"reason"`
+	// RequestedBy is the buyer who cancelled or the seller who refunded the return
+	RequestedBy uint `json:"requested_by"`
+	// IdempotencyKey names the cancellation or return being refunded, a retry with the same key
+	// returns the refund already issued
+	IdempotencyKey string `json:"idempotency_key"`
 }
@@ -10,6 +10,10 @@ type TransactionRepository interface {
 	FindExistingPayment(userId uint) (*domain.Payment, error)
 	UpdatePayment(payment *domain.Payment) error
 	FindPaymentByID(paymentId string) (domain.Payment, error)
+	CreateRefund(refund *domain.Refund) error
+	FindRefunds(paymentId string) ([]domain.Refund, error)
+	FindRefundByKey(idempotencyKey string) (domain.Refund, error)
+	AnonymisePayments(userId uint) error
 }
 
 type transactionRepository struct {
@@ -36,6 +40,32 @@ func (r transactionRepository) FindPaymentByID(paymentId string) (domain.Payment
 	return payment, err
 }
 
+func (r transactionRepository) CreateRefund(refund *domain.Refund) error {
+	return r.db.Create(refund).Error
+}
+
+func (r transactionRepository) FindRefunds(paymentId string) ([]domain.Refund, error) {
+	var refunds []domain.Refund
+	err := r.db.Where("payment_id = ?", paymentId).Find(&refunds).Error
+	return refunds, err
+}
+
+func (r transactionRepository) FindRefundByKey(idempotencyKey string) (domain.Refund, error) {
+	var refund domain.Refund
+	err := r.db.Where("idempotency_key = ?", idempotencyKey).First(&refund).Error
+	return refund, err
+}
+
+// AnonymisePayments drops the customer details and raw gateway responses of a user's payments,
+// the amounts and references are kept for accounting
+func (r transactionRepository) AnonymisePayments(userId uint) error {
+	return r.db.Model(&domain.Payment{}).Where("user_id = ?", userId).Updates(map[string]interface{}{
+		"customer_id":   "",
+		"client_secret": "",
+		"response":      "",
+	}).Error
+}
+
 func NewTransactionRepository(db *gorm.DB) TransactionRepository {
 	return &transactionRepository{
 		db,
@@ -7,6 +7,7 @@ import (
 	"github.com/sharat789/zamazon-be-ms/transactions/internal/domain"
 	"github.com/sharat789/zamazon-be-ms/transactions/internal/dto"
 	"github.com/sharat789/zamazon-be-ms/transactions/internal/repository"
+	"log"
 )
 
 type TransactionService struct {
@@ -20,12 +21,13 @@ func (s TransactionService) GetActivePayment(userId uint) (*domain.Payment, erro
 
 func (s TransactionService) StoreCreatedPayment(input dto.CreatePaymentRequest) error {
 	payment := domain.Payment{
-		UserId:       input.UserId,
-		Amount:       input.Amount,
-		OrderId:      input.OrderId,
-		Status:       string(domain.PaymentStatusInitial),
-		PaymentId:    input.PaymentId,
-		ClientSecret: input.ClientSecret,
+		UserId:         input.UserId,
+		Amount:         input.Amount,
+		OrderId:        input.OrderId,
+		ShippingMethod: input.ShippingMethod,
+		Status:         string(domain.PaymentStatusInitial),
+		PaymentId:      input.PaymentId,
+		ClientSecret:   input.ClientSecret,
 	}
 
 	return s.Repo.CreatePayment(&payment)
@@ -49,6 +51,78 @@ func (s TransactionService) GetPaymentByID(paymentId string) (domain.Payment, er
 	return payment, nil
 }
 
+// ValidateRefund checks the amount is still refundable, whether the order may be refunded at all is
+// decided by the users service before it calls
+func (s TransactionService) ValidateRefund(input dto.RefundRequest) (domain.Payment, error) {
+	if input.Amount <= 0 {
+		return domain.Payment{}, errors.New("refund amount must be positive")
+	}
+
+	payment, err := s.GetPaymentByID(input.PaymentId)
+	if err != nil {
+		return domain.Payment{}, err
+	}
+	if payment.OrderId != input.OrderRefNumber {
+		return domain.Payment{}, errors.New("payment does not belong to the order")
+	}
+	if payment.Status != string(domain.PaymentStatusSuccess) && payment.Status != string(domain.PaymentStatusPartiallyRefunded) {
+		return domain.Payment{}, errors.New("payment is not refundable")
+	}
+
+	refunded, err := s.refundedAmount(payment.PaymentId)
+	if err != nil {
+		return domain.Payment{}, err
+	}
+	if refunded+input.Amount > payment.Amount+0.005 {
+		return domain.Payment{}, errors.New("refund exceeds the captured amount")
+	}
+	return payment, nil
+}
+
+// FindIssuedRefund is the refund already recorded for a retried request, found is false for a new one
+func (s TransactionService) FindIssuedRefund(input dto.RefundRequest) (domain.Refund, bool) {
+	if input.IdempotencyKey == "" {
+		return domain.Refund{}, false
+	}
+	refund, err := s.Repo.FindRefundByKey(input.IdempotencyKey)
+	if err != nil {
+		return domain.Refund{}, false
+	}
+	return refund, true
+}
+
+// StoreRefund records a refund issued by the payment provider and updates the payment status
+func (s TransactionService) StoreRefund(payment domain.Payment, refund domain.Refund) (domain.Refund, error) {
+	err := s.Repo.CreateRefund(&refund)
+	if err != nil {
+		return domain.Refund{}, err
+	}
+
+	refunded, err := s.refundedAmount(payment.PaymentId)
+	if err != nil {
+		return domain.Refund{}, err
+	}
+
+	payment.Status = string(domain.PaymentStatusPartiallyRefunded)
+	if refunded >= payment.Amount-0.005 {
+		payment.Status = string(domain.PaymentStatusRefunded)
+	}
+	return refund, s.Repo.UpdatePayment(&payment)
+}
+
+func (s TransactionService) refundedAmount(paymentId string) (float64, error) {
+	refunds, err := s.Repo.FindRefunds(paymentId)
+	if err != nil {
+		return 0, err
+	}
+
+	var total float64
+	for _, refund := range refunds {
+		total += refund.Amount
+	}
+	return total, nil
+}
+
 func (s TransactionService) GetCurrentUser(c *fiber.Ctx) *client.TokenUser {
 	user, ok := c.Locals("user").(*client.TokenUser)
 	if !ok {
@@ -62,3 +136,16 @@ func NewTransactionService(repo repository.TransactionRepository, authClient *cl
 		AuthClient: authClient,
 	}
 }
+
+// HandleEvent reacts to events published by the users service, unknown events are ignored
+func (s TransactionService) HandleEvent(event dto.Event) error {
+	switch event.Type {
+	case dto.EVENT_USER_DELETED:
+		err := s.Repo.AnonymisePayments(event.UserID)
+		if err != nil {
+			log.Printf("Error while anonymising payments of user %d: %v", event.UserID, err)
+			return errors.New("could not anonymise payments")
+		}
+	}
+	return nil
+}
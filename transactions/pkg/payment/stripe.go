@@ -5,15 +5,37 @@ import (
 	"fmt"
 	"github.com/stripe/stripe-go/v78"
 	"github.com/stripe/stripe-go/v78/checkout/session"
+	"github.com/stripe/stripe-go/v78/coupon"
 	"github.com/stripe/stripe-go/v78/paymentintent"
+	"github.com/stripe/stripe-go/v78/refund"
 	"log"
+	"math"
 )
 
 type PaymentClient interface {
 	CreatePayment(amount float64, userId uint, orderId string) (*stripe.PaymentIntent, error)
 	GetPaymentStatus(paymentId string) (*stripe.PaymentIntent, error)
-	CreateCheckoutSession(amount float64, userId uint, orderId string) (*stripe.CheckoutSession, error)
+	CreateCheckoutSession(order CheckoutOrder) (*stripe.CheckoutSession, error)
 	GetCheckoutSession(sessionId string) (*stripe.CheckoutSession, error)
+	CreateRefund(sessionId string, amount float64, orderId string, idempotencyKey string) (*stripe.Refund, error)
+}
+
+// CheckoutLine is one product line of a checkout session, UnitAmount is tax-exclusive
+type CheckoutLine struct {
+	Name       string
+	UnitAmount float64
+	Quantity   int64
+}
+
+// CheckoutOrder is what a checkout session charges: the product lines, less the discount, plus shipping and tax
+type CheckoutOrder struct {
+	UserId     uint
+	OrderId    string
+	Lines      []CheckoutLine
+	Discount   float64
+	CouponCode string
+	Shipping   float64
+	Tax        float64
 }
 
 type payment struct {
@@ -57,33 +79,50 @@ func (p payment) GetPaymentStatus(paymentId string) (*stripe.PaymentIntent, erro
 	return result, nil
 }
 
-func (p payment) CreateCheckoutSession(amount float64, userId uint, orderId string) (*stripe.CheckoutSession, error) {
+func (p payment) CreateCheckoutSession(order CheckoutOrder) (*stripe.CheckoutSession, error) {
 	stripe.Key = p.apiKey
-	amountInCents := int64(amount * 100)
+
+	var lineItems []*stripe.CheckoutSessionLineItemParams
+	for _, line := range order.Lines {
+		lineItems = append(lineItems, checkoutLineItem(line.Name, line.UnitAmount, line.Quantity))
+	}
+	if order.Shipping > 0 {
+		lineItems = append(lineItems, checkoutLineItem("Shipping", order.Shipping, 1))
+	}
+	if order.Tax > 0 {
+		lineItems = append(lineItems, checkoutLineItem("VAT", order.Tax, 1))
+	}
 
 	params := &stripe.CheckoutSessionParams{
 		PaymentMethodTypes: stripe.StringSlice([]string{
 			"card",
 		}),
-		Mode: stripe.String(string(stripe.CheckoutSessionModePayment)),
-		LineItems: []*stripe.CheckoutSessionLineItemParams{
-			{
-				PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
-					Currency: stripe.String(string(stripe.CurrencyEUR)),
-					ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
-						Name: stripe.String("Order Payment"),
-					},
-					UnitAmount: stripe.Int64(amountInCents),
-				},
-				Quantity: stripe.Int64(1),
-			},
-		},
+		Mode:       stripe.String(string(stripe.CheckoutSessionModePayment)),
+		LineItems:  lineItems,
 		SuccessURL: stripe.String(p.successUrl + "/#/success?session_id={CHECKOUT_SESSION_ID}"),
 		CancelURL:  stripe.String(p.cancelURL + "/#/cart"),
 	}
 
-	params.AddMetadata("userId", fmt.Sprintf("%d", userId))
-	params.AddMetadata("orderId", fmt.Sprintf("%s", orderId))
+	if order.Discount > 0 {
+		// a single use Stripe coupon carries the discount so the customer sees it on the payment page
+		c, err := coupon.New(&stripe.CouponParams{
+			AmountOff:      stripe.Int64(toCents(order.Discount)),
+			Currency:       stripe.String(string(stripe.CurrencyEUR)),
+			Duration:       stripe.String(string(stripe.CouponDurationOnce)),
+			MaxRedemptions: stripe.Int64(1),
+			Name:           stripe.String(order.CouponCode),
+		})
+		if err != nil {
+			log.Printf("Error while creating checkout coupon %v\n", err.Error())
+			return nil, errors.New("could not create checkout session")
+		}
+		params.Discounts = []*stripe.CheckoutSessionDiscountParams{
+			{Coupon: stripe.String(c.ID)},
+		}
+	}
+
+	params.AddMetadata("userId", fmt.Sprintf("%d", order.UserId))
+	params.AddMetadata("orderId", fmt.Sprintf("%s", order.OrderId))
 
 	session, err := session.New(params)
 	if err != nil {
@@ -94,6 +133,23 @@ func (p payment) CreateCheckoutSession(amount float64, userId uint, orderId stri
 	return session, nil
 }
 
+func checkoutLineItem(name string, unitAmount float64, quantity int64) *stripe.CheckoutSessionLineItemParams {
+	return &stripe.CheckoutSessionLineItemParams{
+		PriceData: &stripe.CheckoutSessionLineItemPriceDataParams{
+			Currency: stripe.String(string(stripe.CurrencyEUR)),
+			ProductData: &stripe.CheckoutSessionLineItemPriceDataProductDataParams{
+				Name: stripe.String(name),
+			},
+			UnitAmount: stripe.Int64(toCents(unitAmount)),
+		},
+		Quantity: stripe.Int64(quantity),
+	}
+}
+
+func toCents(amount float64) int64 {
+	return int64(math.Round(amount * 100))
+}
+
 func (p payment) GetCheckoutSession(sessionId string) (*stripe.CheckoutSession, error) {
 	stripe.Key = p.apiKey
 	session, err := session.Get(sessionId, nil)
@@ -104,6 +160,35 @@ func (p payment) GetCheckoutSession(sessionId string) (*stripe.CheckoutSession,
 	return session, nil
 }
 
+// CreateRefund refunds part of the session's payment, Stripe returns the refund it already made for a
+// retried idempotency key instead of refunding again
+func (p payment) CreateRefund(sessionId string, amount float64, orderId string, idempotencyKey string) (*stripe.Refund, error) {
+	stripe.Key = p.apiKey
+	checkoutSession, err := session.Get(sessionId, nil)
+	if err != nil {
+		log.Printf("Error retrieving checkout session for refund: %v\n", err)
+		return nil, errors.New("could not retrieve checkout session")
+	}
+	if checkoutSession.PaymentIntent == nil {
+		return nil, errors.New("checkout session has no payment to refund")
+	}
+
+	params := &stripe.RefundParams{
+		PaymentIntent: stripe.String(checkoutSession.PaymentIntent.ID),
+		Amount:        stripe.Int64(toCents(amount)),
+		Reason:        stripe.String(string(stripe.RefundReasonRequestedByCustomer)),
+	}
+	params.AddMetadata("orderId", orderId)
+	params.SetIdempotencyKey(idempotencyKey)
+
+	r, err := refund.New(params)
+	if err != nil {
+		log.Printf("Error while creating refund %v\n", err.Error())
+		return nil, errors.New("could not create refund")
+	}
+	return r, nil
+}
+
 func NewPaymentClient(apiKey, successUrl, failureUrl string) PaymentClient {
 	return &payment{
 		apiKey:     apiKey,
@@ -4,15 +4,39 @@ import (
 	"errors"
 	"github.com/joho/godotenv"
 	"os"
+	"strings"
 )
 
 type AppConfig struct {
-	Port           string
-	DataSourceName string
-	AppSecret      string
-	JWTSecret      string
-	CatalogURL     string
-	AuthURL        string
+	Port                 string
+	DataSourceName       string
+	AppSecret            string
+	CatalogURL           string
+	AuthURL              string
+	TransactionsURL      string
+	TaxCountry           string
+	BlobDir              string
+	EventEndpoints       []string
+	AdminEmails          []string
+	SMTPAddr             string
+	SMTPFrom             string
+	SMTPUsername         string
+	SMTPPassword         string
+	SellerTwoFactor      bool
+	OAuthCallbackURL     string
+	OAuthSuccessRedirect string
+	GoogleClientID       string
+	GoogleClientSecret   string
+	GoogleIssuer         string
+	GitHubClientID       string
+	GitHubClientSecret   string
+	GitHubURL            string
+	GitHubAPIURL         string
+	// ServiceClientID and ServiceClientSecret get the service token passwords and tokens are handled in the auth service with
+	ServiceClientID     string
+	ServiceClientSecret string
+	// TrustedProxies are the addresses or CIDR ranges of the proxies allowed to set X-Forwarded-For
+	TrustedProxies []string
 }
 
 func EnvSetup() (cfg AppConfig, err error) {
@@ -37,11 +61,6 @@ func EnvSetup() (cfg AppConfig, err error) {
 		return AppConfig{}, errors.New("app secret variable not found")
 	}
 
-	jwtSecret := os.Getenv("JWT_SECRET")
-	if len(jwtSecret) < 1 {
-		return AppConfig{}, errors.New("jwt secret variable not found")
-	}
-
 	catalogURL := os.Getenv("CATALOG_URL")
 	if len(catalogURL) < 1 {
 		return AppConfig{}, errors.New("catalog url variable not found")
@@ -50,5 +69,71 @@ func EnvSetup() (cfg AppConfig, err error) {
 	if len(authURL) < 1 {
 		return AppConfig{}, errors.New("auth url variable not found")
 	}
-	return AppConfig{Port: httpPort, DataSourceName: dsn, AppSecret: appSecret, JWTSecret: jwtSecret, CatalogURL: catalogURL, AuthURL: authURL}, nil
+	transactionsURL := os.Getenv("TRANSACTIONS_URL")
+	if len(transactionsURL) < 1 {
+		return AppConfig{}, errors.New("transactions url variable not found")
+	}
+	// the country tax is charged for when the customer has no address yet
+	taxCountry := os.Getenv("TAX_DEFAULT_COUNTRY")
+	if len(taxCountry) < 1 {
+		taxCountry = "IE"
+	}
+	// generated documents such as invoices are stored under this directory
+	blobDir := os.Getenv("BLOB_DIR")
+	if len(blobDir) < 1 {
+		blobDir = "./data"
+	}
+	// services notified of account events, the transactions service listens by default
+	eventEndpoints := []string{transactionsURL + "/internal/events"}
+	if endpoints := os.Getenv("EVENT_ENDPOINTS"); len(endpoints) > 0 {
+		eventEndpoints = strings.Split(endpoints, ",")
+	}
+	// existing accounts promoted to admin on start up
+	var adminEmails []string
+	if emails := os.Getenv("ADMIN_EMAILS"); len(emails) > 0 {
+		adminEmails = strings.Split(emails, ",")
+	}
+	// notifications are only logged when no mail server is configured
+	smtpAddr := os.Getenv("SMTP_ADDR")
+	smtpFrom := os.Getenv("SMTP_FROM")
+	smtpUsername := os.Getenv("SMTP_USERNAME")
+	smtpPassword := os.Getenv("SMTP_PASSWORD")
+	// sellers hold payout details so they can be made to use two factor authentication
+	sellerTwoFactor := os.Getenv("SELLER_2FA_REQUIRED") == "true"
+	// social login providers are only offered when their client id is set
+	oauthCallbackURL := os.Getenv("OAUTH_CALLBACK_URL")
+	if len(oauthCallbackURL) < 1 {
+		oauthCallbackURL = "http://localhost:" + httpPort + "/users/oauth"
+	}
+	oauthSuccessRedirect := os.Getenv("OAUTH_SUCCESS_REDIRECT")
+	googleClientID := os.Getenv("OAUTH_GOOGLE_CLIENT_ID")
+	googleClientSecret := os.Getenv("OAUTH_GOOGLE_CLIENT_SECRET")
+	googleIssuer := os.Getenv("OAUTH_GOOGLE_ISSUER")
+	if len(googleIssuer) < 1 {
+		googleIssuer = "https://accounts.google.com"
+	}
+	githubClientID := os.Getenv("OAUTH_GITHUB_CLIENT_ID")
+	githubClientSecret := os.Getenv("OAUTH_GITHUB_CLIENT_SECRET")
+	githubURL := os.Getenv("OAUTH_GITHUB_URL")
+	if len(githubURL) < 1 {
+		githubURL = "https://github.com"
+	}
+	githubAPIURL := os.Getenv("OAUTH_GITHUB_API_URL")
+	if len(githubAPIURL) < 1 {
+		githubAPIURL = "https://api.github.com"
+	}
+	serviceClientID := os.Getenv("SERVICE_CLIENT_ID")
+	if len(serviceClientID) < 1 {
+		serviceClientID = "users"
+	}
+	serviceClientSecret := os.Getenv("SERVICE_CLIENT_SECRET")
+	if len(serviceClientSecret) < 1 {
+		return AppConfig{}, errors.New("service client secret variable not found")
+	}
+	// forwarded addresses are ignored unless the request comes from one of these, usually the ingress
+	var trustedProxies []string
+	if proxies := os.Getenv("TRUSTED_PROXIES"); len(proxies) > 0 {
+		trustedProxies = strings.Split(proxies, ",")
+	}
+	return AppConfig{Port: httpPort, DataSourceName: dsn, AppSecret: appSecret, CatalogURL: catalogURL, AuthURL: authURL, TransactionsURL: transactionsURL, TaxCountry: taxCountry, BlobDir: blobDir, EventEndpoints: eventEndpoints, AdminEmails: adminEmails, SMTPAddr: smtpAddr, SMTPFrom: smtpFrom, SMTPUsername: smtpUsername, SMTPPassword: smtpPassword, SellerTwoFactor: sellerTwoFactor, OAuthCallbackURL: oauthCallbackURL, OAuthSuccessRedirect: oauthSuccessRedirect, GoogleClientID: googleClientID, GoogleClientSecret: googleClientSecret, GoogleIssuer: googleIssuer, GitHubClientID: githubClientID, GitHubClientSecret: githubClientSecret, GitHubURL: githubURL, GitHubAPIURL: githubAPIURL, ServiceClientID: serviceClientID, ServiceClientSecret: serviceClientSecret, TrustedProxies: trustedProxies}, nil
 }
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sharat789/zamazon-be-ms/users/internal/api/rest"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/service"
+	"net/http"
+)
+
+type AccountHandler struct {
+	accountService service.AccountService
+	userService    service.UserService
+}
+
+func (h *AccountHandler) ExportAccount(ctx *fiber.Ctx) error {
+	user := h.userService.GetCurrentUser(ctx)
+
+	switch ctx.Query("format", "zip") {
+	case "json":
+		export, err := h.accountService.Export(user.ID)
+		if err != nil {
+			return rest.InternalErrorResponse(ctx, err)
+		}
+		return rest.SuccessResponse(ctx, "account data export", export)
+	case "zip":
+		archive, err := h.accountService.ExportArchive(user.ID)
+		if err != nil {
+			return rest.InternalErrorResponse(ctx, err)
+		}
+		ctx.Set(fiber.HeaderContentType, "application/zip")
+		ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"account-%d.zip\"", user.ID))
+		return ctx.Status(http.StatusOK).Send(archive)
+	default:
+		return rest.BadRequestErrorResponse(ctx, "format must be json or zip")
+	}
+}
+
+func (h *AccountHandler) DeleteAccount(ctx *fiber.Ctx) error {
+	req := dto.DeleteAccountRequest{}
+	if err := ctx.BodyParser(&req); err != nil || req.Password == "" {
+		return rest.BadRequestErrorResponse(ctx, "Please confirm the deletion with your password")
+	}
+
+	user := h.userService.GetCurrentUser(ctx)
+	err := h.accountService.Delete(user.ID, req.Password)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "account deleted", nil)
+}
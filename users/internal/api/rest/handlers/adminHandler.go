@@ -0,0 +1,239 @@
+package handlers
+
+import (
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
+	"github.com/sharat789/zamazon-be-ms/users/internal/api/middleware"
+	"github.com/sharat789/zamazon-be-ms/users/internal/api/rest"
+	"github.com/sharat789/zamazon-be-ms/users/internal/client"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"github.com/sharat789/zamazon-be-ms/users/internal/service"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const (
+	defaultUserPageSize = 50
+	maxUserPageSize     = 200
+)
+
+type AdminHandler struct {
+	adminService service.AdminService
+}
+
+func SetupAdminRoutes(rh *rest.RestHandler, authClient *client.AuthClient) {
+	app := rh.App
+	security := service.SecurityService{
+		Repo:     repository.NewSecurityRepository(rh.DB),
+		Notifier: rh.Notifier,
+	}
+	svc := service.AdminService{
+		Repo: repository.NewAdminRepository(rh.DB),
+		Users: service.UserService{
+			Repo: repository.NewUserRepository(rh.DB),
+			TwoFactor: service.TwoFactorService{
+				Repo:              repository.NewTwoFactorRepository(rh.DB),
+				Security:          security,
+				SecretKey:         rh.Config.AppSecret,
+				EnforceForSellers: rh.Config.SellerTwoFactor,
+			},
+		},
+		Security: security,
+		Auth:     authClient,
+	}
+	handler := AdminHandler{
+		svc,
+	}
+
+	adminRoutes := app.Group("/admin/users", rh.Auth.RequirePermission(auth.PERM_USERS_ADMIN), middleware.RejectInactiveUser(svc.Users.CheckActive))
+	adminRoutes.Get("/", handler.GetUsers)
+	adminRoutes.Get("/:id", handler.GetUser)
+	adminRoutes.Get("/:id/orders", handler.GetUserOrders)
+	adminRoutes.Get("/:id/security-events", handler.GetUserSecurityEvents)
+	adminRoutes.Post("/:id/suspend", handler.SuspendUser)
+	adminRoutes.Post("/:id/reactivate", handler.ReactivateUser)
+	adminRoutes.Patch("/:id/role", handler.ChangeRole)
+	adminRoutes.Post("/:id/roles", handler.GrantRole)
+	adminRoutes.Delete("/:id/roles/:role", handler.RevokeRole)
+	adminRoutes.Post("/:id/reverify", handler.ForceReverification)
+	adminRoutes.Patch("/:id/two-factor", handler.RequireTwoFactor)
+
+	shippingHandler := ShippingHandler{
+		service.ShippingService{Repo: repository.NewShippingRepository(rh.DB)},
+	}
+	shippingRoutes := app.Group("/admin/shipping-methods", rh.Auth.RequirePermission(auth.PERM_USERS_ADMIN), middleware.RejectInactiveUser(svc.Users.CheckActive))
+	shippingRoutes.Get("/", shippingHandler.GetShippingMethods)
+	shippingRoutes.Post("/", shippingHandler.CreateShippingMethod)
+	shippingRoutes.Patch("/:id", shippingHandler.UpdateShippingMethod)
+	shippingRoutes.Delete("/:id", shippingHandler.DeleteShippingMethod)
+	shippingRoutes.Post("/:id/rates", shippingHandler.AddShippingRate)
+	shippingRoutes.Put("/:id/rates/:rateId", shippingHandler.UpdateShippingRate)
+	shippingRoutes.Delete("/:id/rates/:rateId", shippingHandler.DeleteShippingRate)
+
+	couponHandler := CouponHandler{
+		service.PromotionService{Repo: repository.NewPromotionRepository(rh.DB)},
+	}
+	couponRoutes := app.Group("/admin/coupons", rh.Auth.RequirePermission(auth.PERM_USERS_ADMIN), middleware.RejectInactiveUser(svc.Users.CheckActive))
+	couponRoutes.Get("/", couponHandler.GetCoupons)
+	couponRoutes.Post("/", couponHandler.CreateCoupon)
+	couponRoutes.Patch("/:id", couponHandler.UpdateCoupon)
+	couponRoutes.Delete("/:id", couponHandler.DeactivateCoupon)
+}
+
+func (h *AdminHandler) GetUsers(ctx *fiber.Ctx) error {
+	filter := dto.UserFilter{
+		Search:   strings.TrimSpace(ctx.Query("q")),
+		Role:     ctx.Query("role"),
+		Status:   ctx.Query("status"),
+		Page:     ctx.QueryInt("page", 1),
+		PageSize: ctx.QueryInt("page_size", defaultUserPageSize),
+	}
+
+	switch filter.Role {
+	case "", domain.BUYER, domain.SELLER, domain.ADMIN:
+	default:
+		return rest.BadRequestErrorResponse(ctx, "role must be buyer, seller or admin")
+	}
+	switch filter.Status {
+	case "", domain.USER_ACTIVE, domain.USER_SUSPENDED:
+	default:
+		return rest.BadRequestErrorResponse(ctx, "status must be active or suspended")
+	}
+	if filter.Page < 1 {
+		return rest.BadRequestErrorResponse(ctx, "page must be a positive number")
+	}
+	if filter.PageSize < 1 || filter.PageSize > maxUserPageSize {
+		return rest.BadRequestErrorResponse(ctx, fmt.Sprintf("page_size must be between 1 and %d", maxUserPageSize))
+	}
+
+	users, err := h.adminService.ListUsers(filter)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "users found", users)
+}
+
+func (h *AdminHandler) GetUser(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	user, err := h.adminService.GetUser(uint(id))
+	if err != nil {
+		return rest.ErrorResponse(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "user found", user)
+}
+
+func (h *AdminHandler) GetUserOrders(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+
+	filter, err := parseOrderFilter(ctx)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	orders, err := h.adminService.GetUserOrders(uint(id), filter)
+	if err != nil {
+		return rest.ErrorResponse(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "orders found for user", orders)
+}
+
+func (h *AdminHandler) GetUserSecurityEvents(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	events, err := h.adminService.GetUserSecurityEvents(uint(id))
+	if err != nil {
+		return rest.ErrorResponse(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "security events found for user", events)
+}
+
+func (h *AdminHandler) SuspendUser(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.SuspendUserRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a suspension reason")
+	}
+
+	admin := h.adminService.Users.GetCurrentUser(ctx)
+	user, err := h.adminService.SuspendUser(admin.ID, uint(id), strings.TrimSpace(req.Reason))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "user suspended", user)
+}
+
+func (h *AdminHandler) ReactivateUser(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	user, err := h.adminService.ReactivateUser(uint(id))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "user reactivated", user)
+}
+
+func (h *AdminHandler) ChangeRole(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.ChangeRoleRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a valid role")
+	}
+
+	admin := h.adminService.Users.GetCurrentUser(ctx)
+	user, err := h.adminService.ChangeRole(admin.ID, uint(id), req.Role)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "user role changed", user)
+}
+
+func (h *AdminHandler) GrantRole(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.GrantRoleRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a valid role")
+	}
+
+	admin := h.adminService.Users.GetCurrentUser(ctx)
+	user, err := h.adminService.GrantRole(admin.ID, uint(id), req.Role)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "role granted", user)
+}
+
+func (h *AdminHandler) RevokeRole(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+
+	admin := h.adminService.Users.GetCurrentUser(ctx)
+	user, err := h.adminService.RevokeRole(admin.ID, uint(id), ctx.Params("role"))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "role revoked", user)
+}
+
+func (h *AdminHandler) ForceReverification(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	user, err := h.adminService.ForceReverification(uint(id))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "user must verify again", user)
+}
+
+func (h *AdminHandler) RequireTwoFactor(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.RequireTwoFactorRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please specify whether two factor authentication is required")
+	}
+
+	user, err := h.adminService.RequireTwoFactor(uint(id), req.Required)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "two factor requirement updated", user)
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"errors"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sharat789/zamazon-be-ms/users/internal/api/rest"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/service"
+	"strconv"
+)
+
+// PLATFORM_COUPONS is the seller id of coupons that apply to every seller's items
+const PLATFORM_COUPONS = 0
+
+// CouponHandler lets admins manage platform wide coupons, its routes are registered with the other
+// admin routes in SetupAdminRoutes. Sellers manage their own coupons on the seller routes
+type CouponHandler struct {
+	promotions service.PromotionService
+}
+
+func (h *CouponHandler) GetCoupons(ctx *fiber.Ctx) error {
+	coupons, err := h.promotions.GetSellerCoupons(PLATFORM_COUPONS)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, errors.New("unable to fetch coupons"))
+	}
+	return rest.SuccessResponse(ctx, "platform coupons", coupons)
+}
+
+func (h *CouponHandler) CreateCoupon(ctx *fiber.Ctx) error {
+	req := dto.CouponInput{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide valid coupon details")
+	}
+
+	coupon, err := h.promotions.CreateCoupon(PLATFORM_COUPONS, req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "coupon created", coupon)
+}
+
+func (h *CouponHandler) UpdateCoupon(ctx *fiber.Ctx) error {
+	couponId, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.CouponInput{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide valid coupon details")
+	}
+
+	coupon, err := h.promotions.UpdateCoupon(PLATFORM_COUPONS, uint(couponId), req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "coupon updated", coupon)
+}
+
+func (h *CouponHandler) DeactivateCoupon(ctx *fiber.Ctx) error {
+	couponId, _ := strconv.Atoi(ctx.Params("id"))
+	err := h.promotions.DeactivateCoupon(PLATFORM_COUPONS, uint(couponId))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "coupon deactivated", nil)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sharat789/zamazon-be-ms/users/internal/api/rest"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/service"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// oauthStateCookie keeps the state of a social login in the browser that started it
+const oauthStateCookie = "oauth_state"
+
+type OAuthHandler struct {
+	oauthService service.OAuthService
+	// successRedirect is the front end page that receives the token, the callback answers with JSON when empty
+	successRedirect string
+}
+
+func (h *OAuthHandler) GetProviders(ctx *fiber.Ctx) error {
+	return rest.SuccessResponse(ctx, "sign in providers", h.oauthService.ProviderNames())
+}
+
+// Login sends the browser to the provider, the guest cart token is taken from the query as redirects carry no headers
+func (h *OAuthHandler) Login(ctx *fiber.Ctx) error {
+	cartToken := ctx.Query("cart_token", ctx.Get(cartTokenHeader))
+
+	authURL, state, err := h.oauthService.Start(ctx.Params("provider"), cartToken)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	// lax so the cookie comes back on the provider's redirect to the callback
+	ctx.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Value:    state,
+		Path:     "/users/oauth",
+		Expires:  time.Now().Add(service.OAuthStateTTL),
+		Secure:   ctx.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+	return ctx.Redirect(authURL, http.StatusFound)
+}
+
+func (h *OAuthHandler) Callback(ctx *fiber.Ctx) error {
+	browserState := ctx.Cookies(oauthStateCookie)
+	ctx.Cookie(&fiber.Cookie{
+		Name:     oauthStateCookie,
+		Path:     "/users/oauth",
+		Expires:  time.Unix(0, 0),
+		Secure:   ctx.Protocol() == "https",
+		HTTPOnly: true,
+		SameSite: fiber.CookieSameSiteLaxMode,
+	})
+
+	if reason := ctx.Query("error"); reason != "" {
+		return h.respond(ctx, dto.LoginResponse{}, fiber.NewError(http.StatusUnauthorized, "sign in was cancelled: "+reason))
+	}
+	code, state := ctx.Query("code"), ctx.Query("state")
+	if code == "" || state == "" {
+		return rest.BadRequestErrorResponse(ctx, "missing code or state")
+	}
+
+	response, err := h.oauthService.Callback(ctx.UserContext(), ctx.Params("provider"), code, state, browserState, clientInfo(ctx))
+	return h.respond(ctx, response, err)
+}
+
+// respond hands the result back to the front end in the url fragment so it never reaches server logs
+func (h *OAuthHandler) respond(ctx *fiber.Ctx, response dto.LoginResponse, err error) error {
+	if h.successRedirect == "" {
+		return loginResponse(ctx, "signed in", response, err)
+	}
+
+	values := url.Values{}
+	switch {
+	case err != nil:
+		values.Set("error", err.Error())
+	case response.ChallengeToken != "":
+		values.Set("challenge_token", response.ChallengeToken)
+		values.Set("two_factor", response.TwoFactor)
+	default:
+		values.Set("token", response.Token)
+		values.Set("refresh_token", response.RefreshToken)
+	}
+	return ctx.Redirect(h.successRedirect+"#"+values.Encode(), http.StatusFound)
+}
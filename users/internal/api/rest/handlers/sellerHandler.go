@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"github.com/gofiber/fiber/v2"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
+	"github.com/sharat789/zamazon-be-ms/users/internal/api/middleware"
+	"github.com/sharat789/zamazon-be-ms/users/internal/api/rest"
+	"github.com/sharat789/zamazon-be-ms/users/internal/client"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"github.com/sharat789/zamazon-be-ms/users/internal/service"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const reportDateLayout = "2006-01-02"
+
+type SellerHandler struct {
+	userService service.UserService
+}
+
+func SetupSellerRoutes(rh *rest.RestHandler, catalogClient *client.CatalogClient, authClient *client.AuthClient, transactionsClient *client.TransactionsClient) {
+	app := rh.App
+	svc := service.UserService{
+		Repo:               repository.NewUserRepository(rh.DB),
+		CatalogClient:      catalogClient,
+		AuthClient:         authClient,
+		TransactionsClient: transactionsClient,
+		TaxCalculator:      rh.TaxCalculator,
+		Promotions: service.PromotionService{
+			Repo: repository.NewPromotionRepository(rh.DB),
+		},
+		Shipping: service.ShippingService{
+			Repo: repository.NewShippingRepository(rh.DB),
+		},
+		Invoices: service.InvoiceService{
+			Repo:  repository.NewInvoiceRepository(rh.DB),
+			Store: rh.BlobStore,
+		},
+		Security: service.SecurityService{
+			Repo:     repository.NewSecurityRepository(rh.DB),
+			Notifier: rh.Notifier,
+		},
+		TwoFactor: service.TwoFactorService{
+			Repo: repository.NewTwoFactorRepository(rh.DB),
+			Security: service.SecurityService{
+				Repo:     repository.NewSecurityRepository(rh.DB),
+				Notifier: rh.Notifier,
+			},
+			SecretKey:         rh.Config.AppSecret,
+			EnforceForSellers: rh.Config.SellerTwoFactor,
+		},
+	}
+	handler := SellerHandler{
+		svc,
+	}
+
+	sellerRoutes := app.Group("/seller/orders", rh.Auth.RequirePermission(auth.PERM_ORDERS_FULFIL), middleware.RejectInactiveUser(svc.CheckActive))
+	sellerRoutes.Get("/", handler.GetOrders)
+	sellerRoutes.Get("/report", handler.ExportSalesReport)
+	sellerRoutes.Get("/:id/invoice", handler.GetOrderInvoice)
+	sellerRoutes.Patch("/items/:itemId", handler.UpdateOrderItem)
+	sellerRoutes.Patch("/shipments/:id", handler.UpdateShipment)
+
+	sellerRoutes.Get("/returns", handler.GetReturnRequests)
+	sellerRoutes.Post("/returns/:id/approve", handler.ApproveReturn)
+	sellerRoutes.Post("/returns/:id/reject", handler.RejectReturn)
+	sellerRoutes.Post("/returns/:id/receive", handler.ReceiveReturn)
+	sellerRoutes.Post("/returns/:id/refund", rh.Auth.RequirePermission(auth.PERM_ORDERS_REFUND), handler.RefundReturn)
+
+	couponRoutes := app.Group("/seller/coupons", rh.Auth.RequirePermission(auth.PERM_COUPONS_WRITE), middleware.RejectInactiveUser(svc.CheckActive))
+	couponRoutes.Get("/", handler.GetCoupons)
+	couponRoutes.Post("/", handler.CreateCoupon)
+	couponRoutes.Patch("/:id", handler.UpdateCoupon)
+	couponRoutes.Delete("/:id", handler.DeactivateCoupon)
+}
+
+func (h *SellerHandler) GetOrders(ctx *fiber.Ctx) error {
+	seller := h.userService.GetCurrentUser(ctx)
+
+	filter, err := parseSellerOrderFilter(ctx)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	orders, err := h.userService.GetSellerOrders(seller.ID, filter)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, errors.New("unable to fetch seller orders"))
+	}
+
+	return rest.SuccessResponse(ctx, "orders found for seller", orders)
+}
+
+func (h *SellerHandler) UpdateOrderItem(ctx *fiber.Ctx) error {
+	itemId, err := strconv.Atoi(ctx.Params("itemId"))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, "invalid order item id")
+	}
+
+	req := dto.UpdateOrderItemRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide valid status and tracking number")
+	}
+
+	seller := h.userService.GetCurrentUser(ctx)
+	item, err := h.userService.UpdateSellerOrderItem(seller.ID, uint(itemId), req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "order item updated", item)
+}
+
+func (h *SellerHandler) UpdateShipment(ctx *fiber.Ctx) error {
+	subOrderId, err := strconv.Atoi(ctx.Params("id"))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, "invalid shipment id")
+	}
+
+	req := dto.UpdateOrderItemRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide valid status and tracking number")
+	}
+
+	seller := h.userService.GetCurrentUser(ctx)
+	subOrder, err := h.userService.UpdateSellerSubOrder(seller.ID, uint(subOrderId), req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "shipment updated", subOrder)
+}
+
+func (h *SellerHandler) GetOrderInvoice(ctx *fiber.Ctx) error {
+	orderId, _ := strconv.Atoi(ctx.Params("id"))
+	seller := h.userService.GetCurrentUser(ctx)
+
+	invoice, pdf, err := h.userService.GetSellerOrderInvoice(uint(orderId), seller.ID)
+	if err != nil {
+		return rest.ErrorResponse(ctx, http.StatusNotFound, err)
+	}
+	return sendPDF(ctx, invoice.Number, pdf)
+}
+
+func (h *SellerHandler) ExportSalesReport(ctx *fiber.Ctx) error {
+	seller := h.userService.GetCurrentUser(ctx)
+
+	filter, err := parseSellerOrderFilter(ctx)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	report, err := h.userService.GetSalesReport(seller.ID, filter)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, errors.New("unable to build sales report"))
+	}
+
+	if ctx.Query("format") != "csv" {
+		return rest.SuccessResponse(ctx, "sales report", report)
+	}
+
+	body, err := salesReportCSV(report)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, errors.New("unable to export sales report"))
+	}
+
+	ctx.Set(fiber.HeaderContentType, "text/csv")
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"sales-report-%s.csv\"", time.Now().Format(reportDateLayout)))
+	return ctx.Status(http.StatusOK).Send(body)
+}
+
+func (h *SellerHandler) GetReturnRequests(ctx *fiber.Ctx) error {
+	seller := h.userService.GetCurrentUser(ctx)
+	returnRequests, err := h.userService.GetSellerReturnRequests(seller.ID, ctx.Query("status"))
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, errors.New("unable to fetch return requests"))
+	}
+
+	return rest.SuccessResponse(ctx, "return requests found for seller", returnRequests)
+}
+
+func (h *SellerHandler) ApproveReturn(ctx *fiber.Ctx) error {
+	returnId, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.ReviewReturnRequest{}
+	_ = ctx.BodyParser(&req)
+
+	seller := h.userService.GetCurrentUser(ctx)
+	returnRequest, err := h.userService.ApproveReturn(seller.ID, uint(returnId), req.Note)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "return approved", returnRequest)
+}
+
+func (h *SellerHandler) RejectReturn(ctx *fiber.Ctx) error {
+	returnId, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.ReviewReturnRequest{}
+	if err := ctx.BodyParser(&req); err != nil || req.Note == "" {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a reason for rejecting the return")
+	}
+
+	seller := h.userService.GetCurrentUser(ctx)
+	returnRequest, err := h.userService.RejectReturn(seller.ID, uint(returnId), req.Note)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "return rejected", returnRequest)
+}
+
+func (h *SellerHandler) ReceiveReturn(ctx *fiber.Ctx) error {
+	returnId, _ := strconv.Atoi(ctx.Params("id"))
+
+	seller := h.userService.GetCurrentUser(ctx)
+	returnRequest, err := h.userService.ReceiveReturn(seller.ID, uint(returnId))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "return received and refunded", returnRequest)
+}
+
+func (h *SellerHandler) RefundReturn(ctx *fiber.Ctx) error {
+	returnId, _ := strconv.Atoi(ctx.Params("id"))
+
+	seller := h.userService.GetCurrentUser(ctx)
+	returnRequest, err := h.userService.RefundReturn(seller.ID, uint(returnId))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "return refunded", returnRequest)
+}
+
+func (h *SellerHandler) GetCoupons(ctx *fiber.Ctx) error {
+	seller := h.userService.GetCurrentUser(ctx)
+	coupons, err := h.userService.Promotions.GetSellerCoupons(seller.ID)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, errors.New("unable to fetch coupons"))
+	}
+
+	return rest.SuccessResponse(ctx, "coupons found for seller", coupons)
+}
+
+func (h *SellerHandler) CreateCoupon(ctx *fiber.Ctx) error {
+	req := dto.CouponInput{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide valid coupon details")
+	}
+
+	seller := h.userService.GetCurrentUser(ctx)
+	coupon, err := h.userService.Promotions.CreateCoupon(seller.ID, req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "coupon created", coupon)
+}
+
+func (h *SellerHandler) UpdateCoupon(ctx *fiber.Ctx) error {
+	couponId, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.CouponInput{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide valid coupon details")
+	}
+
+	seller := h.userService.GetCurrentUser(ctx)
+	coupon, err := h.userService.Promotions.UpdateCoupon(seller.ID, uint(couponId), req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "coupon updated", coupon)
+}
+
+func (h *SellerHandler) DeactivateCoupon(ctx *fiber.Ctx) error {
+	couponId, _ := strconv.Atoi(ctx.Params("id"))
+
+	seller := h.userService.GetCurrentUser(ctx)
+	err := h.userService.Promotions.DeactivateCoupon(seller.ID, uint(couponId))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "coupon deactivated", nil)
+}
+
+// parseSellerOrderFilter reads the status, from and to query parameters, dates are inclusive
+func parseSellerOrderFilter(ctx *fiber.Ctx) (dto.SellerOrderFilter, error) {
+	filter := dto.SellerOrderFilter{
+		Status: ctx.Query("status"),
+	}
+
+	switch filter.Status {
+	case "", domain.ITEM_PENDING, domain.ITEM_PACKED, domain.ITEM_SHIPPED, domain.ITEM_DELIVERED, domain.ITEM_CANCELLED:
+	default:
+		return dto.SellerOrderFilter{}, errors.New("status must be pending, packed, shipped, delivered or cancelled")
+	}
+
+	if from := ctx.Query("from"); from != "" {
+		t, err := time.Parse(reportDateLayout, from)
+		if err != nil {
+			return dto.SellerOrderFilter{}, errors.New("from must be formatted as YYYY-MM-DD")
+		}
+		filter.From = t
+	}
+
+	if to := ctx.Query("to"); to != "" {
+		t, err := time.Parse(reportDateLayout, to)
+		if err != nil {
+			return dto.SellerOrderFilter{}, errors.New("to must be formatted as YYYY-MM-DD")
+		}
+		filter.To = t.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
+func salesReportCSV(report dto.SalesReport) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+
+	err := w.Write([]string{"order_ref_number", "order_date", "product_id", "name", "qty", "unit_price", "discount", "line_total", "status"})
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range report.Rows {
+		err = w.Write([]string{
+			row.OrderRefNumber,
+			row.OrderDate.Format(time.RFC3339),
+			strconv.Itoa(int(row.ProductID)),
+			row.Name,
+			strconv.Itoa(int(row.Qty)),
+			strconv.FormatFloat(row.UnitPrice, 'f', 2, 64),
+			strconv.FormatFloat(row.Discount, 'f', 2, 64),
+			strconv.FormatFloat(row.LineTotal, 'f', 2, 64),
+			row.Status,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	w.Flush()
+	return buf.Bytes(), w.Error()
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sharat789/zamazon-be-ms/users/internal/api/rest"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/service"
+	"strconv"
+)
+
+// ShippingHandler lets admins manage shipping methods and their rate tables, its routes are
+// registered with the other admin routes in SetupAdminRoutes
+type ShippingHandler struct {
+	shippingService service.ShippingService
+}
+
+func (h *ShippingHandler) GetShippingMethods(ctx *fiber.Ctx) error {
+	methods, err := h.shippingService.ListAllShippingMethods()
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "shipping methods", methods)
+}
+
+func (h *ShippingHandler) CreateShippingMethod(ctx *fiber.Ctx) error {
+	req := dto.ShippingMethodRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a valid shipping method")
+	}
+
+	method, err := h.shippingService.CreateShippingMethod(req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "shipping method created", method)
+}
+
+func (h *ShippingHandler) UpdateShippingMethod(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.ShippingMethodRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a valid shipping method")
+	}
+
+	method, err := h.shippingService.UpdateShippingMethod(uint(id), req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "shipping method updated", method)
+}
+
+func (h *ShippingHandler) DeleteShippingMethod(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	err := h.shippingService.DeleteShippingMethod(uint(id))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "shipping method deleted", nil)
+}
+
+func (h *ShippingHandler) AddShippingRate(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.ShippingRateRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a valid shipping rate")
+	}
+
+	rate, err := h.shippingService.AddShippingRate(uint(id), req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "shipping rate created", rate)
+}
+
+func (h *ShippingHandler) UpdateShippingRate(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	rateID, _ := strconv.Atoi(ctx.Params("rateId"))
+	req := dto.ShippingRateRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a valid shipping rate")
+	}
+
+	rate, err := h.shippingService.UpdateShippingRate(uint(id), uint(rateID), req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "shipping rate updated", rate)
+}
+
+func (h *ShippingHandler) DeleteShippingRate(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	rateID, _ := strconv.Atoi(ctx.Params("rateId"))
+	err := h.shippingService.DeleteShippingRate(uint(id), uint(rateID))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "shipping rate deleted", nil)
+}
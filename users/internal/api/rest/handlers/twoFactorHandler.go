@@ -0,0 +1,95 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sharat789/zamazon-be-ms/users/internal/api/rest"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/service"
+)
+
+type TwoFactorHandler struct {
+	userService service.UserService
+}
+
+func (h *TwoFactorHandler) Login(ctx *fiber.Ctx) error {
+	req := dto.TwoFactorLoginInput{}
+	if err := ctx.BodyParser(&req); err != nil || req.ChallengeToken == "" {
+		return rest.BadRequestErrorResponse(ctx, "Please provide the challenge token and a code")
+	}
+
+	response, err := h.userService.LoginTwoFactor(req, clientInfo(ctx))
+	return loginResponse(ctx, "signed in", response, err)
+}
+
+func (h *TwoFactorHandler) LoginEnroll(ctx *fiber.Ctx) error {
+	req := dto.TwoFactorLoginInput{}
+	if err := ctx.BodyParser(&req); err != nil || req.ChallengeToken == "" {
+		return rest.BadRequestErrorResponse(ctx, "Please provide the challenge token")
+	}
+
+	enrollment, err := h.userService.EnrollTwoFactorLogin(req.ChallengeToken)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "scan the QR code and confirm with a code to finish signing in", enrollment)
+}
+
+func (h *TwoFactorHandler) GetStatus(ctx *fiber.Ctx) error {
+	user := h.userService.GetCurrentUser(ctx)
+	status, err := h.userService.GetTwoFactorStatus(user.ID)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "two factor authentication status", status)
+}
+
+func (h *TwoFactorHandler) Enroll(ctx *fiber.Ctx) error {
+	user := h.userService.GetCurrentUser(ctx)
+	enrollment, err := h.userService.EnrollTwoFactor(user.ID)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "scan the QR code and confirm with a code", enrollment)
+}
+
+func (h *TwoFactorHandler) Confirm(ctx *fiber.Ctx) error {
+	req := dto.TwoFactorCodeInput{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a code")
+	}
+
+	user := h.userService.GetCurrentUser(ctx)
+	codes, err := h.userService.ConfirmTwoFactor(user.ID, req.Code, clientIP(ctx))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "two factor authentication enabled, store the recovery codes safely", codes)
+}
+
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(ctx *fiber.Ctx) error {
+	req := dto.TwoFactorCodeInput{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a code")
+	}
+
+	user := h.userService.GetCurrentUser(ctx)
+	codes, err := h.userService.RegenerateRecoveryCodes(user.ID, req.Code, clientIP(ctx))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "new recovery codes created, the old ones no longer work", codes)
+}
+
+func (h *TwoFactorHandler) Disable(ctx *fiber.Ctx) error {
+	req := dto.TwoFactorCodeInput{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a code")
+	}
+
+	user := h.userService.GetCurrentUser(ctx)
+	err := h.userService.DisableTwoFactor(user.ID, req.Code, clientIP(ctx))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "two factor authentication disabled", nil)
+}
@@ -2,41 +2,134 @@ package handlers
 
 import (
 	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 	"github.com/sharat789/zamazon-be-ms/users/internal/api/middleware"
 	"github.com/sharat789/zamazon-be-ms/users/internal/api/rest"
 	"github.com/sharat789/zamazon-be-ms/users/internal/client"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
 	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
 	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
 	"github.com/sharat789/zamazon-be-ms/users/internal/service"
 	"log"
 	"net/http"
 	"strconv"
+	"strings"
+	"time"
+)
+
+// cartTokenHeader carries the opaque token of an anonymous cart
+const cartTokenHeader = "X-Cart-Token"
+
+const (
+	defaultOrderPageSize = 20
+	maxOrderPageSize     = 100
 )
 
 type UserHandler struct {
 	userService service.UserService
 }
 
-func SetupUserRoutes(rh *rest.RestHandler, catalogClient *client.CatalogClient, authClient *client.AuthClient) {
+func SetupUserRoutes(rh *rest.RestHandler, catalogClient *client.CatalogClient, authClient *client.AuthClient, transactionsClient *client.TransactionsClient) {
 	app := rh.App
 	svc := service.UserService{
-		Repo:          repository.NewUserRepository(rh.DB),
-		CatalogClient: catalogClient,
-		AuthClient:    authClient,
+		Repo:               repository.NewUserRepository(rh.DB),
+		CatalogClient:      catalogClient,
+		AuthClient:         authClient,
+		TransactionsClient: transactionsClient,
+		TaxCalculator:      rh.TaxCalculator,
+		Promotions: service.PromotionService{
+			Repo: repository.NewPromotionRepository(rh.DB),
+		},
+		Shipping: service.ShippingService{
+			Repo: repository.NewShippingRepository(rh.DB),
+		},
+		Invoices: service.InvoiceService{
+			Repo:  repository.NewInvoiceRepository(rh.DB),
+			Store: rh.BlobStore,
+		},
+		Security: service.SecurityService{
+			Repo:     repository.NewSecurityRepository(rh.DB),
+			Notifier: rh.Notifier,
+		},
+		TwoFactor: service.TwoFactorService{
+			Repo: repository.NewTwoFactorRepository(rh.DB),
+			Security: service.SecurityService{
+				Repo:     repository.NewSecurityRepository(rh.DB),
+				Notifier: rh.Notifier,
+			},
+			SecretKey:         rh.Config.AppSecret,
+			EnforceForSellers: rh.Config.SellerTwoFactor,
+		},
 	}
 	handler := UserHandler{
 		svc,
 	}
+	wishlistHandler := WishlistHandler{
+		service.WishlistService{
+			Repo:          repository.NewWishlistRepository(rh.DB),
+			CatalogClient: catalogClient,
+			Cart:          svc,
+		},
+	}
+	accountHandler := AccountHandler{
+		service.AccountService{
+			Repo:     repository.NewAccountRepository(rh.DB),
+			Users:    svc.Repo,
+			Auth:     authClient,
+			Invoices: svc.Invoices,
+			Outbox: service.OutboxService{
+				Repo:      repository.NewOutboxRepository(rh.DB),
+				Publisher: rh.Events,
+			},
+		},
+		svc,
+	}
+	twoFactorHandler := TwoFactorHandler{
+		svc,
+	}
+	oauthHandler := OAuthHandler{
+		service.OAuthService{
+			Repo:      repository.NewOAuthRepository(rh.DB),
+			Providers: rh.OAuthProviders,
+			Users:     svc,
+		},
+		rh.Config.OAuthSuccessRedirect,
+	}
 	publicRoutes := app.Group("/users")
 	//public endpoints
 	publicRoutes.Post("/register", handler.RegisterUser)
 	publicRoutes.Post("/login", handler.Login)
+	publicRoutes.Post("/login/2fa", twoFactorHandler.Login)
+	publicRoutes.Post("/token/refresh", handler.RefreshToken)
+	publicRoutes.Post("/login/2fa/enroll", twoFactorHandler.LoginEnroll)
+	publicRoutes.Get("/oauth/providers", oauthHandler.GetProviders)
+	publicRoutes.Get("/oauth/:provider/login", oauthHandler.Login)
+	publicRoutes.Get("/oauth/:provider/callback", oauthHandler.Callback)
 	publicRoutes.Get("/health", func(c *fiber.Ctx) error {
 		return c.JSON(fiber.Map{"status": "ok"})
 	})
-	privateRoutes := publicRoutes.Group("/", middleware.AuthorizeUser(authClient))
+
+	//guest cart endpoints
+	publicRoutes.Post("/guest/cart", handler.AddToGuestCart)
+	publicRoutes.Get("/guest/cart", handler.GetGuestCart)
+	publicRoutes.Put("/guest/cart/:productID", handler.UpdateProductQtyInGuestCart)
+	publicRoutes.Delete("/guest/cart/:productID", handler.RemoveProductFromGuestCart)
+	publicRoutes.Delete("/guest/cart", handler.ClearGuestCart)
+
+	publicRoutes.Get("/shipping-methods", handler.GetShippingMethods)
+
+	//shared wishlists are readable by anyone holding the link
+	publicRoutes.Get("/wishlists/shared/:token", wishlistHandler.GetSharedWishlist)
+
+	privateRoutes := publicRoutes.Group("/", rh.Auth.AuthorizeUser(), middleware.RejectInactiveUser(svc.CheckActive))
 	//private endpoints
+	privateRoutes.Post("/logout", handler.Logout)
+	privateRoutes.Post("/password", handler.ChangePassword)
+	privateRoutes.Get("/sessions", handler.Sessions)
+	privateRoutes.Delete("/sessions", handler.RevokeOtherSessions)
+	privateRoutes.Delete("/sessions/:id", handler.RevokeSession)
 	privateRoutes.Post("/verifyUser", handler.VerifyUser)
 	privateRoutes.Get("/verify", handler.GetVerificationCode)
 
@@ -44,15 +137,48 @@ func SetupUserRoutes(rh *rest.RestHandler, catalogClient *client.CatalogClient,
 	privateRoutes.Get("/profile", handler.GetUserProfile)
 	privateRoutes.Patch("/profile", handler.UpdateUserProfile)
 
+	privateRoutes.Get("/2fa", twoFactorHandler.GetStatus)
+	privateRoutes.Post("/2fa/enroll", twoFactorHandler.Enroll)
+	privateRoutes.Post("/2fa/confirm", twoFactorHandler.Confirm)
+	privateRoutes.Post("/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+	privateRoutes.Delete("/2fa", twoFactorHandler.Disable)
+
+	privateRoutes.Get("/account/export", accountHandler.ExportAccount)
+	privateRoutes.Delete("/account", accountHandler.DeleteAccount)
+
 	privateRoutes.Post("/cart", handler.AddToCart)
 	privateRoutes.Get("/cart", handler.GetCart)
-	privateRoutes.Put("/cart/:productId", handler.UpdateProductQtyInCart)
+	privateRoutes.Post("/cart/acknowledge", handler.AcknowledgeCartChanges)
+	privateRoutes.Get("/cart/shipping-options", handler.GetShippingOptions)
+	privateRoutes.Post("/cart/coupon", handler.ApplyCoupon)
+	privateRoutes.Delete("/cart/coupon", handler.RemoveCoupon)
+	privateRoutes.Put("/cart/:productID", handler.UpdateProductQtyInCart)
 	privateRoutes.Delete("/cart/:productID", handler.RemoveProductFromCart)
 	privateRoutes.Delete("/cart", handler.ClearCart)
+	privateRoutes.Post("/cart/:productID/save-for-later", wishlistHandler.SaveForLater)
+
+	privateRoutes.Get("/wishlists", wishlistHandler.GetWishlists)
+	privateRoutes.Post("/wishlists", wishlistHandler.CreateWishlist)
+	privateRoutes.Get("/wishlists/:id", wishlistHandler.GetWishlist)
+	privateRoutes.Patch("/wishlists/:id", wishlistHandler.UpdateWishlist)
+	privateRoutes.Delete("/wishlists/:id", wishlistHandler.DeleteWishlist)
+	privateRoutes.Post("/wishlists/:id/items", wishlistHandler.AddItem)
+	privateRoutes.Delete("/wishlists/:id/items/:productID", wishlistHandler.RemoveItem)
+	privateRoutes.Post("/wishlists/:id/items/:productID/move-to-cart", wishlistHandler.MoveItemToCart)
 
 	privateRoutes.Get("/order", handler.GetOrders)
 	privateRoutes.Get("/order/:id", handler.GetOrderByID)
-	privateRoutes.Post("/order", handler.CreateOrder)
+	privateRoutes.Get("/order/:id/invoice", handler.GetOrderInvoice)
+	privateRoutes.Get("/order/:id/credit-notes", handler.GetCreditNotes)
+	privateRoutes.Get("/order/:id/credit-notes/:creditNoteId", handler.GetCreditNote)
+	privateRoutes.Post("/order/:id/cancel", handler.CancelOrder)
+	privateRoutes.Post("/order/:id/reorder", handler.Reorder)
+	privateRoutes.Post("/order/:id/returns", handler.RequestReturn)
+	privateRoutes.Get("/returns", handler.GetReturnRequests)
+
+	//internal endpoints are called by other services with a service token, never by users
+	internalRoutes := app.Group("/internal")
+	internalRoutes.Post("/orders", rh.Auth.RequireScope(auth.SCOPE_USERS_ORDERS), handler.CreateOrder)
 }
 func (h *UserHandler) RegisterUser(ctx *fiber.Ctx) error {
 	user := dto.UserSignup{}
@@ -63,8 +189,11 @@ func (h *UserHandler) RegisterUser(ctx *fiber.Ctx) error {
 			"message": "Please provide valid inputs",
 		})
 	}
-	token, err := h.userService.UserSignup(user)
+	response, err := h.userService.UserSignup(user, clientInfo(ctx))
 
+	if errors.Is(err, client.ErrPasswordRefused) {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
 	if err != nil {
 		return ctx.Status(http.StatusInternalServerError).JSON(&fiber.Map{
 			"message": "User signup failed",
@@ -72,8 +201,10 @@ func (h *UserHandler) RegisterUser(ctx *fiber.Ctx) error {
 	}
 
 	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
-		"message": user.Email,
-		"token":   token,
+		"message":       user.Email,
+		"token":         response.Token,
+		"refresh_token": response.RefreshToken,
+		"expires_in":    response.ExpiresIn,
 	})
 }
 func (h *UserHandler) Login(ctx *fiber.Ctx) error {
@@ -85,18 +216,126 @@ func (h *UserHandler) Login(ctx *fiber.Ctx) error {
 			"message": "Please provide valid inputs",
 		})
 	}
-	token, err := h.userService.Login(loginInput.Email, loginInput.Password)
+	response, err := h.userService.Login(loginInput, clientInfo(ctx))
+	return loginResponse(ctx, loginInput.Email, response, err)
+}
+
+func (h *UserHandler) RefreshToken(ctx *fiber.Ctx) error {
+	req := dto.RefreshTokenInput{}
+	if err := ctx.BodyParser(&req); err != nil || req.RefreshToken == "" {
+		return rest.BadRequestErrorResponse(ctx, "Please provide the refresh token")
+	}
+
+	response, err := h.userService.RefreshToken(req.RefreshToken, clientInfo(ctx))
+	return loginResponse(ctx, "token refreshed", response, err)
+}
 
+func (h *UserHandler) Logout(ctx *fiber.Ctx) error {
+	req := dto.LogoutInput{}
+	if len(ctx.Body()) > 0 {
+		if err := ctx.BodyParser(&req); err != nil {
+			return rest.BadRequestErrorResponse(ctx, "Please provide valid inputs")
+		}
+	}
+
+	err := h.userService.Logout(ctx.Get(fiber.HeaderAuthorization), req)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "logged out", nil)
+}
+
+func (h *UserHandler) ChangePassword(ctx *fiber.Ctx) error {
+	req := dto.ChangePasswordInput{}
+	if err := ctx.BodyParser(&req); err != nil || req.CurrentPassword == "" || req.NewPassword == "" {
+		return rest.BadRequestErrorResponse(ctx, "Please provide the current and the new password")
+	}
+
+	user := h.userService.GetCurrentUser(ctx)
+	err := h.userService.ChangePassword(user.ID, req, ctx.Get(fiber.HeaderAuthorization), clientInfo(ctx))
+	var throttled service.LoginThrottledError
+	if errors.As(err, &throttled) {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+		return rest.ErrorResponse(ctx, http.StatusTooManyRequests, err)
+	}
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "password changed, other devices have been signed out", nil)
+}
+
+func (h *UserHandler) Sessions(ctx *fiber.Ctx) error {
+	sessions, err := h.userService.Sessions(ctx.Get(fiber.HeaderAuthorization))
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "sessions", sessions)
+}
+
+func (h *UserHandler) RevokeSession(ctx *fiber.Ctx) error {
+	err := h.userService.RevokeSession(ctx.Get(fiber.HeaderAuthorization), ctx.Params("id"))
+	if errors.Is(err, client.ErrSessionNotFound) {
+		return rest.ErrorResponse(ctx, http.StatusNotFound, err)
+	}
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "signed out of session", nil)
+}
+
+func (h *UserHandler) RevokeOtherSessions(ctx *fiber.Ctx) error {
+	revoked, err := h.userService.RevokeOtherSessions(ctx.Get(fiber.HeaderAuthorization))
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "signed out of other sessions", fiber.Map{"revoked": revoked})
+}
+
+// loginResponse answers every sign in step, a challenge token means another step is needed
+func loginResponse(ctx *fiber.Ctx, message string, response dto.LoginResponse, err error) error {
+	var throttled service.LoginThrottledError
+	if errors.As(err, &throttled) {
+		ctx.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(throttled.RetryAfter.Seconds())+1))
+		return ctx.Status(http.StatusTooManyRequests).JSON(&fiber.Map{
+			"message": err.Error(),
+		})
+	}
 	if err != nil {
 		return ctx.Status(http.StatusUnauthorized).JSON(&fiber.Map{
 			"message": err.Error(),
 		})
 	}
-	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
-		"message": loginInput.Email,
-		"token":   token,
-	})
+
+	if response.ChallengeToken != "" {
+		return ctx.Status(http.StatusOK).JSON(&fiber.Map{
+			"message":         "two factor authentication required",
+			"challenge_token": response.ChallengeToken,
+			"two_factor":      response.TwoFactor,
+		})
+	}
+	body := fiber.Map{
+		"message":       message,
+		"token":         response.Token,
+		"refresh_token": response.RefreshToken,
+		"expires_in":    response.ExpiresIn,
+	}
+	if len(response.RecoveryCodes) > 0 {
+		body["recovery_codes"] = response.RecoveryCodes
+	}
+	return ctx.Status(http.StatusOK).JSON(&body)
+}
+
+// clientIP is the address the request came from, forwarded addresses are only used when a trusted
+// proxy sent the request, see the fiber config in StartServer
+func clientIP(ctx *fiber.Ctx) string {
+	return ctx.IP()
+}
+
+// clientInfo describes the device a sign in comes from so it can be shown in the session list
+func clientInfo(ctx *fiber.Ctx) dto.ClientInfo {
+	return dto.ClientInfo{IP: clientIP(ctx), UserAgent: ctx.Get(fiber.HeaderUserAgent)}
 }
+
 func (h *UserHandler) VerifyUser(ctx *fiber.Ctx) error {
 	user := h.userService.GetCurrentUser(ctx)
 
@@ -197,13 +436,66 @@ func (h *UserHandler) CreateUserProfile(ctx *fiber.Ctx) error {
 }
 func (h *UserHandler) GetCart(ctx *fiber.Ctx) error {
 	user := h.userService.GetCurrentUser(ctx)
-	cart, _, err := h.userService.FindCart(user.ID)
+	shippingMethod := ctx.Query("shipping_method")
+	cart, err := h.userService.FindCart(user.ID, shippingMethod)
 	if err != nil {
+		if shippingMethod != "" {
+			return rest.BadRequestErrorResponse(ctx, err.Error())
+		}
 		return rest.InternalErrorResponse(ctx, errors.New("unable to fetch cart"))
 	}
 	return rest.SuccessResponse(ctx, "cart found for user", cart)
 }
 
+func (h *UserHandler) GetShippingOptions(ctx *fiber.Ctx) error {
+	user := h.userService.GetCurrentUser(ctx)
+	quotes, err := h.userService.GetShippingOptions(user.ID)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, errors.New("unable to quote shipping"))
+	}
+	return rest.SuccessResponse(ctx, "shipping options", quotes)
+}
+
+func (h *UserHandler) GetShippingMethods(ctx *fiber.Ctx) error {
+	methods, err := h.userService.Shipping.GetShippingMethods()
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, errors.New("unable to fetch shipping methods"))
+	}
+	return rest.SuccessResponse(ctx, "shipping methods", methods)
+}
+
+func (h *UserHandler) AcknowledgeCartChanges(ctx *fiber.Ctx) error {
+	user := h.userService.GetCurrentUser(ctx)
+	cart, err := h.userService.AcknowledgeCartChanges(user.ID)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "cart price changes acknowledged", cart)
+}
+
+func (h *UserHandler) ApplyCoupon(ctx *fiber.Ctx) error {
+	req := dto.ApplyCouponRequest{}
+	if err := ctx.BodyParser(&req); err != nil || req.Code == "" {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a coupon code")
+	}
+
+	user := h.userService.GetCurrentUser(ctx)
+	cart, err := h.userService.ApplyCoupon(user.ID, req.Code)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "coupon applied", cart)
+}
+
+func (h *UserHandler) RemoveCoupon(ctx *fiber.Ctx) error {
+	user := h.userService.GetCurrentUser(ctx)
+	cart, err := h.userService.RemoveCoupon(user.ID)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "coupon removed", cart)
+}
+
 func (h *UserHandler) AddToCart(ctx *fiber.Ctx) error {
 	req := dto.CreateCartRequest{}
 	err := ctx.BodyParser(&req)
@@ -268,9 +560,101 @@ func (h *UserHandler) ClearCart(ctx *fiber.Ctx) error {
 
 	return rest.SuccessResponse(ctx, "cart cleared", nil)
 }
+func (h *UserHandler) AddToGuestCart(ctx *fiber.Ctx) error {
+	req := dto.CreateCartRequest{}
+	err := ctx.BodyParser(&req)
+
+	if err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(&fiber.Map{
+			"message": "Please provide valid product and quantity",
+		})
+	}
+
+	cartItems, token, err := h.userService.CreateGuestCart(ctx.Get(cartTokenHeader), req)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+
+	return ctx.Status(http.StatusOK).JSON(&fiber.Map{
+		"message":    "cart created",
+		"data":       cartItems,
+		"cart_token": token,
+	})
+}
+
+func (h *UserHandler) GetGuestCart(ctx *fiber.Ctx) error {
+	token := ctx.Get(cartTokenHeader)
+	if token == "" {
+		return rest.BadRequestErrorResponse(ctx, "missing cart token")
+	}
+
+	cart, err := h.userService.FindGuestCart(token)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, errors.New("unable to fetch cart"))
+	}
+	return rest.SuccessResponse(ctx, "cart found for guest", cart)
+}
+
+func (h *UserHandler) UpdateProductQtyInGuestCart(ctx *fiber.Ctx) error {
+	token := ctx.Get(cartTokenHeader)
+	if token == "" {
+		return rest.BadRequestErrorResponse(ctx, "missing cart token")
+	}
+
+	productID, _ := strconv.Atoi(ctx.Params("productID"))
+	req := dto.UpdateCartRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return ctx.Status(http.StatusBadRequest).JSON(&fiber.Map{
+			"message": "Please provide valid product and quantity",
+		})
+	}
+
+	err := h.userService.UpdateProductQtyInGuestCart(token, uint(productID), req.Qty)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+
+	return rest.SuccessResponse(ctx, "cart updated", nil)
+}
+
+func (h *UserHandler) RemoveProductFromGuestCart(ctx *fiber.Ctx) error {
+	token := ctx.Get(cartTokenHeader)
+	if token == "" {
+		return rest.BadRequestErrorResponse(ctx, "missing cart token")
+	}
+
+	productID, _ := strconv.Atoi(ctx.Params("productID"))
+	err := h.userService.RemoveProductFromGuestCart(token, uint(productID))
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+
+	return rest.SuccessResponse(ctx, "product removed from cart", nil)
+}
+
+func (h *UserHandler) ClearGuestCart(ctx *fiber.Ctx) error {
+	token := ctx.Get(cartTokenHeader)
+	if token == "" {
+		return rest.BadRequestErrorResponse(ctx, "missing cart token")
+	}
+
+	err := h.userService.ClearGuestCart(token)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+
+	return rest.SuccessResponse(ctx, "cart cleared", nil)
+}
+
 func (h *UserHandler) GetOrders(ctx *fiber.Ctx) error {
 	tokenUser := h.userService.GetCurrentUser(ctx)
-	orders, err := h.userService.GetOrders(tokenUser.ID)
+
+	filter, err := parseOrderFilter(ctx)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	orders, err := h.userService.GetOrders(tokenUser.ID, filter)
 	if err != nil {
 		return rest.InternalErrorResponse(ctx, errors.New("unable to fetch orders"))
 	}
@@ -278,6 +662,45 @@ func (h *UserHandler) GetOrders(ctx *fiber.Ctx) error {
 	return rest.SuccessResponse(ctx, "orders found for user", orders)
 }
 
+func parseOrderFilter(ctx *fiber.Ctx) (dto.OrderFilter, error) {
+	filter := dto.OrderFilter{
+		Status:   ctx.Query("status"),
+		Search:   strings.TrimSpace(ctx.Query("q")),
+		Page:     ctx.QueryInt("page", 1),
+		PageSize: ctx.QueryInt("page_size", defaultOrderPageSize),
+	}
+
+	switch filter.Status {
+	case "", domain.ORDER_COMPLETED, domain.ORDER_PARTIALLY_SHIPPED, domain.ORDER_SHIPPED, domain.ORDER_DELIVERED, domain.ORDER_CANCELLING, domain.ORDER_CANCELLED:
+	default:
+		return dto.OrderFilter{}, errors.New("status must be completed, partially_shipped, shipped, delivered, cancellation_pending or cancelled")
+	}
+
+	if filter.Page < 1 {
+		return dto.OrderFilter{}, errors.New("page must be a positive number")
+	}
+	if filter.PageSize < 1 || filter.PageSize > maxOrderPageSize {
+		return dto.OrderFilter{}, fmt.Errorf("page_size must be between 1 and %d", maxOrderPageSize)
+	}
+
+	if from := ctx.Query("from"); from != "" {
+		t, err := time.Parse(reportDateLayout, from)
+		if err != nil {
+			return dto.OrderFilter{}, errors.New("from must be formatted as YYYY-MM-DD")
+		}
+		filter.From = t
+	}
+
+	if to := ctx.Query("to"); to != "" {
+		t, err := time.Parse(reportDateLayout, to)
+		if err != nil {
+			return dto.OrderFilter{}, errors.New("to must be formatted as YYYY-MM-DD")
+		}
+		filter.To = t.AddDate(0, 0, 1)
+	}
+	return filter, nil
+}
+
 func (h *UserHandler) GetOrderByID(ctx *fiber.Ctx) error {
 	orderId, _ := strconv.Atoi(ctx.Params("id"))
 	user := h.userService.GetCurrentUser(ctx)
@@ -289,6 +712,47 @@ func (h *UserHandler) GetOrderByID(ctx *fiber.Ctx) error {
 	return rest.SuccessResponse(ctx, "orders found for user", order)
 }
 
+func (h *UserHandler) GetOrderInvoice(ctx *fiber.Ctx) error {
+	orderId, _ := strconv.Atoi(ctx.Params("id"))
+	user := h.userService.GetCurrentUser(ctx)
+
+	invoice, pdf, err := h.userService.GetOrderInvoice(uint(orderId), user.ID)
+	if err != nil {
+		return rest.ErrorResponse(ctx, http.StatusNotFound, err)
+	}
+	return sendPDF(ctx, invoice.Number, pdf)
+}
+
+func (h *UserHandler) GetCreditNotes(ctx *fiber.Ctx) error {
+	orderId, _ := strconv.Atoi(ctx.Params("id"))
+	user := h.userService.GetCurrentUser(ctx)
+
+	creditNotes, err := h.userService.GetCreditNotes(uint(orderId), user.ID)
+	if err != nil {
+		return rest.ErrorResponse(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "credit notes found for order", creditNotes)
+}
+
+func (h *UserHandler) GetCreditNote(ctx *fiber.Ctx) error {
+	orderId, _ := strconv.Atoi(ctx.Params("id"))
+	creditNoteId, _ := strconv.Atoi(ctx.Params("creditNoteId"))
+	user := h.userService.GetCurrentUser(ctx)
+
+	creditNote, pdf, err := h.userService.GetCreditNote(uint(orderId), uint(creditNoteId), user.ID)
+	if err != nil {
+		return rest.ErrorResponse(ctx, http.StatusNotFound, err)
+	}
+	return sendPDF(ctx, creditNote.Number, pdf)
+}
+
+func sendPDF(ctx *fiber.Ctx, name string, pdf []byte) error {
+	ctx.Set(fiber.HeaderContentType, "application/pdf")
+	ctx.Set(fiber.HeaderContentDisposition, fmt.Sprintf("attachment; filename=\"%s.pdf\"", name))
+	return ctx.Status(http.StatusOK).Send(pdf)
+}
+
+// CreateOrder records an order the transactions service has taken payment for
 func (h *UserHandler) CreateOrder(ctx *fiber.Ctx) error {
 	var request dto.CreateOrderRequest
 	if err := ctx.BodyParser(&request); err != nil {
@@ -300,14 +764,6 @@ func (h *UserHandler) CreateOrder(ctx *fiber.Ctx) error {
 		return rest.ErrorResponse(ctx, http.StatusBadRequest, errors.New("missing required order information"))
 	}
 
-	// Use the token user for authorization check
-	tokenUser := h.userService.GetCurrentUser(ctx)
-
-	// Additional security check - ensure the order is for the authorized user
-	if tokenUser.ID != request.UserID && tokenUser.UserRole != "seller" {
-		return rest.ErrorResponse(ctx, http.StatusForbidden, errors.New("unauthorized to create order for this user"))
-	}
-
 	// Create the order through service layer
 	err := h.userService.CreateOrder(request)
 	if err != nil {
@@ -326,6 +782,60 @@ func (h *UserHandler) CreateOrder(ctx *fiber.Ctx) error {
 	})
 }
 
+func (h *UserHandler) CancelOrder(ctx *fiber.Ctx) error {
+	orderId, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.CancelOrderRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a valid cancellation reason")
+	}
+
+	user := h.userService.GetCurrentUser(ctx)
+	err := h.userService.CancelOrder(uint(orderId), user.ID, req.Reason)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "order cancelled", nil)
+}
+
+func (h *UserHandler) Reorder(ctx *fiber.Ctx) error {
+	orderId, _ := strconv.Atoi(ctx.Params("id"))
+	user := h.userService.GetCurrentUser(ctx)
+
+	result, err := h.userService.Reorder(uint(orderId), user)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "order items added to cart", result)
+}
+
+func (h *UserHandler) RequestReturn(ctx *fiber.Ctx) error {
+	orderId, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.CreateReturnRequest{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a valid order item, quantity and reason")
+	}
+
+	user := h.userService.GetCurrentUser(ctx)
+	returnRequest, err := h.userService.RequestReturn(uint(orderId), user.ID, req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+
+	return rest.SuccessResponse(ctx, "return requested", returnRequest)
+}
+
+func (h *UserHandler) GetReturnRequests(ctx *fiber.Ctx) error {
+	user := h.userService.GetCurrentUser(ctx)
+	returnRequests, err := h.userService.GetReturnRequests(user.ID)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, errors.New("unable to fetch return requests"))
+	}
+
+	return rest.SuccessResponse(ctx, "return requests found for user", returnRequests)
+}
+
 //func (h *UserHandler) becomeSeller(ctx *fiber.Ctx) error {
 //	user := h.userService.GetCurrentUser(ctx)
 //	req := dto.SellerInput{}
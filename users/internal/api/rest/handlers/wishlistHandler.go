@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"github.com/sharat789/zamazon-be-ms/users/internal/api/rest"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/service"
+	"net/http"
+	"strconv"
+)
+
+type WishlistHandler struct {
+	wishlistService service.WishlistService
+}
+
+func (h *WishlistHandler) GetWishlists(ctx *fiber.Ctx) error {
+	user := h.wishlistService.Cart.GetCurrentUser(ctx)
+	wishlists, err := h.wishlistService.GetWishlists(user.ID)
+	if err != nil {
+		return rest.InternalErrorResponse(ctx, err)
+	}
+	return rest.SuccessResponse(ctx, "wishlists found", wishlists)
+}
+
+func (h *WishlistHandler) CreateWishlist(ctx *fiber.Ctx) error {
+	req := dto.WishlistInput{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a valid wishlist name")
+	}
+
+	user := h.wishlistService.Cart.GetCurrentUser(ctx)
+	wishlist, err := h.wishlistService.CreateWishlist(user.ID, req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "wishlist created", wishlist)
+}
+
+func (h *WishlistHandler) GetWishlist(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	user := h.wishlistService.Cart.GetCurrentUser(ctx)
+
+	wishlist, err := h.wishlistService.GetWishlist(uint(id), user.ID)
+	if err != nil {
+		return rest.ErrorResponse(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "wishlist found", wishlist)
+}
+
+func (h *WishlistHandler) GetSharedWishlist(ctx *fiber.Ctx) error {
+	wishlist, err := h.wishlistService.GetSharedWishlist(ctx.Params("token"))
+	if err != nil {
+		return rest.ErrorResponse(ctx, http.StatusNotFound, err)
+	}
+	return rest.SuccessResponse(ctx, "wishlist found", wishlist)
+}
+
+func (h *WishlistHandler) UpdateWishlist(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.WishlistInput{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide valid inputs")
+	}
+
+	user := h.wishlistService.Cart.GetCurrentUser(ctx)
+	wishlist, err := h.wishlistService.UpdateWishlist(uint(id), user.ID, req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "wishlist updated", wishlist)
+}
+
+func (h *WishlistHandler) DeleteWishlist(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	user := h.wishlistService.Cart.GetCurrentUser(ctx)
+
+	err := h.wishlistService.DeleteWishlist(uint(id), user.ID)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "wishlist deleted", nil)
+}
+
+func (h *WishlistHandler) AddItem(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	req := dto.WishlistItemInput{}
+	if err := ctx.BodyParser(&req); err != nil {
+		return rest.BadRequestErrorResponse(ctx, "Please provide a valid product")
+	}
+
+	user := h.wishlistService.Cart.GetCurrentUser(ctx)
+	wishlist, err := h.wishlistService.AddItem(uint(id), user.ID, req)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "item added to wishlist", wishlist)
+}
+
+func (h *WishlistHandler) RemoveItem(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	productID, err := strconv.Atoi(ctx.Params("productID"))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, "invalid product id")
+	}
+
+	user := h.wishlistService.Cart.GetCurrentUser(ctx)
+	err = h.wishlistService.RemoveItem(uint(id), user.ID, uint(productID))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "item removed from wishlist", nil)
+}
+
+func (h *WishlistHandler) MoveItemToCart(ctx *fiber.Ctx) error {
+	id, _ := strconv.Atoi(ctx.Params("id"))
+	productID, err := strconv.Atoi(ctx.Params("productID"))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, "invalid product id")
+	}
+
+	user := h.wishlistService.Cart.GetCurrentUser(ctx)
+	cart, err := h.wishlistService.MoveItemToCart(uint(id), uint(productID), user)
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "item moved to cart", cart)
+}
+
+func (h *WishlistHandler) SaveForLater(ctx *fiber.Ctx) error {
+	productID, err := strconv.Atoi(ctx.Params("productID"))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, "invalid product id")
+	}
+
+	user := h.wishlistService.Cart.GetCurrentUser(ctx)
+	wishlist, err := h.wishlistService.SaveForLater(user.ID, uint(productID))
+	if err != nil {
+		return rest.BadRequestErrorResponse(ctx, err.Error())
+	}
+	return rest.SuccessResponse(ctx, "item saved for later", wishlist)
+}
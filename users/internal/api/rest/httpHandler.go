@@ -2,12 +2,26 @@ package rest
 
 import (
 	"github.com/gofiber/fiber/v2"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 	"github.com/sharat789/zamazon-be-ms/users/configs"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/blob"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/events"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/notification"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/oauth"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/tax"
 	"gorm.io/gorm"
 )
 
 type RestHandler struct {
-	App    *fiber.App
-	DB     *gorm.DB
-	Config configs.AppConfig
+	App           *fiber.App
+	DB            *gorm.DB
+	Config        configs.AppConfig
+	TaxCalculator tax.TaxCalculator
+	BlobStore     blob.BlobStore
+	Events        events.Publisher
+	Notifier      notification.Notifier
+	// OAuthProviders are the social login providers keyed by the name used in their routes
+	OAuthProviders map[string]oauth.Provider
+	// Auth verifies access tokens locally against the keys the auth service publishes
+	Auth *auth.Verifier
 }
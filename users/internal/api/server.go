@@ -5,19 +5,35 @@ import (
 	"github.com/gofiber/fiber/v2/middleware/adaptor"
 	"github.com/gofiber/fiber/v2/middleware/cors"
 	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 	"github.com/sharat789/zamazon-be-ms/metrics"
 	"github.com/sharat789/zamazon-be-ms/users/configs"
 	"github.com/sharat789/zamazon-be-ms/users/internal/api/rest"
 	"github.com/sharat789/zamazon-be-ms/users/internal/api/rest/handlers"
 	"github.com/sharat789/zamazon-be-ms/users/internal/client"
 	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"github.com/sharat789/zamazon-be-ms/users/internal/service"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/blob"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/events"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/notification"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/oauth"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/tax"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"log"
+	"time"
 )
 
 func StartServer(cfg configs.AppConfig) {
-	app := fiber.New()
+	// the client address is taken from X-Forwarded-For only when the ingress set it, anyone else
+	// could put any address there to get around rate limits and lockouts
+	app := fiber.New(fiber.Config{
+		ProxyHeader:             fiber.HeaderXForwardedFor,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          cfg.TrustedProxies,
+		EnableIPValidation:      true,
+	})
 	app.Use(metrics.PrometheusMiddleware())
 	db, err := gorm.Open(postgres.Open(cfg.DataSourceName), &gorm.Config{})
 	app.Get("/metrics", adaptor.HTTPHandler(promhttp.Handler()))
@@ -31,6 +47,26 @@ func StartServer(cfg configs.AppConfig) {
 		&domain.Cart{},
 		&domain.Order{},
 		&domain.OrderItem{},
+		&domain.SubOrder{},
+		&domain.OrderStatusHistory{},
+		&domain.ReturnRequest{},
+		&domain.Wishlist{},
+		&domain.WishlistItem{},
+		&domain.Coupon{},
+		&domain.CouponRedemption{},
+		&domain.CartCoupon{},
+		&domain.ShippingMethod{},
+		&domain.ShippingRate{},
+		&domain.Invoice{},
+		&domain.SecurityEvent{},
+		&domain.LoginThrottle{},
+		&domain.TwoFactor{},
+		&domain.RecoveryCode{},
+		&domain.LoginChallenge{},
+		&domain.UserIdentity{},
+		&domain.OAuthState{},
+		&domain.RoleGrant{},
+		&domain.OutboxEvent{},
 	)
 
 	if err != nil {
@@ -39,26 +75,75 @@ func StartServer(cfg configs.AppConfig) {
 
 	log.Println("migration successful")
 
+	shippingService := service.ShippingService{Repo: repository.NewShippingRepository(db)}
+	if err = shippingService.SeedDefaults(); err != nil {
+		log.Fatalf("error seeding shipping methods %v", err)
+	}
+
+	adminService := service.AdminService{Repo: repository.NewAdminRepository(db)}
+	if err = adminService.SeedAdmins(cfg.AdminEmails); err != nil {
+		log.Fatalf("error seeding admins %v", err)
+	}
+
 	c := cors.New(cors.Config{
 		AllowOrigins: "http://localhost:4200, http://localhost:3030/",
-		AllowHeaders: "Content-Type, Accept, Authorization",
+		AllowHeaders: "Content-Type, Accept, Authorization, X-Cart-Token",
 		AllowMethods: "GET, POST, PUT, PATCH, DELETE, OPTIONS",
 	})
 
 	app.Use(c)
 
-	catalogClient := client.NewCatalogClient(cfg.CatalogURL)
-	authClient := client.NewAuthClient(cfg.AuthURL)
+	// one service token covers every internal route this service calls
+	serviceTokens := auth.NewServiceTokenSource(cfg.AuthURL+"/auth/token", cfg.ServiceClientID, cfg.ServiceClientSecret, auth.SCOPE_AUTH_PASSWORDS, auth.SCOPE_AUTH_TOKENS, auth.SCOPE_CATALOG_STOCK, auth.SCOPE_TRANSACTIONS_REFUNDS, auth.SCOPE_TRANSACTIONS_EVENTS)
+	catalogClient := client.NewCatalogClient(cfg.CatalogURL, serviceTokens)
+	authClient := client.NewAuthClient(cfg.AuthURL, serviceTokens)
+	transactionsClient := client.NewTransactionsClient(cfg.TransactionsURL, serviceTokens)
+	taxCalculator := tax.NewTaxCalculator(cfg.TaxCountry)
+	notifier := notification.NewLogNotifier()
+	if cfg.SMTPAddr != "" {
+		notifier = notification.NewSMTPNotifier(cfg.SMTPAddr, cfg.SMTPFrom, cfg.SMTPUsername, cfg.SMTPPassword)
+	}
+	verifier, err := auth.NewVerifier(auth.Config{
+		JWKSURL:  cfg.AuthURL + "/.well-known/jwks.json",
+		Issuer:   auth.ISSUER,
+		Audience: auth.AUDIENCE_USERS,
+	})
+	if err != nil {
+		log.Fatalf("error setting up token verification %v", err)
+	}
 	rh := &rest.RestHandler{
-		app,
-		db,
-		cfg,
+		App:            app,
+		DB:             db,
+		Config:         cfg,
+		TaxCalculator:  taxCalculator,
+		BlobStore:      blob.NewLocalBlobStore(cfg.BlobDir),
+		Events:         events.NewHTTPPublisher(cfg.EventEndpoints, serviceTokens),
+		Notifier:       notifier,
+		OAuthProviders: oauthProviders(cfg),
+		Auth:           verifier,
 	}
 
-	SetupRoutes(rh, catalogClient, authClient)
+	outbox := service.OutboxService{Repo: repository.NewOutboxRepository(db), Publisher: rh.Events}
+	outbox.Start(15 * time.Second)
+
+	SetupRoutes(rh, catalogClient, authClient, transactionsClient)
 	app.Listen(cfg.Port)
 }
 
-func SetupRoutes(rh *rest.RestHandler, catalogClient *client.CatalogClient, authClient *client.AuthClient) {
-	handlers.SetupUserRoutes(rh, catalogClient, authClient)
+// oauthProviders enables the social login providers that have a client configured
+func oauthProviders(cfg configs.AppConfig) map[string]oauth.Provider {
+	providers := map[string]oauth.Provider{}
+	if cfg.GoogleClientID != "" {
+		providers["google"] = oauth.NewOIDCProvider("google", cfg.GoogleIssuer, cfg.GoogleClientID, cfg.GoogleClientSecret, cfg.OAuthCallbackURL+"/google/callback")
+	}
+	if cfg.GitHubClientID != "" {
+		providers["github"] = oauth.NewGitHubProvider("github", cfg.GitHubURL, cfg.GitHubAPIURL, cfg.GitHubClientID, cfg.GitHubClientSecret, cfg.OAuthCallbackURL+"/github/callback")
+	}
+	return providers
+}
+
+func SetupRoutes(rh *rest.RestHandler, catalogClient *client.CatalogClient, authClient *client.AuthClient, transactionsClient *client.TransactionsClient) {
+	handlers.SetupUserRoutes(rh, catalogClient, authClient, transactionsClient)
+	handlers.SetupSellerRoutes(rh, catalogClient, authClient, transactionsClient)
+	handlers.SetupAdminRoutes(rh, authClient)
 }
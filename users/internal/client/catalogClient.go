@@ -1,21 +1,27 @@
 package client
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
 	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
-	"log"
 	"net/http"
 )
 
+var ErrProductNotFound = errors.New("product not found in catalog service")
+
 type CatalogClient struct {
 	BaseURL string
+	// Tokens authenticate this service on the catalog service's internal routes
+	Tokens *auth.ServiceTokenSource
 }
 
-func NewCatalogClient(baseURL string) *CatalogClient {
+func NewCatalogClient(baseURL string, tokens *auth.ServiceTokenSource) *CatalogClient {
 	return &CatalogClient{
 		BaseURL: baseURL,
+		Tokens:  tokens,
 	}
 }
 
@@ -28,6 +34,9 @@ func (c *CatalogClient) GetProductByID(productID uint) (*dto.ProductResponse, er
 	}
 	defer resp.Body.Close()
 
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrProductNotFound
+	}
 	if resp.StatusCode != http.StatusOK {
 		return nil, errors.New("failed to get product from catalog service")
 	}
@@ -39,6 +48,41 @@ func (c *CatalogClient) GetProductByID(productID uint) (*dto.ProductResponse, er
 	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
 		return nil, err
 	}
-	log.Println(response.Data)
+	if response.Data == nil {
+		return nil, ErrProductNotFound
+	}
 	return response.Data, nil
 }
+
+// AdjustStock changes the stock of a product by delta, negative values reserve stock and positive values release it
+func (c *CatalogClient) AdjustStock(productID uint, delta int) error {
+	requestBody, err := json.Marshal(map[string]int{
+		"delta": delta,
+	})
+	if err != nil {
+		return err
+	}
+
+	token, err := c.Tokens.Token()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/internal/products/%d/stock", c.BaseURL, productID), bytes.NewBuffer(requestBody))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("failed to adjust stock for product %d: %d", productID, resp.StatusCode)
+	}
+	return nil
+}
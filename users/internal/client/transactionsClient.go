@@ -0,0 +1,64 @@
+package client
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"net/http"
+)
+
+type TransactionsClient struct {
+	BaseURL string
+	// Tokens authenticate this service on the transactions service's internal routes
+	Tokens *auth.ServiceTokenSource
+}
+
+func NewTransactionsClient(baseURL string, tokens *auth.ServiceTokenSource) *TransactionsClient {
+	return &TransactionsClient{
+		BaseURL: baseURL,
+		Tokens:  tokens,
+	}
+}
+
+// RefundPayment asks the transactions service to refund part or all of a captured payment,
+// it is only called once a cancellation or a return has been accepted
+func (c *TransactionsClient) RefundPayment(request dto.RefundRequest) (*dto.RefundResponse, error) {
+	token, err := c.Tokens.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	requestBody, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, fmt.Sprintf("%s/internal/refunds", c.BaseURL), bytes.NewBuffer(requestBody))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to refund payment: %d", resp.StatusCode)
+	}
+
+	var response struct {
+		Data dto.RefundResponse `json:"data"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return nil, err
+	}
+
+	return &response.Data, nil
+}
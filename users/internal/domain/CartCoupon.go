@@ -0,0 +1,11 @@
+package domain
+
+import "time"
+
+// CartCoupon is the coupon a user applied to their cart, a cart carries at most one coupon
+type CartCoupon struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex;"`
+	CouponID  uint      `json:"coupon_id"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
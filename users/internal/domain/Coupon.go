@@ -0,0 +1,35 @@
+package domain
+
+import "time"
+
+const (
+	COUPON_PERCENTAGE = "percentage"
+	COUPON_FIXED      = "fixed"
+	COUPON_BXGY       = "bxgy"
+)
+
+// Coupon is a promotion code, coupons with a SellerID only discount that seller's items,
+// coupons without one apply platform wide
+type Coupon struct {
+	ID          uint   `json:"id" gorm:"PrimaryKey"`
+	Code        string `json:"code" gorm:"uniqueIndex;not null"`
+	Description string `json:"description"`
+	SellerID    uint   `json:"seller_id" gorm:"index;"`
+	Type        string `json:"type"`
+	// Value is the percentage or fixed amount off, for buy-x-get-y it is the percentage off the free items
+	Value        float64    `json:"value"`
+	MaxDiscount  float64    `json:"max_discount"`
+	MinSpend     float64    `json:"min_spend"`
+	BuyQty       uint       `json:"buy_qty"`
+	GetQty       uint       `json:"get_qty"`
+	ProductIDs   []uint     `json:"product_ids" gorm:"serializer:json"`
+	CategoryIDs  []uint     `json:"category_ids" gorm:"serializer:json"`
+	UsageLimit   uint       `json:"usage_limit"`
+	PerUserLimit uint       `json:"per_user_limit"`
+	UsedCount    uint       `json:"used_count" gorm:"default:0"`
+	StartsAt     *time.Time `json:"starts_at"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	Active       bool       `json:"active"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"default:current_timestamp"`
+}
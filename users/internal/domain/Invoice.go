@@ -0,0 +1,27 @@
+package domain
+
+import "time"
+
+const (
+	INVOICE     = "invoice"
+	CREDIT_NOTE = "credit_note"
+)
+
+// Invoice is an issued invoice or credit note, the rendered PDF is kept in the blob store under BlobKey
+type Invoice struct {
+	ID              uint      `json:"id" gorm:"PrimaryKey"`
+	Number          string    `json:"number" gorm:"index;"`
+	Type            string    `json:"type"`
+	OrderID         uint      `json:"order_id" gorm:"index;"`
+	UserID          uint      `json:"user_id" gorm:"index;"`
+	InvoiceID       uint      `json:"invoice_id"`
+	ReturnRequestID uint      `json:"return_request_id"`
+	PaymentId       string    `json:"payment_id"`
+	RefundId        string    `json:"refund_id"`
+	TotalExclTax    float64   `json:"total_excl_tax"`
+	Tax             float64   `json:"tax"`
+	Total           float64   `json:"total"`
+	BlobKey         string    `json:"-"`
+	CreatedAt       time.Time `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt       time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+}
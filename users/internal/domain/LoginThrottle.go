@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// LoginThrottle counts recent failed sign ins for an account or an ip address
+type LoginThrottle struct {
+	ID            uint       `json:"id" gorm:"PrimaryKey"`
+	Key           string     `json:"key" gorm:"uniqueIndex;not null"`
+	Failures      uint       `json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until"`
+}
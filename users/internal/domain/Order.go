@@ -2,15 +2,35 @@ package domain
 
 import "time"
 
+const (
+	ORDER_COMPLETED         = "completed"
+	ORDER_PARTIALLY_SHIPPED = "partially_shipped"
+	ORDER_SHIPPED           = "shipped"
+	ORDER_DELIVERED         = "delivered"
+	ORDER_CANCELLED         = "cancelled"
+	// ORDER_CANCELLING is a cancellation whose refund has been asked for but not confirmed yet
+	ORDER_CANCELLING = "cancellation_pending"
+)
+
 type Order struct {
-	ID             uint        `json:"id" gorm:"PrimaryKey"`
-	UserID         uint        `json:"user_id"`
-	Status         string      `json:"status"`
-	Amount         float64     `json:"amount"`
-	TransactionId  string      `json:"transaction_id"`
-	OrderRefNumber string      `json:"order_ref_number"`
-	PaymentId      string      `json:"payment_id"`
-	Items          []OrderItem `json:"items"`
-	CreatedAt      time.Time   `json:"created_at" gorm:"default:current_timestamp"`
-	UpdatedAt      time.Time   `json:"updated_at" gorm:"default:current_timestamp"`
+	ID             uint                 `json:"id" gorm:"PrimaryKey"`
+	UserID         uint                 `json:"user_id"`
+	Status         string               `json:"status"`
+	Amount         float64              `json:"amount"`
+	Subtotal       float64              `json:"subtotal"`
+	Discount       float64              `json:"discount"`
+	ShippingMethod string               `json:"shipping_method"`
+	ShippingCost   float64              `json:"shipping_cost"`
+	TotalExclTax   float64              `json:"total_excl_tax"`
+	Tax            float64              `json:"tax"`
+	TaxCountry     string               `json:"tax_country"`
+	CouponCode     string               `json:"coupon_code"`
+	TransactionId  string               `json:"transaction_id"`
+	OrderRefNumber string               `json:"order_ref_number"`
+	PaymentId      string               `json:"payment_id"`
+	Items          []OrderItem          `json:"items"`
+	SubOrders      []SubOrder           `json:"sub_orders"`
+	StatusHistory  []OrderStatusHistory `json:"status_history"`
+	CreatedAt      time.Time            `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt      time.Time            `json:"updated_at" gorm:"default:current_timestamp"`
 }
@@ -2,15 +2,32 @@ package domain
 
 import "time"
 
+const (
+	ITEM_PENDING   = "pending"
+	ITEM_PACKED    = "packed"
+	ITEM_SHIPPED   = "shipped"
+	ITEM_DELIVERED = "delivered"
+	ITEM_CANCELLED = "cancelled"
+)
+
 type OrderItem struct {
-	ID        uint      `json:"id" gorm:"PrimaryKey"`
-	OrderID   uint      `json:"order_id"`
-	ProductID uint      `json:"product_id"`
-	Name      string    `json:"name" gorm:"index;"`
-	ImageURL  string    `json:"image_url"`
-	SellerId  uint      `json:"seller_id"`
-	Price     uint      `json:"price"`
-	Qty       uint      `json:"qty"`
-	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
-	UpdatedAt time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+	ID             uint       `json:"id" gorm:"PrimaryKey"`
+	OrderID        uint       `json:"order_id"`
+	SubOrderID     uint       `json:"sub_order_id" gorm:"index;"`
+	ProductID      uint       `json:"product_id"`
+	Name           string     `json:"name" gorm:"index;"`
+	ImageURL       string     `json:"image_url"`
+	SellerId       uint       `json:"seller_id" gorm:"index;"`
+	Price          float64    `json:"price"`
+	Discount       float64    `json:"discount"`
+	TaxRate        float64    `json:"tax_rate"`
+	Tax            float64    `json:"tax"`
+	TotalExclTax   float64    `json:"total_excl_tax"`
+	TotalInclTax   float64    `json:"total_incl_tax"`
+	Qty            uint       `json:"qty"`
+	Status         string     `json:"status" gorm:"default:pending"`
+	TrackingNumber string     `json:"tracking_number"`
+	ShippedAt      *time.Time `json:"shipped_at"`
+	CreatedAt      time.Time  `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt      time.Time  `json:"updated_at" gorm:"default:current_timestamp"`
 }
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+type OrderStatusHistory struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	OrderID   uint      `json:"order_id" gorm:"index;"`
+	Status    string    `json:"status"`
+	Note      string    `json:"note"`
+	ChangedBy uint      `json:"changed_by"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
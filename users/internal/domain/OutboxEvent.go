@@ -0,0 +1,18 @@
+package domain
+
+import "time"
+
+// OutboxEvent is an event waiting to be delivered to one subscriber. It is written in the same
+// transaction as the change it announces and retried until the subscriber has accepted it
+type OutboxEvent struct {
+	ID            uint       `json:"id" gorm:"PrimaryKey"`
+	Type          string     `json:"type"`
+	UserID        uint       `json:"user_id" gorm:"index;"`
+	OccurredAt    time.Time  `json:"occurred_at"`
+	Subscriber    string     `json:"subscriber"`
+	Attempts      uint       `json:"attempts"`
+	NextAttemptAt time.Time  `json:"next_attempt_at" gorm:"index;"`
+	LastError     string     `json:"last_error"`
+	DeliveredAt   *time.Time `json:"delivered_at" gorm:"index;"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"default:current_timestamp"`
+}
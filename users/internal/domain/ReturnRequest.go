@@ -0,0 +1,30 @@
+package domain
+
+import "time"
+
+const (
+	RETURN_REQUESTED = "return_requested"
+	RETURN_APPROVED  = "return_approved"
+	RETURN_REJECTED  = "return_rejected"
+	RETURN_RECEIVED  = "return_received"
+	RETURN_REFUNDED  = "refunded"
+	// RETURN_REFUND_PENDING is a return whose refund has been asked for but not confirmed yet
+	RETURN_REFUND_PENDING = "refund_pending"
+)
+
+type ReturnRequest struct {
+	ID           uint      `json:"id" gorm:"PrimaryKey"`
+	OrderID      uint      `json:"order_id" gorm:"index;"`
+	OrderItemID  uint      `json:"order_item_id" gorm:"index;"`
+	UserID       uint      `json:"user_id" gorm:"index;"`
+	SellerId     uint      `json:"seller_id" gorm:"index;"`
+	ProductID    uint      `json:"product_id"`
+	Qty          uint      `json:"qty"`
+	Reason       string    `json:"reason"`
+	Status       string    `json:"status" gorm:"default:return_requested"`
+	SellerNote   string    `json:"seller_note"`
+	RefundAmount float64   `json:"refund_amount"`
+	RefundId     string    `json:"refund_id"`
+	CreatedAt    time.Time `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt    time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+}
@@ -0,0 +1,12 @@
+package domain
+
+import "time"
+
+// RoleGrant gives a user a role on top of their primary role, the UserType they signed up with
+type RoleGrant struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	UserID    uint      `json:"user_id" gorm:"uniqueIndex:idx_user_role;not null"`
+	Role      string    `json:"role" gorm:"uniqueIndex:idx_user_role;not null"`
+	GrantedBy uint      `json:"granted_by"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
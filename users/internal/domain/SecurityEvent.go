@@ -0,0 +1,32 @@
+package domain
+
+import "time"
+
+const (
+	EVENT_LOGIN_SUCCEEDED = "login_succeeded"
+	EVENT_LOGIN_FAILED    = "login_failed"
+	EVENT_LOGIN_THROTTLED = "login_throttled"
+	EVENT_ACCOUNT_LOCKED  = "account_locked"
+
+	EVENT_TWO_FACTOR_ENABLED   = "two_factor_enabled"
+	EVENT_TWO_FACTOR_DISABLED  = "two_factor_disabled"
+	EVENT_TWO_FACTOR_FAILED    = "two_factor_failed"
+	EVENT_RECOVERY_CODE_USED   = "recovery_code_used"
+	EVENT_RECOVERY_CODES_RESET = "recovery_codes_reset"
+
+	EVENT_IDENTITY_LINKED = "identity_linked"
+
+	EVENT_PASSWORD_CHANGED  = "password_changed"
+	EVENT_PASSWORD_REHASHED = "password_rehashed"
+)
+
+// SecurityEvent records security relevant activity on an account without any secrets
+type SecurityEvent struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index;"`
+	Email     string    `json:"email" gorm:"index;"`
+	IP        string    `json:"ip"`
+	Type      string    `json:"type"`
+	Detail    string    `json:"detail"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+const (
+	SHIPPING_STANDARD = "standard"
+	SHIPPING_EXPRESS  = "express"
+	SHIPPING_PICKUP   = "pickup"
+)
+
+type ShippingMethod struct {
+	ID          uint           `json:"id" gorm:"PrimaryKey"`
+	Code        string         `json:"code" gorm:"uniqueIndex;not null"`
+	Name        string         `json:"name"`
+	Description string         `json:"description"`
+	MinDays     uint           `json:"min_days"`
+	MaxDays     uint           `json:"max_days"`
+	Active      bool           `json:"active"`
+	Rates       []ShippingRate `json:"rates"`
+	CreatedAt   time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt   time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+}
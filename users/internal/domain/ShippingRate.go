@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+// ShippingRate is one row of a shipping method's rate table. An empty Country matches every country,
+// a zero MaxWeight matches any weight and FreeAbove is the order value from which shipping is free
+type ShippingRate struct {
+	ID               uint      `json:"id" gorm:"PrimaryKey"`
+	ShippingMethodID uint      `json:"shipping_method_id" gorm:"index;"`
+	Country          string    `json:"country"`
+	MaxWeight        float64   `json:"max_weight"`
+	MinOrderValue    float64   `json:"min_order_value"`
+	Cost             float64   `json:"cost"`
+	FreeAbove        float64   `json:"free_above"`
+	CreatedAt        time.Time `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt        time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+}
@@ -0,0 +1,22 @@
+package domain
+
+import "time"
+
+// SubOrder is the part of an order fulfilled by one seller, shipped and tracked independently
+type SubOrder struct {
+	ID             uint        `json:"id" gorm:"PrimaryKey"`
+	OrderID        uint        `json:"order_id" gorm:"index;"`
+	SellerId       uint        `json:"seller_id" gorm:"index;"`
+	Status         string      `json:"status" gorm:"default:pending"`
+	TrackingNumber string      `json:"tracking_number"`
+	ShippedAt      *time.Time  `json:"shipped_at"`
+	Subtotal       float64     `json:"subtotal"`
+	Discount       float64     `json:"discount"`
+	ShippingCost   float64     `json:"shipping_cost"`
+	TotalExclTax   float64     `json:"total_excl_tax"`
+	Tax            float64     `json:"tax"`
+	Total          float64     `json:"total"`
+	Items          []OrderItem `json:"items"`
+	CreatedAt      time.Time   `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt      time.Time   `json:"updated_at" gorm:"default:current_timestamp"`
+}
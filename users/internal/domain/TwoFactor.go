@@ -0,0 +1,36 @@
+package domain
+
+import "time"
+
+// TwoFactor holds a user's TOTP enrollment, the secret is stored encrypted
+type TwoFactor struct {
+	ID           uint       `json:"id" gorm:"PrimaryKey"`
+	UserID       uint       `json:"user_id" gorm:"uniqueIndex;not null"`
+	Secret       string     `json:"-"`
+	Enabled      bool       `json:"enabled" gorm:"default:false"`
+	ConfirmedAt  *time.Time `json:"confirmed_at"`
+	LastUsedStep int64      `json:"-"`
+	CreatedAt    time.Time  `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt    time.Time  `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// RecoveryCode is a single use code for signing in without the authenticator, only its hash is kept
+type RecoveryCode struct {
+	ID        uint       `json:"id" gorm:"PrimaryKey"`
+	UserID    uint       `json:"user_id" gorm:"index;"`
+	CodeHash  string     `json:"-" gorm:"index;"`
+	UsedAt    *time.Time `json:"used_at"`
+	CreatedAt time.Time  `json:"created_at" gorm:"default:current_timestamp"`
+}
+
+// LoginChallenge is the pending second step of a sign in, only the hash of its token is kept
+type LoginChallenge struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	TokenHash string    `json:"-" gorm:"uniqueIndex;not null"`
+	UserID    uint      `json:"user_id" gorm:"index;"`
+	CartToken string    `json:"-"`
+	IP        string    `json:"ip"`
+	Attempts  uint      `json:"attempts"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
@@ -5,20 +5,52 @@ import "time"
 const (
 	SELLER = "seller"
 	BUYER  = "buyer"
+	ADMIN  = "admin"
+)
+
+const (
+	USER_ACTIVE    = "active"
+	USER_SUSPENDED = "suspended"
 )
 
 type User struct {
-	ID               uint      `json:"id" gorm:"PrimaryKey"`
-	FName            string    `json:"f_name"`
-	LName            string    `json:"l_name"`
-	Email            string    `json:"email" gorm:"index;unique;not null"`
-	Phone            string    `json:"phone"`
-	Password         string    `json:"password"`
-	VerificationCode string    `json:"verificationCode"`
-	Expiry           time.Time `json:"expiry"`
-	Address          Address   `json:"address"`
-	IsVerified       bool      `json:"isVerified" gorm:"default:false"`
-	UserType         string    `json:"user_type" gorm:"default:buyer"`
-	CreatedAt        time.Time `json:"created_at" gorm:"default:current_timestamp"`
-	UpdatedAt        time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+	ID               uint        `json:"id" gorm:"PrimaryKey"`
+	FName            string      `json:"f_name"`
+	LName            string      `json:"l_name"`
+	Email            string      `json:"email" gorm:"index;unique;not null"`
+	Phone            string      `json:"phone"`
+	Password         string      `json:"password"`
+	VerificationCode string      `json:"verificationCode"`
+	Expiry           time.Time   `json:"expiry"`
+	Address          Address     `json:"address"`
+	IsVerified       bool        `json:"isVerified" gorm:"default:false"`
+	UserType         string      `json:"user_type" gorm:"default:buyer"`
+	RoleGrants       []RoleGrant `json:"-" gorm:"foreignKey:UserID"`
+	Status           string      `json:"status" gorm:"default:active"`
+	SuspendedAt      *time.Time  `json:"suspended_at"`
+	SuspendedReason  string      `json:"suspended_reason"`
+	TwoFactorForced  bool        `json:"two_factor_forced" gorm:"default:false"`
+	AnonymisedAt     *time.Time  `json:"anonymised_at"`
+	CreatedAt        time.Time   `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt        time.Time   `json:"updated_at" gorm:"default:current_timestamp"`
+}
+
+// Roles are the primary role followed by every role granted on top of it
+func (u User) Roles() []string {
+	roles := []string{u.UserType}
+	for _, grant := range u.RoleGrants {
+		if grant.Role != u.UserType {
+			roles = append(roles, grant.Role)
+		}
+	}
+	return roles
+}
+
+func (u User) HasRole(role string) bool {
+	for _, r := range u.Roles() {
+		if r == role {
+			return true
+		}
+	}
+	return false
 }
@@ -0,0 +1,24 @@
+package domain
+
+import "time"
+
+// UserIdentity links an account to the subject a social login provider knows it by
+type UserIdentity struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	UserID    uint      `json:"user_id" gorm:"index;"`
+	Provider  string    `json:"provider" gorm:"uniqueIndex:idx_provider_subject;not null"`
+	Subject   string    `json:"-" gorm:"uniqueIndex:idx_provider_subject;not null"`
+	Email     string    `json:"email"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
+
+// OAuthState is a social login in progress, it ties the provider callback to the browser that started it
+type OAuthState struct {
+	ID        uint      `json:"id" gorm:"PrimaryKey"`
+	StateHash string    `json:"-" gorm:"uniqueIndex;not null"`
+	Provider  string    `json:"provider"`
+	Verifier  string    `json:"-"`
+	CartToken string    `json:"-"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at" gorm:"default:current_timestamp"`
+}
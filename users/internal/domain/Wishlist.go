@@ -0,0 +1,17 @@
+package domain
+
+import "time"
+
+const SAVE_FOR_LATER_LIST = "Saved for later"
+
+type Wishlist struct {
+	ID             uint           `json:"id" gorm:"PrimaryKey"`
+	UserID         uint           `json:"user_id" gorm:"index;"`
+	Name           string         `json:"name"`
+	IsSaveForLater bool           `json:"is_save_for_later" gorm:"default:false"`
+	IsPublic       bool           `json:"is_public" gorm:"default:false"`
+	ShareToken     string         `json:"share_token" gorm:"index;"`
+	Items          []WishlistItem `json:"items"`
+	CreatedAt      time.Time      `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt      time.Time      `json:"updated_at" gorm:"default:current_timestamp"`
+}
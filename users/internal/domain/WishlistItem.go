@@ -0,0 +1,16 @@
+package domain
+
+import "time"
+
+type WishlistItem struct {
+	ID             uint      `json:"id" gorm:"PrimaryKey"`
+	WishlistID     uint      `json:"wishlist_id" gorm:"index;"`
+	ProductID      uint      `json:"product_id"`
+	Name           string    `json:"name"`
+	ImageURL       string    `json:"image_url"`
+	SellerId       uint      `json:"seller_id"`
+	PriceWhenAdded float64   `json:"price_when_added"`
+	Qty            uint      `json:"qty" gorm:"default:1"`
+	CreatedAt      time.Time `json:"created_at" gorm:"default:current_timestamp"`
+	UpdatedAt      time.Time `json:"updated_at" gorm:"default:current_timestamp"`
+}
@@ -0,0 +1,34 @@
+package dto
+
+import (
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"time"
+)
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+type AccountProfile struct {
+	ID         uint      `json:"id"`
+	FName      string    `json:"f_name"`
+	LName      string    `json:"l_name"`
+	Email      string    `json:"email"`
+	Phone      string    `json:"phone"`
+	UserType   string    `json:"user_type"`
+	IsVerified bool      `json:"is_verified"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// AccountExport is everything the users service holds about one user
+type AccountExport struct {
+	ExportedAt        time.Time                 `json:"exported_at"`
+	Profile           AccountProfile            `json:"profile"`
+	Address           *domain.Address           `json:"address"`
+	Orders            []domain.Order            `json:"orders"`
+	ReturnRequests    []domain.ReturnRequest    `json:"return_requests"`
+	Wishlists         []domain.Wishlist         `json:"wishlists"`
+	Cart              []domain.Cart             `json:"cart"`
+	CouponRedemptions []domain.CouponRedemption `json:"coupon_redemptions"`
+	Invoices          []domain.Invoice          `json:"invoices"`
+}
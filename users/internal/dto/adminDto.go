@@ -0,0 +1,48 @@
+package dto
+
+import "time"
+
+type UserFilter struct {
+	Search   string
+	Role     string
+	Status   string
+	Page     int
+	PageSize int
+}
+
+type SuspendUserRequest struct {
+	Reason string `json:"reason"`
+}
+
+type ChangeRoleRequest struct {
+	Role string `json:"role"`
+}
+
+// GrantRoleRequest names a role to give a user besides their primary one
+type GrantRoleRequest struct {
+	Role string `json:"role"`
+}
+
+type AdminUser struct {
+	ID              uint       `json:"id"`
+	FName           string     `json:"f_name"`
+	LName           string     `json:"l_name"`
+	Email           string     `json:"email"`
+	Phone           string     `json:"phone"`
+	UserType        string     `json:"user_type"`
+	Roles           []string   `json:"roles"`
+	Status          string     `json:"status"`
+	IsVerified      bool       `json:"is_verified"`
+	TwoFactorForced bool       `json:"two_factor_forced"`
+	SuspendedAt     *time.Time `json:"suspended_at"`
+	SuspendedReason string     `json:"suspended_reason"`
+	CreatedAt       time.Time  `json:"created_at"`
+}
+
+type UserPage struct {
+	Users      []AdminUser `json:"users"`
+	Page       int         `json:"page"`
+	PageSize   int         `json:"page_size"`
+	Total      int64       `json:"total"`
+	TotalPages int         `json:"total_pages"`
+}
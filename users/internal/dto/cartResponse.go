@@ -0,0 +1,38 @@
+package dto
+
+type CartItemResponse struct {
+	ID             uint    `json:"id"`
+	ProductID      uint    `json:"product_id"`
+	Name           string  `json:"name"`
+	ImageURL       string  `json:"image_url"`
+	SellerId       uint    `json:"seller_id"`
+	CategoryID     uint    `json:"category_id"`
+	Weight         float64 `json:"weight"`
+	Price          float64 `json:"price"`
+	Qty            uint    `json:"qty"`
+	CurrentPrice   float64 `json:"current_price"`
+	AvailableStock uint    `json:"available_stock"`
+	PriceChanged   bool    `json:"price_changed"`
+	OutOfStock     bool    `json:"out_of_stock"`
+	Unavailable    bool    `json:"unavailable"`
+	Discount       float64 `json:"discount"`
+	TaxCategory    string  `json:"tax_category"`
+	TaxRate        float64 `json:"tax_rate"`
+	Tax            float64 `json:"tax"`
+}
+
+type CartResponse struct {
+	Items                   []CartItemResponse `json:"items"`
+	Subtotal                float64            `json:"subtotal"`
+	Discount                float64            `json:"discount"`
+	ShippingMethod          string             `json:"shipping_method,omitempty"`
+	ShippingCost            float64            `json:"shipping_cost"`
+	TotalExclTax            float64            `json:"total_excl_tax"`
+	Tax                     float64            `json:"tax"`
+	Total                   float64            `json:"total"`
+	TaxCountry              string             `json:"tax_country"`
+	CouponCode              string             `json:"coupon_code,omitempty"`
+	CouponError             string             `json:"coupon_error,omitempty"`
+	RequiresAcknowledgement bool               `json:"requires_acknowledgement"`
+	HasUnavailableItems     bool               `json:"has_unavailable_items"`
+}
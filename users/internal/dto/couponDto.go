@@ -0,0 +1,25 @@
+package dto
+
+import "time"
+
+type CouponInput struct {
+	Code         string     `json:"code"`
+	Description  string     `json:"description"`
+	Type         string     `json:"type"`
+	Value        float64    `json:"value"`
+	MaxDiscount  float64    `json:"max_discount"`
+	MinSpend     float64    `json:"min_spend"`
+	BuyQty       uint       `json:"buy_qty"`
+	GetQty       uint       `json:"get_qty"`
+	ProductIDs   []uint     `json:"product_ids"`
+	CategoryIDs  []uint     `json:"category_ids"`
+	UsageLimit   uint       `json:"usage_limit"`
+	PerUserLimit uint       `json:"per_user_limit"`
+	StartsAt     *time.Time `json:"starts_at"`
+	ExpiresAt    *time.Time `json:"expires_at"`
+	Active       *bool      `json:"active"`
+}
+
+type ApplyCouponRequest struct {
+	Code string `json:"code"`
+}
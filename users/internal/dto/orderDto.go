@@ -0,0 +1,51 @@
+package dto
+
+import "time"
+
+type OrderFilter struct {
+	Status   string
+	From     time.Time
+	To       time.Time
+	Search   string
+	Page     int
+	PageSize int
+}
+
+type OrderSummary struct {
+	ID             uint      `json:"id"`
+	OrderRefNumber string    `json:"order_ref_number"`
+	Status         string    `json:"status"`
+	Amount         float64   `json:"amount"`
+	ItemCount      uint      `json:"item_count"`
+	CreatedAt      time.Time `json:"created_at"`
+}
+
+type OrderHistory struct {
+	Orders     []OrderSummary `json:"orders"`
+	Page       int            `json:"page"`
+	PageSize   int            `json:"page_size"`
+	Total      int64          `json:"total"`
+	TotalPages int            `json:"total_pages"`
+}
+
+const (
+	REORDER_ADDED         = "added"
+	REORDER_PRICE_CHANGED = "price_changed"
+	REORDER_OUT_OF_STOCK  = "out_of_stock"
+	REORDER_DISCONTINUED  = "discontinued"
+)
+
+type ReorderItemResult struct {
+	ProductID     uint    `json:"product_id"`
+	Name          string  `json:"name"`
+	Outcome       string  `json:"outcome"`
+	RequestedQty  uint    `json:"requested_qty"`
+	AddedQty      uint    `json:"added_qty"`
+	OriginalPrice float64 `json:"original_price"`
+	CurrentPrice  float64 `json:"current_price"`
+}
+
+type ReorderResponse struct {
+	Items []ReorderItemResult `json:"items"`
+	Cart  *CartResponse       `json:"cart"`
+}
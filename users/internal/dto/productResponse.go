@@ -9,6 +9,8 @@ type ProductResponse struct {
 	UserID      uint    `json:"user_id"`
 	CategoryID  uint    `json:"category_id"`
 	Stock       uint    `json:"stock"`
+	TaxCategory string  `json:"tax_category"`
+	Weight      float64 `json:"weight"`
 	CreatedAt   string  `json:"created_at,omitempty"`
 	UpdatedAt   string  `json:"updated_at,omitempty"`
 }
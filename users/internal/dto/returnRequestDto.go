@@ -0,0 +1,34 @@
+package dto
+
+type CancelOrderRequest struct {
+	Reason string `json:"reason"`
+}
+
+type CreateReturnRequest struct {
+	OrderItemID uint   `json:"order_item_id"`
+	Qty         uint   `json:"qty"`
+	Reason      string `json:"reason"`
+}
+
+type ReviewReturnRequest struct {
+	Note string `json:"note"`
+}
+
+type RefundRequest struct {
+	PaymentId      string  `json:"payment_id"`
+	OrderRefNumber string  `json:"order_ref_number"`
+	Amount         float64 `json:"amount"`
+	Reason         string  `json:"reason"`
+	// RequestedBy is the buyer who cancelled or the seller who refunded the return
+	RequestedBy uint `json:"requested_by"`
+	// IdempotencyKey names the cancellation or return being refunded, a retry with the same key
+	// returns the refund already issued
+	IdempotencyKey string `json:"idempotency_key"`
+}
+
+type RefundResponse struct {
+	ID       uint    `json:"id"`
+	RefundId string  `json:"refund_id"`
+	Amount   float64 `json:"amount"`
+	Status   string  `json:"status"`
+}
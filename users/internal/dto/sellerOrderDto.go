@@ -0,0 +1,66 @@
+package dto
+
+import "time"
+
+type SellerOrderFilter struct {
+	Status string
+	From   time.Time
+	To     time.Time
+}
+
+type UpdateOrderItemRequest struct {
+	Status         string `json:"status"`
+	TrackingNumber string `json:"tracking_number"`
+}
+
+type SellerOrderItem struct {
+	ID             uint       `json:"id"`
+	ProductID      uint       `json:"product_id"`
+	Name           string     `json:"name"`
+	ImageURL       string     `json:"image_url"`
+	Price          float64    `json:"price"`
+	Discount       float64    `json:"discount"`
+	Qty            uint       `json:"qty"`
+	Status         string     `json:"status"`
+	TrackingNumber string     `json:"tracking_number"`
+	ShippedAt      *time.Time `json:"shipped_at"`
+}
+
+type SellerOrder struct {
+	OrderID         uint              `json:"order_id"`
+	OrderRefNumber  string            `json:"order_ref_number"`
+	OrderStatus     string            `json:"order_status"`
+	SubOrderID      uint              `json:"sub_order_id"`
+	ShipmentStatus  string            `json:"shipment_status"`
+	TrackingNumber  string            `json:"tracking_number"`
+	ShippingCost    float64           `json:"shipping_cost"`
+	Tax             float64           `json:"tax"`
+	CreatedAt       time.Time         `json:"created_at"`
+	CustomerName    string            `json:"customer_name"`
+	CustomerEmail   string            `json:"customer_email"`
+	CustomerPhone   string            `json:"customer_phone"`
+	ShippingAddress AddressInput      `json:"shipping_address"`
+	Items           []SellerOrderItem `json:"items"`
+	Total           float64           `json:"total"`
+}
+
+type SalesReportRow struct {
+	OrderRefNumber string    `json:"order_ref_number"`
+	OrderDate      time.Time `json:"order_date"`
+	ProductID      uint      `json:"product_id"`
+	Name           string    `json:"name"`
+	Qty            uint      `json:"qty"`
+	UnitPrice      float64   `json:"unit_price"`
+	Discount       float64   `json:"discount"`
+	LineTotal      float64   `json:"line_total"`
+	Status         string    `json:"status"`
+}
+
+type SalesReport struct {
+	From       time.Time        `json:"from"`
+	To         time.Time        `json:"to"`
+	OrderCount int              `json:"order_count"`
+	UnitsSold  uint             `json:"units_sold"`
+	Revenue    float64          `json:"revenue"`
+	Rows       []SalesReportRow `json:"rows"`
+}
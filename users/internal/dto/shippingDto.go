@@ -0,0 +1,31 @@
+package dto
+
+type ShippingQuote struct {
+	Code    string  `json:"code"`
+	Name    string  `json:"name"`
+	MinDays uint    `json:"min_days"`
+	MaxDays uint    `json:"max_days"`
+	Cost    float64 `json:"cost"`
+	Free    bool    `json:"free"`
+}
+
+// ShippingMethodRequest creates or edits a shipping method, the code and rates are only read on
+// creation since carts refer to a method by its code and rates are edited one by one
+type ShippingMethodRequest struct {
+	Code        string                `json:"code"`
+	Name        string                `json:"name"`
+	Description string                `json:"description"`
+	MinDays     uint                  `json:"min_days"`
+	MaxDays     uint                  `json:"max_days"`
+	Active      *bool                 `json:"active"`
+	Rates       []ShippingRateRequest `json:"rates"`
+}
+
+// ShippingRateRequest is a row of a rate table, see domain.ShippingRate for how rows are matched
+type ShippingRateRequest struct {
+	Country       string  `json:"country"`
+	MaxWeight     float64 `json:"max_weight"`
+	MinOrderValue float64 `json:"min_order_value"`
+	Cost          float64 `json:"cost"`
+	FreeAbove     float64 `json:"free_above"`
+}
@@ -15,14 +15,14 @@ type CreatePaymentRequest struct {
 }
 
 type OrderItem struct {
-	ID        uint   `json:"id"`
-	OrderID   uint   `json:"order_id"`
-	ProductID uint   `json:"product_id"`
-	Name      string `json:"name"`
-	ImageURL  string `json:"image_url"`
-	SellerId  uint   `json:"seller_id"`
-	Price     uint   `json:"price"`
-	Qty       uint   `json:"qty"`
+	ID        uint    `json:"id"`
+	OrderID   uint    `json:"order_id"`
+	ProductID uint    `json:"product_id"`
+	Name      string  `json:"name"`
+	ImageURL  string  `json:"image_url"`
+	SellerId  uint    `json:"seller_id"`
+	Price     float64 `json:"price"`
+	Qty       uint    `json:"qty"`
 }
 
 type CreateOrderRequest struct {
@@ -30,4 +30,5 @@ type CreateOrderRequest struct {
 	Amount         float64 `json:"amount"`
 	OrderRefNumber string  `json:"order_ref_number"`
 	PaymentId      string  `json:"payment_id"`
+	ShippingMethod string  `json:"shipping_method"`
 }
@@ -0,0 +1,63 @@
+package dto
+
+import "time"
+
+const (
+	TWO_FACTOR_VERIFY = "verify"
+	TWO_FACTOR_ENROLL = "enroll"
+)
+
+// LoginResponse carries either the access token or, when a second step is needed, the challenge token
+type LoginResponse struct {
+	Token          string   `json:"token,omitempty"`
+	RefreshToken   string   `json:"refresh_token,omitempty"`
+	ExpiresIn      int64    `json:"expires_in,omitempty"`
+	ChallengeToken string   `json:"challenge_token,omitempty"`
+	TwoFactor      string   `json:"two_factor,omitempty"`
+	RecoveryCodes  []string `json:"recovery_codes,omitempty"`
+}
+
+type RefreshTokenInput struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+type LogoutInput struct {
+	RefreshToken string `json:"refresh_token"`
+	AllDevices   bool   `json:"all_devices"`
+}
+
+type TwoFactorLoginInput struct {
+	ChallengeToken string `json:"challenge_token"`
+	Code           string `json:"code"`
+}
+
+type TwoFactorCodeInput struct {
+	Code string `json:"code"`
+}
+
+type TwoFactorEnrollment struct {
+	Secret          string `json:"secret"`
+	ProvisioningURI string `json:"provisioning_uri"`
+	QRCode          string `json:"qr_code"`
+}
+
+type TwoFactorStatus struct {
+	Enabled           bool  `json:"enabled"`
+	Required          bool  `json:"required"`
+	RecoveryCodesLeft int64 `json:"recovery_codes_left"`
+}
+
+type RequireTwoFactorRequest struct {
+	Required bool `json:"required"`
+}
+
+// Session is a device the user is signed in on, as listed by the auth service
+type Session struct {
+	ID         string    `json:"id"`
+	UserAgent  string    `json:"user_agent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"created_at"`
+	LastSeenAt time.Time `json:"last_seen_at"`
+	ExpiresAt  time.Time `json:"expires_at"`
+	Current    bool      `json:"current"`
+}
@@ -1,8 +1,9 @@
 package dto
 
 type UserLogin struct {
-	Email    string `json:"email"`
-	Password string `json:"password"`
+	Email     string `json:"email"`
+	Password  string `json:"password"`
+	CartToken string `json:"cart_token"`
 }
 
 type UserSignup struct {
@@ -10,6 +11,17 @@ type UserSignup struct {
 	Phone string `json:"phone"`
 }
 
+// ClientInfo is the device a request came from, sign ins record it on the session they start
+type ClientInfo struct {
+	IP        string
+	UserAgent string
+}
+
+type ChangePasswordInput struct {
+	CurrentPassword string `json:"current_password"`
+	NewPassword     string `json:"new_password"`
+}
+
 type VerificationCodeInput struct {
 	Code string `json:"code"`
 }
@@ -0,0 +1,33 @@
+package dto
+
+type WishlistInput struct {
+	Name     string `json:"name"`
+	IsPublic *bool  `json:"is_public"`
+}
+
+type WishlistItemInput struct {
+	ProductID uint `json:"product_id"`
+	Qty       uint `json:"qty"`
+}
+
+type WishlistItemResponse struct {
+	ProductID      uint    `json:"product_id"`
+	Name           string  `json:"name"`
+	ImageURL       string  `json:"image_url"`
+	Qty            uint    `json:"qty"`
+	PriceWhenAdded float64 `json:"price_when_added"`
+	CurrentPrice   float64 `json:"current_price"`
+	PriceDropped   bool    `json:"price_dropped"`
+	PriceDrop      float64 `json:"price_drop"`
+	InStock        bool    `json:"in_stock"`
+	Unavailable    bool    `json:"unavailable"`
+}
+
+type WishlistResponse struct {
+	ID             uint                   `json:"id"`
+	Name           string                 `json:"name"`
+	IsSaveForLater bool                   `json:"is_save_for_later"`
+	IsPublic       bool                   `json:"is_public"`
+	ShareToken     string                 `json:"share_token,omitempty"`
+	Items          []WishlistItemResponse `json:"items"`
+}
@@ -0,0 +1,181 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"gorm.io/gorm"
+	"log"
+	"time"
+)
+
+// AccountRepository gathers and erases everything held about a user
+type AccountRepository interface {
+	FindAccountOrders(userId uint) ([]domain.Order, error)
+	FindAccountReturns(userId uint) ([]domain.ReturnRequest, error)
+	FindAccountWishlists(userId uint) ([]domain.Wishlist, error)
+	FindAccountCart(userId uint) ([]domain.Cart, error)
+	FindAccountRedemptions(userId uint) ([]domain.CouponRedemption, error)
+	FindAccountInvoices(userId uint) ([]domain.Invoice, error)
+
+	CountOpenSubOrders(userId uint) (int64, error)
+	CountOpenReturns(userId uint) (int64, error)
+	AnonymiseUser(userId uint, at time.Time, outbox []domain.OutboxEvent) error
+}
+
+type accountRepository struct {
+	db *gorm.DB
+}
+
+func (r accountRepository) FindAccountOrders(userId uint) ([]domain.Order, error) {
+	var orders []domain.Order
+	err := r.db.Preload("Items").Preload("SubOrders").Preload("StatusHistory").
+		Where("user_id = ?", userId).Order("created_at").Find(&orders).Error
+	if err != nil {
+		log.Printf("Error while fetching account orders %v", err)
+		return nil, errors.New("could not fetch orders")
+	}
+	return orders, nil
+}
+
+func (r accountRepository) FindAccountReturns(userId uint) ([]domain.ReturnRequest, error) {
+	var returns []domain.ReturnRequest
+	err := r.db.Where("user_id = ?", userId).Order("created_at").Find(&returns).Error
+	if err != nil {
+		log.Printf("Error while fetching account return requests %v", err)
+		return nil, errors.New("could not fetch return requests")
+	}
+	return returns, nil
+}
+
+func (r accountRepository) FindAccountWishlists(userId uint) ([]domain.Wishlist, error) {
+	var wishlists []domain.Wishlist
+	err := r.db.Preload("Items").Where("user_id = ?", userId).Order("created_at").Find(&wishlists).Error
+	if err != nil {
+		log.Printf("Error while fetching account wishlists %v", err)
+		return nil, errors.New("could not fetch wishlists")
+	}
+	return wishlists, nil
+}
+
+func (r accountRepository) FindAccountCart(userId uint) ([]domain.Cart, error) {
+	var items []domain.Cart
+	err := r.db.Where("user_id = ?", userId).Find(&items).Error
+	if err != nil {
+		log.Printf("Error while fetching account cart %v", err)
+		return nil, errors.New("could not fetch cart")
+	}
+	return items, nil
+}
+
+func (r accountRepository) FindAccountRedemptions(userId uint) ([]domain.CouponRedemption, error) {
+	var redemptions []domain.CouponRedemption
+	err := r.db.Where("user_id = ?", userId).Order("created_at").Find(&redemptions).Error
+	if err != nil {
+		log.Printf("Error while fetching account coupon redemptions %v", err)
+		return nil, errors.New("could not fetch coupon redemptions")
+	}
+	return redemptions, nil
+}
+
+func (r accountRepository) FindAccountInvoices(userId uint) ([]domain.Invoice, error) {
+	var invoices []domain.Invoice
+	err := r.db.Where("user_id = ?", userId).Order("created_at").Find(&invoices).Error
+	if err != nil {
+		log.Printf("Error while fetching account invoices %v", err)
+		return nil, errors.New("could not fetch invoices")
+	}
+	return invoices, nil
+}
+
+// CountOpenSubOrders counts shipments still being fulfilled, whether the user bought or sells them
+func (r accountRepository) CountOpenSubOrders(userId uint) (int64, error) {
+	var count int64
+	buyerOrders := r.db.Model(&domain.Order{}).Select("id").Where("user_id = ?", userId)
+	err := r.db.Model(&domain.SubOrder{}).
+		Where("order_id IN (?) OR seller_id = ?", buyerOrders, userId).
+		Where("status NOT IN ?", []string{domain.ITEM_DELIVERED, domain.ITEM_CANCELLED}).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("Error while counting open sub-orders %v", err)
+		return 0, errors.New("could not check open orders")
+	}
+	return count, nil
+}
+
+// CountOpenReturns counts return requests that may still lead to a refund
+func (r accountRepository) CountOpenReturns(userId uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.ReturnRequest{}).
+		Where("user_id = ? OR seller_id = ?", userId, userId).
+		Where("status IN ?", []string{domain.RETURN_REQUESTED, domain.RETURN_APPROVED, domain.RETURN_RECEIVED, domain.RETURN_REFUND_PENDING}).
+		Count(&count).Error
+	if err != nil {
+		log.Printf("Error while counting open return requests %v", err)
+		return 0, errors.New("could not check open returns")
+	}
+	return count, nil
+}
+
+// AnonymiseUser strips the personal data of a user and removes what is only kept for their convenience,
+// orders, invoices, returns and redemptions stay as they are required for accounting. The outbox entries
+// telling the other services are stored in the same transaction so the deletion cannot go unannounced
+func (r accountRepository) AnonymiseUser(userId uint, at time.Time, outbox []domain.OutboxEvent) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if len(outbox) > 0 {
+			err := tx.Create(&outbox).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		err := tx.Model(&domain.User{}).Where("id = ?", userId).Updates(map[string]interface{}{
+			"f_name":            "Deleted",
+			"l_name":            "User",
+			"email":             fmt.Sprintf("deleted-%d@users.invalid", userId),
+			"phone":             "",
+			"password":          "",
+			"verification_code": "",
+			"is_verified":       false,
+			"anonymised_at":     at,
+		}).Error
+		if err != nil {
+			return err
+		}
+
+		err = tx.Where("user_id = ?", userId).Delete(&domain.Address{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("user_id = ?", userId).Delete(&domain.Cart{}).Error
+		if err != nil {
+			return err
+		}
+		err = tx.Where("user_id = ?", userId).Delete(&domain.CartCoupon{}).Error
+		if err != nil {
+			return err
+		}
+		for _, model := range []interface{}{&domain.TwoFactor{}, &domain.RecoveryCode{}, &domain.LoginChallenge{}, &domain.UserIdentity{}, &domain.RoleGrant{}} {
+			err = tx.Where("user_id = ?", userId).Delete(model).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		wishlists := tx.Model(&domain.Wishlist{}).Select("id").Where("user_id = ?", userId)
+		err = tx.Where("wishlist_id IN (?)", wishlists).Delete(&domain.WishlistItem{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userId).Delete(&domain.Wishlist{}).Error
+	})
+	if err != nil {
+		log.Printf("Error while anonymising user %d: %v", userId, err)
+		return errors.New("could not delete account")
+	}
+	return nil
+}
+
+func NewAccountRepository(db *gorm.DB) AccountRepository {
+	return &accountRepository{db}
+}
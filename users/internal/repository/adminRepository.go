@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+// UserQuery narrows and pages the user list of the admin console
+type UserQuery struct {
+	Search string
+	Role   string
+	Status string
+	Offset int
+	Limit  int
+}
+
+type AdminRepository interface {
+	FindUsers(query UserQuery) ([]domain.User, int64, error)
+	UpdateUserStatus(id uint, status string, reason string, suspendedAt *time.Time) error
+	UpdateUserRole(id uint, role string) error
+	ResetUserVerification(id uint) error
+	UpdateTwoFactorForced(id uint, forced bool) error
+	PromoteUsers(emails []string, role string) error
+	GrantRole(grant *domain.RoleGrant) error
+	RevokeRole(id uint, role string) error
+}
+
+type adminRepository struct {
+	db *gorm.DB
+}
+
+func (r adminRepository) FindUsers(query UserQuery) ([]domain.User, int64, error) {
+	var users []domain.User
+	var total int64
+
+	db := r.db.Model(&domain.User{}).Where("anonymised_at IS NULL")
+	if query.Search != "" {
+		pattern := "%" + query.Search + "%"
+		db = db.Where("email ILIKE ? OR f_name ILIKE ? OR l_name ILIKE ? OR phone ILIKE ?", pattern, pattern, pattern, pattern)
+	}
+	if query.Role != "" {
+		granted := r.db.Model(&domain.RoleGrant{}).Select("user_id").Where("role = ?", query.Role)
+		db = db.Where("user_type = ? OR id IN (?)", query.Role, granted)
+	}
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+
+	err := db.Count(&total).Error
+	if err != nil {
+		log.Printf("Error while counting users %v", err)
+		return nil, 0, errors.New("could not fetch users")
+	}
+
+	err = db.Preload("RoleGrants").Order("id").Offset(query.Offset).Limit(query.Limit).Find(&users).Error
+	if err != nil {
+		log.Printf("Error while fetching users %v", err)
+		return nil, 0, errors.New("could not fetch users")
+	}
+	return users, total, nil
+}
+
+func (r adminRepository) UpdateUserStatus(id uint, status string, reason string, suspendedAt *time.Time) error {
+	err := r.db.Model(&domain.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"status":           status,
+		"suspended_reason": reason,
+		"suspended_at":     suspendedAt,
+	}).Error
+	if err != nil {
+		log.Printf("Error while updating status of user %d: %v", id, err)
+		return errors.New("could not update user status")
+	}
+	return nil
+}
+
+func (r adminRepository) UpdateUserRole(id uint, role string) error {
+	err := r.db.Model(&domain.User{}).Where("id = ?", id).Update("user_type", role).Error
+	if err != nil {
+		log.Printf("Error while updating role of user %d: %v", id, err)
+		return errors.New("could not update user role")
+	}
+	return nil
+}
+
+func (r adminRepository) ResetUserVerification(id uint) error {
+	err := r.db.Model(&domain.User{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"is_verified":       false,
+		"verification_code": "",
+	}).Error
+	if err != nil {
+		log.Printf("Error while resetting verification of user %d: %v", id, err)
+		return errors.New("could not reset user verification")
+	}
+	return nil
+}
+
+func (r adminRepository) UpdateTwoFactorForced(id uint, forced bool) error {
+	err := r.db.Model(&domain.User{}).Where("id = ?", id).Update("two_factor_forced", forced).Error
+	if err != nil {
+		log.Printf("Error while updating two factor requirement of user %d: %v", id, err)
+		return errors.New("could not update two factor requirement")
+	}
+	return nil
+}
+
+// PromoteUsers gives the role to the existing users with one of the emails
+func (r adminRepository) PromoteUsers(emails []string, role string) error {
+	if len(emails) == 0 {
+		return nil
+	}
+	err := r.db.Model(&domain.User{}).Where("email IN ?", emails).Update("user_type", role).Error
+	if err != nil {
+		log.Printf("Error while promoting users to %s: %v", role, err)
+		return errors.New("could not promote users")
+	}
+	return nil
+}
+
+// GrantRole is a no-op when the user already holds the granted role
+func (r adminRepository) GrantRole(grant *domain.RoleGrant) error {
+	err := r.db.Clauses(clause.OnConflict{DoNothing: true}).Create(grant).Error
+	if err != nil {
+		log.Printf("Error while granting role %s to user %d: %v", grant.Role, grant.UserID, err)
+		return errors.New("could not grant role")
+	}
+	return nil
+}
+
+func (r adminRepository) RevokeRole(id uint, role string) error {
+	err := r.db.Where("user_id = ? AND role = ?", id, role).Delete(&domain.RoleGrant{}).Error
+	if err != nil {
+		log.Printf("Error while revoking role %s from user %d: %v", role, id, err)
+		return errors.New("could not revoke role")
+	}
+	return nil
+}
+
+func NewAdminRepository(db *gorm.DB) AdminRepository {
+	return &adminRepository{db}
+}
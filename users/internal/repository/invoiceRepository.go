@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"gorm.io/gorm"
+	"log"
+)
+
+type InvoiceRepository interface {
+	CreateInvoice(i *domain.Invoice) error
+	UpdateInvoice(i domain.Invoice) error
+	FindInvoiceByID(id uint) (domain.Invoice, error)
+	FindOrderInvoice(orderId uint) (domain.Invoice, error)
+	FindOrderCreditNotes(orderId uint) ([]domain.Invoice, error)
+}
+
+type invoiceRepository struct {
+	db *gorm.DB
+}
+
+func (r invoiceRepository) CreateInvoice(e *domain.Invoice) error {
+	err := r.db.Create(e).Error
+	if err != nil {
+		log.Printf("Error while creating invoice %v", err)
+		return errors.New("could not create invoice")
+	}
+	return nil
+}
+
+func (r invoiceRepository) UpdateInvoice(e domain.Invoice) error {
+	err := r.db.Save(&e).Error
+	if err != nil {
+		log.Printf("Error while updating invoice %v", err)
+		return errors.New("could not update invoice")
+	}
+	return nil
+}
+
+func (r invoiceRepository) FindInvoiceByID(id uint) (domain.Invoice, error) {
+	invoice := domain.Invoice{}
+	err := r.db.First(&invoice, id).Error
+	if err != nil {
+		return domain.Invoice{}, errors.New("invoice not found")
+	}
+	return invoice, nil
+}
+
+func (r invoiceRepository) FindOrderInvoice(orderId uint) (domain.Invoice, error) {
+	invoice := domain.Invoice{}
+	err := r.db.Where("order_id=? AND type=?", orderId, domain.INVOICE).First(&invoice).Error
+	if err != nil {
+		return domain.Invoice{}, errors.New("invoice not found")
+	}
+	return invoice, nil
+}
+
+func (r invoiceRepository) FindOrderCreditNotes(orderId uint) ([]domain.Invoice, error) {
+	var creditNotes []domain.Invoice
+	err := r.db.Where("order_id=? AND type=?", orderId, domain.CREDIT_NOTE).Order("created_at").Find(&creditNotes).Error
+	if err != nil {
+		log.Printf("Error while fetching credit notes %v", err)
+		return nil, errors.New("could not fetch credit notes")
+	}
+	return creditNotes, nil
+}
+
+func NewInvoiceRepository(db *gorm.DB) InvoiceRepository {
+	return &invoiceRepository{
+		db,
+	}
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+)
+
+type OAuthRepository interface {
+	CreateOAuthState(s *domain.OAuthState) error
+	ConsumeOAuthState(stateHash string) (domain.OAuthState, error)
+
+	FindIdentity(provider string, subject string) (domain.UserIdentity, error)
+	CreateIdentity(i *domain.UserIdentity) error
+	ClaimAccount(userID uint) error
+}
+
+type oauthRepository struct {
+	db *gorm.DB
+}
+
+func (r oauthRepository) CreateOAuthState(s *domain.OAuthState) error {
+	err := r.db.Create(s).Error
+	if err != nil {
+		log.Printf("Error while creating oauth state %v", err)
+		return errors.New("could not start sign in")
+	}
+	return nil
+}
+
+// ConsumeOAuthState deletes and returns the state so a callback can only be used once
+func (r oauthRepository) ConsumeOAuthState(stateHash string) (domain.OAuthState, error) {
+	var states []domain.OAuthState
+	err := r.db.Clauses(clause.Returning{}).Where("state_hash = ?", stateHash).Delete(&states).Error
+	if err != nil {
+		log.Printf("Error while consuming oauth state %v", err)
+		return domain.OAuthState{}, errors.New("could not complete sign in")
+	}
+	if len(states) == 0 {
+		return domain.OAuthState{}, errors.New("sign in state not found")
+	}
+	return states[0], nil
+}
+
+func (r oauthRepository) FindIdentity(provider string, subject string) (domain.UserIdentity, error) {
+	var identity domain.UserIdentity
+	err := r.db.Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if err != nil {
+		return domain.UserIdentity{}, errors.New("identity not found")
+	}
+	return identity, nil
+}
+
+func (r oauthRepository) CreateIdentity(i *domain.UserIdentity) error {
+	err := r.db.Create(i).Error
+	if err != nil {
+		log.Printf("Error while linking identity %v", err)
+		return errors.New("could not link the account")
+	}
+	return nil
+}
+
+// ClaimAccount hands an unverified account to whoever proved they own its email with a provider,
+// the password and two factor setup of whoever registered it are removed with the verification
+func (r oauthRepository) ClaimAccount(userID uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&domain.User{}).Where("id = ?", userID).Updates(map[string]interface{}{
+			"password":    "",
+			"is_verified": true,
+		}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&domain.TwoFactor{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&domain.RecoveryCode{}).Error
+	})
+	if err != nil {
+		log.Printf("Error while claiming account %d: %v", userID, err)
+		return errors.New("could not link the account")
+	}
+	return nil
+}
+
+func NewOAuthRepository(db *gorm.DB) OAuthRepository {
+	return &oauthRepository{db}
+}
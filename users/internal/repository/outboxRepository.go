@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"gorm.io/gorm"
+	"log"
+	"time"
+)
+
+type OutboxRepository interface {
+	FindDueOutboxEvents(now time.Time, limit int) ([]domain.OutboxEvent, error)
+	MarkOutboxEventDelivered(id uint, at time.Time) error
+	MarkOutboxEventFailed(id uint, attempts uint, nextAttemptAt time.Time, reason string) error
+}
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func (r outboxRepository) FindDueOutboxEvents(now time.Time, limit int) ([]domain.OutboxEvent, error) {
+	var events []domain.OutboxEvent
+	err := r.db.Where("delivered_at IS NULL AND next_attempt_at <= ?", now).Order("next_attempt_at").Limit(limit).Find(&events).Error
+	if err != nil {
+		log.Printf("Error while fetching outbox events %v", err)
+		return nil, errors.New("could not fetch outbox events")
+	}
+	return events, nil
+}
+
+func (r outboxRepository) MarkOutboxEventDelivered(id uint, at time.Time) error {
+	err := r.db.Model(&domain.OutboxEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"delivered_at": at,
+		"last_error":   "",
+	}).Error
+	if err != nil {
+		log.Printf("Error while marking outbox event delivered %v", err)
+		return errors.New("could not update outbox event")
+	}
+	return nil
+}
+
+func (r outboxRepository) MarkOutboxEventFailed(id uint, attempts uint, nextAttemptAt time.Time, reason string) error {
+	err := r.db.Model(&domain.OutboxEvent{}).Where("id = ?", id).Updates(map[string]interface{}{
+		"attempts":        attempts,
+		"next_attempt_at": nextAttemptAt,
+		"last_error":      reason,
+	}).Error
+	if err != nil {
+		log.Printf("Error while recording outbox delivery failure %v", err)
+		return errors.New("could not update outbox event")
+	}
+	return nil
+}
+
+func NewOutboxRepository(db *gorm.DB) OutboxRepository {
+	return &outboxRepository{db}
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+)
+
+var (
+	ErrCouponLimitReached     = errors.New("coupon usage limit reached")
+	ErrCouponUserLimitReached = errors.New("you have already used this coupon")
+)
+
+type PromotionRepository interface {
+	CreateCoupon(c *domain.Coupon) error
+	FindCouponByID(id uint) (domain.Coupon, error)
+	FindCouponByCode(code string) (domain.Coupon, error)
+	FindSellerCoupons(sellerId uint) ([]domain.Coupon, error)
+	UpdateCoupon(c domain.Coupon) error
+
+	CountUserRedemptions(couponId uint, userId uint) (int64, error)
+
+	FindCartCoupon(userId uint) (domain.CartCoupon, error)
+	SaveCartCoupon(userId uint, couponId uint) error
+	DeleteCartCoupon(userId uint) error
+}
+
+type promotionRepository struct {
+	db *gorm.DB
+}
+
+func (r promotionRepository) CreateCoupon(c *domain.Coupon) error {
+	err := r.db.Create(c).Error
+	if err != nil {
+		log.Printf("Error while creating coupon %v", err)
+		return errors.New("could not create coupon")
+	}
+	return nil
+}
+
+func (r promotionRepository) FindCouponByID(id uint) (domain.Coupon, error) {
+	coupon := domain.Coupon{}
+	err := r.db.First(&coupon, id).Error
+	if err != nil {
+		return domain.Coupon{}, errors.New("coupon not found")
+	}
+	return coupon, nil
+}
+
+func (r promotionRepository) FindCouponByCode(code string) (domain.Coupon, error) {
+	coupon := domain.Coupon{}
+	err := r.db.First(&coupon, "code=?", code).Error
+	if err != nil {
+		return domain.Coupon{}, errors.New("coupon not found")
+	}
+	return coupon, nil
+}
+
+func (r promotionRepository) FindSellerCoupons(sellerId uint) ([]domain.Coupon, error) {
+	var coupons []domain.Coupon
+	err := r.db.Where("seller_id=?", sellerId).Order("created_at desc").Find(&coupons).Error
+	if err != nil {
+		log.Printf("Error while fetching coupons %v", err)
+		return nil, errors.New("could not fetch coupons")
+	}
+	return coupons, nil
+}
+
+func (r promotionRepository) UpdateCoupon(c domain.Coupon) error {
+	err := r.db.Save(&c).Error
+	if err != nil {
+		log.Printf("Error while updating coupon %v", err)
+		return errors.New("could not update coupon")
+	}
+	return nil
+}
+
+// redeemCoupon counts a redemption within the order transaction. The coupon row stays locked until
+// the order is written, so concurrent orders cannot go past the usage limit or the per user limit
+func redeemCoupon(tx *gorm.DB, e *domain.CouponRedemption) error {
+	coupon := domain.Coupon{}
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&coupon, e.CouponID).Error
+	if err != nil {
+		return err
+	}
+
+	if coupon.PerUserLimit > 0 {
+		var count int64
+		err = tx.Model(&domain.CouponRedemption{}).Where("coupon_id=? AND user_id=?", e.CouponID, e.UserID).Count(&count).Error
+		if err != nil {
+			return err
+		}
+		if count >= int64(coupon.PerUserLimit) {
+			return ErrCouponUserLimitReached
+		}
+	}
+
+	result := tx.Model(&domain.Coupon{}).
+		Where("id=? AND (usage_limit=0 OR used_count < usage_limit)", e.CouponID).
+		Update("used_count", gorm.Expr("used_count + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrCouponLimitReached
+	}
+	return tx.Create(e).Error
+}
+
+func (r promotionRepository) CountUserRedemptions(couponId uint, userId uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.CouponRedemption{}).Where("coupon_id=? AND user_id=?", couponId, userId).Count(&count).Error
+	return count, err
+}
+
+func (r promotionRepository) FindCartCoupon(userId uint) (domain.CartCoupon, error) {
+	cartCoupon := domain.CartCoupon{}
+	err := r.db.First(&cartCoupon, "user_id=?", userId).Error
+	return cartCoupon, err
+}
+
+func (r promotionRepository) SaveCartCoupon(userId uint, couponId uint) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"coupon_id"}),
+	}).Create(&domain.CartCoupon{UserID: userId, CouponID: couponId}).Error
+	if err != nil {
+		log.Printf("Error while applying coupon to cart %v", err)
+		return errors.New("could not apply coupon")
+	}
+	return nil
+}
+
+func (r promotionRepository) DeleteCartCoupon(userId uint) error {
+	return r.db.Where("user_id=?", userId).Delete(&domain.CartCoupon{}).Error
+}
+
+func NewPromotionRepository(db *gorm.DB) PromotionRepository {
+	return &promotionRepository{
+		db,
+	}
+}
@@ -0,0 +1,94 @@
+package repository
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+type SecurityRepository interface {
+	FindThrottle(key string) (domain.LoginThrottle, error)
+	IncrementThrottle(key string, at time.Time, window time.Duration) (domain.LoginThrottle, error)
+	LockThrottle(key string, until time.Time) error
+	DeleteThrottle(key string) error
+
+	CreateSecurityEvent(e *domain.SecurityEvent) error
+	FindSecurityEvents(userId uint, limit int) ([]domain.SecurityEvent, error)
+}
+
+type securityRepository struct {
+	db *gorm.DB
+}
+
+func (r securityRepository) FindThrottle(key string) (domain.LoginThrottle, error) {
+	var throttle domain.LoginThrottle
+	err := r.db.Where("key = ?", key).First(&throttle).Error
+	if err != nil {
+		return domain.LoginThrottle{}, errors.New("login throttle not found")
+	}
+	return throttle, nil
+}
+
+// IncrementThrottle atomically counts a failure, failures older than the window start a new count
+func (r securityRepository) IncrementThrottle(key string, at time.Time, window time.Duration) (domain.LoginThrottle, error) {
+	throttle := domain.LoginThrottle{Key: key, Failures: 1, LastFailureAt: at}
+	err := r.db.Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]interface{}{
+				"failures":        gorm.Expr("CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END", at.Add(-window)),
+				"last_failure_at": at,
+			}),
+		},
+		clause.Returning{},
+	).Create(&throttle).Error
+	if err != nil {
+		log.Printf("Error while counting failed login %v", err)
+		return domain.LoginThrottle{}, errors.New("could not record failed login")
+	}
+	return throttle, nil
+}
+
+func (r securityRepository) LockThrottle(key string, until time.Time) error {
+	err := r.db.Model(&domain.LoginThrottle{}).Where("key = ?", key).Update("locked_until", until).Error
+	if err != nil {
+		log.Printf("Error while locking login %v", err)
+		return errors.New("could not lock login")
+	}
+	return nil
+}
+
+func (r securityRepository) DeleteThrottle(key string) error {
+	err := r.db.Where("key = ?", key).Delete(&domain.LoginThrottle{}).Error
+	if err != nil {
+		log.Printf("Error while clearing login throttle %v", err)
+		return errors.New("could not clear login throttle")
+	}
+	return nil
+}
+
+func (r securityRepository) CreateSecurityEvent(e *domain.SecurityEvent) error {
+	err := r.db.Create(e).Error
+	if err != nil {
+		log.Printf("Error while recording security event %v", err)
+		return errors.New("could not record security event")
+	}
+	return nil
+}
+
+func (r securityRepository) FindSecurityEvents(userId uint, limit int) ([]domain.SecurityEvent, error) {
+	var events []domain.SecurityEvent
+	err := r.db.Where("user_id = ?", userId).Order("created_at desc").Limit(limit).Find(&events).Error
+	if err != nil {
+		log.Printf("Error while fetching security events %v", err)
+		return nil, errors.New("could not fetch security events")
+	}
+	return events, nil
+}
+
+func NewSecurityRepository(db *gorm.DB) SecurityRepository {
+	return &securityRepository{db}
+}
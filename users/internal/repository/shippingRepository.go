@@ -0,0 +1,151 @@
+package repository
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"gorm.io/gorm"
+	"log"
+)
+
+type ShippingRepository interface {
+	CreateShippingMethod(m *domain.ShippingMethod) error
+	FindShippingMethods(activeOnly bool) ([]domain.ShippingMethod, error)
+	FindShippingMethodByCode(code string) (domain.ShippingMethod, error)
+	CountShippingMethods() (int64, error)
+	FindShippingMethodByID(id uint) (domain.ShippingMethod, error)
+	UpdateShippingMethod(m *domain.ShippingMethod) error
+	DeleteShippingMethod(id uint) error
+	CreateShippingRate(rate *domain.ShippingRate) error
+	FindShippingRate(methodID uint, id uint) (domain.ShippingRate, error)
+	UpdateShippingRate(rate *domain.ShippingRate) error
+	DeleteShippingRate(methodID uint, id uint) error
+}
+
+type shippingRepository struct {
+	db *gorm.DB
+}
+
+func (r shippingRepository) CreateShippingMethod(m *domain.ShippingMethod) error {
+	err := r.db.Create(m).Error
+	if err != nil {
+		log.Printf("Error while creating shipping method %v", err)
+		return errors.New("could not create shipping method")
+	}
+	return nil
+}
+
+func (r shippingRepository) FindShippingMethods(activeOnly bool) ([]domain.ShippingMethod, error) {
+	var methods []domain.ShippingMethod
+	query := r.db.Preload("Rates")
+	if activeOnly {
+		query = query.Where("active=?", true)
+	}
+	err := query.Order("id").Find(&methods).Error
+	if err != nil {
+		log.Printf("Error while fetching shipping methods %v", err)
+		return nil, errors.New("could not fetch shipping methods")
+	}
+	return methods, nil
+}
+
+func (r shippingRepository) FindShippingMethodByCode(code string) (domain.ShippingMethod, error) {
+	method := domain.ShippingMethod{}
+	err := r.db.Preload("Rates").First(&method, "code=?", code).Error
+	if err != nil {
+		return domain.ShippingMethod{}, errors.New("shipping method not found")
+	}
+	return method, nil
+}
+
+func (r shippingRepository) CountShippingMethods() (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.ShippingMethod{}).Count(&count).Error
+	return count, err
+}
+
+func (r shippingRepository) FindShippingMethodByID(id uint) (domain.ShippingMethod, error) {
+	method := domain.ShippingMethod{}
+	err := r.db.Preload("Rates").First(&method, id).Error
+	if err != nil {
+		return domain.ShippingMethod{}, errors.New("shipping method not found")
+	}
+	return method, nil
+}
+
+// UpdateShippingMethod saves the details of a method, the code and the rates are left as they are
+func (r shippingRepository) UpdateShippingMethod(m *domain.ShippingMethod) error {
+	err := r.db.Model(&domain.ShippingMethod{}).Where("id = ?", m.ID).Updates(map[string]interface{}{
+		"name":        m.Name,
+		"description": m.Description,
+		"min_days":    m.MinDays,
+		"max_days":    m.MaxDays,
+		"active":      m.Active,
+	}).Error
+	if err != nil {
+		log.Printf("Error while updating shipping method %d: %v", m.ID, err)
+		return errors.New("could not update shipping method")
+	}
+	return nil
+}
+
+func (r shippingRepository) DeleteShippingMethod(id uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("shipping_method_id = ?", id).Delete(&domain.ShippingRate{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&domain.ShippingMethod{}, id).Error
+	})
+	if err != nil {
+		log.Printf("Error while deleting shipping method %d: %v", id, err)
+		return errors.New("could not delete shipping method")
+	}
+	return nil
+}
+
+func (r shippingRepository) CreateShippingRate(rate *domain.ShippingRate) error {
+	err := r.db.Create(rate).Error
+	if err != nil {
+		log.Printf("Error while creating shipping rate %v", err)
+		return errors.New("could not create shipping rate")
+	}
+	return nil
+}
+
+func (r shippingRepository) FindShippingRate(methodID uint, id uint) (domain.ShippingRate, error) {
+	rate := domain.ShippingRate{}
+	err := r.db.First(&rate, "id = ? AND shipping_method_id = ?", id, methodID).Error
+	if err != nil {
+		return domain.ShippingRate{}, errors.New("shipping rate not found")
+	}
+	return rate, nil
+}
+
+func (r shippingRepository) UpdateShippingRate(rate *domain.ShippingRate) error {
+	err := r.db.Model(&domain.ShippingRate{}).Where("id = ?", rate.ID).Updates(map[string]interface{}{
+		"country":         rate.Country,
+		"max_weight":      rate.MaxWeight,
+		"min_order_value": rate.MinOrderValue,
+		"cost":            rate.Cost,
+		"free_above":      rate.FreeAbove,
+	}).Error
+	if err != nil {
+		log.Printf("Error while updating shipping rate %d: %v", rate.ID, err)
+		return errors.New("could not update shipping rate")
+	}
+	return nil
+}
+
+func (r shippingRepository) DeleteShippingRate(methodID uint, id uint) error {
+	err := r.db.Where("shipping_method_id = ?", methodID).Delete(&domain.ShippingRate{}, id).Error
+	if err != nil {
+		log.Printf("Error while deleting shipping rate %d: %v", id, err)
+		return errors.New("could not delete shipping rate")
+	}
+	return nil
+}
+
+func NewShippingRepository(db *gorm.DB) ShippingRepository {
+	return &shippingRepository{
+		db,
+	}
+}
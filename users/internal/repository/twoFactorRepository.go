@@ -0,0 +1,156 @@
+package repository
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"log"
+	"time"
+)
+
+type TwoFactorRepository interface {
+	FindTwoFactor(userId uint) (domain.TwoFactor, error)
+	SaveTwoFactor(t domain.TwoFactor) error
+	UseTwoFactorStep(userId uint, step int64) (bool, error)
+	DeleteTwoFactor(userId uint) error
+
+	ReplaceRecoveryCodes(userId uint, codeHashes []string) error
+	UseRecoveryCode(userId uint, codeHash string, at time.Time) (bool, error)
+	CountRecoveryCodes(userId uint) (int64, error)
+
+	CreateLoginChallenge(c *domain.LoginChallenge) error
+	FindLoginChallenge(tokenHash string) (domain.LoginChallenge, error)
+	IncrementChallengeAttempts(id uint) error
+	DeleteLoginChallenge(id uint) error
+}
+
+type twoFactorRepository struct {
+	db *gorm.DB
+}
+
+func (r twoFactorRepository) FindTwoFactor(userId uint) (domain.TwoFactor, error) {
+	var twoFactor domain.TwoFactor
+	err := r.db.Where("user_id = ?", userId).First(&twoFactor).Error
+	if err != nil {
+		return domain.TwoFactor{}, errors.New("two factor authentication is not set up")
+	}
+	return twoFactor, nil
+}
+
+// SaveTwoFactor creates or replaces the enrollment of the user
+func (r twoFactorRepository) SaveTwoFactor(t domain.TwoFactor) error {
+	err := r.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"secret", "enabled", "confirmed_at", "last_used_step", "updated_at"}),
+	}).Create(&t).Error
+	if err != nil {
+		log.Printf("Error while saving two factor enrollment %v", err)
+		return errors.New("could not save two factor authentication")
+	}
+	return nil
+}
+
+// UseTwoFactorStep records the step of an accepted code, it reports false when that or a later step was already used
+func (r twoFactorRepository) UseTwoFactorStep(userId uint, step int64) (bool, error) {
+	result := r.db.Model(&domain.TwoFactor{}).Where("user_id = ? AND last_used_step < ?", userId, step).Update("last_used_step", step)
+	if result.Error != nil {
+		log.Printf("Error while recording two factor code %v", result.Error)
+		return false, errors.New("could not verify code")
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r twoFactorRepository) DeleteTwoFactor(userId uint) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ?", userId).Delete(&domain.RecoveryCode{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userId).Delete(&domain.TwoFactor{}).Error
+	})
+	if err != nil {
+		log.Printf("Error while removing two factor authentication %v", err)
+		return errors.New("could not disable two factor authentication")
+	}
+	return nil
+}
+
+func (r twoFactorRepository) ReplaceRecoveryCodes(userId uint, codeHashes []string) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("user_id = ?", userId).Delete(&domain.RecoveryCode{}).Error
+		if err != nil {
+			return err
+		}
+		codes := make([]domain.RecoveryCode, 0, len(codeHashes))
+		for _, hash := range codeHashes {
+			codes = append(codes, domain.RecoveryCode{UserID: userId, CodeHash: hash})
+		}
+		return tx.Create(&codes).Error
+	})
+	if err != nil {
+		log.Printf("Error while storing recovery codes %v", err)
+		return errors.New("could not create recovery codes")
+	}
+	return nil
+}
+
+// UseRecoveryCode marks an unused code as used, it reports false when no such code is left
+func (r twoFactorRepository) UseRecoveryCode(userId uint, codeHash string, at time.Time) (bool, error) {
+	result := r.db.Model(&domain.RecoveryCode{}).Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, codeHash).Update("used_at", at)
+	if result.Error != nil {
+		log.Printf("Error while using recovery code %v", result.Error)
+		return false, errors.New("could not verify recovery code")
+	}
+	return result.RowsAffected == 1, nil
+}
+
+func (r twoFactorRepository) CountRecoveryCodes(userId uint) (int64, error) {
+	var count int64
+	err := r.db.Model(&domain.RecoveryCode{}).Where("user_id = ? AND used_at IS NULL", userId).Count(&count).Error
+	if err != nil {
+		log.Printf("Error while counting recovery codes %v", err)
+		return 0, errors.New("could not count recovery codes")
+	}
+	return count, nil
+}
+
+func (r twoFactorRepository) CreateLoginChallenge(c *domain.LoginChallenge) error {
+	err := r.db.Create(c).Error
+	if err != nil {
+		log.Printf("Error while creating login challenge %v", err)
+		return errors.New("could not start two factor sign in")
+	}
+	return nil
+}
+
+func (r twoFactorRepository) FindLoginChallenge(tokenHash string) (domain.LoginChallenge, error) {
+	var challenge domain.LoginChallenge
+	err := r.db.Where("token_hash = ?", tokenHash).First(&challenge).Error
+	if err != nil {
+		return domain.LoginChallenge{}, errors.New("login challenge not found")
+	}
+	return challenge, nil
+}
+
+func (r twoFactorRepository) IncrementChallengeAttempts(id uint) error {
+	err := r.db.Model(&domain.LoginChallenge{}).Where("id = ?", id).Update("attempts", gorm.Expr("attempts + 1")).Error
+	if err != nil {
+		log.Printf("Error while counting login challenge attempt %v", err)
+		return errors.New("could not verify code")
+	}
+	return nil
+}
+
+func (r twoFactorRepository) DeleteLoginChallenge(id uint) error {
+	err := r.db.Delete(&domain.LoginChallenge{}, id).Error
+	if err != nil {
+		log.Printf("Error while deleting login challenge %v", err)
+		return errors.New("could not finish two factor sign in")
+	}
+	return nil
+}
+
+func NewTwoFactorRepository(db *gorm.DB) TwoFactorRepository {
+	return &twoFactorRepository{db}
+}
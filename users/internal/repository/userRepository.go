@@ -6,8 +6,19 @@ import (
 	"gorm.io/gorm"
 	"gorm.io/gorm/clause"
 	"log"
+	"time"
 )
 
+// OrderQuery narrows and pages a buyer's order history
+type OrderQuery struct {
+	Status string
+	From   time.Time
+	To     time.Time
+	Search string
+	Offset int
+	Limit  int
+}
+
 type UserRepository interface {
 	CreateUser(u domain.User) (domain.User, error)
 	FindUser(email string) (domain.User, error)
@@ -23,10 +34,41 @@ type UserRepository interface {
 	DeleteCartItems(userId uint) error
 	DeleteCartItem(userID, productID uint) error
 
+	//guest cart operations
+	FindGuestCartItems(token string) ([]domain.Cart, error)
+	FindGuestCartItem(token string, productId uint) (domain.Cart, error)
+	DeleteGuestCartItem(token string, productId uint) error
+	DeleteGuestCartItems(token string) error
+	AssignCartItemToUser(id uint, userId uint) error
+
 	//order operations
-	FindOrders(userId uint) ([]domain.Order, error)
-	CreateOrder(order domain.Order) error
+	FindOrders(userId uint, query OrderQuery) ([]domain.Order, int64, error)
+	CountOrderItems(orderIds []uint) (map[uint]uint, error)
+	CreateOrder(order *domain.Order, redemption *domain.CouponRedemption) error
 	FindOrderByID(orderId uint, userId uint) (domain.Order, error)
+	FindOrder(orderId uint) (domain.Order, error)
+	UpdateOrderStatus(orderId uint, status string) error
+	UpdateOrderItemsStatus(orderId uint, status string) error
+	CreateOrderStatusHistory(h domain.OrderStatusHistory) error
+
+	//sub-order operations
+	FindSubOrder(id uint) (domain.SubOrder, error)
+	FindSellerSubOrder(id uint, sellerId uint) (domain.SubOrder, error)
+	UpdateSubOrder(subOrder domain.SubOrder) error
+	UpdateSubOrdersStatus(orderId uint, status string) error
+
+	//return operations
+	CreateReturnRequest(r *domain.ReturnRequest) error
+	FindReturnRequestByID(id uint) (domain.ReturnRequest, error)
+	FindUserReturnRequests(userId uint) ([]domain.ReturnRequest, error)
+	FindSellerReturnRequests(sellerId uint, status string) ([]domain.ReturnRequest, error)
+	FindOrderItemReturnRequests(itemId uint) ([]domain.ReturnRequest, error)
+	UpdateReturnRequest(r domain.ReturnRequest) error
+
+	//seller operations
+	FindSellerOrders(sellerId uint, status string, from, to time.Time) ([]domain.Order, error)
+	FindSellerOrderItem(itemId uint, sellerId uint) (domain.OrderItem, error)
+	UpdateOrderItem(item domain.OrderItem) error
 
 	// profile operations
 	CreateProfile(e domain.Address) error
@@ -49,7 +91,7 @@ func (r userRepository) CreateUser(user domain.User) (domain.User, error) {
 func (r userRepository) FindUser(email string) (domain.User, error) {
 	var user domain.User
 
-	err := r.db.Preload("Address").First(&user, "email=?", email).Error
+	err := r.db.Preload("Address").Preload("RoleGrants").First(&user, "email=?", email).Error
 	if err != nil {
 		log.Printf("Could not find user with the email %s: %v", email, err)
 		return domain.User{}, errors.New("could not find user")
@@ -61,7 +103,7 @@ func (r userRepository) FindUser(email string) (domain.User, error) {
 func (r userRepository) FindUserByID(id uint) (domain.User, error) {
 	var user domain.User
 
-	err := r.db.Preload("Address").First(&user, id).Error
+	err := r.db.Preload("Address").Preload("RoleGrants").First(&user, id).Error
 	if err != nil {
 		log.Printf("Could not find user with the id %d: %v", id, err)
 		return domain.User{}, errors.New("could not find user")
@@ -85,7 +127,7 @@ func (r userRepository) UpdateUser(id uint, u domain.User) (domain.User, error)
 
 func (r userRepository) FindOrderByID(orderId uint, userId uint) (domain.Order, error) {
 	order := domain.Order{}
-	err := r.db.Preload("Items").Where("id=? AND user_id=?", orderId, userId).First(&order).Error
+	err := r.db.Preload("Items").Preload("SubOrders.Items").Preload("StatusHistory").Where("id=? AND user_id=?", orderId, userId).First(&order).Error
 	if err != nil {
 		log.Printf("Error while fetching order %v", err)
 		return domain.Order{}, errors.New("could not fetch order")
@@ -93,18 +135,280 @@ func (r userRepository) FindOrderByID(orderId uint, userId uint) (domain.Order,
 	return order, nil
 }
 
-func (r userRepository) FindOrders(userId uint) ([]domain.Order, error) {
+func (r userRepository) FindOrders(userId uint, query OrderQuery) ([]domain.Order, int64, error) {
 	var orders []domain.Order
-	err := r.db.Preload("Items").Where("user_id = ?", userId).Find(&orders).Error
+	var total int64
+
+	db := r.db.Model(&domain.Order{}).Where("user_id = ?", userId)
+	if query.Status != "" {
+		db = db.Where("status = ?", query.Status)
+	}
+	if !query.From.IsZero() {
+		db = db.Where("created_at >= ?", query.From)
+	}
+	if !query.To.IsZero() {
+		db = db.Where("created_at < ?", query.To)
+	}
+	if query.Search != "" {
+		pattern := "%" + query.Search + "%"
+		matchingItems := r.db.Model(&domain.OrderItem{}).Select("order_id").Where("name ILIKE ?", pattern)
+		db = db.Where("order_ref_number ILIKE ? OR id IN (?)", pattern, matchingItems)
+	}
+
+	err := db.Count(&total).Error
+	if err != nil {
+		log.Printf("Error while counting orders %v", err)
+		return nil, 0, errors.New("could not fetch orders")
+	}
+
+	err = db.Order("created_at desc").Offset(query.Offset).Limit(query.Limit).Find(&orders).Error
 	if err != nil {
 		log.Printf("Error while fetching orders %v", err)
-		return nil, errors.New("could not fetch orders")
+		return nil, 0, errors.New("could not fetch orders")
+	}
+	return orders, total, nil
+}
+
+func (r userRepository) CountOrderItems(orderIds []uint) (map[uint]uint, error) {
+	var rows []struct {
+		OrderID uint
+		Qty     uint
+	}
+	counts := map[uint]uint{}
+	if len(orderIds) == 0 {
+		return counts, nil
+	}
+
+	err := r.db.Model(&domain.OrderItem{}).Select("order_id, SUM(qty) AS qty").
+		Where("order_id IN ?", orderIds).Group("order_id").Scan(&rows).Error
+	if err != nil {
+		log.Printf("Error while counting order items %v", err)
+		return nil, errors.New("could not count order items")
+	}
+	for _, row := range rows {
+		counts[row.OrderID] = row.Qty
+	}
+	return counts, nil
+}
+
+func (r userRepository) FindOrder(orderId uint) (domain.Order, error) {
+	order := domain.Order{}
+	err := r.db.Preload("Items").Preload("SubOrders").First(&order, orderId).Error
+	if err != nil {
+		log.Printf("Error while fetching order %v", err)
+		return domain.Order{}, errors.New("could not fetch order")
+	}
+	return order, nil
+}
+
+func (r userRepository) UpdateOrderStatus(orderId uint, status string) error {
+	err := r.db.Model(&domain.Order{}).Where("id=?", orderId).Update("status", status).Error
+	if err != nil {
+		log.Printf("Error while updating order status %v", err)
+		return errors.New("could not update order status")
+	}
+	return nil
+}
+
+func (r userRepository) FindSellerSubOrder(id uint, sellerId uint) (domain.SubOrder, error) {
+	subOrder := domain.SubOrder{}
+	err := r.db.Preload("Items").Where("id=? AND seller_id=?", id, sellerId).First(&subOrder).Error
+	if err != nil {
+		log.Printf("Error while fetching sub-order %v", err)
+		return domain.SubOrder{}, errors.New("could not find shipment")
+	}
+	return subOrder, nil
+}
+
+func (r userRepository) FindSubOrder(id uint) (domain.SubOrder, error) {
+	subOrder := domain.SubOrder{}
+	err := r.db.Preload("Items").First(&subOrder, id).Error
+	if err != nil {
+		log.Printf("Error while fetching sub-order %v", err)
+		return domain.SubOrder{}, errors.New("could not find shipment")
+	}
+	return subOrder, nil
+}
+
+func (r userRepository) UpdateSubOrder(subOrder domain.SubOrder) error {
+	err := r.db.Omit("Items").Save(&subOrder).Error
+	if err != nil {
+		log.Printf("Error while updating sub-order %v", err)
+		return errors.New("could not update shipment")
+	}
+	return nil
+}
+
+func (r userRepository) UpdateSubOrdersStatus(orderId uint, status string) error {
+	err := r.db.Model(&domain.SubOrder{}).Where("order_id=?", orderId).Update("status", status).Error
+	if err != nil {
+		log.Printf("Error while updating sub-orders status %v", err)
+		return errors.New("could not update shipments status")
+	}
+	return nil
+}
+
+func (r userRepository) UpdateOrderItemsStatus(orderId uint, status string) error {
+	err := r.db.Model(&domain.OrderItem{}).Where("order_id=?", orderId).Update("status", status).Error
+	if err != nil {
+		log.Printf("Error while updating order items status %v", err)
+		return errors.New("could not update order items status")
+	}
+	return nil
+}
+
+func (r userRepository) CreateOrderStatusHistory(h domain.OrderStatusHistory) error {
+	err := r.db.Create(&h).Error
+	if err != nil {
+		log.Printf("Error while creating order status history %v", err)
+		return errors.New("could not record order status")
+	}
+	return nil
+}
+
+func (r userRepository) CreateReturnRequest(e *domain.ReturnRequest) error {
+	err := r.db.Create(e).Error
+	if err != nil {
+		log.Printf("Error while creating return request %v", err)
+		return errors.New("could not create return request")
+	}
+	return nil
+}
+
+func (r userRepository) FindReturnRequestByID(id uint) (domain.ReturnRequest, error) {
+	returnRequest := domain.ReturnRequest{}
+	err := r.db.First(&returnRequest, id).Error
+	if err != nil {
+		log.Printf("Error while fetching return request %v", err)
+		return domain.ReturnRequest{}, errors.New("could not find return request")
+	}
+	return returnRequest, nil
+}
+
+func (r userRepository) FindUserReturnRequests(userId uint) ([]domain.ReturnRequest, error) {
+	var returnRequests []domain.ReturnRequest
+	err := r.db.Where("user_id=?", userId).Order("created_at desc").Find(&returnRequests).Error
+	if err != nil {
+		log.Printf("Error while fetching return requests %v", err)
+		return nil, errors.New("could not fetch return requests")
+	}
+	return returnRequests, nil
+}
+
+func (r userRepository) FindSellerReturnRequests(sellerId uint, status string) ([]domain.ReturnRequest, error) {
+	var returnRequests []domain.ReturnRequest
+	query := r.db.Where("seller_id=?", sellerId)
+	if status != "" {
+		query = query.Where("status=?", status)
+	}
+	err := query.Order("created_at desc").Find(&returnRequests).Error
+	if err != nil {
+		log.Printf("Error while fetching seller return requests %v", err)
+		return nil, errors.New("could not fetch return requests")
+	}
+	return returnRequests, nil
+}
+
+func (r userRepository) FindOrderItemReturnRequests(itemId uint) ([]domain.ReturnRequest, error) {
+	var returnRequests []domain.ReturnRequest
+	err := r.db.Where("order_item_id=?", itemId).Find(&returnRequests).Error
+	if err != nil {
+		log.Printf("Error while fetching return requests for item %v", err)
+		return nil, errors.New("could not fetch return requests")
+	}
+	return returnRequests, nil
+}
+
+func (r userRepository) UpdateReturnRequest(e domain.ReturnRequest) error {
+	err := r.db.Save(&e).Error
+	if err != nil {
+		log.Printf("Error while updating return request %v", err)
+		return errors.New("could not update return request")
+	}
+	return nil
+}
+
+func (r userRepository) FindSellerOrders(sellerId uint, status string, from, to time.Time) ([]domain.Order, error) {
+	var orders []domain.Order
+
+	sellerItems := func(db *gorm.DB) *gorm.DB {
+		db = db.Where("seller_id=?", sellerId)
+		if status != "" {
+			db = db.Where("status=?", status)
+		}
+		return db
+	}
+
+	orderIds := sellerItems(r.db.Model(&domain.OrderItem{}).Select("order_id"))
+	query := r.db.Preload("Items", sellerItems).Preload("SubOrders", "seller_id=?", sellerId).Where("id IN (?)", orderIds)
+	if !from.IsZero() {
+		query = query.Where("created_at >= ?", from)
+	}
+	if !to.IsZero() {
+		query = query.Where("created_at < ?", to)
+	}
+
+	err := query.Order("created_at desc").Find(&orders).Error
+	if err != nil {
+		log.Printf("Error while fetching seller orders %v", err)
+		return nil, errors.New("could not fetch seller orders")
 	}
 	return orders, nil
 }
 
-func (r userRepository) CreateOrder(order domain.Order) error {
-	err := r.db.Create(&order).Error
+func (r userRepository) FindSellerOrderItem(itemId uint, sellerId uint) (domain.OrderItem, error) {
+	item := domain.OrderItem{}
+	err := r.db.Where("id=? AND seller_id=?", itemId, sellerId).First(&item).Error
+	if err != nil {
+		log.Printf("Error while fetching order item %v", err)
+		return domain.OrderItem{}, errors.New("could not find order item")
+	}
+	return item, nil
+}
+
+func (r userRepository) UpdateOrderItem(item domain.OrderItem) error {
+	err := r.db.Save(&item).Error
+	if err != nil {
+		log.Printf("Error while updating order item %v", err)
+		return errors.New("could not update order item")
+	}
+	return nil
+}
+
+// CreateOrder stores the order with its items, then its sub-orders, linking every item to the sub-order of its seller
+// CreateOrder writes the order with its sub orders, and the coupon redemption when a coupon was used.
+// The order is not written when the coupon has reached one of its limits
+func (r userRepository) CreateOrder(order *domain.Order, redemption *domain.CouponRedemption) error {
+	err := r.db.Transaction(func(tx *gorm.DB) error {
+		subOrders := order.SubOrders
+		order.SubOrders = nil
+		if err := tx.Omit("SubOrders").Create(order).Error; err != nil {
+			return err
+		}
+
+		for i := range subOrders {
+			subOrders[i].OrderID = order.ID
+			if err := tx.Omit("Items").Create(&subOrders[i]).Error; err != nil {
+				return err
+			}
+			err := tx.Model(&domain.OrderItem{}).
+				Where("order_id=? AND seller_id=?", order.ID, subOrders[i].SellerId).
+				Update("sub_order_id", subOrders[i].ID).Error
+			if err != nil {
+				return err
+			}
+		}
+		order.SubOrders = subOrders
+
+		if redemption != nil {
+			redemption.OrderID = order.ID
+			return redeemCoupon(tx, redemption)
+		}
+		return nil
+	})
+	if errors.Is(err, ErrCouponLimitReached) || errors.Is(err, ErrCouponUserLimitReached) {
+		return err
+	}
 	if err != nil {
 		log.Printf("Error while creating order %v", err)
 		return errors.New("could not create order")
@@ -167,6 +471,33 @@ func (r userRepository) DeleteCartItems(userId uint) error {
 	return err
 }
 
+func (r userRepository) FindGuestCartItems(token string) ([]domain.Cart, error) {
+	var carts []domain.Cart
+	err := r.db.Where("guest_token=?", token).Find(&carts).Error
+	return carts, err
+}
+
+func (r userRepository) FindGuestCartItem(token string, productId uint) (domain.Cart, error) {
+	cartItem := domain.Cart{}
+	err := r.db.First(&cartItem, "guest_token=? AND product_id=?", token, productId).Error
+	return cartItem, err
+}
+
+func (r userRepository) DeleteGuestCartItem(token string, productId uint) error {
+	return r.db.Where("guest_token=? AND product_id=?", token, productId).Delete(&domain.Cart{}).Error
+}
+
+func (r userRepository) DeleteGuestCartItems(token string) error {
+	return r.db.Where("guest_token=?", token).Delete(&domain.Cart{}).Error
+}
+
+func (r userRepository) AssignCartItemToUser(id uint, userId uint) error {
+	return r.db.Model(&domain.Cart{}).Where("id=?", id).Updates(map[string]interface{}{
+		"user_id":     userId,
+		"guest_token": "",
+	}).Error
+}
+
 // NewUserRepository creates a new user repository
 func NewUserRepository(db *gorm.DB) UserRepository {
 	return &userRepository{
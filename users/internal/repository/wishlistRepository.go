@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"gorm.io/gorm"
+	"log"
+)
+
+type WishlistRepository interface {
+	CreateWishlist(w *domain.Wishlist) error
+	FindWishlists(userId uint) ([]domain.Wishlist, error)
+	FindWishlistByID(id uint, userId uint) (domain.Wishlist, error)
+	FindSaveForLaterList(userId uint) (domain.Wishlist, error)
+	FindWishlistByShareToken(token string) (domain.Wishlist, error)
+	UpdateWishlist(w domain.Wishlist) error
+	DeleteWishlist(id uint) error
+
+	CreateWishlistItem(item *domain.WishlistItem) error
+	FindWishlistItem(wishlistId uint, productId uint) (domain.WishlistItem, error)
+	UpdateWishlistItem(item domain.WishlistItem) error
+	DeleteWishlistItem(wishlistId uint, productId uint) error
+}
+
+type wishlistRepository struct {
+	db *gorm.DB
+}
+
+func (r wishlistRepository) CreateWishlist(w *domain.Wishlist) error {
+	err := r.db.Create(w).Error
+	if err != nil {
+		log.Printf("Error while creating wishlist %v", err)
+		return errors.New("could not create wishlist")
+	}
+	return nil
+}
+
+func (r wishlistRepository) FindWishlists(userId uint) ([]domain.Wishlist, error) {
+	var wishlists []domain.Wishlist
+	err := r.db.Preload("Items").Where("user_id=?", userId).Order("created_at").Find(&wishlists).Error
+	if err != nil {
+		log.Printf("Error while fetching wishlists %v", err)
+		return nil, errors.New("could not fetch wishlists")
+	}
+	return wishlists, nil
+}
+
+func (r wishlistRepository) FindWishlistByID(id uint, userId uint) (domain.Wishlist, error) {
+	wishlist := domain.Wishlist{}
+	err := r.db.Preload("Items").Where("id=? AND user_id=?", id, userId).First(&wishlist).Error
+	if err != nil {
+		log.Printf("Error while fetching wishlist %v", err)
+		return domain.Wishlist{}, errors.New("could not find wishlist")
+	}
+	return wishlist, nil
+}
+
+func (r wishlistRepository) FindSaveForLaterList(userId uint) (domain.Wishlist, error) {
+	wishlist := domain.Wishlist{}
+	err := r.db.Preload("Items").Where("user_id=? AND is_save_for_later=?", userId, true).First(&wishlist).Error
+	if err != nil {
+		return domain.Wishlist{}, errors.New("could not find save for later list")
+	}
+	return wishlist, nil
+}
+
+func (r wishlistRepository) FindWishlistByShareToken(token string) (domain.Wishlist, error) {
+	wishlist := domain.Wishlist{}
+	err := r.db.Preload("Items").Where("share_token=? AND is_public=?", token, true).First(&wishlist).Error
+	if err != nil {
+		log.Printf("Error while fetching shared wishlist %v", err)
+		return domain.Wishlist{}, errors.New("could not find wishlist")
+	}
+	return wishlist, nil
+}
+
+func (r wishlistRepository) UpdateWishlist(w domain.Wishlist) error {
+	err := r.db.Model(&domain.Wishlist{}).Where("id=?", w.ID).Updates(map[string]interface{}{
+		"name":        w.Name,
+		"is_public":   w.IsPublic,
+		"share_token": w.ShareToken,
+	}).Error
+	if err != nil {
+		log.Printf("Error while updating wishlist %v", err)
+		return errors.New("could not update wishlist")
+	}
+	return nil
+}
+
+func (r wishlistRepository) DeleteWishlist(id uint) error {
+	return r.db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Where("wishlist_id=?", id).Delete(&domain.WishlistItem{}).Error
+		if err != nil {
+			return err
+		}
+		return tx.Delete(&domain.Wishlist{}, id).Error
+	})
+}
+
+func (r wishlistRepository) CreateWishlistItem(item *domain.WishlistItem) error {
+	err := r.db.Create(item).Error
+	if err != nil {
+		log.Printf("Error while adding wishlist item %v", err)
+		return errors.New("could not add item to wishlist")
+	}
+	return nil
+}
+
+func (r wishlistRepository) FindWishlistItem(wishlistId uint, productId uint) (domain.WishlistItem, error) {
+	item := domain.WishlistItem{}
+	err := r.db.First(&item, "wishlist_id=? AND product_id=?", wishlistId, productId).Error
+	return item, err
+}
+
+func (r wishlistRepository) UpdateWishlistItem(item domain.WishlistItem) error {
+	return r.db.Save(&item).Error
+}
+
+func (r wishlistRepository) DeleteWishlistItem(wishlistId uint, productId uint) error {
+	return r.db.Where("wishlist_id=? AND product_id=?", wishlistId, productId).Delete(&domain.WishlistItem{}).Error
+}
+
+func NewWishlistRepository(db *gorm.DB) WishlistRepository {
+	return &wishlistRepository{
+		db,
+	}
+}
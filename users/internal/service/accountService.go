@@ -0,0 +1,150 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/sharat789/zamazon-be-ms/users/internal/client"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/events"
+	"log"
+	"time"
+)
+
+type AccountService struct {
+	Repo     repository.AccountRepository
+	Users    repository.UserRepository
+	Auth     *client.AuthClient
+	Invoices InvoiceService
+	Outbox   OutboxService
+}
+
+// Export collects everything held about the user
+func (s AccountService) Export(userID uint) (dto.AccountExport, error) {
+	user, err := s.Users.FindUserByID(userID)
+	if err != nil {
+		return dto.AccountExport{}, err
+	}
+
+	export := dto.AccountExport{
+		ExportedAt: time.Now(),
+		Profile: dto.AccountProfile{
+			ID:         user.ID,
+			FName:      user.FName,
+			LName:      user.LName,
+			Email:      user.Email,
+			Phone:      user.Phone,
+			UserType:   user.UserType,
+			IsVerified: user.IsVerified,
+			CreatedAt:  user.CreatedAt,
+		},
+	}
+	if user.Address.ID != 0 {
+		export.Address = &user.Address
+	}
+
+	if export.Orders, err = s.Repo.FindAccountOrders(userID); err != nil {
+		return dto.AccountExport{}, err
+	}
+	if export.ReturnRequests, err = s.Repo.FindAccountReturns(userID); err != nil {
+		return dto.AccountExport{}, err
+	}
+	if export.Wishlists, err = s.Repo.FindAccountWishlists(userID); err != nil {
+		return dto.AccountExport{}, err
+	}
+	if export.Cart, err = s.Repo.FindAccountCart(userID); err != nil {
+		return dto.AccountExport{}, err
+	}
+	if export.CouponRedemptions, err = s.Repo.FindAccountRedemptions(userID); err != nil {
+		return dto.AccountExport{}, err
+	}
+	if export.Invoices, err = s.Repo.FindAccountInvoices(userID); err != nil {
+		return dto.AccountExport{}, err
+	}
+	return export, nil
+}
+
+// ExportArchive packs the export as account.json alongside the PDF of every invoice and credit note
+func (s AccountService) ExportArchive(userID uint) ([]byte, error) {
+	export, err := s.Export(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+
+	w, err := archive.Create("account.json")
+	if err != nil {
+		return nil, err
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err = encoder.Encode(export); err != nil {
+		return nil, err
+	}
+
+	for _, inv := range export.Invoices {
+		pdf, err := s.Invoices.Store.Get(inv.BlobKey)
+		if err != nil {
+			log.Printf("Error while adding %s to account export %v", inv.Number, err)
+			continue
+		}
+		w, err = archive.Create(fmt.Sprintf("invoices/%s.pdf", inv.Number))
+		if err != nil {
+			return nil, err
+		}
+		if _, err = w.Write(pdf); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = archive.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Delete anonymises the account once nothing is left in flight and tells the other services about it
+func (s AccountService) Delete(userID uint, password string) error {
+	user, err := s.Users.FindUserByID(userID)
+	if err != nil {
+		return err
+	}
+	if user.AnonymisedAt != nil {
+		return errors.New("account is already deleted")
+	}
+
+	_, err = s.Auth.VerifyPassword(password, user.Password)
+	if err != nil {
+		return errors.New("password is incorrect")
+	}
+
+	openOrders, err := s.Repo.CountOpenSubOrders(userID)
+	if err != nil {
+		return err
+	}
+	if openOrders > 0 {
+		return errors.New("account has orders that are still being fulfilled")
+	}
+	openReturns, err := s.Repo.CountOpenReturns(userID)
+	if err != nil {
+		return err
+	}
+	if openReturns > 0 {
+		return errors.New("account has returns that are still being processed")
+	}
+
+	err = s.Auth.RevokeUser(userID)
+	if err != nil {
+		return err
+	}
+
+	// the other services erase their copy of the user's data once the outbox delivers the event
+	now := time.Now()
+	outbox := s.Outbox.Prepare(events.Event{Type: events.USER_DELETED, UserID: userID, OccurredAt: now})
+	return s.Repo.AnonymiseUser(userID, now, outbox)
+}
@@ -0,0 +1,267 @@
+package service
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/client"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"time"
+)
+
+// number of security events shown for a user
+const securityEventLimit = 100
+
+type AdminService struct {
+	Repo     repository.AdminRepository
+	Users    UserService
+	Security SecurityService
+	// Auth signs users out of every service when their account or roles change
+	Auth *client.AuthClient
+}
+
+// SeedAdmins grants the admin role to the configured accounts so a fresh platform can be administered
+func (s AdminService) SeedAdmins(emails []string) error {
+	return s.Repo.PromoteUsers(emails, domain.ADMIN)
+}
+
+func (s AdminService) ListUsers(filter dto.UserFilter) (dto.UserPage, error) {
+	users, total, err := s.Repo.FindUsers(repository.UserQuery{
+		Search: filter.Search,
+		Role:   filter.Role,
+		Status: filter.Status,
+		Offset: (filter.Page - 1) * filter.PageSize,
+		Limit:  filter.PageSize,
+	})
+	if err != nil {
+		return dto.UserPage{}, err
+	}
+
+	page := dto.UserPage{
+		Users:      make([]dto.AdminUser, 0, len(users)),
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		Total:      total,
+		TotalPages: int((total + int64(filter.PageSize) - 1) / int64(filter.PageSize)),
+	}
+	for _, user := range users {
+		page.Users = append(page.Users, adminUser(user))
+	}
+	return page, nil
+}
+
+func (s AdminService) GetUser(id uint) (dto.AdminUser, error) {
+	user, err := s.findManagedUser(id)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	return adminUser(user), nil
+}
+
+func (s AdminService) GetUserOrders(id uint, filter dto.OrderFilter) (dto.OrderHistory, error) {
+	user, err := s.findManagedUser(id)
+	if err != nil {
+		return dto.OrderHistory{}, err
+	}
+	return s.Users.GetOrders(user.ID, filter)
+}
+
+func (s AdminService) GetUserSecurityEvents(id uint) ([]domain.SecurityEvent, error) {
+	user, err := s.findManagedUser(id)
+	if err != nil {
+		return nil, err
+	}
+	return s.Security.GetSecurityEvents(user.ID, securityEventLimit)
+}
+
+func (s AdminService) SuspendUser(adminID uint, id uint, reason string) (dto.AdminUser, error) {
+	if adminID == id {
+		return dto.AdminUser{}, errors.New("admins cannot suspend their own account")
+	}
+	if reason == "" {
+		return dto.AdminUser{}, errors.New("a suspension reason is required")
+	}
+	user, err := s.findManagedUser(id)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	if user.Status == domain.USER_SUSPENDED {
+		return dto.AdminUser{}, errors.New("account is already suspended")
+	}
+
+	err = s.Auth.RevokeUser(id)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	now := time.Now()
+	err = s.Repo.UpdateUserStatus(id, domain.USER_SUSPENDED, reason, &now)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	return s.GetUser(id)
+}
+
+func (s AdminService) ReactivateUser(id uint) (dto.AdminUser, error) {
+	user, err := s.findManagedUser(id)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	if user.Status != domain.USER_SUSPENDED {
+		return dto.AdminUser{}, errors.New("account is not suspended")
+	}
+
+	err = s.Repo.UpdateUserStatus(id, domain.USER_ACTIVE, "", nil)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	return s.GetUser(id)
+}
+
+// ChangeRole switches the role of an account, the user is signed out everywhere so tokens carrying
+// the old role stop working on every service
+func (s AdminService) ChangeRole(adminID uint, id uint, role string) (dto.AdminUser, error) {
+	if !isUserRole(role) {
+		return dto.AdminUser{}, errors.New("role must be buyer, seller or admin")
+	}
+	if adminID == id {
+		return dto.AdminUser{}, errors.New("admins cannot change their own role")
+	}
+	if _, err := s.findManagedUser(id); err != nil {
+		return dto.AdminUser{}, err
+	}
+
+	err := s.Auth.RevokeUser(id)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	err = s.Repo.UpdateUserRole(id, role)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	// the new primary role no longer needs to be granted separately
+	err = s.Repo.RevokeRole(id, role)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	return s.GetUser(id)
+}
+
+// GrantRole gives the user another role besides their primary one, the user is signed out everywhere
+// and gets the role with their next sign in
+func (s AdminService) GrantRole(adminID uint, id uint, role string) (dto.AdminUser, error) {
+	if !isUserRole(role) {
+		return dto.AdminUser{}, errors.New("role must be buyer, seller or admin")
+	}
+	if adminID == id {
+		return dto.AdminUser{}, errors.New("admins cannot change their own roles")
+	}
+	user, err := s.findManagedUser(id)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	if user.HasRole(role) {
+		return dto.AdminUser{}, errors.New("user already has this role")
+	}
+
+	err = s.Auth.RevokeUser(id)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	err = s.Repo.GrantRole(&domain.RoleGrant{UserID: id, Role: role, GrantedBy: adminID})
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	return s.GetUser(id)
+}
+
+// RevokeRole takes a granted role away and signs the user out everywhere, the primary role can only
+// be changed with ChangeRole
+func (s AdminService) RevokeRole(adminID uint, id uint, role string) (dto.AdminUser, error) {
+	if adminID == id {
+		return dto.AdminUser{}, errors.New("admins cannot change their own roles")
+	}
+	user, err := s.findManagedUser(id)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	if user.UserType == role {
+		return dto.AdminUser{}, errors.New("the primary role cannot be revoked, change it instead")
+	}
+	if !user.HasRole(role) {
+		return dto.AdminUser{}, errors.New("user does not have this role")
+	}
+
+	err = s.Auth.RevokeUser(id)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	err = s.Repo.RevokeRole(id, role)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	return s.GetUser(id)
+}
+
+func isUserRole(role string) bool {
+	switch role {
+	case domain.BUYER, domain.SELLER, domain.ADMIN:
+		return true
+	}
+	return false
+}
+
+// ForceReverification makes the user confirm their contact details again
+func (s AdminService) ForceReverification(id uint) (dto.AdminUser, error) {
+	if _, err := s.findManagedUser(id); err != nil {
+		return dto.AdminUser{}, err
+	}
+
+	err := s.Repo.ResetUserVerification(id)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	return s.GetUser(id)
+}
+
+// RequireTwoFactor forces the user into two factor authentication at their next sign in
+func (s AdminService) RequireTwoFactor(id uint, required bool) (dto.AdminUser, error) {
+	if _, err := s.findManagedUser(id); err != nil {
+		return dto.AdminUser{}, err
+	}
+
+	err := s.Repo.UpdateTwoFactorForced(id, required)
+	if err != nil {
+		return dto.AdminUser{}, err
+	}
+	return s.GetUser(id)
+}
+
+// findManagedUser loads a user an admin can act on, deleted accounts are left alone
+func (s AdminService) findManagedUser(id uint) (domain.User, error) {
+	user, err := s.Users.Repo.FindUserByID(id)
+	if err != nil {
+		return domain.User{}, errors.New("user not found")
+	}
+	if user.AnonymisedAt != nil {
+		return domain.User{}, errors.New("user account has been deleted")
+	}
+	return user, nil
+}
+
+func adminUser(user domain.User) dto.AdminUser {
+	return dto.AdminUser{
+		ID:              user.ID,
+		FName:           user.FName,
+		LName:           user.LName,
+		Email:           user.Email,
+		Phone:           user.Phone,
+		UserType:        user.UserType,
+		Roles:           user.Roles(),
+		Status:          user.Status,
+		IsVerified:      user.IsVerified,
+		TwoFactorForced: user.TwoFactorForced,
+		SuspendedAt:     user.SuspendedAt,
+		SuspendedReason: user.SuspendedReason,
+		CreatedAt:       user.CreatedAt,
+	}
+}
@@ -0,0 +1,230 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/blob"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/invoice"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var invoiceIssuer = []string{"Zamazon Marketplace", "invoices@zamazon.com"}
+
+type InvoiceService struct {
+	Repo  repository.InvoiceRepository
+	Store blob.BlobStore
+}
+
+// creditNote describes the part of an order that was refunded
+type creditNote struct {
+	Lines           []invoice.Line
+	TotalExclTax    float64
+	Tax             float64
+	RefundId        string
+	ReturnRequestID uint
+	Note            string
+}
+
+// IssueInvoice numbers, renders and stores the invoice of a paid order
+func (s InvoiceService) IssueInvoice(order domain.Order, customer domain.User) (domain.Invoice, error) {
+	existing, err := s.Repo.FindOrderInvoice(order.ID)
+	if err == nil {
+		return existing, nil
+	}
+
+	totalExclTax := order.TotalExclTax
+	if totalExclTax == 0 && order.Tax == 0 {
+		// orders placed before tax was recorded
+		totalExclTax = order.Amount
+	}
+
+	var lines []invoice.Line
+	for _, item := range order.Items {
+		lines = append(lines, invoice.Line{
+			Description: item.Name,
+			Qty:         item.Qty,
+			UnitPrice:   item.Price,
+			TaxRate:     item.TaxRate,
+			Amount:      item.Price * float64(item.Qty),
+		})
+	}
+
+	inv := domain.Invoice{
+		Type:         domain.INVOICE,
+		OrderID:      order.ID,
+		UserID:       order.UserID,
+		PaymentId:    order.PaymentId,
+		TotalExclTax: totalExclTax,
+		Tax:          order.Tax,
+		Total:        roundCents(totalExclTax + order.Tax),
+	}
+	return s.issue(inv, "INV", invoice.Document{
+		Title:            "Invoice",
+		OrderRefNumber:   order.OrderRefNumber,
+		PaymentReference: order.PaymentId,
+		Customer:         customerLines(customer),
+		Lines:            lines,
+		Discount:         order.Discount,
+		Shipping:         order.ShippingCost,
+	})
+}
+
+// IssueCreditNote numbers, renders and stores a credit note against the order's invoice
+func (s InvoiceService) IssueCreditNote(order domain.Order, customer domain.User, cn creditNote) (domain.Invoice, error) {
+	original, err := s.Repo.FindOrderInvoice(order.ID)
+	if err != nil {
+		original, err = s.IssueInvoice(order, customer)
+		if err != nil {
+			return domain.Invoice{}, err
+		}
+	}
+
+	inv := domain.Invoice{
+		Type:            domain.CREDIT_NOTE,
+		OrderID:         order.ID,
+		UserID:          order.UserID,
+		InvoiceID:       original.ID,
+		ReturnRequestID: cn.ReturnRequestID,
+		PaymentId:       order.PaymentId,
+		RefundId:        cn.RefundId,
+		TotalExclTax:    roundCents(cn.TotalExclTax),
+		Tax:             roundCents(cn.Tax),
+		Total:           roundCents(cn.TotalExclTax + cn.Tax),
+	}
+	return s.issue(inv, "CN", invoice.Document{
+		Title:            "Credit note",
+		OrderRefNumber:   order.OrderRefNumber,
+		PaymentReference: cn.RefundId,
+		RelatedNumber:    original.Number,
+		Customer:         customerLines(customer),
+		Lines:            cn.Lines,
+		Note:             cn.Note,
+	})
+}
+
+// issue stores the document first so its id gives the sequential number, then renders the PDF
+func (s InvoiceService) issue(inv domain.Invoice, prefix string, doc invoice.Document) (domain.Invoice, error) {
+	err := s.Repo.CreateInvoice(&inv)
+	if err != nil {
+		return domain.Invoice{}, err
+	}
+
+	inv.Number = fmt.Sprintf("%s-%d-%06d", prefix, inv.CreatedAt.Year(), inv.ID)
+	inv.BlobKey = fmt.Sprintf("invoices/%d/%s.pdf", inv.OrderID, inv.Number)
+
+	doc.Number = inv.Number
+	doc.IssuedAt = inv.CreatedAt
+	if doc.IssuedAt.IsZero() {
+		doc.IssuedAt = time.Now()
+	}
+	doc.Seller = invoiceIssuer
+	doc.TotalExclTax = inv.TotalExclTax
+	doc.Tax = inv.Tax
+	doc.Total = inv.Total
+
+	pdf, err := invoice.Render(doc)
+	if err != nil {
+		log.Printf("Error while rendering %s: %v", inv.Number, err)
+		return domain.Invoice{}, errors.New("could not render invoice")
+	}
+	err = s.Store.Put(inv.BlobKey, pdf)
+	if err != nil {
+		return domain.Invoice{}, err
+	}
+
+	err = s.Repo.UpdateInvoice(inv)
+	return inv, err
+}
+
+// SellerExtract renders the part of an order's invoice that covers one seller's sub-order, for that
+// seller. It is not numbered or stored, the invoice issued to the buyer stays the document of record,
+// and the buyer's email is left out as the seller only needs the delivery details
+func (s InvoiceService) SellerExtract(original domain.Invoice, order domain.Order, subOrder domain.SubOrder, items []domain.OrderItem, customer domain.User) (domain.Invoice, []byte, error) {
+	var lines []invoice.Line
+	for _, item := range items {
+		lines = append(lines, invoice.Line{
+			Description: item.Name,
+			Qty:         item.Qty,
+			UnitPrice:   item.Price,
+			TaxRate:     item.TaxRate,
+			Amount:      item.Price * float64(item.Qty),
+		})
+	}
+
+	totalExclTax := subOrder.TotalExclTax
+	if totalExclTax == 0 && subOrder.Tax == 0 {
+		// sub-orders created before tax was recorded
+		totalExclTax = subOrder.Subtotal - subOrder.Discount + subOrder.ShippingCost
+	}
+	extract := domain.Invoice{
+		Number:       original.Number,
+		Type:         domain.INVOICE,
+		OrderID:      order.ID,
+		PaymentId:    order.PaymentId,
+		TotalExclTax: roundCents(totalExclTax),
+		Tax:          roundCents(subOrder.Tax),
+		Total:        roundCents(totalExclTax + subOrder.Tax),
+		CreatedAt:    original.CreatedAt,
+	}
+
+	customer.Email = ""
+	pdf, err := invoice.Render(invoice.Document{
+		Title:            "Invoice extract",
+		Number:           original.Number,
+		IssuedAt:         original.CreatedAt,
+		OrderRefNumber:   order.OrderRefNumber,
+		PaymentReference: order.PaymentId,
+		Seller:           invoiceIssuer,
+		Customer:         customerLines(customer),
+		Lines:            lines,
+		Discount:         subOrder.Discount,
+		Shipping:         subOrder.ShippingCost,
+		TotalExclTax:     extract.TotalExclTax,
+		Tax:              extract.Tax,
+		Total:            extract.Total,
+		Note:             "Extract of invoice " + original.Number + " covering only the items of your sub-order.",
+	})
+	if err != nil {
+		log.Printf("Error while rendering the seller extract of %s: %v", original.Number, err)
+		return domain.Invoice{}, nil, errors.New("could not render invoice")
+	}
+	return extract, pdf, nil
+}
+
+func (s InvoiceService) GetPDF(inv domain.Invoice) ([]byte, error) {
+	if inv.BlobKey == "" {
+		return nil, errors.New("invoice document is not available")
+	}
+	return s.Store.Get(inv.BlobKey)
+}
+
+func customerLines(customer domain.User) []string {
+	lines := []string{
+		strings.TrimSpace(customer.FName + " " + customer.LName),
+		customer.Email,
+		customer.Address.AddressLine1,
+		customer.Address.AddressLine2,
+		strings.TrimSpace(customer.Address.City + " " + postCode(customer.Address.PostCode)),
+		customer.Address.Country,
+	}
+
+	var filled []string
+	for _, line := range lines {
+		if line != "" {
+			filled = append(filled, line)
+		}
+	}
+	return filled
+}
+
+func postCode(code uint) string {
+	if code == 0 {
+		return ""
+	}
+	return strconv.Itoa(int(code))
+}
@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/oauth"
+	"log"
+	"sort"
+	"time"
+)
+
+// OAuthStateTTL is how long a user has to finish signing in with the provider
+const OAuthStateTTL = 10 * time.Minute
+
+type OAuthService struct {
+	Repo      repository.OAuthRepository
+	Providers map[string]oauth.Provider
+	Users     UserService
+}
+
+// ProviderNames lists the providers users can sign in with
+func (s OAuthService) ProviderNames() []string {
+	names := make([]string, 0, len(s.Providers))
+	for name := range s.Providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s OAuthService) provider(name string) (oauth.Provider, error) {
+	provider, ok := s.Providers[name]
+	if !ok {
+		return nil, fmt.Errorf("sign in with %s is not available", name)
+	}
+	return provider, nil
+}
+
+// Start returns the provider url to send the user to and the state the browser must keep until it
+// comes back, the guest cart is merged once they are back
+func (s OAuthService) Start(providerName string, cartToken string) (string, string, error) {
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := newRandomToken()
+	if err != nil {
+		return "", "", errors.New("could not start sign in")
+	}
+	verifier, err := newRandomToken()
+	if err != nil {
+		return "", "", errors.New("could not start sign in")
+	}
+
+	err = s.Repo.CreateOAuthState(&domain.OAuthState{
+		StateHash: hashToken(state),
+		Provider:  provider.Name(),
+		Verifier:  verifier,
+		CartToken: cartToken,
+		ExpiresAt: time.Now().Add(OAuthStateTTL),
+	})
+	if err != nil {
+		return "", "", err
+	}
+
+	url, err := provider.AuthCodeURL(state, verifier)
+	if err != nil {
+		log.Printf("Error while building %s sign in url %v", provider.Name(), err)
+		return "", "", errors.New("could not start sign in")
+	}
+	return url, state, nil
+}
+
+// Callback finishes a sign in started by Start, the account is found by the provider identity,
+// then by the email the provider verified, and is otherwise created. An account with that email
+// nobody verified is claimed rather than shared, see claimUnverifiedAccount.
+// browserState is the state kept by the browser that called Start, a callback arriving in any other
+// browser is refused so nobody can sign a victim into the attacker's account with their own callback url
+func (s OAuthService) Callback(ctx context.Context, providerName string, code string, state string, browserState string, client dto.ClientInfo) (dto.LoginResponse, error) {
+	provider, err := s.provider(providerName)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+	if browserState == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return dto.LoginResponse{}, errors.New("sign in was started in another browser, please try again")
+	}
+
+	pending, err := s.Repo.ConsumeOAuthState(hashToken(state))
+	if err != nil {
+		return dto.LoginResponse{}, errors.New("sign in has expired, please try again")
+	}
+	if pending.Provider != provider.Name() || time.Now().After(pending.ExpiresAt) {
+		return dto.LoginResponse{}, errors.New("sign in has expired, please try again")
+	}
+
+	identity, err := provider.Exchange(ctx, code, pending.Verifier)
+	if err != nil {
+		log.Printf("Error while completing %s sign in %v", provider.Name(), err)
+		return dto.LoginResponse{}, errors.New("could not sign in with " + provider.Name())
+	}
+
+	user, err := s.findOrLinkUser(identity, client.IP)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+	return s.Users.signIn(user, pending.CartToken, client)
+}
+
+func (s OAuthService) findOrLinkUser(identity oauth.Identity, ip string) (domain.User, error) {
+	linked, err := s.Repo.FindIdentity(identity.Provider, identity.Subject)
+	if err == nil {
+		return s.Users.Repo.FindUserByID(linked.UserID)
+	}
+
+	// an unverified address could belong to someone else, linking it would hand them the account
+	if identity.Email == "" || !identity.EmailVerified {
+		return domain.User{}, oauth.ErrUnverifiedEmail
+	}
+
+	user, err := s.Users.Repo.FindUser(identity.Email)
+	if err != nil {
+		user, err = s.Users.Repo.CreateUser(domain.User{
+			Email:      identity.Email,
+			FName:      identity.FirstName,
+			LName:      identity.LastName,
+			IsVerified: true,
+		})
+		if err != nil {
+			return domain.User{}, err
+		}
+	} else if !user.IsVerified {
+		user, err = s.claimUnverifiedAccount(user)
+		if err != nil {
+			return domain.User{}, err
+		}
+	}
+
+	err = s.Repo.CreateIdentity(&domain.UserIdentity{
+		UserID:   user.ID,
+		Provider: identity.Provider,
+		Subject:  identity.Subject,
+		Email:    identity.Email,
+	})
+	if err != nil {
+		return domain.User{}, err
+	}
+	s.Users.Security.Record(domain.SecurityEvent{UserID: user.ID, Email: user.Email, IP: ip, Type: domain.EVENT_IDENTITY_LINKED, Detail: identity.Provider})
+	return user, nil
+}
+
+// claimUnverifiedAccount gives an account nobody verified to the owner of its email. Whoever
+// registered it never proved the address was theirs, so they are signed out and lose the password
+// and two factor setup they chose rather than sharing the account with the owner
+func (s OAuthService) claimUnverifiedAccount(user domain.User) (domain.User, error) {
+	err := s.Users.AuthClient.RevokeUser(user.ID)
+	if err != nil {
+		log.Printf("Error while signing out unverified account %d: %v", user.ID, err)
+		return domain.User{}, errors.New("could not link the account")
+	}
+	err = s.Repo.ClaimAccount(user.ID)
+	if err != nil {
+		return domain.User{}, err
+	}
+	return s.Users.Repo.FindUserByID(user.ID)
+}
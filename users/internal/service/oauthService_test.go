@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
+	"github.com/sharat789/zamazon-be-ms/users/internal/client"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/oauth"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/oauth/oauthtest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+var testClient = dto.ClientInfo{IP: "203.0.113.7", UserAgent: "test"}
+
+// fakeUsers keeps accounts in memory, only the methods a social login uses are implemented
+type fakeUsers struct {
+	repository.UserRepository
+	users map[uint]domain.User
+}
+
+func (r *fakeUsers) FindUser(email string) (domain.User, error) {
+	for _, user := range r.users {
+		if strings.EqualFold(user.Email, email) {
+			return user, nil
+		}
+	}
+	return domain.User{}, errors.New("user not found")
+}
+
+func (r *fakeUsers) FindUserByID(id uint) (domain.User, error) {
+	user, ok := r.users[id]
+	if !ok {
+		return domain.User{}, errors.New("user not found")
+	}
+	return user, nil
+}
+
+func (r *fakeUsers) CreateUser(u domain.User) (domain.User, error) {
+	u.ID = uint(len(r.users) + 1)
+	if u.UserType == "" {
+		u.UserType = domain.BUYER
+	}
+	r.users[u.ID] = u
+	return u, nil
+}
+
+type fakeOAuth struct {
+	users      *fakeUsers
+	states     map[string]domain.OAuthState
+	identities []domain.UserIdentity
+}
+
+func (r *fakeOAuth) CreateOAuthState(s *domain.OAuthState) error {
+	r.states[s.StateHash] = *s
+	return nil
+}
+
+func (r *fakeOAuth) ConsumeOAuthState(stateHash string) (domain.OAuthState, error) {
+	state, ok := r.states[stateHash]
+	if !ok {
+		return domain.OAuthState{}, errors.New("sign in state not found")
+	}
+	delete(r.states, stateHash)
+	return state, nil
+}
+
+func (r *fakeOAuth) FindIdentity(provider string, subject string) (domain.UserIdentity, error) {
+	for _, identity := range r.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity, nil
+		}
+	}
+	return domain.UserIdentity{}, errors.New("identity not found")
+}
+
+func (r *fakeOAuth) CreateIdentity(i *domain.UserIdentity) error {
+	r.identities = append(r.identities, *i)
+	return nil
+}
+
+func (r *fakeOAuth) ClaimAccount(userID uint) error {
+	user := r.users.users[userID]
+	user.Password = ""
+	user.IsVerified = true
+	r.users.users[userID] = user
+	return nil
+}
+
+type fakeSecurity struct {
+	repository.SecurityRepository
+}
+
+func (fakeSecurity) CreateSecurityEvent(e *domain.SecurityEvent) error { return nil }
+func (fakeSecurity) DeleteThrottle(key string) error                   { return nil }
+
+type fakeTwoFactor struct {
+	repository.TwoFactorRepository
+}
+
+func (fakeTwoFactor) FindTwoFactor(userId uint) (domain.TwoFactor, error) {
+	return domain.TwoFactor{}, errors.New("two factor not set up")
+}
+
+// fakeAuth answers the auth service routes a sign in calls and records whose tokens were revoked
+type fakeAuth struct {
+	mu      sync.Mutex
+	revoked []uint
+}
+
+func (a *fakeAuth) handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/auth/token", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"access_token": "service-token", "expires_in": 3600})
+	})
+	mux.HandleFunc("/internal/auth/generate-token", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(client.TokenPair{Token: "access-token", RefreshToken: "refresh-token", ExpiresIn: 900})
+	})
+	mux.HandleFunc("/internal/auth/revoke-user", func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			UserID uint `json:"user_id"`
+		}
+		json.NewDecoder(r.Body).Decode(&body)
+		a.mu.Lock()
+		a.revoked = append(a.revoked, body.UserID)
+		a.mu.Unlock()
+	})
+	return mux
+}
+
+type oauthFixture struct {
+	service  OAuthService
+	provider *oauthtest.Server
+	users    *fakeUsers
+	repo     *fakeOAuth
+	auth     *fakeAuth
+}
+
+func newOAuthFixture(t *testing.T, user oauthtest.UserInfo) *oauthFixture {
+	t.Helper()
+	provider := oauthtest.NewServer(user)
+	providerServer := httptest.NewServer(provider.Handler())
+	t.Cleanup(providerServer.Close)
+
+	authService := &fakeAuth{}
+	authServer := httptest.NewServer(authService.handler())
+	t.Cleanup(authServer.Close)
+
+	users := &fakeUsers{users: map[uint]domain.User{}}
+	repo := &fakeOAuth{users: users, states: map[string]domain.OAuthState{}}
+	tokens := auth.NewServiceTokenSource(authServer.URL+"/auth/token", "users", "secret", auth.SCOPE_AUTH_TOKENS)
+	return &oauthFixture{
+		service: OAuthService{
+			Repo: repo,
+			Providers: map[string]oauth.Provider{
+				"google": oauth.NewOIDCProvider("google", providerServer.URL, "client-id", "client-secret", "http://localhost/users/oauth/google/callback"),
+			},
+			Users: UserService{
+				Repo:       users,
+				AuthClient: client.NewAuthClient(authServer.URL, tokens),
+				Security:   SecurityService{Repo: fakeSecurity{}},
+				TwoFactor:  TwoFactorService{Repo: fakeTwoFactor{}},
+			},
+		},
+		provider: provider,
+		users:    users,
+		repo:     repo,
+		auth:     authService,
+	}
+}
+
+// signIn starts a sign in and returns the code and state the provider sends the browser back with
+func (f *oauthFixture) signIn(t *testing.T) (string, string) {
+	t.Helper()
+	authURL, state, err := f.service.Start("google", "")
+	if err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+	callback, err := oauthtest.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if callback.Get("state") != state {
+		t.Fatalf("provider returned state %q, want %q", callback.Get("state"), state)
+	}
+	return callback.Get("code"), state
+}
+
+var verifiedJane = oauthtest.UserInfo{Subject: "google-jane", Email: "jane@example.com", EmailVerified: true, GivenName: "Jane", FamilyName: "Doe"}
+
+func TestCallbackCreatesAccountForVerifiedEmail(t *testing.T) {
+	f := newOAuthFixture(t, verifiedJane)
+	code, state := f.signIn(t)
+
+	response, err := f.service.Callback(context.Background(), "google", code, state, state, testClient)
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if response.Token != "access-token" {
+		t.Fatalf("token = %q, want access-token", response.Token)
+	}
+
+	user, err := f.users.FindUser("jane@example.com")
+	if err != nil {
+		t.Fatalf("account was not created: %v", err)
+	}
+	if !user.IsVerified || user.FName != "Jane" {
+		t.Fatalf("created account = %+v", user)
+	}
+	if len(f.repo.identities) != 1 || f.repo.identities[0].UserID != user.ID || f.repo.identities[0].Subject != "google-jane" {
+		t.Fatalf("identities = %+v", f.repo.identities)
+	}
+}
+
+func TestCallbackRejectsUnverifiedEmail(t *testing.T) {
+	f := newOAuthFixture(t, oauthtest.UserInfo{Subject: "google-jane", Email: "jane@example.com", EmailVerified: false})
+	f.users.users[1] = domain.User{ID: 1, Email: "jane@example.com", Password: "hash", IsVerified: true, UserType: domain.BUYER}
+	code, state := f.signIn(t)
+
+	_, err := f.service.Callback(context.Background(), "google", code, state, state, testClient)
+	if !errors.Is(err, oauth.ErrUnverifiedEmail) {
+		t.Fatalf("err = %v, want %v", err, oauth.ErrUnverifiedEmail)
+	}
+	if len(f.repo.identities) != 0 {
+		t.Fatalf("an unverified email was linked: %+v", f.repo.identities)
+	}
+	if len(f.users.users) != 1 {
+		t.Fatalf("an account was created for an unverified email")
+	}
+}
+
+func TestCallbackLinksAccountByVerifiedEmail(t *testing.T) {
+	f := newOAuthFixture(t, verifiedJane)
+	f.users.users[1] = domain.User{ID: 1, Email: "Jane@Example.com", Password: "hash", IsVerified: true, UserType: domain.BUYER}
+	code, state := f.signIn(t)
+
+	_, err := f.service.Callback(context.Background(), "google", code, state, state, testClient)
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	if len(f.repo.identities) != 1 || f.repo.identities[0].UserID != 1 {
+		t.Fatalf("identities = %+v, want a link to account 1", f.repo.identities)
+	}
+	if f.users.users[1].Password != "hash" || len(f.auth.revoked) != 0 {
+		t.Fatal("a verified account lost its password or sessions when it was linked")
+	}
+
+	// the identity is found by its subject from then on
+	code, state = f.signIn(t)
+	_, err = f.service.Callback(context.Background(), "google", code, state, state, testClient)
+	if err != nil {
+		t.Fatalf("second Callback: %v", err)
+	}
+	if len(f.repo.identities) != 1 {
+		t.Fatalf("identity was linked twice: %+v", f.repo.identities)
+	}
+}
+
+func TestCallbackClaimsUnverifiedAccount(t *testing.T) {
+	f := newOAuthFixture(t, verifiedJane)
+	f.users.users[1] = domain.User{ID: 1, Email: "jane@example.com", Password: "registered-by-someone-else", IsVerified: false, UserType: domain.BUYER}
+	code, state := f.signIn(t)
+
+	_, err := f.service.Callback(context.Background(), "google", code, state, state, testClient)
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+	user := f.users.users[1]
+	if user.Password != "" || !user.IsVerified {
+		t.Fatalf("claimed account = %+v, want no password and verified", user)
+	}
+	if len(f.auth.revoked) != 1 || f.auth.revoked[0] != 1 {
+		t.Fatalf("revoked = %v, want the previous sessions of account 1 revoked", f.auth.revoked)
+	}
+}
+
+func TestCallbackRejectsReplayedState(t *testing.T) {
+	f := newOAuthFixture(t, verifiedJane)
+	code, state := f.signIn(t)
+
+	_, err := f.service.Callback(context.Background(), "google", code, state, state, testClient)
+	if err != nil {
+		t.Fatalf("Callback: %v", err)
+	}
+
+	_, err = f.service.Callback(context.Background(), "google", code, state, state, testClient)
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("replayed state: err = %v, want the sign in to have expired", err)
+	}
+}
+
+func TestCallbackRejectsExpiredState(t *testing.T) {
+	f := newOAuthFixture(t, verifiedJane)
+	code, state := f.signIn(t)
+
+	pending := f.repo.states[hashToken(state)]
+	pending.ExpiresAt = time.Now().Add(-time.Second)
+	f.repo.states[hashToken(state)] = pending
+
+	_, err := f.service.Callback(context.Background(), "google", code, state, state, testClient)
+	if err == nil || !strings.Contains(err.Error(), "expired") {
+		t.Fatalf("expired state: err = %v, want the sign in to have expired", err)
+	}
+	if len(f.users.users) != 0 {
+		t.Fatal("an account was created from an expired sign in")
+	}
+}
+
+func TestCallbackRejectsStateFromAnotherBrowser(t *testing.T) {
+	f := newOAuthFixture(t, verifiedJane)
+	code, state := f.signIn(t)
+	_, otherState := f.signIn(t)
+
+	for _, browserState := range []string{"", otherState} {
+		_, err := f.service.Callback(context.Background(), "google", code, state, browserState, testClient)
+		if err == nil || !strings.Contains(err.Error(), "another browser") {
+			t.Fatalf("browser state %q: err = %v, want the callback refused", browserState, err)
+		}
+	}
+
+	// the refused attempts leave the sign in for the browser that started it
+	_, err := f.service.Callback(context.Background(), "google", code, state, state, testClient)
+	if err != nil {
+		t.Fatalf("Callback from the browser that started it: %v", err)
+	}
+}
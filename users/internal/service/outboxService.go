@@ -0,0 +1,82 @@
+package service
+
+import (
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/events"
+	"log"
+	"time"
+)
+
+const (
+	outboxBatchSize = 50
+	// failed deliveries back off from outboxRetryBase up to outboxMaxBackoff and are never dropped
+	outboxRetryBase  = 30 * time.Second
+	outboxMaxBackoff = time.Hour
+	// outboxAlertAttempts is when a stuck delivery starts being logged on every attempt
+	outboxAlertAttempts = 10
+)
+
+// OutboxService delivers events recorded in the outbox, subscribers may see an event more than once
+// so they have to handle it idempotently
+type OutboxService struct {
+	Repo      repository.OutboxRepository
+	Publisher events.Publisher
+}
+
+// Prepare turns an event into one outbox entry per subscriber, it is stored together with the change it announces
+func (s OutboxService) Prepare(event events.Event) []domain.OutboxEvent {
+	var entries []domain.OutboxEvent
+	for _, subscriber := range s.Publisher.Subscribers() {
+		entries = append(entries, domain.OutboxEvent{
+			Type:          event.Type,
+			UserID:        event.UserID,
+			OccurredAt:    event.OccurredAt,
+			Subscriber:    subscriber,
+			NextAttemptAt: event.OccurredAt,
+		})
+	}
+	return entries
+}
+
+// Start delivers due events in the background
+func (s OutboxService) Start(every time.Duration) {
+	go func() {
+		for range time.Tick(every) {
+			s.DeliverDue(time.Now())
+		}
+	}()
+}
+
+// DeliverDue attempts every event whose next attempt is due, failures are rescheduled with a backoff
+func (s OutboxService) DeliverDue(now time.Time) {
+	entries, err := s.Repo.FindDueOutboxEvents(now, outboxBatchSize)
+	if err != nil {
+		return
+	}
+
+	for _, entry := range entries {
+		err = s.Publisher.Deliver(entry.Subscriber, events.Event{Type: entry.Type, UserID: entry.UserID, OccurredAt: entry.OccurredAt})
+		if err == nil {
+			_ = s.Repo.MarkOutboxEventDelivered(entry.ID, time.Now())
+			continue
+		}
+
+		attempts := entry.Attempts + 1
+		if attempts >= outboxAlertAttempts {
+			log.Printf("Error while delivering %s event %d to %s, %d attempts so far: %v", entry.Type, entry.ID, entry.Subscriber, attempts, err)
+		}
+		_ = s.Repo.MarkOutboxEventFailed(entry.ID, attempts, now.Add(outboxBackoff(attempts)), err.Error())
+	}
+}
+
+func outboxBackoff(attempts uint) time.Duration {
+	if attempts > 16 {
+		return outboxMaxBackoff
+	}
+	delay := outboxRetryBase << (attempts - 1)
+	if delay > outboxMaxBackoff {
+		return outboxMaxBackoff
+	}
+	return delay
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+type PromotionService struct {
+	Repo repository.PromotionRepository
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// CreateCoupon stores the coupon active unless the input says otherwise, the column has no default
+// so an inactive coupon is written as such
+func (s PromotionService) CreateCoupon(sellerID uint, input dto.CouponInput) (domain.Coupon, error) {
+	coupon := domain.Coupon{
+		SellerID: sellerID,
+		Active:   true,
+	}
+	applyCouponInput(&coupon, input)
+
+	err := validateCoupon(coupon)
+	if err != nil {
+		return domain.Coupon{}, err
+	}
+
+	existing, _ := s.Repo.FindCouponByCode(coupon.Code)
+	if existing.ID != 0 {
+		return domain.Coupon{}, errors.New("coupon code already exists")
+	}
+
+	err = s.Repo.CreateCoupon(&coupon)
+	return coupon, err
+}
+
+func (s PromotionService) GetSellerCoupons(sellerID uint) ([]domain.Coupon, error) {
+	return s.Repo.FindSellerCoupons(sellerID)
+}
+
+// UpdateCoupon changes the rules of a coupon, the code itself cannot be changed once created
+func (s PromotionService) UpdateCoupon(sellerID uint, couponID uint, input dto.CouponInput) (domain.Coupon, error) {
+	coupon, err := s.Repo.FindCouponByID(couponID)
+	if err != nil || coupon.SellerID != sellerID {
+		return domain.Coupon{}, errors.New("coupon not found")
+	}
+
+	code := coupon.Code
+	applyCouponInput(&coupon, input)
+	coupon.Code = code
+
+	err = validateCoupon(coupon)
+	if err != nil {
+		return domain.Coupon{}, err
+	}
+
+	err = s.Repo.UpdateCoupon(coupon)
+	return coupon, err
+}
+
+func (s PromotionService) DeactivateCoupon(sellerID uint, couponID uint) error {
+	coupon, err := s.Repo.FindCouponByID(couponID)
+	if err != nil || coupon.SellerID != sellerID {
+		return errors.New("coupon not found")
+	}
+	coupon.Active = false
+	return s.Repo.UpdateCoupon(coupon)
+}
+
+func applyCouponInput(coupon *domain.Coupon, input dto.CouponInput) {
+	coupon.Code = normalizeCouponCode(input.Code)
+	coupon.Description = input.Description
+	coupon.Type = input.Type
+	coupon.Value = input.Value
+	coupon.MaxDiscount = input.MaxDiscount
+	coupon.MinSpend = input.MinSpend
+	coupon.BuyQty = input.BuyQty
+	coupon.GetQty = input.GetQty
+	coupon.ProductIDs = input.ProductIDs
+	coupon.CategoryIDs = input.CategoryIDs
+	coupon.UsageLimit = input.UsageLimit
+	coupon.PerUserLimit = input.PerUserLimit
+	coupon.StartsAt = input.StartsAt
+	coupon.ExpiresAt = input.ExpiresAt
+	if input.Active != nil {
+		coupon.Active = *input.Active
+	}
+	if coupon.Type == domain.COUPON_BXGY && coupon.Value == 0 {
+		coupon.Value = 100
+	}
+}
+
+func validateCoupon(coupon domain.Coupon) error {
+	if coupon.Code == "" {
+		return errors.New("coupon code is required")
+	}
+	switch coupon.Type {
+	case domain.COUPON_PERCENTAGE:
+		if coupon.Value <= 0 || coupon.Value > 100 {
+			return errors.New("percentage must be between 0 and 100")
+		}
+	case domain.COUPON_FIXED:
+		if coupon.Value <= 0 {
+			return errors.New("fixed discount must be greater than 0")
+		}
+	case domain.COUPON_BXGY:
+		if coupon.BuyQty < 1 || coupon.GetQty < 1 {
+			return errors.New("buy and get quantities must be at least 1")
+		}
+		if coupon.Value <= 0 || coupon.Value > 100 {
+			return errors.New("percentage off the free items must be between 0 and 100")
+		}
+	default:
+		return errors.New("type must be percentage, fixed or bxgy")
+	}
+	if coupon.MinSpend < 0 || coupon.MaxDiscount < 0 {
+		return errors.New("minimum spend and maximum discount cannot be negative")
+	}
+	if coupon.StartsAt != nil && coupon.ExpiresAt != nil && !coupon.ExpiresAt.After(*coupon.StartsAt) {
+		return errors.New("expiry must be after the start date")
+	}
+	return nil
+}
+
+// ApplyCoupon validates the code against the cart and attaches it to the user's cart
+func (s PromotionService) ApplyCoupon(userID uint, code string, cart *dto.CartResponse) error {
+	coupon, err := s.Repo.FindCouponByCode(normalizeCouponCode(code))
+	if err != nil {
+		return errors.New("invalid coupon code")
+	}
+
+	_, err = s.evaluate(coupon, userID, cart, time.Now())
+	if err != nil {
+		return err
+	}
+	return s.Repo.SaveCartCoupon(userID, coupon.ID)
+}
+
+func (s PromotionService) RemoveCoupon(userID uint) error {
+	err := s.Repo.DeleteCartCoupon(userID)
+	if err != nil {
+		log.Printf("Error while removing cart coupon %v", err)
+		return errors.New("unable to remove coupon")
+	}
+	return nil
+}
+
+// PriceCart applies the cart's coupon, if any, to the revalidated cart. A coupon that no longer
+// applies is kept on the cart with the reason in CouponError so the user can review it
+func (s PromotionService) PriceCart(userID uint, cart *dto.CartResponse) *domain.Coupon {
+	cartCoupon, err := s.Repo.FindCartCoupon(userID)
+	if err != nil {
+		return nil
+	}
+	coupon, err := s.Repo.FindCouponByID(cartCoupon.CouponID)
+	if err != nil {
+		_ = s.Repo.DeleteCartCoupon(userID)
+		return nil
+	}
+
+	cart.CouponCode = coupon.Code
+	discounts, err := s.evaluate(coupon, userID, cart, time.Now())
+	if err != nil {
+		cart.CouponError = err.Error()
+		return nil
+	}
+
+	for i, discount := range discounts {
+		cart.Items[i].Discount = discount
+		cart.Discount += discount
+	}
+	cart.Discount = roundCents(cart.Discount)
+	cart.Total = roundCents(cart.Subtotal - cart.Discount)
+	return &coupon
+}
+
+// evaluate checks the coupon rules against the cart and returns the discount for every cart line
+func (s PromotionService) evaluate(coupon domain.Coupon, userID uint, cart *dto.CartResponse, now time.Time) ([]float64, error) {
+	if !coupon.Active {
+		return nil, errors.New("coupon is no longer active")
+	}
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return nil, errors.New("coupon is not active yet")
+	}
+	if coupon.ExpiresAt != nil && now.After(*coupon.ExpiresAt) {
+		return nil, errors.New("coupon has expired")
+	}
+	if coupon.UsageLimit > 0 && coupon.UsedCount >= coupon.UsageLimit {
+		return nil, repository.ErrCouponLimitReached
+	}
+	if coupon.PerUserLimit > 0 {
+		count, err := s.Repo.CountUserRedemptions(coupon.ID, userID)
+		if err != nil {
+			return nil, errors.New("unable to validate coupon")
+		}
+		if count >= int64(coupon.PerUserLimit) {
+			return nil, repository.ErrCouponUserLimitReached
+		}
+	}
+
+	eligible := make([]bool, len(cart.Items))
+	var eligibleTotal float64
+	for i, item := range cart.Items {
+		if item.Unavailable || !couponCoversItem(coupon, item) {
+			continue
+		}
+		eligible[i] = true
+		eligibleTotal += item.CurrentPrice * float64(item.Qty)
+	}
+	if eligibleTotal == 0 {
+		return nil, errors.New("coupon does not apply to any item in the cart")
+	}
+	if eligibleTotal < coupon.MinSpend {
+		return nil, fmt.Errorf("a minimum spend of %.2f on eligible items is required", coupon.MinSpend)
+	}
+
+	discounts := make([]float64, len(cart.Items))
+	switch coupon.Type {
+	case domain.COUPON_PERCENTAGE:
+		for i, item := range cart.Items {
+			if eligible[i] {
+				discounts[i] = item.CurrentPrice * float64(item.Qty) * coupon.Value / 100
+			}
+		}
+	case domain.COUPON_FIXED:
+		amount := math.Min(coupon.Value, eligibleTotal)
+		for i, item := range cart.Items {
+			if eligible[i] {
+				discounts[i] = amount * item.CurrentPrice * float64(item.Qty) / eligibleTotal
+			}
+		}
+	case domain.COUPON_BXGY:
+		discounts = buyXGetYDiscounts(coupon, cart.Items, eligible)
+	}
+
+	var total float64
+	for _, discount := range discounts {
+		total += discount
+	}
+	if total == 0 {
+		return nil, errors.New("cart does not qualify for this coupon")
+	}
+	if coupon.MaxDiscount > 0 && total > coupon.MaxDiscount {
+		for i := range discounts {
+			discounts[i] = discounts[i] * coupon.MaxDiscount / total
+		}
+	}
+	for i := range discounts {
+		discounts[i] = roundCents(discounts[i])
+	}
+	return discounts, nil
+}
+
+// buyXGetYDiscounts makes the cheapest GetQty units of every BuyQty+GetQty eligible units free,
+// or discounted by Value percent
+func buyXGetYDiscounts(coupon domain.Coupon, items []dto.CartItemResponse, eligible []bool) []float64 {
+	type unit struct {
+		line  int
+		price float64
+	}
+	var units []unit
+	for i, item := range items {
+		if !eligible[i] {
+			continue
+		}
+		for q := uint(0); q < item.Qty; q++ {
+			units = append(units, unit{i, item.CurrentPrice})
+		}
+	}
+	sort.SliceStable(units, func(a, b int) bool {
+		return units[a].price < units[b].price
+	})
+
+	discounts := make([]float64, len(items))
+	free := uint(len(units)) / (coupon.BuyQty + coupon.GetQty) * coupon.GetQty
+	for _, u := range units[:free] {
+		discounts[u.line] += u.price * coupon.Value / 100
+	}
+	return discounts
+}
+
+func couponCoversItem(coupon domain.Coupon, item dto.CartItemResponse) bool {
+	if coupon.SellerID != 0 && coupon.SellerID != item.SellerId {
+		return false
+	}
+	if len(coupon.ProductIDs) > 0 && !containsID(coupon.ProductIDs, item.ProductID) {
+		return false
+	}
+	if len(coupon.CategoryIDs) > 0 && !containsID(coupon.CategoryIDs, item.CategoryID) {
+		return false
+	}
+	return true
+}
+
+func containsID(ids []uint, id uint) bool {
+	for _, v := range ids {
+		if v == id {
+			return true
+		}
+	}
+	return false
+}
+
+func roundCents(amount float64) float64 {
+	return math.Round(amount*100) / 100
+}
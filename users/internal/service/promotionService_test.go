@@ -0,0 +1,121 @@
+package service
+
+import (
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func cartLine(productID uint, sellerID uint, price float64, qty uint) dto.CartItemResponse {
+	return dto.CartItemResponse{ProductID: productID, SellerId: sellerID, CategoryID: 1, Price: price, CurrentPrice: price, Qty: qty}
+}
+
+func TestCouponDiscountAllocation(t *testing.T) {
+	twoSellers := []dto.CartItemResponse{cartLine(1, 1, 20, 2), cartLine(2, 2, 10, 1)}
+	tests := []struct {
+		name   string
+		coupon domain.Coupon
+		items  []dto.CartItemResponse
+		want   []float64
+	}{
+		{
+			name:   "percentage off every line",
+			coupon: domain.Coupon{Type: domain.COUPON_PERCENTAGE, Value: 10},
+			items:  twoSellers,
+			want:   []float64{4, 1},
+		},
+		{
+			name:   "seller coupon only discounts that seller's lines",
+			coupon: domain.Coupon{Type: domain.COUPON_PERCENTAGE, Value: 10, SellerID: 2},
+			items:  twoSellers,
+			want:   []float64{0, 1},
+		},
+		{
+			name:   "product coupon only discounts the listed products",
+			coupon: domain.Coupon{Type: domain.COUPON_PERCENTAGE, Value: 50, ProductIDs: []uint{1}},
+			items:  twoSellers,
+			want:   []float64{20, 0},
+		},
+		{
+			name:   "fixed amount is shared in proportion to line value",
+			coupon: domain.Coupon{Type: domain.COUPON_FIXED, Value: 15},
+			items:  twoSellers,
+			want:   []float64{12, 3},
+		},
+		{
+			name:   "fixed amount is capped at the eligible value",
+			coupon: domain.Coupon{Type: domain.COUPON_FIXED, Value: 100},
+			items:  twoSellers,
+			want:   []float64{40, 10},
+		},
+		{
+			name:   "fixed amount shares are rounded to the cent",
+			coupon: domain.Coupon{Type: domain.COUPON_FIXED, Value: 10},
+			items:  []dto.CartItemResponse{cartLine(1, 1, 10, 1), cartLine(2, 1, 10, 1), cartLine(3, 1, 10, 1)},
+			want:   []float64{3.33, 3.33, 3.33},
+		},
+		{
+			name:   "maximum discount scales every line down",
+			coupon: domain.Coupon{Type: domain.COUPON_PERCENTAGE, Value: 50, MaxDiscount: 10},
+			items:  twoSellers,
+			want:   []float64{8, 2},
+		},
+		{
+			name:   "buy two get the cheapest free",
+			coupon: domain.Coupon{Type: domain.COUPON_BXGY, BuyQty: 2, GetQty: 1, Value: 100},
+			items:  twoSellers,
+			want:   []float64{0, 10},
+		},
+		{
+			name:   "buy one get one half price",
+			coupon: domain.Coupon{Type: domain.COUPON_BXGY, BuyQty: 1, GetQty: 1, Value: 50},
+			items:  []dto.CartItemResponse{cartLine(1, 1, 20, 2), cartLine(2, 1, 10, 2)},
+			want:   []float64{0, 10},
+		},
+		{
+			name:   "unavailable lines are not discounted",
+			coupon: domain.Coupon{Type: domain.COUPON_PERCENTAGE, Value: 10},
+			items:  []dto.CartItemResponse{cartLine(1, 1, 20, 2), {ProductID: 2, SellerId: 2, CurrentPrice: 10, Qty: 1, Unavailable: true}},
+			want:   []float64{4, 0},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.coupon.Active = true
+			got, err := PromotionService{}.evaluate(tt.coupon, 1, &dto.CartResponse{Items: tt.items}, time.Now())
+			if err != nil {
+				t.Fatalf("evaluate: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("discounts = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCouponRejected(t *testing.T) {
+	now := time.Now()
+	expired := now.Add(-time.Hour)
+	items := []dto.CartItemResponse{cartLine(1, 1, 20, 2), cartLine(2, 2, 10, 1)}
+	tests := []struct {
+		name   string
+		coupon domain.Coupon
+	}{
+		{"inactive", domain.Coupon{Type: domain.COUPON_PERCENTAGE, Value: 10}},
+		{"expired", domain.Coupon{Type: domain.COUPON_PERCENTAGE, Value: 10, Active: true, ExpiresAt: &expired}},
+		{"usage limit reached", domain.Coupon{Type: domain.COUPON_PERCENTAGE, Value: 10, Active: true, UsageLimit: 5, UsedCount: 5}},
+		{"minimum spend not met", domain.Coupon{Type: domain.COUPON_FIXED, Value: 5, Active: true, MinSpend: 60}},
+		{"no eligible line", domain.Coupon{Type: domain.COUPON_PERCENTAGE, Value: 10, Active: true, SellerID: 3}},
+		{"too few items for the free one", domain.Coupon{Type: domain.COUPON_BXGY, BuyQty: 3, GetQty: 1, Value: 100, Active: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := PromotionService{}.evaluate(tt.coupon, 1, &dto.CartResponse{Items: items}, now)
+			if err == nil {
+				t.Fatal("evaluate accepted the coupon")
+			}
+		})
+	}
+}
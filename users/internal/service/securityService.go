@@ -0,0 +1,171 @@
+package service
+
+import (
+	"fmt"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/notification"
+	"log"
+	"strings"
+	"time"
+)
+
+// failures older than this no longer count towards a backoff
+const failedLoginWindow = 24 * time.Hour
+
+// throttlePolicy describes how quickly repeated failed sign ins slow down and then lock out
+type throttlePolicy struct {
+	prefix       string
+	freeAttempts uint
+	maxBackoff   time.Duration
+	lockAfter    uint
+	lockDuration time.Duration
+}
+
+var (
+	// accounts are throttled per address they are tried from, failures from one address cannot lock
+	// the owner out when signing in from another
+	accountThrottle = throttlePolicy{prefix: "account:", freeAttempts: 3, maxBackoff: 5 * time.Minute, lockAfter: 10, lockDuration: 30 * time.Minute}
+	// addresses are shared behind NAT so they get more room before slowing down
+	ipThrottle = throttlePolicy{prefix: "ip:", freeAttempts: 10, maxBackoff: 5 * time.Minute, lockAfter: 50, lockDuration: time.Hour}
+)
+
+// backoff returns how long sign ins stay blocked after the given number of consecutive failures
+func (p throttlePolicy) backoff(failures uint) time.Duration {
+	if failures >= p.lockAfter {
+		return p.lockDuration
+	}
+	if failures <= p.freeAttempts {
+		return 0
+	}
+	shift := failures - p.freeAttempts - 1
+	if shift > 16 {
+		return p.maxBackoff
+	}
+	delay := time.Second << shift
+	if delay > p.maxBackoff {
+		return p.maxBackoff
+	}
+	return delay
+}
+
+// LoginThrottledError is returned while an account or address has to wait before trying again
+type LoginThrottledError struct {
+	RetryAfter time.Duration
+}
+
+func (e LoginThrottledError) Error() string {
+	return "too many failed sign in attempts, please try again later"
+}
+
+type SecurityService struct {
+	Repo     repository.SecurityRepository
+	Notifier notification.Notifier
+}
+
+// CheckLogin rejects a sign in while the account or the address it comes from is backing off
+func (s SecurityService) CheckLogin(email string, ip string) error {
+	now := time.Now()
+	keys := []string{accountKey(email, ip), ipThrottle.prefix + ip}
+	for _, key := range keys {
+		throttle, err := s.Repo.FindThrottle(key)
+		if err != nil || throttle.LockedUntil == nil || !throttle.LockedUntil.After(now) {
+			continue
+		}
+		s.Record(domain.SecurityEvent{Email: normaliseEmail(email), IP: ip, Type: domain.EVENT_LOGIN_THROTTLED, Detail: "backing off " + key})
+		return LoginThrottledError{RetryAfter: throttle.LockedUntil.Sub(now)}
+	}
+	return nil
+}
+
+// LoginFailed counts a failed sign in against the account and the address, user is nil for unknown emails
+func (s SecurityService) LoginFailed(user *domain.User, email string, ip string) {
+	now := time.Now()
+	event := domain.SecurityEvent{Email: normaliseEmail(email), IP: ip, Type: domain.EVENT_LOGIN_FAILED}
+	if user != nil {
+		event.UserID = user.ID
+	}
+
+	failures := s.countFailure(accountThrottle, accountKey(email, ip), now)
+	s.countFailure(ipThrottle, ipThrottle.prefix+ip, now)
+
+	event.Detail = fmt.Sprintf("%d consecutive failures", failures)
+	s.Record(event)
+
+	if failures == accountThrottle.lockAfter {
+		event.Type = domain.EVENT_ACCOUNT_LOCKED
+		event.Detail = fmt.Sprintf("locked for %s from %s", accountThrottle.lockDuration, ip)
+		s.Record(event)
+		if user != nil {
+			s.notifyLockout(*user, ip)
+		}
+	}
+}
+
+// LoginSucceeded clears the account's failures from the address, the address keeps its own count so
+// one valid account cannot reset it
+func (s SecurityService) LoginSucceeded(user domain.User, ip string) {
+	err := s.Repo.DeleteThrottle(accountKey(user.Email, ip))
+	if err != nil {
+		log.Printf("Error while clearing failed logins of user %d: %v", user.ID, err)
+	}
+	s.Record(domain.SecurityEvent{UserID: user.ID, Email: normaliseEmail(user.Email), IP: ip, Type: domain.EVENT_LOGIN_SUCCEEDED})
+}
+
+// PasswordChanged records the change and tells the user, so a change they did not make does not go unnoticed
+func (s SecurityService) PasswordChanged(user domain.User, ip string) {
+	s.Record(domain.SecurityEvent{UserID: user.ID, Email: normaliseEmail(user.Email), IP: ip, Type: domain.EVENT_PASSWORD_CHANGED})
+
+	message := fmt.Sprintf("The password of your account was changed from %s and your other devices were signed out. "+
+		"If this was not you, please reset your password and contact support.", ip)
+	err := s.Notifier.Notify(user.Email, "Your password was changed", message)
+	if err != nil {
+		log.Printf("Error while notifying user %d of a password change: %v", user.ID, err)
+	}
+}
+
+// Record stores a security event, a failure to store it must not block the request that caused it
+func (s SecurityService) Record(event domain.SecurityEvent) {
+	err := s.Repo.CreateSecurityEvent(&event)
+	if err != nil {
+		log.Printf("Error while recording %s security event: %v", event.Type, err)
+	}
+}
+
+func (s SecurityService) GetSecurityEvents(userID uint, limit int) ([]domain.SecurityEvent, error) {
+	return s.Repo.FindSecurityEvents(userID, limit)
+}
+
+func (s SecurityService) countFailure(policy throttlePolicy, key string, now time.Time) uint {
+	throttle, err := s.Repo.IncrementThrottle(key, now, failedLoginWindow)
+	if err != nil {
+		return 0
+	}
+	if delay := policy.backoff(throttle.Failures); delay > 0 {
+		err = s.Repo.LockThrottle(key, now.Add(delay))
+		if err != nil {
+			log.Printf("Error while backing off failed logins %v", err)
+		}
+	}
+	return throttle.Failures
+}
+
+func (s SecurityService) notifyLockout(user domain.User, ip string) {
+	message := fmt.Sprintf("We noticed %d failed attempts to sign in to your account, the latest from %s. "+
+		"Sign in from that address has been paused for %s. If this was not you, we recommend changing your password once you can sign in again.",
+		accountThrottle.lockAfter, ip, accountThrottle.lockDuration)
+
+	err := s.Notifier.Notify(user.Email, "Suspicious sign in attempts on your account", message)
+	if err != nil {
+		log.Printf("Error while notifying user %d of a lockout: %v", user.ID, err)
+	}
+}
+
+// accountKey throttles the failures of an account from one address
+func accountKey(email string, ip string) string {
+	return accountThrottle.prefix + normaliseEmail(email) + "|" + ip
+}
+
+func normaliseEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
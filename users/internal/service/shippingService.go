@@ -0,0 +1,305 @@
+package service
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/tax"
+	"log"
+	"regexp"
+	"strings"
+)
+
+var errNoShippingRate = errors.New("shipping method is not available for this destination")
+
+var shippingCodePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+type ShippingService struct {
+	Repo repository.ShippingRepository
+}
+
+// SeedDefaults creates the standard, express and pickup methods the first time the service starts
+func (s ShippingService) SeedDefaults() error {
+	count, err := s.Repo.CountShippingMethods()
+	if err != nil || count > 0 {
+		return err
+	}
+
+	defaults := []domain.ShippingMethod{
+		{
+			Code: domain.SHIPPING_STANDARD, Name: "Standard delivery", MinDays: 3, MaxDays: 5, Active: true,
+			Rates: []domain.ShippingRate{
+				{MaxWeight: 2, Cost: 4.99, FreeAbove: 50},
+				{MaxWeight: 10, Cost: 8.99, FreeAbove: 50},
+				{Cost: 14.99, FreeAbove: 100},
+			},
+		},
+		{
+			Code: domain.SHIPPING_EXPRESS, Name: "Express delivery", MinDays: 1, MaxDays: 2, Active: true,
+			Rates: []domain.ShippingRate{
+				{MaxWeight: 2, Cost: 9.99, FreeAbove: 150},
+				{MaxWeight: 10, Cost: 14.99, FreeAbove: 150},
+				{Cost: 24.99},
+			},
+		},
+		{
+			Code: domain.SHIPPING_PICKUP, Name: "Collect from pickup point", MinDays: 2, MaxDays: 4, Active: true,
+			Rates: []domain.ShippingRate{
+				{Cost: 0},
+			},
+		},
+	}
+	for i := range defaults {
+		err = s.Repo.CreateShippingMethod(&defaults[i])
+		if err != nil {
+			return err
+		}
+	}
+	log.Println("default shipping methods created")
+	return nil
+}
+
+func (s ShippingService) GetShippingMethods() ([]domain.ShippingMethod, error) {
+	return s.Repo.FindShippingMethods(true)
+}
+
+// ListAllShippingMethods includes the inactive methods, for admins
+func (s ShippingService) ListAllShippingMethods() ([]domain.ShippingMethod, error) {
+	return s.Repo.FindShippingMethods(false)
+}
+
+func (s ShippingService) CreateShippingMethod(input dto.ShippingMethodRequest) (domain.ShippingMethod, error) {
+	code := strings.ToLower(strings.TrimSpace(input.Code))
+	if !shippingCodePattern.MatchString(code) {
+		return domain.ShippingMethod{}, errors.New("code must be up to 32 lowercase letters, digits, dashes or underscores")
+	}
+	if _, err := s.Repo.FindShippingMethodByCode(code); err == nil {
+		return domain.ShippingMethod{}, errors.New("a shipping method with this code already exists")
+	}
+
+	method := domain.ShippingMethod{Code: code, Active: true}
+	err := applyShippingMethod(&method, input)
+	if err != nil {
+		return domain.ShippingMethod{}, err
+	}
+	for _, rateInput := range input.Rates {
+		rate, err := shippingRate(rateInput)
+		if err != nil {
+			return domain.ShippingMethod{}, err
+		}
+		method.Rates = append(method.Rates, rate)
+	}
+
+	err = s.Repo.CreateShippingMethod(&method)
+	if err != nil {
+		return domain.ShippingMethod{}, err
+	}
+	return method, nil
+}
+
+func (s ShippingService) UpdateShippingMethod(id uint, input dto.ShippingMethodRequest) (domain.ShippingMethod, error) {
+	method, err := s.Repo.FindShippingMethodByID(id)
+	if err != nil {
+		return domain.ShippingMethod{}, err
+	}
+	if input.Code != "" && strings.ToLower(strings.TrimSpace(input.Code)) != method.Code {
+		return domain.ShippingMethod{}, errors.New("the code of a shipping method cannot be changed")
+	}
+
+	err = applyShippingMethod(&method, input)
+	if err != nil {
+		return domain.ShippingMethod{}, err
+	}
+	err = s.Repo.UpdateShippingMethod(&method)
+	if err != nil {
+		return domain.ShippingMethod{}, err
+	}
+	return s.Repo.FindShippingMethodByID(id)
+}
+
+// DeleteShippingMethod removes a method and its rates, orders keep the code and cost they were placed with.
+// Deactivating the method hides it without losing its rates
+func (s ShippingService) DeleteShippingMethod(id uint) error {
+	if _, err := s.Repo.FindShippingMethodByID(id); err != nil {
+		return err
+	}
+	return s.Repo.DeleteShippingMethod(id)
+}
+
+func (s ShippingService) AddShippingRate(methodID uint, input dto.ShippingRateRequest) (domain.ShippingRate, error) {
+	if _, err := s.Repo.FindShippingMethodByID(methodID); err != nil {
+		return domain.ShippingRate{}, err
+	}
+	rate, err := shippingRate(input)
+	if err != nil {
+		return domain.ShippingRate{}, err
+	}
+	rate.ShippingMethodID = methodID
+
+	err = s.Repo.CreateShippingRate(&rate)
+	if err != nil {
+		return domain.ShippingRate{}, err
+	}
+	return rate, nil
+}
+
+func (s ShippingService) UpdateShippingRate(methodID uint, id uint, input dto.ShippingRateRequest) (domain.ShippingRate, error) {
+	existing, err := s.Repo.FindShippingRate(methodID, id)
+	if err != nil {
+		return domain.ShippingRate{}, err
+	}
+	rate, err := shippingRate(input)
+	if err != nil {
+		return domain.ShippingRate{}, err
+	}
+	rate.ID = existing.ID
+	rate.ShippingMethodID = existing.ShippingMethodID
+
+	err = s.Repo.UpdateShippingRate(&rate)
+	if err != nil {
+		return domain.ShippingRate{}, err
+	}
+	return s.Repo.FindShippingRate(methodID, id)
+}
+
+func (s ShippingService) DeleteShippingRate(methodID uint, id uint) error {
+	if _, err := s.Repo.FindShippingRate(methodID, id); err != nil {
+		return err
+	}
+	return s.Repo.DeleteShippingRate(methodID, id)
+}
+
+func applyShippingMethod(method *domain.ShippingMethod, input dto.ShippingMethodRequest) error {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return errors.New("name is required")
+	}
+	if input.MinDays > input.MaxDays {
+		return errors.New("min_days cannot be more than max_days")
+	}
+	method.Name = name
+	method.Description = strings.TrimSpace(input.Description)
+	method.MinDays = input.MinDays
+	method.MaxDays = input.MaxDays
+	if input.Active != nil {
+		method.Active = *input.Active
+	}
+	return nil
+}
+
+func shippingRate(input dto.ShippingRateRequest) (domain.ShippingRate, error) {
+	if input.MaxWeight < 0 || input.MinOrderValue < 0 || input.Cost < 0 || input.FreeAbove < 0 {
+		return domain.ShippingRate{}, errors.New("weights, order values and costs cannot be negative")
+	}
+	country := ""
+	if strings.TrimSpace(input.Country) != "" {
+		country = tax.CountryCode(input.Country)
+		if len(country) != 2 {
+			return domain.ShippingRate{}, errors.New("country must be a two letter country code")
+		}
+	}
+	return domain.ShippingRate{
+		Country:       country,
+		MaxWeight:     input.MaxWeight,
+		MinOrderValue: input.MinOrderValue,
+		Cost:          input.Cost,
+		FreeAbove:     input.FreeAbove,
+	}, nil
+}
+
+// GetQuotes prices every active method that delivers to the cart's destination
+func (s ShippingService) GetQuotes(country string, cart *dto.CartResponse) ([]dto.ShippingQuote, error) {
+	methods, err := s.Repo.FindShippingMethods(true)
+	if err != nil {
+		return nil, err
+	}
+
+	weight, value := cartWeight(cart), cart.Subtotal-cart.Discount
+	quotes := make([]dto.ShippingQuote, 0, len(methods))
+	for _, method := range methods {
+		cost, err := quote(method, country, weight, value)
+		if err != nil {
+			continue
+		}
+		quotes = append(quotes, dto.ShippingQuote{
+			Code:    method.Code,
+			Name:    method.Name,
+			MinDays: method.MinDays,
+			MaxDays: method.MaxDays,
+			Cost:    cost,
+			Free:    cost == 0,
+		})
+	}
+	return quotes, nil
+}
+
+// PriceCart adds the cost of the selected shipping method to the cart, the discounted value counts towards free shipping
+func (s ShippingService) PriceCart(code string, country string, cart *dto.CartResponse) error {
+	method, err := s.Repo.FindShippingMethodByCode(code)
+	if err != nil || !method.Active {
+		return errors.New("shipping method not found")
+	}
+
+	cost, err := quote(method, country, cartWeight(cart), cart.Subtotal-cart.Discount)
+	if err != nil {
+		return err
+	}
+	cart.ShippingMethod = method.Code
+	cart.ShippingCost = cost
+	return nil
+}
+
+// quote picks the most specific matching rate: a country rate over the catch-all rate,
+// then the lowest weight band, then the highest order value band
+func quote(method domain.ShippingMethod, country string, weight float64, value float64) (float64, error) {
+	country = tax.CountryCode(country)
+
+	var best *domain.ShippingRate
+	for i := range method.Rates {
+		rate := &method.Rates[i]
+		if rate.Country != "" && tax.CountryCode(rate.Country) != country {
+			continue
+		}
+		if rate.MaxWeight > 0 && weight > rate.MaxWeight {
+			continue
+		}
+		if value < rate.MinOrderValue {
+			continue
+		}
+		if best == nil || moreSpecificRate(rate, best) {
+			best = rate
+		}
+	}
+	if best == nil {
+		return 0, errNoShippingRate
+	}
+
+	if best.FreeAbove > 0 && value >= best.FreeAbove {
+		return 0, nil
+	}
+	return best.Cost, nil
+}
+
+func moreSpecificRate(a *domain.ShippingRate, b *domain.ShippingRate) bool {
+	if (a.Country != "") != (b.Country != "") {
+		return a.Country != ""
+	}
+	if a.MaxWeight != b.MaxWeight {
+		if a.MaxWeight == 0 || b.MaxWeight == 0 {
+			return b.MaxWeight == 0
+		}
+		return a.MaxWeight < b.MaxWeight
+	}
+	return a.MinOrderValue > b.MinOrderValue
+}
+
+func cartWeight(cart *dto.CartResponse) float64 {
+	var weight float64
+	for _, item := range cart.Items {
+		if !item.Unavailable {
+			weight += item.Weight * float64(item.Qty)
+		}
+	}
+	return weight
+}
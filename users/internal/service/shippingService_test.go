@@ -0,0 +1,70 @@
+package service
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"testing"
+)
+
+func TestShippingQuote(t *testing.T) {
+	method := domain.ShippingMethod{
+		Code: domain.SHIPPING_STANDARD,
+		Rates: []domain.ShippingRate{
+			{Cost: 10, FreeAbove: 100},
+			{MaxWeight: 2, Cost: 5},
+			{Country: "DE", Cost: 4},
+			{Country: "DE", MinOrderValue: 50, Cost: 2},
+		},
+	}
+	tests := []struct {
+		name    string
+		country string
+		weight  float64
+		value   float64
+		want    float64
+	}{
+		{"lowest weight band wins", "FR", 1, 20, 5},
+		{"heavier parcels fall through to the catch-all", "FR", 3, 20, 10},
+		{"free above the threshold", "FR", 3, 150, 0},
+		{"free shipping belongs to the matched rate", "FR", 1, 150, 5},
+		{"country rate over the catch-all", "DE", 1, 20, 4},
+		{"highest order value band wins", "DE", 1, 60, 2},
+		{"country names are accepted", "germany", 1, 20, 4},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := quote(method, tt.country, tt.weight, tt.value)
+			if err != nil {
+				t.Fatalf("quote: %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("quote(%q, %v, %v) = %v, want %v", tt.country, tt.weight, tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestShippingQuoteWithoutMatchingRate(t *testing.T) {
+	method := domain.ShippingMethod{
+		Code: domain.SHIPPING_EXPRESS,
+		Rates: []domain.ShippingRate{
+			{Country: "DE", MaxWeight: 5, Cost: 8},
+		},
+	}
+	tests := []struct {
+		name    string
+		country string
+		weight  float64
+	}{
+		{"other country", "FR", 1},
+		{"too heavy", "DE", 6},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := quote(method, tt.country, tt.weight, 20)
+			if !errors.Is(err, errNoShippingRate) {
+				t.Errorf("quote(%q, %v) error = %v, want %v", tt.country, tt.weight, err, errNoShippingRate)
+			}
+		})
+	}
+}
@@ -0,0 +1,338 @@
+package service
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/totp"
+	"github.com/skip2/go-qrcode"
+	"log"
+	"strings"
+	"time"
+)
+
+const (
+	twoFactorIssuer      = "Zamazon"
+	challengeTTL         = 5 * time.Minute
+	maxChallengeAttempts = 5
+	recoveryCodeCount    = 10
+)
+
+var errInvalidTwoFactorCode = errors.New("invalid two factor code")
+
+type TwoFactorService struct {
+	Repo     repository.TwoFactorRepository
+	Security SecurityService
+	// SecretKey encrypts the stored TOTP secrets
+	SecretKey string
+	// EnforceForSellers makes every seller set up two factor authentication
+	EnforceForSellers bool
+}
+
+// Required reports whether the user may not sign in without a second factor
+func (s TwoFactorService) Required(user domain.User) bool {
+	return user.TwoFactorForced || (s.EnforceForSellers && user.HasRole(domain.SELLER))
+}
+
+func (s TwoFactorService) Enabled(userID uint) bool {
+	twoFactor, err := s.Repo.FindTwoFactor(userID)
+	return err == nil && twoFactor.Enabled
+}
+
+func (s TwoFactorService) Status(user domain.User) (dto.TwoFactorStatus, error) {
+	status := dto.TwoFactorStatus{
+		Enabled:  s.Enabled(user.ID),
+		Required: s.Required(user),
+	}
+	if status.Enabled {
+		left, err := s.Repo.CountRecoveryCodes(user.ID)
+		if err != nil {
+			return dto.TwoFactorStatus{}, err
+		}
+		status.RecoveryCodesLeft = left
+	}
+	return status, nil
+}
+
+// StartChallenge issues the short lived token the second sign in step is completed with
+func (s TwoFactorService) StartChallenge(user domain.User, cartToken string, ip string) (dto.LoginResponse, error) {
+	token, err := newRandomToken()
+	if err != nil {
+		return dto.LoginResponse{}, errors.New("could not start two factor sign in")
+	}
+
+	err = s.Repo.CreateLoginChallenge(&domain.LoginChallenge{
+		TokenHash: hashToken(token),
+		UserID:    user.ID,
+		CartToken: cartToken,
+		IP:        ip,
+		ExpiresAt: time.Now().Add(challengeTTL),
+	})
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+
+	step := dto.TWO_FACTOR_VERIFY
+	if !s.Enabled(user.ID) {
+		step = dto.TWO_FACTOR_ENROLL
+	}
+	return dto.LoginResponse{ChallengeToken: token, TwoFactor: step}, nil
+}
+
+// FindChallenge returns a challenge that can still be answered
+func (s TwoFactorService) FindChallenge(token string) (domain.LoginChallenge, error) {
+	challenge, err := s.Repo.FindLoginChallenge(hashToken(token))
+	if err != nil {
+		return domain.LoginChallenge{}, errors.New("sign in has expired, please sign in again")
+	}
+	if time.Now().After(challenge.ExpiresAt) || challenge.Attempts >= maxChallengeAttempts {
+		_ = s.Repo.DeleteLoginChallenge(challenge.ID)
+		return domain.LoginChallenge{}, errors.New("sign in has expired, please sign in again")
+	}
+	return challenge, nil
+}
+
+// CompleteChallenge checks the code of a challenge, users enrolling during sign in confirm their enrollment with it
+// and get their recovery codes back
+func (s TwoFactorService) CompleteChallenge(challenge domain.LoginChallenge, user domain.User, code string) ([]string, error) {
+	err := s.Repo.IncrementChallengeAttempts(challenge.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if s.Enabled(user.ID) {
+		err = s.verifyCode(user, code, challenge.IP)
+	} else {
+		recoveryCodes, err = s.Confirm(user, code, challenge.IP)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Repo.DeleteLoginChallenge(challenge.ID)
+	if err != nil {
+		log.Printf("Error while removing completed login challenge %v", err)
+	}
+	return recoveryCodes, nil
+}
+
+// Enroll creates a new secret for the user, it only takes effect once confirmed with a code
+func (s TwoFactorService) Enroll(user domain.User) (dto.TwoFactorEnrollment, error) {
+	if s.Enabled(user.ID) {
+		return dto.TwoFactorEnrollment{}, errors.New("two factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return dto.TwoFactorEnrollment{}, errors.New("could not create two factor secret")
+	}
+	sealed, err := s.sealSecret(secret)
+	if err != nil {
+		return dto.TwoFactorEnrollment{}, err
+	}
+
+	err = s.Repo.SaveTwoFactor(domain.TwoFactor{UserID: user.ID, Secret: sealed})
+	if err != nil {
+		return dto.TwoFactorEnrollment{}, err
+	}
+
+	uri := totp.ProvisioningURI(twoFactorIssuer, user.Email, secret)
+	png, err := qrcode.Encode(uri, qrcode.Medium, 256)
+	if err != nil {
+		log.Printf("Error while rendering two factor QR code %v", err)
+		return dto.TwoFactorEnrollment{}, errors.New("could not create two factor QR code")
+	}
+
+	return dto.TwoFactorEnrollment{
+		Secret:          secret,
+		ProvisioningURI: uri,
+		QRCode:          "data:image/png;base64," + base64.StdEncoding.EncodeToString(png),
+	}, nil
+}
+
+// Confirm enables a pending enrollment once the user proves their authenticator works
+func (s TwoFactorService) Confirm(user domain.User, code string, ip string) ([]string, error) {
+	twoFactor, err := s.Repo.FindTwoFactor(user.ID)
+	if err != nil {
+		return nil, errors.New("start the two factor enrollment first")
+	}
+	if twoFactor.Enabled {
+		return nil, errors.New("two factor authentication is already enabled")
+	}
+
+	err = s.verifyTOTP(twoFactor, code)
+	if err != nil {
+		s.Security.Record(domain.SecurityEvent{UserID: user.ID, Email: user.Email, IP: ip, Type: domain.EVENT_TWO_FACTOR_FAILED, Detail: "enrollment"})
+		return nil, err
+	}
+
+	now := time.Now()
+	twoFactor.Enabled = true
+	twoFactor.ConfirmedAt = &now
+	err = s.Repo.SaveTwoFactor(twoFactor)
+	if err != nil {
+		return nil, err
+	}
+
+	s.Security.Record(domain.SecurityEvent{UserID: user.ID, Email: user.Email, IP: ip, Type: domain.EVENT_TWO_FACTOR_ENABLED})
+	return s.newRecoveryCodes(user.ID)
+}
+
+// Disable removes two factor authentication, users it is required for have to keep it
+func (s TwoFactorService) Disable(user domain.User, code string, ip string) error {
+	if s.Required(user) {
+		return errors.New("two factor authentication is required for this account")
+	}
+	err := s.verifyCode(user, code, ip)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repo.DeleteTwoFactor(user.ID)
+	if err != nil {
+		return err
+	}
+	s.Security.Record(domain.SecurityEvent{UserID: user.ID, Email: user.Email, IP: ip, Type: domain.EVENT_TWO_FACTOR_DISABLED})
+	return nil
+}
+
+// RegenerateRecoveryCodes replaces all recovery codes, the old ones stop working
+func (s TwoFactorService) RegenerateRecoveryCodes(user domain.User, code string, ip string) ([]string, error) {
+	err := s.verifyCode(user, code, ip)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := s.newRecoveryCodes(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	s.Security.Record(domain.SecurityEvent{UserID: user.ID, Email: user.Email, IP: ip, Type: domain.EVENT_RECOVERY_CODES_RESET})
+	return codes, nil
+}
+
+// verifyCode accepts a current TOTP code or an unused recovery code of an enabled enrollment
+func (s TwoFactorService) verifyCode(user domain.User, code string, ip string) error {
+	twoFactor, err := s.Repo.FindTwoFactor(user.ID)
+	if err != nil || !twoFactor.Enabled {
+		return errors.New("two factor authentication is not enabled")
+	}
+
+	if isRecoveryCode(code) {
+		used, err := s.Repo.UseRecoveryCode(user.ID, hashToken(normaliseRecoveryCode(code)), time.Now())
+		if err != nil {
+			return err
+		}
+		if used {
+			s.Security.Record(domain.SecurityEvent{UserID: user.ID, Email: user.Email, IP: ip, Type: domain.EVENT_RECOVERY_CODE_USED})
+			return nil
+		}
+	} else if err = s.verifyTOTP(twoFactor, code); err == nil {
+		return nil
+	}
+
+	s.Security.Record(domain.SecurityEvent{UserID: user.ID, Email: user.Email, IP: ip, Type: domain.EVENT_TWO_FACTOR_FAILED})
+	return errInvalidTwoFactorCode
+}
+
+// verifyTOTP checks the code and burns its time step so it cannot be used twice
+func (s TwoFactorService) verifyTOTP(twoFactor domain.TwoFactor, code string) error {
+	secret, err := s.openSecret(twoFactor.Secret)
+	if err != nil {
+		return err
+	}
+
+	step, ok := totp.Validate(secret, code, time.Now())
+	if !ok {
+		return errInvalidTwoFactorCode
+	}
+	fresh, err := s.Repo.UseTwoFactorStep(twoFactor.UserID, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return errors.New("two factor code was already used, wait for the next one")
+	}
+	return nil
+}
+
+func (s TwoFactorService) newRecoveryCodes(userID uint) ([]string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		b := make([]byte, 5)
+		_, err := rand.Read(b)
+		if err != nil {
+			return nil, errors.New("could not create recovery codes")
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+		codes = append(codes, code[:4]+"-"+code[4:])
+		hashes = append(hashes, hashToken(code))
+	}
+
+	err := s.Repo.ReplaceRecoveryCodes(userID, hashes)
+	if err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+func (s TwoFactorService) cipher() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(s.SecretKey))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func (s TwoFactorService) sealSecret(secret string) (string, error) {
+	aead, err := s.cipher()
+	if err != nil {
+		return "", errors.New("could not protect two factor secret")
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return "", errors.New("could not protect two factor secret")
+	}
+	return base64.StdEncoding.EncodeToString(aead.Seal(nonce, nonce, []byte(secret), nil)), nil
+}
+
+func (s TwoFactorService) openSecret(sealed string) (string, error) {
+	data, err := base64.StdEncoding.DecodeString(sealed)
+	aead, cipherErr := s.cipher()
+	if err != nil || cipherErr != nil || len(data) < aead.NonceSize() {
+		return "", errors.New("could not read two factor secret")
+	}
+	nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+	secret, err := aead.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		log.Printf("Error while decrypting two factor secret %v", err)
+		return "", errors.New("could not read two factor secret")
+	}
+	return string(secret), nil
+}
+
+// recovery codes are eight base32 characters, TOTP codes six digits
+func isRecoveryCode(code string) bool {
+	return len(normaliseRecoveryCode(code)) == 8
+}
+
+func normaliseRecoveryCode(code string) string {
+	code = strings.ToLower(strings.TrimSpace(code))
+	return strings.NewReplacer("-", "", " ", "").Replace(code)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,27 +1,44 @@
 package service
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"github.com/gofiber/fiber/v2"
 	"github.com/sharat789/zamazon-be-ms/users/internal/client"
 	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
 	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
 	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/invoice"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/tax"
 	"log"
+	"math"
+	"strings"
 	"time"
 )
 
 type UserService struct {
-	Repo          repository.UserRepository
-	CatalogClient *client.CatalogClient
-	AuthClient    *client.AuthClient
+	Repo               repository.UserRepository
+	CatalogClient      *client.CatalogClient
+	AuthClient         *client.AuthClient
+	TransactionsClient *client.TransactionsClient
+	TaxCalculator      tax.TaxCalculator
+	Promotions         PromotionService
+	Shipping           ShippingService
+	Invoices           InvoiceService
+	Security           SecurityService
+	TwoFactor          TwoFactorService
 }
 
-func (s UserService) UserSignup(input dto.UserSignup) (string, error) {
+// errInvalidCredentials does not tell whether the email or the password was wrong
+var errInvalidCredentials = errors.New("invalid email or password")
+
+func (s UserService) UserSignup(input dto.UserSignup, client dto.ClientInfo) (dto.LoginResponse, error) {
 	hashPassword, err := s.AuthClient.CreateHashPassword(input.Password)
 
 	if err != nil {
-		return "", err
+		return dto.LoginResponse{}, err
 	}
 
 	user, err := s.Repo.CreateUser(domain.User{
@@ -31,32 +48,292 @@ func (s UserService) UserSignup(input dto.UserSignup) (string, error) {
 	})
 
 	if err != nil {
-		return "", err
+		return dto.LoginResponse{}, err
 	}
 
-	return s.AuthClient.GenerateToken(user.ID, user.Email, user.UserType)
+	s.mergeGuestCartOnLogin(input.CartToken, user.ID)
+	return s.issueTokens(user, client)
 }
 func (s UserService) findUserByEmail(email string) (*domain.User, error) {
 	user, err := s.Repo.FindUser(email)
-	log.Println(user)
 	return &user, err
 }
 
-func (s UserService) Login(email string, password string) (string, error) {
-	log.Println(email, password)
+// Login signs the user in, the address the attempt came from is used to throttle guessing
+// accounts with two factor authentication get a challenge token instead of an access token
+func (s UserService) Login(input dto.UserLogin, client dto.ClientInfo) (dto.LoginResponse, error) {
+	email, password, ip := input.Email, input.Password, client.IP
+
+	err := s.Security.CheckLogin(email, ip)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
 
 	user, err := s.findUserByEmail(email)
 
 	if err != nil {
-		return "", errors.New("user does not exist with the provided email")
+		s.Security.LoginFailed(nil, email, ip)
+		return dto.LoginResponse{}, errInvalidCredentials
 	}
-	log.Println(user.Password)
-	err = s.AuthClient.VerifyPassword(password, user.Password)
+	needsRehash, err := s.AuthClient.VerifyPassword(password, user.Password)
 
 	if err != nil {
-		return "", err
+		s.Security.LoginFailed(user, email, ip)
+		return dto.LoginResponse{}, errInvalidCredentials
+	}
+	if needsRehash {
+		s.upgradePasswordHash(*user, password, ip)
+	}
+
+	return s.signIn(*user, input.CartToken, client)
+}
+
+// upgradePasswordHash stores the password again with the current hashing settings while it is at hand,
+// the sign in goes ahead even if this fails since the old hash still works
+func (s UserService) upgradePasswordHash(user domain.User, password string, ip string) {
+	hashPassword, err := s.AuthClient.RehashPassword(password, user.Password)
+	if err != nil {
+		log.Printf("Error while rehashing the password of user %d: %v", user.ID, err)
+		return
+	}
+	_, err = s.Repo.UpdateUser(user.ID, domain.User{Password: hashPassword})
+	if err != nil {
+		log.Printf("Error while storing the rehashed password of user %d: %v", user.ID, err)
+		return
+	}
+	s.Security.Record(domain.SecurityEvent{UserID: user.ID, Email: user.Email, IP: ip, Type: domain.EVENT_PASSWORD_REHASHED})
+}
+
+// ChangePassword replaces the password after checking the current one, wrong guesses count as failed
+// sign ins. Every other session is signed out so whoever knew the old password loses access
+func (s UserService) ChangePassword(userID uint, input dto.ChangePasswordInput, token string, client dto.ClientInfo) error {
+	user, err := s.Repo.FindUserByID(userID)
+	if err != nil {
+		return err
+	}
+	// accounts made through a social login have no password to change
+	if user.Password == "" {
+		return errors.New("account has no password, sign in with your social login instead")
+	}
+
+	err = s.Security.CheckLogin(user.Email, client.IP)
+	if err != nil {
+		return err
+	}
+	_, err = s.AuthClient.VerifyPassword(input.CurrentPassword, user.Password)
+	if err != nil {
+		s.Security.LoginFailed(&user, user.Email, client.IP)
+		return errors.New("current password is incorrect")
+	}
+	if input.NewPassword == input.CurrentPassword {
+		return errors.New("new password must be different from the current one")
+	}
+
+	hashPassword, err := s.AuthClient.CreateHashPassword(input.NewPassword)
+	if err != nil {
+		return err
+	}
+	_, err = s.Repo.UpdateUser(user.ID, domain.User{Password: hashPassword})
+	if err != nil {
+		return err
+	}
+
+	_, err = s.AuthClient.RevokeOtherSessions(token)
+	if err != nil {
+		log.Printf("Error while signing user %d out of other sessions: %v", user.ID, err)
+	}
+	s.Security.PasswordChanged(user, client.IP)
+	return nil
+}
+
+// signIn continues a sign in once the first factor, a password or a social login, has been checked
+func (s UserService) signIn(user domain.User, cartToken string, client dto.ClientInfo) (dto.LoginResponse, error) {
+	if user.Status == domain.USER_SUSPENDED {
+		s.Security.Record(domain.SecurityEvent{UserID: user.ID, Email: user.Email, IP: client.IP, Type: domain.EVENT_LOGIN_FAILED, Detail: "account suspended"})
+		return dto.LoginResponse{}, errors.New("account is suspended")
+	}
+
+	if s.TwoFactor.Required(user) || s.TwoFactor.Enabled(user.ID) {
+		return s.TwoFactor.StartChallenge(user, cartToken, client.IP)
+	}
+	return s.completeLogin(user, cartToken, client)
+}
+
+// completeLogin issues the access token once every sign in step has passed
+func (s UserService) completeLogin(user domain.User, cartToken string, client dto.ClientInfo) (dto.LoginResponse, error) {
+	s.Security.LoginSucceeded(user, client.IP)
+	s.mergeGuestCartOnLogin(cartToken, user.ID)
+
+	return s.issueTokens(user, client)
+}
+
+// issueTokens starts a new session for the user on the device they signed in from
+func (s UserService) issueTokens(user domain.User, client dto.ClientInfo) (dto.LoginResponse, error) {
+	tokens, err := s.AuthClient.GenerateTokens(user.ID, user.Email, user.UserType, user.Roles(), client)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+	return dto.LoginResponse{Token: tokens.Token, RefreshToken: tokens.RefreshToken, ExpiresIn: tokens.ExpiresIn}, nil
+}
+
+// RefreshToken renews the access token, refresh tokens are single use so the new one replaces it
+func (s UserService) RefreshToken(refreshToken string, client dto.ClientInfo) (dto.LoginResponse, error) {
+	tokens, err := s.AuthClient.RefreshToken(refreshToken, client)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+	return dto.LoginResponse{Token: tokens.Token, RefreshToken: tokens.RefreshToken, ExpiresIn: tokens.ExpiresIn}, nil
+}
+
+func (s UserService) Logout(token string, input dto.LogoutInput) error {
+	return s.AuthClient.Logout(token, input.RefreshToken, input.AllDevices)
+}
+
+// Sessions lists the devices the user is signed in on, the one the token belongs to is marked current
+func (s UserService) Sessions(token string) ([]dto.Session, error) {
+	return s.AuthClient.Sessions(token)
+}
+
+// RevokeSession signs the user out of one device, its tokens stop working straight away
+func (s UserService) RevokeSession(token string, id string) error {
+	return s.AuthClient.RevokeSession(token, id)
+}
+
+// RevokeOtherSessions signs the user out everywhere but the device making the request
+func (s UserService) RevokeOtherSessions(token string) (int, error) {
+	return s.AuthClient.RevokeOtherSessions(token)
+}
+
+// EnrollTwoFactorLogin lets a user who must use two factor authentication set it up during sign in
+func (s UserService) EnrollTwoFactorLogin(challengeToken string) (dto.TwoFactorEnrollment, error) {
+	challenge, err := s.TwoFactor.FindChallenge(challengeToken)
+	if err != nil {
+		return dto.TwoFactorEnrollment{}, err
+	}
+	user, err := s.Repo.FindUserByID(challenge.UserID)
+	if err != nil {
+		return dto.TwoFactorEnrollment{}, err
+	}
+	return s.TwoFactor.Enroll(user)
+}
+
+// LoginTwoFactor completes a sign in with a TOTP or recovery code, wrong codes count as failed sign ins
+func (s UserService) LoginTwoFactor(input dto.TwoFactorLoginInput, client dto.ClientInfo) (dto.LoginResponse, error) {
+	challenge, err := s.TwoFactor.FindChallenge(input.ChallengeToken)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+	user, err := s.Repo.FindUserByID(challenge.UserID)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+
+	err = s.Security.CheckLogin(user.Email, client.IP)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+
+	recoveryCodes, err := s.TwoFactor.CompleteChallenge(challenge, user, input.Code)
+	if err != nil {
+		s.Security.LoginFailed(&user, user.Email, client.IP)
+		return dto.LoginResponse{}, err
+	}
+
+	response, err := s.completeLogin(user, challenge.CartToken, client)
+	if err != nil {
+		return dto.LoginResponse{}, err
+	}
+	response.RecoveryCodes = recoveryCodes
+	return response, nil
+}
+
+func (s UserService) GetTwoFactorStatus(userID uint) (dto.TwoFactorStatus, error) {
+	user, err := s.Repo.FindUserByID(userID)
+	if err != nil {
+		return dto.TwoFactorStatus{}, err
+	}
+	return s.TwoFactor.Status(user)
+}
+
+func (s UserService) EnrollTwoFactor(userID uint) (dto.TwoFactorEnrollment, error) {
+	user, err := s.Repo.FindUserByID(userID)
+	if err != nil {
+		return dto.TwoFactorEnrollment{}, err
+	}
+	return s.TwoFactor.Enroll(user)
+}
+
+func (s UserService) ConfirmTwoFactor(userID uint, code string, ip string) ([]string, error) {
+	user, err := s.Repo.FindUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.TwoFactor.Confirm(user, code, ip)
+}
+
+func (s UserService) DisableTwoFactor(userID uint, code string, ip string) error {
+	user, err := s.Repo.FindUserByID(userID)
+	if err != nil {
+		return err
+	}
+	return s.TwoFactor.Disable(user, code, ip)
+}
+
+func (s UserService) RegenerateRecoveryCodes(userID uint, code string, ip string) ([]string, error) {
+	user, err := s.Repo.FindUserByID(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.TwoFactor.RegenerateRecoveryCodes(user, code, ip)
+}
+
+// mergeGuestCartOnLogin merges the guest cart if one was given, a failed merge must not block the login
+func (s UserService) mergeGuestCartOnLogin(token string, userID uint) {
+	if token == "" {
+		return
+	}
+	err := s.MergeGuestCart(token, userID)
+	if err != nil {
+		log.Printf("Error while merging guest cart for user %d: %v", userID, err)
 	}
-	return s.AuthClient.GenerateToken(user.ID, user.Email, user.UserType)
+}
+
+// CheckActive rejects tokens of suspended or deleted accounts and tokens issued before a role change
+func (s UserService) CheckActive(tokenUser *client.TokenUser) error {
+	user, err := s.Repo.FindUserByID(tokenUser.ID)
+	if err != nil {
+		return errors.New("account not found")
+	}
+	if user.AnonymisedAt != nil {
+		return errors.New("account has been deleted")
+	}
+	if user.Status == domain.USER_SUSPENDED {
+		return errors.New("account is suspended")
+	}
+	if !sameRoles(user.Roles(), tokenUser.Roles) {
+		return errors.New("account roles have changed, please sign in again")
+	}
+	if s.TwoFactor.Required(user) && !s.TwoFactor.Enabled(user.ID) {
+		return errors.New("two factor authentication must be set up, please sign in again")
+	}
+	return nil
+}
+
+// sameRoles compares roles regardless of their order
+func sameRoles(a []string, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	held := map[string]bool{}
+	for _, role := range a {
+		held[role] = true
+	}
+	for _, role := range b {
+		if !held[role] {
+			return false
+		}
+	}
+	return true
 }
 
 func (s UserService) isVerifiedUser(id uint) bool {
@@ -196,145 +473,608 @@ func (s UserService) UpdateProfile(id uint, input dto.ProfileInput) error {
 	return nil
 }
 
-func (s UserService) FindCart(id uint) ([]domain.Cart, float64, error) {
+// FindCart returns the priced cart, shipping is only added when a shipping method is selected
+func (s UserService) FindCart(id uint, shippingMethod string) (*dto.CartResponse, error) {
 	cartItems, err := s.Repo.FindCartItems(id)
 
 	if err != nil {
 		log.Printf("Error while fetching cart %v", err)
-		return nil, 0, errors.New("unable to fetch cart items")
+		return nil, errors.New("unable to fetch cart items")
+	}
+	cart := s.revalidateCart(cartItems)
+	s.Promotions.PriceCart(id, cart)
+
+	country := s.deliveryCountry(id)
+	if shippingMethod != "" {
+		err = s.Shipping.PriceCart(shippingMethod, country, cart)
+		if err != nil {
+			return nil, err
+		}
+	}
+	s.applyTax(country, cart)
+	return cart, nil
+}
+
+// GetShippingOptions quotes every shipping method for the user's cart and address
+func (s UserService) GetShippingOptions(id uint) ([]dto.ShippingQuote, error) {
+	cart, err := s.FindCart(id, "")
+	if err != nil {
+		return nil, err
+	}
+	return s.Shipping.GetQuotes(s.deliveryCountry(id), cart)
+}
+
+// deliveryCountry is the country of the user's address, empty when the user has no address yet
+func (s UserService) deliveryCountry(userID uint) string {
+	user, err := s.Repo.FindUserByID(userID)
+	if err != nil {
+		return ""
+	}
+	return user.Address.Country
+}
+
+// applyTax adds the tax of every line, on its discounted amount, and the tax-exclusive and tax-inclusive totals.
+// Catalog prices are tax-exclusive
+func (s UserService) applyTax(country string, cart *dto.CartResponse) {
+	cart.TaxCountry = country
+	cart.Tax = 0
+	for i, item := range cart.Items {
+		if item.Unavailable {
+			continue
+		}
+		lineTotal := item.CurrentPrice*float64(item.Qty) - item.Discount
+		cart.Items[i].TaxRate = s.TaxCalculator.Rate(country, item.TaxCategory)
+		cart.Items[i].Tax = s.TaxCalculator.Calculate(country, item.TaxCategory, lineTotal)
+		cart.Tax += cart.Items[i].Tax
+	}
+	// shipping is taxed at the standard rate of the destination
+	cart.Tax += s.TaxCalculator.Calculate(country, tax.CategoryStandard, cart.ShippingCost)
+	cart.Tax = roundCents(cart.Tax)
+	cart.TotalExclTax = roundCents(cart.Subtotal - cart.Discount + cart.ShippingCost)
+	cart.Total = roundCents(cart.TotalExclTax + cart.Tax)
+}
+
+// ApplyCoupon attaches a coupon to the user's cart if it applies to the cart as it is now
+func (s UserService) ApplyCoupon(userID uint, code string) (*dto.CartResponse, error) {
+	cartItems, err := s.Repo.FindCartItems(userID)
+	if err != nil {
+		return nil, errors.New("unable to fetch cart items")
+	}
+	if len(cartItems) == 0 {
+		return nil, errors.New("cart is empty")
+	}
+
+	err = s.Promotions.ApplyCoupon(userID, code, s.revalidateCart(cartItems))
+	if err != nil {
+		return nil, err
+	}
+	return s.FindCart(userID, "")
+}
+
+func (s UserService) RemoveCoupon(userID uint) (*dto.CartResponse, error) {
+	err := s.Promotions.RemoveCoupon(userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.FindCart(userID, "")
+}
+
+// revalidateCart checks every cart line against the live catalog and flags
+// lines whose price changed since it was added, or that can no longer be bought
+func (s UserService) revalidateCart(cartItems []domain.Cart) *dto.CartResponse {
+	cart := &dto.CartResponse{
+		Items: make([]dto.CartItemResponse, 0, len(cartItems)),
+	}
+
+	for _, item := range cartItems {
+		line := dto.CartItemResponse{
+			ID:           item.ID,
+			ProductID:    item.ProductID,
+			Name:         item.Name,
+			ImageURL:     item.ImageURL,
+			SellerId:     item.SellerId,
+			Price:        item.Price,
+			Qty:          item.Qty,
+			CurrentPrice: item.Price,
+		}
+
+		product, err := s.CatalogClient.GetProductByID(item.ProductID)
+		if errors.Is(err, client.ErrProductNotFound) {
+			line.Unavailable = true
+		} else if err != nil {
+			// the catalog could not be reached, treat the line as unavailable rather than charge a stale price
+			log.Printf("Error while revalidating product %d: %v", item.ProductID, err)
+			line.Unavailable = true
+		} else {
+			line.CategoryID = product.CategoryID
+			line.TaxCategory = product.TaxCategory
+			line.Weight = product.Weight
+			line.CurrentPrice = product.Price
+			line.AvailableStock = product.Stock
+			line.PriceChanged = product.Price != item.Price
+			line.OutOfStock = product.Stock < item.Qty
+		}
+
+		if line.PriceChanged {
+			cart.RequiresAcknowledgement = true
+		}
+		if line.Unavailable || line.OutOfStock {
+			cart.HasUnavailableItems = true
+		}
+		if !line.Unavailable {
+			cart.Total += float64(line.Qty) * line.CurrentPrice
+		}
+		cart.Items = append(cart.Items, line)
+	}
+	cart.Total = roundCents(cart.Total)
+	cart.Subtotal = cart.Total
+	return cart
+}
+
+// AcknowledgeCartChanges accepts the live catalog price for every line whose price changed
+func (s UserService) AcknowledgeCartChanges(userID uint) (*dto.CartResponse, error) {
+	cartItems, err := s.Repo.FindCartItems(userID)
+	if err != nil {
+		return nil, errors.New("unable to fetch cart items")
 	}
-	var totalAmount float64
+
 	for _, item := range cartItems {
-		totalAmount += float64(item.Qty) * item.Price
+		product, err := s.CatalogClient.GetProductByID(item.ProductID)
+		if err != nil || product.Price == item.Price {
+			continue
+		}
+		item.Price = product.Price
+		item.Name = product.Name
+		item.ImageURL = product.ImageURL
+		err = s.Repo.UpdateCartItem(item)
+		if err != nil {
+			log.Printf("Error while updating cart price %v", err)
+			return nil, errors.New("unable to update cart")
+		}
 	}
-	return cartItems, totalAmount, nil
+	return s.FindCart(userID, "")
 }
 
 func (s UserService) CreateCart(input dto.CreateCartRequest, u *client.TokenUser) ([]domain.Cart, error) {
 	// check if cart exists
 	cart, _ := s.Repo.FindCartItem(u.ID, input.ProductID)
+	err := s.saveCartItem(cart, domain.Cart{UserID: u.ID}, input)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.FindCartItems(u.ID)
+}
+
+// saveCartItem creates, updates or removes a cart line, owner carries the user id or guest token of the cart
+func (s UserService) saveCartItem(cart domain.Cart, owner domain.Cart, input dto.CreateCartRequest) error {
+	if input.ProductID == 0 {
+		return errors.New("product id is required")
+	}
+
 	if cart.ID != 0 {
-		if input.ProductID == 0 {
-			return nil, errors.New("product id is required")
-		}
 		if input.Qty < 1 {
 			err := s.Repo.DeleteCartById(cart.ID)
 			if err != nil {
 				log.Printf("Error while deleting cart %v", err)
-				return nil, errors.New("unable to delete cart")
-			}
-		} else {
-			cart.Qty = input.Qty
-			err := s.Repo.UpdateCartItem(cart)
-			if err != nil {
-				log.Printf("Error while updating cart %v", err)
-				return nil, errors.New("unable to update cart")
+				return errors.New("unable to delete cart")
 			}
+			return nil
 		}
-	} else {
-		// Use the catalog client to get the product
-		product, err := s.CatalogClient.GetProductByID(input.ProductID)
-		log.Println(product)
-		if err != nil {
-			return nil, errors.New("product not found")
-		}
+		return s.updateCartItemQty(cart, int(input.Qty))
+	}
 
-		err = s.Repo.CreateCart(domain.Cart{
-			UserID:    u.ID,
-			ProductID: input.ProductID,
-			Name:      product.Name,
-			ImageURL:  product.ImageURL,
-			SellerId:  product.UserID,
-			Price:     product.Price,
-			Qty:       input.Qty,
-		})
-		if err != nil {
-			log.Printf("Error while creating cart %v", err)
-			return nil, errors.New("unable to create cart")
-		}
+	// Use the catalog client to get the product
+	product, err := s.CatalogClient.GetProductByID(input.ProductID)
+	if err != nil {
+		return errors.New("product not found")
 	}
-	return s.Repo.FindCartItems(u.ID)
+	if product.Stock < input.Qty {
+		return errors.New("not enough stock for the requested quantity")
+	}
+
+	err = s.Repo.CreateCart(domain.Cart{
+		UserID:     owner.UserID,
+		GuestToken: owner.GuestToken,
+		ProductID:  input.ProductID,
+		Name:       product.Name,
+		ImageURL:   product.ImageURL,
+		SellerId:   product.UserID,
+		Price:      product.Price,
+		Qty:        input.Qty,
+	})
+	if err != nil {
+		log.Printf("Error while creating cart %v", err)
+		return errors.New("unable to create cart")
+	}
+	return nil
 }
 
-func (s UserService) UpdateProductQtyInCart(userID uint, productID uint, qty int) error {
-	cart, err := s.Repo.FindCartItem(userID, productID)
+func (s UserService) updateCartItemQty(cart domain.Cart, qty int) error {
+	if qty < 1 {
+		return errors.New("quantity must be at least 1")
+	}
+	err := s.checkStock(cart.ProductID, uint(qty))
 	if err != nil {
-		return errors.New("cart item not found")
+		return err
 	}
 	cart.Qty = uint(qty)
 	err = s.Repo.UpdateCartItem(cart)
 	if err != nil {
+		log.Printf("Error while updating cart %v", err)
 		return errors.New("unable to update cart item")
 	}
 	return nil
 }
 
-func (s UserService) RemoveProductFromCart(userID uint, productID uint) error {
-	err := s.Repo.DeleteCartItem(userID, productID)
+func (s UserService) checkStock(productID uint, qty uint) error {
+	product, err := s.CatalogClient.GetProductByID(productID)
 	if err != nil {
-		return errors.New("unable to remove product from cart")
+		return errors.New("product not found")
+	}
+	if product.Stock < qty {
+		return errors.New("not enough stock for the requested quantity")
 	}
 	return nil
 }
-func (s UserService) CreateOrder(request dto.CreateOrderRequest) error {
-	cartItems, _, err := s.FindCart(request.UserID)
 
+func (s UserService) UpdateProductQtyInCart(userID uint, productID uint, qty int) error {
+	cart, err := s.Repo.FindCartItem(userID, productID)
 	if err != nil {
-		return errors.New("unable to fetch cart items")
+		return errors.New("cart item not found")
 	}
+	return s.updateCartItemQty(cart, qty)
+}
 
-	if len(cartItems) == 0 {
-		return errors.New("cart is empty")
+func (s UserService) RemoveProductFromCart(userID uint, productID uint) error {
+	err := s.Repo.DeleteCartItem(userID, productID)
+	if err != nil {
+		return errors.New("unable to remove product from cart")
 	}
+	return nil
+}
 
-	var orderItems []domain.OrderItem
-
-	for _, item := range cartItems {
-		orderItems = append(orderItems, domain.OrderItem{
-			ProductID: item.ProductID,
-			Name:      item.Name,
-			ImageURL:  item.ImageURL,
-			SellerId:  item.SellerId,
-			Price:     uint(item.Price),
-			Qty:       item.Qty,
-		})
+// CreateGuestCart adds a product to an anonymous cart. Cart tokens are only ever issued here, a new one
+// is issued when none is given or the given one does not belong to a cart, so a token chosen by the
+// client is never adopted
+func (s UserService) CreateGuestCart(token string, input dto.CreateCartRequest) ([]domain.Cart, string, error) {
+	if token != "" {
+		existing, err := s.Repo.FindGuestCartItems(token)
+		if err != nil {
+			log.Printf("Error while fetching guest cart %v", err)
+			return nil, "", errors.New("unable to create cart")
+		}
+		if len(existing) == 0 {
+			token = ""
+		}
 	}
-
-	order := domain.Order{
-		UserID:         request.UserID,
-		PaymentId:      request.PaymentId,
-		OrderRefNumber: request.OrderRefNumber,
-		Amount:         request.Amount,
-		Items:          orderItems,
-		Status:         "completed",
+	if token == "" {
+		newToken, err := newRandomToken()
+		if err != nil {
+			return nil, "", errors.New("unable to create cart")
+		}
+		token = newToken
 	}
 
-	err = s.Repo.CreateOrder(order)
-
+	cart, _ := s.Repo.FindGuestCartItem(token, input.ProductID)
+	err := s.saveCartItem(cart, domain.Cart{GuestToken: token}, input)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
-	err = s.Repo.DeleteCartItems(request.UserID)
-	log.Printf("Error while deleting cart items %v", err)
-
-	return err
+	cartItems, err := s.Repo.FindGuestCartItems(token)
+	return cartItems, token, err
 }
 
-func (s UserService) ClearCart(userID uint) error {
-	err := s.Repo.DeleteCartItems(userID)
+func (s UserService) FindGuestCart(token string) (*dto.CartResponse, error) {
+	cartItems, err := s.Repo.FindGuestCartItems(token)
 	if err != nil {
-		return errors.New("unable to clear cart")
+		log.Printf("Error while fetching guest cart %v", err)
+		return nil, errors.New("unable to fetch cart items")
+	}
+	cart := s.revalidateCart(cartItems)
+	s.applyTax("", cart)
+	return cart, nil
+}
+
+func (s UserService) UpdateProductQtyInGuestCart(token string, productID uint, qty int) error {
+	cart, err := s.Repo.FindGuestCartItem(token, productID)
+	if err != nil {
+		return errors.New("cart item not found")
+	}
+	return s.updateCartItemQty(cart, qty)
+}
+
+func (s UserService) RemoveProductFromGuestCart(token string, productID uint) error {
+	err := s.Repo.DeleteGuestCartItem(token, productID)
+	if err != nil {
+		return errors.New("unable to remove product from cart")
+	}
+	return nil
+}
+
+func (s UserService) ClearGuestCart(token string) error {
+	err := s.Repo.DeleteGuestCartItems(token)
+	if err != nil {
+		return errors.New("unable to clear cart")
+	}
+	return nil
+}
+
+// MergeGuestCart moves the lines of a guest cart into the user's cart. When both carts
+// hold the same product the quantities are added up, capped at the available stock.
+func (s UserService) MergeGuestCart(token string, userID uint) error {
+	guestItems, err := s.Repo.FindGuestCartItems(token)
+	if err != nil {
+		return errors.New("unable to fetch guest cart")
+	}
+
+	for _, guestItem := range guestItems {
+		userItem, err := s.Repo.FindCartItem(userID, guestItem.ProductID)
+		if err != nil || userItem.ID == 0 {
+			err = s.Repo.AssignCartItemToUser(guestItem.ID, userID)
+			if err != nil {
+				log.Printf("Error while moving guest cart item %d: %v", guestItem.ID, err)
+				return errors.New("unable to merge guest cart")
+			}
+			continue
+		}
+
+		qty := userItem.Qty + guestItem.Qty
+		product, err := s.CatalogClient.GetProductByID(guestItem.ProductID)
+		if err == nil && product.Stock < qty {
+			qty = max(product.Stock, userItem.Qty)
+		}
+		if qty != userItem.Qty {
+			userItem.Qty = qty
+			err = s.Repo.UpdateCartItem(userItem)
+			if err != nil {
+				log.Printf("Error while merging guest cart item %d: %v", guestItem.ID, err)
+				return errors.New("unable to merge guest cart")
+			}
+		}
+	}
+
+	return s.Repo.DeleteGuestCartItems(token)
+}
+
+// newRandomToken returns an unguessable hex token used for guest carts and share links
+func newRandomToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (s UserService) CreateOrder(request dto.CreateOrderRequest) error {
+	cartItems, err := s.Repo.FindCartItems(request.UserID)
+
+	if err != nil {
+		return errors.New("unable to fetch cart items")
+	}
+
+	if len(cartItems) == 0 {
+		return errors.New("cart is empty")
+	}
+
+	cart := s.revalidateCart(cartItems)
+	coupon := s.Promotions.PriceCart(request.UserID, cart)
+	country := s.deliveryCountry(request.UserID)
+	if request.ShippingMethod != "" {
+		err = s.Shipping.PriceCart(request.ShippingMethod, country, cart)
+		if err != nil {
+			// the order has been paid for, keep the method the buyer chose at checkout
+			log.Printf("Error while pricing shipping for order %s: %v", request.OrderRefNumber, err)
+			cart.ShippingMethod = request.ShippingMethod
+		}
+	}
+	s.applyTax(country, cart)
+
+	// the checkout charged the revalidated cart, an order that would come to another amount now is
+	// refused rather than recorded at prices the buyer did not pay
+	if math.Abs(cart.Total-request.Amount) > 0.005 {
+		log.Printf("Error while creating order %s: cart total %.2f does not match the amount paid %.2f", request.OrderRefNumber, cart.Total, request.Amount)
+		return errors.New("cart has changed since checkout, the order total does not match the amount paid")
+	}
+
+	var orderItems []domain.OrderItem
+
+	for _, line := range cart.Items {
+		if line.Unavailable {
+			continue
+		}
+		totalExclTax := roundCents(line.CurrentPrice*float64(line.Qty) - line.Discount)
+		orderItems = append(orderItems, domain.OrderItem{
+			ProductID:    line.ProductID,
+			Name:         line.Name,
+			ImageURL:     line.ImageURL,
+			SellerId:     line.SellerId,
+			Price:        line.CurrentPrice,
+			Qty:          line.Qty,
+			Discount:     line.Discount,
+			TaxRate:      line.TaxRate,
+			Tax:          line.Tax,
+			TotalExclTax: totalExclTax,
+			TotalInclTax: roundCents(totalExclTax + line.Tax),
+		})
+	}
+	if len(orderItems) == 0 {
+		return errors.New("cart has no available items")
+	}
+
+	order := domain.Order{
+		UserID:         request.UserID,
+		PaymentId:      request.PaymentId,
+		OrderRefNumber: request.OrderRefNumber,
+		Amount:         request.Amount,
+		Subtotal:       cart.Subtotal,
+		Discount:       cart.Discount,
+		ShippingMethod: cart.ShippingMethod,
+		ShippingCost:   cart.ShippingCost,
+		TotalExclTax:   cart.TotalExclTax,
+		Tax:            cart.Tax,
+		TaxCountry:     country,
+		Items:          orderItems,
+		Status:         domain.ORDER_COMPLETED,
+		StatusHistory: []domain.OrderStatusHistory{
+			{Status: domain.ORDER_COMPLETED, Note: "payment received", ChangedBy: request.UserID},
+		},
+	}
+
+	// the coupon is redeemed with the order, one that reached its limits since the cart was priced
+	// fails the checkout instead of being handed out past them
+	var redemption *domain.CouponRedemption
+	if coupon != nil {
+		order.CouponCode = coupon.Code
+		redemption = &domain.CouponRedemption{
+			CouponID: coupon.ID,
+			UserID:   request.UserID,
+			Discount: order.Discount,
+		}
+	}
+	order.SubOrders = splitBySeller(order)
+
+	err = s.Repo.CreateOrder(&order, redemption)
+
+	if err != nil {
+		return err
+	}
+
+	if coupon != nil {
+		err = s.Promotions.RemoveCoupon(request.UserID)
+		if err != nil {
+			log.Printf("Error while detaching coupon %s from the cart: %v", coupon.Code, err)
+		}
+	}
+
+	s.issueInvoice(order)
+
+	// reserve the stock for the purchased items
+	for _, item := range orderItems {
+		err = s.CatalogClient.AdjustStock(item.ProductID, -int(item.Qty))
+		if err != nil {
+			log.Printf("Error while reserving stock for product %d: %v", item.ProductID, err)
+		}
+	}
+
+	err = s.Repo.DeleteCartItems(request.UserID)
+	log.Printf("Error while deleting cart items %v", err)
+
+	return err
+}
+
+func (s UserService) ClearCart(userID uint) error {
+	err := s.Repo.DeleteCartItems(userID)
+	if err != nil {
+		return errors.New("unable to clear cart")
 	}
 	return nil
 }
 
-func (s UserService) GetOrders(userID uint) ([]domain.Order, error) {
-	orders, err := s.Repo.FindOrders(userID)
+func (s UserService) GetOrders(userID uint, filter dto.OrderFilter) (dto.OrderHistory, error) {
+	orders, total, err := s.Repo.FindOrders(userID, repository.OrderQuery{
+		Status: filter.Status,
+		From:   filter.From,
+		To:     filter.To,
+		Search: filter.Search,
+		Offset: (filter.Page - 1) * filter.PageSize,
+		Limit:  filter.PageSize,
+	})
+	if err != nil {
+		return dto.OrderHistory{}, err
+	}
+
+	orderIds := make([]uint, 0, len(orders))
+	for _, order := range orders {
+		orderIds = append(orderIds, order.ID)
+	}
+	itemCounts, err := s.Repo.CountOrderItems(orderIds)
+	if err != nil {
+		return dto.OrderHistory{}, err
+	}
+
+	history := dto.OrderHistory{
+		Orders:     make([]dto.OrderSummary, 0, len(orders)),
+		Page:       filter.Page,
+		PageSize:   filter.PageSize,
+		Total:      total,
+		TotalPages: int((total + int64(filter.PageSize) - 1) / int64(filter.PageSize)),
+	}
+	for _, order := range orders {
+		history.Orders = append(history.Orders, dto.OrderSummary{
+			ID:             order.ID,
+			OrderRefNumber: order.OrderRefNumber,
+			Status:         order.Status,
+			Amount:         order.Amount,
+			ItemCount:      itemCounts[order.ID],
+			CreatedAt:      order.CreatedAt,
+		})
+	}
+	return history, nil
+}
+
+// Reorder adds the items of a past order to the user's cart, revalidating each product against the catalog
+func (s UserService) Reorder(orderID uint, u *client.TokenUser) (*dto.ReorderResponse, error) {
+	order, err := s.Repo.FindOrderByID(orderID, u.ID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+
+	results := make([]dto.ReorderItemResult, 0, len(order.Items))
+	for _, item := range order.Items {
+		results = append(results, s.reorderItem(item, u))
+	}
+
+	cart, err := s.FindCart(u.ID, "")
 	if err != nil {
 		return nil, err
 	}
-	return orders, nil
+	return &dto.ReorderResponse{Items: results, Cart: cart}, nil
+}
+
+// reorderItem adds as much of a past order line to the cart as the catalog currently allows
+func (s UserService) reorderItem(item domain.OrderItem, u *client.TokenUser) dto.ReorderItemResult {
+	result := dto.ReorderItemResult{
+		ProductID:     item.ProductID,
+		Name:          item.Name,
+		RequestedQty:  item.Qty,
+		OriginalPrice: item.Price,
+	}
+
+	product, err := s.CatalogClient.GetProductByID(item.ProductID)
+	if err != nil {
+		result.Outcome = dto.REORDER_DISCONTINUED
+		return result
+	}
+	result.CurrentPrice = product.Price
+
+	cartItem, _ := s.Repo.FindCartItem(u.ID, item.ProductID)
+	if product.Stock <= cartItem.Qty {
+		result.Outcome = dto.REORDER_OUT_OF_STOCK
+		return result
+	}
+
+	qty := item.Qty
+	if available := product.Stock - cartItem.Qty; qty > available {
+		qty = available
+	}
+	err = s.saveCartItem(cartItem, domain.Cart{UserID: u.ID}, dto.CreateCartRequest{
+		ProductID: item.ProductID,
+		Qty:       cartItem.Qty + qty,
+	})
+	if err != nil {
+		log.Printf("Error while adding reordered item %d to cart %v", item.ProductID, err)
+		result.Outcome = dto.REORDER_OUT_OF_STOCK
+		return result
+	}
+
+	result.AddedQty = qty
+	result.Outcome = dto.REORDER_ADDED
+	if product.Price != item.Price {
+		result.Outcome = dto.REORDER_PRICE_CHANGED
+	}
+	return result
 }
 
 func (s UserService) GetOrderByID(id uint, userId uint) (domain.Order, error) {
@@ -346,6 +1086,828 @@ func (s UserService) GetOrderByID(id uint, userId uint) (domain.Order, error) {
 	return order, nil
 }
 
+func (s UserService) GetSellerOrders(sellerID uint, filter dto.SellerOrderFilter) ([]dto.SellerOrder, error) {
+	orders, err := s.Repo.FindSellerOrders(sellerID, filter.Status, filter.From, filter.To)
+	if err != nil {
+		return nil, err
+	}
+
+	customers := map[uint]domain.User{}
+	sellerOrders := make([]dto.SellerOrder, 0, len(orders))
+	for _, order := range orders {
+		customer, ok := customers[order.UserID]
+		if !ok {
+			customer, err = s.Repo.FindUserByID(order.UserID)
+			if err != nil {
+				log.Printf("Error while fetching customer %d for order %d: %v", order.UserID, order.ID, err)
+			}
+			customers[order.UserID] = customer
+		}
+
+		sellerOrder := dto.SellerOrder{
+			OrderID:        order.ID,
+			OrderRefNumber: order.OrderRefNumber,
+			OrderStatus:    order.Status,
+			CreatedAt:      order.CreatedAt,
+			CustomerName:   strings.TrimSpace(customer.FName + " " + customer.LName),
+			CustomerEmail:  customer.Email,
+			CustomerPhone:  customer.Phone,
+			ShippingAddress: dto.AddressInput{
+				AddressLine1: customer.Address.AddressLine1,
+				AddressLine2: customer.Address.AddressLine2,
+				City:         customer.Address.City,
+				PostCode:     customer.Address.PostCode,
+				Country:      customer.Address.Country,
+			},
+		}
+		for _, item := range order.Items {
+			sellerOrder.Items = append(sellerOrder.Items, dto.SellerOrderItem{
+				ID:             item.ID,
+				ProductID:      item.ProductID,
+				Name:           item.Name,
+				ImageURL:       item.ImageURL,
+				Price:          item.Price,
+				Discount:       item.Discount,
+				Qty:            item.Qty,
+				Status:         item.Status,
+				TrackingNumber: item.TrackingNumber,
+				ShippedAt:      item.ShippedAt,
+			})
+			sellerOrder.Total += item.Price*float64(item.Qty) - item.Discount
+		}
+		// orders placed before sub-orders existed only carry the items
+		if len(order.SubOrders) > 0 {
+			subOrder := order.SubOrders[0]
+			sellerOrder.SubOrderID = subOrder.ID
+			sellerOrder.ShipmentStatus = subOrder.Status
+			sellerOrder.TrackingNumber = subOrder.TrackingNumber
+			sellerOrder.ShippingCost = subOrder.ShippingCost
+			sellerOrder.Tax = subOrder.Tax
+			sellerOrder.Total = subOrder.Total
+		}
+		sellerOrders = append(sellerOrders, sellerOrder)
+	}
+	return sellerOrders, nil
+}
+
+func (s UserService) UpdateSellerOrderItem(sellerID uint, itemID uint, input dto.UpdateOrderItemRequest) (domain.OrderItem, error) {
+	item, err := s.Repo.FindSellerOrderItem(itemID, sellerID)
+	if err != nil {
+		return domain.OrderItem{}, errors.New("order item not found")
+	}
+	err = s.checkOrderFulfillable(item.OrderID)
+	if err != nil {
+		return domain.OrderItem{}, err
+	}
+
+	err = transitionOrderItem(&item, input)
+	if err != nil {
+		return domain.OrderItem{}, err
+	}
+
+	err = s.Repo.UpdateOrderItem(item)
+	if err != nil {
+		return domain.OrderItem{}, err
+	}
+
+	if item.SubOrderID != 0 {
+		err = s.syncSubOrderStatus(item.SubOrderID)
+		if err != nil {
+			log.Printf("Error while updating shipment %d: %v", item.SubOrderID, err)
+		}
+	}
+	if item.Status != domain.ITEM_PACKED {
+		err = s.syncOrderShipmentStatus(item.OrderID, sellerID)
+		if err != nil {
+			log.Printf("Error while updating shipment status for order %d: %v", item.OrderID, err)
+		}
+	}
+	return item, nil
+}
+
+// checkOrderFulfillable stops sellers shipping an order the buyer is cancelling
+func (s UserService) checkOrderFulfillable(orderID uint) error {
+	order, err := s.Repo.FindOrder(orderID)
+	if err != nil {
+		return errors.New("order not found")
+	}
+	if order.Status == domain.ORDER_CANCELLING || order.Status == domain.ORDER_CANCELLED {
+		return errors.New("order has been cancelled")
+	}
+	return nil
+}
+
+// transitionOrderItem moves an item to the requested fulfilment status if the transition is allowed
+func transitionOrderItem(item *domain.OrderItem, input dto.UpdateOrderItemRequest) error {
+	switch input.Status {
+	case domain.ITEM_PACKED:
+		if item.Status != domain.ITEM_PENDING {
+			return errors.New("only pending items can be packed")
+		}
+	case domain.ITEM_SHIPPED:
+		if item.Status != domain.ITEM_PENDING && item.Status != domain.ITEM_PACKED {
+			return errors.New("only pending or packed items can be shipped")
+		}
+		if input.TrackingNumber == "" {
+			return errors.New("tracking number is required to ship an item")
+		}
+		shippedAt := time.Now()
+		item.TrackingNumber = input.TrackingNumber
+		item.ShippedAt = &shippedAt
+	case domain.ITEM_DELIVERED:
+		if item.Status != domain.ITEM_SHIPPED {
+			return errors.New("only shipped items can be delivered")
+		}
+	default:
+		return errors.New("status must be packed, shipped or delivered")
+	}
+
+	item.Status = input.Status
+	return nil
+}
+
+// UpdateSellerSubOrder moves every open item of the seller's shipment to the requested status
+func (s UserService) UpdateSellerSubOrder(sellerID uint, subOrderID uint, input dto.UpdateOrderItemRequest) (domain.SubOrder, error) {
+	subOrder, err := s.Repo.FindSellerSubOrder(subOrderID, sellerID)
+	if err != nil {
+		return domain.SubOrder{}, errors.New("shipment not found")
+	}
+	err = s.checkOrderFulfillable(subOrder.OrderID)
+	if err != nil {
+		return domain.SubOrder{}, err
+	}
+
+	updated := 0
+	for _, item := range subOrder.Items {
+		if item.Status == domain.ITEM_CANCELLED || item.Status == input.Status {
+			continue
+		}
+		err = transitionOrderItem(&item, input)
+		if err != nil {
+			return domain.SubOrder{}, err
+		}
+		err = s.Repo.UpdateOrderItem(item)
+		if err != nil {
+			return domain.SubOrder{}, err
+		}
+		updated++
+	}
+	if updated == 0 {
+		return subOrder, nil
+	}
+
+	err = s.syncSubOrderStatus(subOrder.ID)
+	if err != nil {
+		return domain.SubOrder{}, err
+	}
+	if input.Status != domain.ITEM_PACKED {
+		err = s.syncOrderShipmentStatus(subOrder.OrderID, sellerID)
+		if err != nil {
+			log.Printf("Error while updating shipment status for order %d: %v", subOrder.OrderID, err)
+		}
+	}
+	return s.Repo.FindSellerSubOrder(subOrder.ID, sellerID)
+}
+
+// syncSubOrderStatus rolls the item states of a shipment up to the shipment
+func (s UserService) syncSubOrderStatus(subOrderID uint) error {
+	subOrder, err := s.Repo.FindSubOrder(subOrderID)
+	if err != nil {
+		return err
+	}
+
+	status := subOrderStatus(subOrder.Items)
+	changed := status != subOrder.Status
+	subOrder.Status = status
+	for _, item := range subOrder.Items {
+		if subOrder.TrackingNumber == "" && item.TrackingNumber != "" {
+			subOrder.TrackingNumber = item.TrackingNumber
+			subOrder.ShippedAt = item.ShippedAt
+			changed = true
+		}
+	}
+	if !changed {
+		return nil
+	}
+	return s.Repo.UpdateSubOrder(subOrder)
+}
+
+func subOrderStatus(items []domain.OrderItem) string {
+	active, packed, shipped, delivered := 0, 0, 0, 0
+	for _, item := range items {
+		switch item.Status {
+		case domain.ITEM_CANCELLED:
+			continue
+		case domain.ITEM_PACKED:
+			packed++
+		case domain.ITEM_SHIPPED:
+			shipped++
+		case domain.ITEM_DELIVERED:
+			delivered++
+		}
+		active++
+	}
+
+	switch {
+	case active == 0:
+		return domain.ITEM_CANCELLED
+	case delivered == active:
+		return domain.ITEM_DELIVERED
+	case shipped+delivered == active:
+		return domain.ITEM_SHIPPED
+	case shipped+delivered > 0:
+		return domain.ORDER_PARTIALLY_SHIPPED
+	case packed == active:
+		return domain.ITEM_PACKED
+	default:
+		return domain.ITEM_PENDING
+	}
+}
+
+// splitBySeller groups the order lines into one sub-order per seller. The order's shipping,
+// and the tax on it, are shared between the sub-orders in proportion to their value
+func splitBySeller(order domain.Order) []domain.SubOrder {
+	var subOrders []domain.SubOrder
+	index := map[uint]int{}
+	var itemsTotal, itemsTax float64
+	for _, item := range order.Items {
+		i, ok := index[item.SellerId]
+		if !ok {
+			i = len(subOrders)
+			index[item.SellerId] = i
+			subOrders = append(subOrders, domain.SubOrder{
+				SellerId: item.SellerId,
+				Status:   domain.ITEM_PENDING,
+			})
+		}
+		subOrders[i].Subtotal += item.Price * float64(item.Qty)
+		subOrders[i].Discount += item.Discount
+		subOrders[i].TotalExclTax += item.TotalExclTax
+		subOrders[i].Tax += item.Tax
+		itemsTotal += item.TotalExclTax
+		itemsTax += item.Tax
+	}
+
+	shippingTax := roundCents(order.Tax - itemsTax)
+	var allocatedShipping, allocatedTax float64
+	for i := range subOrders {
+		subOrder := &subOrders[i]
+		shipping, tax := order.ShippingCost-allocatedShipping, shippingTax-allocatedTax
+		if i < len(subOrders)-1 {
+			share := 1 / float64(len(subOrders))
+			if itemsTotal > 0 {
+				share = subOrder.TotalExclTax / itemsTotal
+			}
+			shipping, tax = roundCents(order.ShippingCost*share), roundCents(shippingTax*share)
+		}
+		allocatedShipping += shipping
+		allocatedTax += tax
+
+		subOrder.ShippingCost = roundCents(shipping)
+		subOrder.Subtotal = roundCents(subOrder.Subtotal)
+		subOrder.Discount = roundCents(subOrder.Discount)
+		subOrder.TotalExclTax = roundCents(subOrder.TotalExclTax + shipping)
+		subOrder.Tax = roundCents(subOrder.Tax + tax)
+		subOrder.Total = roundCents(subOrder.TotalExclTax + subOrder.Tax)
+	}
+	return subOrders
+}
+
+// syncOrderShipmentStatus rolls the line item shipment states up to the order
+func (s UserService) syncOrderShipmentStatus(orderID uint, changedBy uint) error {
+	order, err := s.Repo.FindOrder(orderID)
+	if err != nil {
+		return err
+	}
+
+	shipped, delivered := 0, 0
+	for _, item := range order.Items {
+		switch item.Status {
+		case domain.ITEM_DELIVERED:
+			delivered++
+			shipped++
+		case domain.ITEM_SHIPPED:
+			shipped++
+		}
+	}
+	if shipped == 0 {
+		return nil
+	}
+
+	status := domain.ORDER_PARTIALLY_SHIPPED
+	if delivered == len(order.Items) {
+		status = domain.ORDER_DELIVERED
+	} else if shipped == len(order.Items) {
+		status = domain.ORDER_SHIPPED
+	}
+	if status == order.Status {
+		return nil
+	}
+	return s.setOrderStatus(orderID, status, "", changedBy)
+}
+
+// setOrderStatus updates the order and appends the change to its status history
+func (s UserService) setOrderStatus(orderID uint, status string, note string, changedBy uint) error {
+	err := s.Repo.UpdateOrderStatus(orderID, status)
+	if err != nil {
+		return err
+	}
+	return s.recordOrderStatus(orderID, status, note, changedBy)
+}
+
+func (s UserService) recordOrderStatus(orderID uint, status string, note string, changedBy uint) error {
+	return s.Repo.CreateOrderStatusHistory(domain.OrderStatusHistory{
+		OrderID:   orderID,
+		Status:    status,
+		Note:      note,
+		ChangedBy: changedBy,
+	})
+}
+
+func (s UserService) CancelOrder(orderID uint, userID uint, reason string) error {
+	order, err := s.Repo.FindOrderByID(orderID, userID)
+	if err != nil {
+		return errors.New("order not found")
+	}
+
+	if order.Status == domain.ORDER_CANCELLED {
+		return errors.New("order already cancelled")
+	}
+	for _, item := range order.Items {
+		if item.Status != domain.ITEM_PENDING && item.Status != domain.ITEM_PACKED {
+			return errors.New("order can no longer be cancelled as it has been shipped")
+		}
+	}
+
+	// the order stops being fulfilled before the refund is asked for, a cancellation whose refund
+	// failed stays pending and is completed by cancelling again, the key keeps it to a single refund
+	if order.Status != domain.ORDER_CANCELLING {
+		err = s.setOrderStatus(order.ID, domain.ORDER_CANCELLING, reason, userID)
+		if err != nil {
+			return err
+		}
+	}
+
+	refund, err := s.TransactionsClient.RefundPayment(dto.RefundRequest{
+		PaymentId:      order.PaymentId,
+		OrderRefNumber: order.OrderRefNumber,
+		Amount:         order.Amount,
+		Reason:         "order cancelled",
+		RequestedBy:    userID,
+		IdempotencyKey: fmt.Sprintf("order-%d-cancel", order.ID),
+	})
+	if err != nil {
+		log.Printf("Error while refunding cancelled order %d: %v", order.ID, err)
+		return errors.New("unable to refund order, please try cancelling again")
+	}
+
+	err = s.Repo.UpdateOrderItemsStatus(order.ID, domain.ITEM_CANCELLED)
+	if err != nil {
+		return err
+	}
+	err = s.Repo.UpdateSubOrdersStatus(order.ID, domain.ITEM_CANCELLED)
+	if err != nil {
+		return err
+	}
+	err = s.setOrderStatus(order.ID, domain.ORDER_CANCELLED, reason, userID)
+	if err != nil {
+		return err
+	}
+
+	cn := creditNote{
+		TotalExclTax: order.TotalExclTax,
+		Tax:          order.Tax,
+		RefundId:     refund.RefundId,
+		Note:         "Order cancelled: " + reason,
+	}
+	if cn.TotalExclTax == 0 && cn.Tax == 0 {
+		cn.TotalExclTax = order.Amount
+	}
+	for _, item := range order.Items {
+		cn.Lines = append(cn.Lines, creditNoteLine(item, item.Qty))
+	}
+	if order.ShippingCost > 0 {
+		cn.Lines = append(cn.Lines, invoice.Line{Description: "Shipping", Qty: 1, UnitPrice: order.ShippingCost, Amount: order.ShippingCost})
+	}
+	s.issueCreditNote(order, cn)
+
+	// release the stock reserved by the order
+	for _, item := range order.Items {
+		err = s.CatalogClient.AdjustStock(item.ProductID, int(item.Qty))
+		if err != nil {
+			log.Printf("Error while releasing stock for product %d: %v", item.ProductID, err)
+		}
+	}
+	return nil
+}
+
+func (s UserService) RequestReturn(orderID uint, userID uint, input dto.CreateReturnRequest) (domain.ReturnRequest, error) {
+	order, err := s.Repo.FindOrderByID(orderID, userID)
+	if err != nil {
+		return domain.ReturnRequest{}, errors.New("order not found")
+	}
+
+	var item *domain.OrderItem
+	for i := range order.Items {
+		if order.Items[i].ID == input.OrderItemID {
+			item = &order.Items[i]
+		}
+	}
+	if item == nil {
+		return domain.ReturnRequest{}, errors.New("order item not found")
+	}
+	if item.Status != domain.ITEM_DELIVERED {
+		return domain.ReturnRequest{}, errors.New("only delivered items can be returned")
+	}
+	if input.Reason == "" {
+		return domain.ReturnRequest{}, errors.New("reason is required")
+	}
+
+	existing, err := s.Repo.FindOrderItemReturnRequests(item.ID)
+	if err != nil {
+		return domain.ReturnRequest{}, err
+	}
+	var returnedQty uint
+	for _, r := range existing {
+		if r.Status != domain.RETURN_REJECTED {
+			returnedQty += r.Qty
+		}
+	}
+	if input.Qty < 1 || returnedQty+input.Qty > item.Qty {
+		return domain.ReturnRequest{}, errors.New("return quantity exceeds purchased quantity")
+	}
+
+	returnRequest := domain.ReturnRequest{
+		OrderID:      order.ID,
+		OrderItemID:  item.ID,
+		UserID:       userID,
+		SellerId:     item.SellerId,
+		ProductID:    item.ProductID,
+		Qty:          input.Qty,
+		Reason:       input.Reason,
+		Status:       domain.RETURN_REQUESTED,
+		RefundAmount: roundCents(lineTotal(*item) / float64(item.Qty) * float64(input.Qty)),
+	}
+	err = s.Repo.CreateReturnRequest(&returnRequest)
+	if err != nil {
+		return domain.ReturnRequest{}, err
+	}
+
+	err = s.recordReturnStatus(returnRequest, input.Reason, userID)
+	return returnRequest, err
+}
+
+func (s UserService) GetReturnRequests(userID uint) ([]domain.ReturnRequest, error) {
+	return s.Repo.FindUserReturnRequests(userID)
+}
+
+func (s UserService) GetSellerReturnRequests(sellerID uint, status string) ([]domain.ReturnRequest, error) {
+	return s.Repo.FindSellerReturnRequests(sellerID, status)
+}
+
+func (s UserService) ApproveReturn(sellerID uint, returnID uint, note string) (domain.ReturnRequest, error) {
+	return s.reviewReturn(sellerID, returnID, domain.RETURN_APPROVED, note)
+}
+
+func (s UserService) RejectReturn(sellerID uint, returnID uint, note string) (domain.ReturnRequest, error) {
+	return s.reviewReturn(sellerID, returnID, domain.RETURN_REJECTED, note)
+}
+
+func (s UserService) reviewReturn(sellerID uint, returnID uint, status string, note string) (domain.ReturnRequest, error) {
+	returnRequest, err := s.findSellerReturn(sellerID, returnID)
+	if err != nil {
+		return domain.ReturnRequest{}, err
+	}
+	if returnRequest.Status != domain.RETURN_REQUESTED {
+		return domain.ReturnRequest{}, errors.New("return request has already been reviewed")
+	}
+
+	returnRequest.Status = status
+	returnRequest.SellerNote = note
+	err = s.Repo.UpdateReturnRequest(returnRequest)
+	if err != nil {
+		return domain.ReturnRequest{}, err
+	}
+
+	err = s.recordReturnStatus(returnRequest, note, sellerID)
+	return returnRequest, err
+}
+
+// ReceiveReturn marks the returned goods as received, puts them back in stock and refunds the buyer
+func (s UserService) ReceiveReturn(sellerID uint, returnID uint) (domain.ReturnRequest, error) {
+	returnRequest, err := s.findSellerReturn(sellerID, returnID)
+	if err != nil {
+		return domain.ReturnRequest{}, err
+	}
+	if returnRequest.Status != domain.RETURN_APPROVED {
+		return domain.ReturnRequest{}, errors.New("only approved returns can be received")
+	}
+
+	returnRequest.Status = domain.RETURN_RECEIVED
+	err = s.Repo.UpdateReturnRequest(returnRequest)
+	if err != nil {
+		return domain.ReturnRequest{}, err
+	}
+
+	err = s.CatalogClient.AdjustStock(returnRequest.ProductID, int(returnRequest.Qty))
+	if err != nil {
+		log.Printf("Error while restocking product %d: %v", returnRequest.ProductID, err)
+	}
+
+	err = s.recordReturnStatus(returnRequest, "", sellerID)
+	if err != nil {
+		return domain.ReturnRequest{}, err
+	}
+
+	return s.RefundReturn(sellerID, returnID)
+}
+
+// RefundReturn issues the refund for a received return, it can be retried if the payment provider failed
+func (s UserService) RefundReturn(sellerID uint, returnID uint) (domain.ReturnRequest, error) {
+	returnRequest, err := s.findSellerReturn(sellerID, returnID)
+	if err != nil {
+		return domain.ReturnRequest{}, err
+	}
+	if returnRequest.Status != domain.RETURN_RECEIVED && returnRequest.Status != domain.RETURN_REFUND_PENDING {
+		return domain.ReturnRequest{}, errors.New("only received returns can be refunded")
+	}
+
+	order, err := s.Repo.FindOrder(returnRequest.OrderID)
+	if err != nil {
+		return domain.ReturnRequest{}, err
+	}
+	err = s.checkSellerRefund(sellerID, order, returnRequest)
+	if err != nil {
+		return domain.ReturnRequest{}, err
+	}
+
+	// the return is marked pending before the refund is asked for, a retry after a failure reuses
+	// the key so the payment provider refunds it only once
+	if returnRequest.Status != domain.RETURN_REFUND_PENDING {
+		returnRequest.Status = domain.RETURN_REFUND_PENDING
+		err = s.Repo.UpdateReturnRequest(returnRequest)
+		if err != nil {
+			return domain.ReturnRequest{}, err
+		}
+		err = s.recordReturnStatus(returnRequest, "", sellerID)
+		if err != nil {
+			return domain.ReturnRequest{}, err
+		}
+	}
+
+	refund, err := s.TransactionsClient.RefundPayment(dto.RefundRequest{
+		PaymentId:      order.PaymentId,
+		OrderRefNumber: order.OrderRefNumber,
+		Amount:         returnRequest.RefundAmount,
+		Reason:         returnRequest.Reason,
+		RequestedBy:    sellerID,
+		IdempotencyKey: fmt.Sprintf("return-%d-refund", returnRequest.ID),
+	})
+	if err != nil {
+		log.Printf("Error while refunding return %d: %v", returnRequest.ID, err)
+		return domain.ReturnRequest{}, errors.New("unable to refund return")
+	}
+
+	returnRequest.Status = domain.RETURN_REFUNDED
+	returnRequest.RefundId = refund.RefundId
+	err = s.Repo.UpdateReturnRequest(returnRequest)
+	if err != nil {
+		return domain.ReturnRequest{}, err
+	}
+
+	for _, item := range order.Items {
+		if item.ID != returnRequest.OrderItemID {
+			continue
+		}
+		line := creditNoteLine(item, returnRequest.Qty)
+		cn := creditNote{
+			Lines:           []invoice.Line{line},
+			TotalExclTax:    line.Amount,
+			Tax:             returnRequest.RefundAmount - line.Amount,
+			RefundId:        refund.RefundId,
+			ReturnRequestID: returnRequest.ID,
+			Note:            "Returned: " + returnRequest.Reason,
+		}
+		if item.TotalInclTax == 0 {
+			// orders placed before tax was recorded
+			cn.TotalExclTax, cn.Tax = returnRequest.RefundAmount, 0
+		}
+		s.issueCreditNote(order, cn)
+	}
+
+	err = s.recordReturnStatus(returnRequest, "", sellerID)
+	return returnRequest, err
+}
+
+// issueInvoice creates the invoice of a paid order, failures are logged as the invoice can be issued again on download
+func (s UserService) issueInvoice(order domain.Order) {
+	customer, err := s.Repo.FindUserByID(order.UserID)
+	if err != nil {
+		log.Printf("Error while fetching customer for invoice of order %d: %v", order.ID, err)
+		return
+	}
+	_, err = s.Invoices.IssueInvoice(order, customer)
+	if err != nil {
+		log.Printf("Error while issuing invoice for order %d: %v", order.ID, err)
+	}
+}
+
+func (s UserService) issueCreditNote(order domain.Order, cn creditNote) {
+	customer, err := s.Repo.FindUserByID(order.UserID)
+	if err != nil {
+		log.Printf("Error while fetching customer for credit note of order %d: %v", order.ID, err)
+		return
+	}
+	_, err = s.Invoices.IssueCreditNote(order, customer, cn)
+	if err != nil {
+		log.Printf("Error while issuing credit note for order %d: %v", order.ID, err)
+	}
+}
+
+// creditNoteLine credits qty units of an order item at the tax-exclusive price the buyer paid
+func creditNoteLine(item domain.OrderItem, qty uint) invoice.Line {
+	unitPrice := item.Price - item.Discount/float64(item.Qty)
+	return invoice.Line{
+		Description: item.Name,
+		Qty:         qty,
+		UnitPrice:   roundCents(unitPrice),
+		TaxRate:     item.TaxRate,
+		Amount:      roundCents(unitPrice * float64(qty)),
+	}
+}
+
+// GetOrderInvoice returns the invoice of the buyer's order with its PDF, issuing it if the order predates invoicing
+func (s UserService) GetOrderInvoice(orderID uint, userID uint) (domain.Invoice, []byte, error) {
+	order, err := s.Repo.FindOrderByID(orderID, userID)
+	if err != nil {
+		return domain.Invoice{}, nil, errors.New("order not found")
+	}
+	return s.orderInvoice(order)
+}
+
+// GetSellerOrderInvoice returns the extract of the order's invoice covering the seller's sub-order,
+// the buyer's full invoice lists what other sellers sold and is never served to a seller
+func (s UserService) GetSellerOrderInvoice(orderID uint, sellerID uint) (domain.Invoice, []byte, error) {
+	order, err := s.Repo.FindOrder(orderID)
+	if err != nil {
+		return domain.Invoice{}, nil, errors.New("order not found")
+	}
+	var subOrder *domain.SubOrder
+	for i := range order.SubOrders {
+		if order.SubOrders[i].SellerId == sellerID {
+			subOrder = &order.SubOrders[i]
+		}
+	}
+	if subOrder == nil {
+		return domain.Invoice{}, nil, errors.New("order not found")
+	}
+	var items []domain.OrderItem
+	for _, item := range order.Items {
+		if item.SellerId == sellerID {
+			items = append(items, item)
+		}
+	}
+
+	customer, err := s.Repo.FindUserByID(order.UserID)
+	if err != nil {
+		return domain.Invoice{}, nil, errors.New("unable to issue invoice")
+	}
+	original, err := s.Invoices.IssueInvoice(order, customer)
+	if err != nil {
+		return domain.Invoice{}, nil, err
+	}
+	return s.Invoices.SellerExtract(original, order, *subOrder, items, customer)
+}
+
+func (s UserService) orderInvoice(order domain.Order) (domain.Invoice, []byte, error) {
+	inv, err := s.Invoices.Repo.FindOrderInvoice(order.ID)
+	if err != nil {
+		customer, err := s.Repo.FindUserByID(order.UserID)
+		if err != nil {
+			return domain.Invoice{}, nil, errors.New("unable to issue invoice")
+		}
+		inv, err = s.Invoices.IssueInvoice(order, customer)
+		if err != nil {
+			return domain.Invoice{}, nil, err
+		}
+	}
+
+	pdf, err := s.Invoices.GetPDF(inv)
+	return inv, pdf, err
+}
+
+func (s UserService) GetCreditNotes(orderID uint, userID uint) ([]domain.Invoice, error) {
+	order, err := s.Repo.FindOrderByID(orderID, userID)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+	return s.Invoices.Repo.FindOrderCreditNotes(order.ID)
+}
+
+func (s UserService) GetCreditNote(orderID uint, creditNoteID uint, userID uint) (domain.Invoice, []byte, error) {
+	order, err := s.Repo.FindOrderByID(orderID, userID)
+	if err != nil {
+		return domain.Invoice{}, nil, errors.New("order not found")
+	}
+	cn, err := s.Invoices.Repo.FindInvoiceByID(creditNoteID)
+	if err != nil || cn.OrderID != order.ID || cn.Type != domain.CREDIT_NOTE {
+		return domain.Invoice{}, nil, errors.New("credit note not found")
+	}
+
+	pdf, err := s.Invoices.GetPDF(cn)
+	return cn, pdf, err
+}
+
+// checkSellerRefund limits a seller's refund to their own line of the order, together with what was
+// already refunded for that line it may not exceed what the buyer paid for it
+// lineTotal is what the buyer paid for an order line including tax
+func lineTotal(item domain.OrderItem) float64 {
+	if item.TotalInclTax == 0 {
+		// orders placed before tax was recorded
+		return item.Price * float64(item.Qty)
+	}
+	return item.TotalInclTax
+}
+
+func (s UserService) checkSellerRefund(sellerID uint, order domain.Order, returnRequest domain.ReturnRequest) error {
+	var item *domain.OrderItem
+	for i := range order.Items {
+		if order.Items[i].ID == returnRequest.OrderItemID {
+			item = &order.Items[i]
+		}
+	}
+	if item == nil || item.SellerId != sellerID {
+		return errors.New("return request not found")
+	}
+
+	paid := lineTotal(*item)
+
+	returns, err := s.Repo.FindOrderItemReturnRequests(item.ID)
+	if err != nil {
+		return err
+	}
+	refunded := 0.0
+	for _, r := range returns {
+		if r.ID != returnRequest.ID && (r.Status == domain.RETURN_REFUNDED || r.Status == domain.RETURN_REFUND_PENDING) {
+			refunded += r.RefundAmount
+		}
+	}
+	if returnRequest.RefundAmount <= 0 || refunded+returnRequest.RefundAmount > paid+0.005 {
+		return errors.New("refund exceeds the amount paid for the item")
+	}
+	return nil
+}
+
+func (s UserService) findSellerReturn(sellerID uint, returnID uint) (domain.ReturnRequest, error) {
+	returnRequest, err := s.Repo.FindReturnRequestByID(returnID)
+	if err != nil || returnRequest.SellerId != sellerID {
+		return domain.ReturnRequest{}, errors.New("return request not found")
+	}
+	return returnRequest, nil
+}
+
+// recordReturnStatus adds a return step to the status history of the order it belongs to
+func (s UserService) recordReturnStatus(returnRequest domain.ReturnRequest, note string, changedBy uint) error {
+	detail := fmt.Sprintf("return #%d for item #%d (qty %d)", returnRequest.ID, returnRequest.OrderItemID, returnRequest.Qty)
+	if note != "" {
+		detail = fmt.Sprintf("%s: %s", detail, note)
+	}
+	return s.recordOrderStatus(returnRequest.OrderID, returnRequest.Status, detail, changedBy)
+}
+
+func (s UserService) GetSalesReport(sellerID uint, filter dto.SellerOrderFilter) (dto.SalesReport, error) {
+	orders, err := s.Repo.FindSellerOrders(sellerID, filter.Status, filter.From, filter.To)
+	if err != nil {
+		return dto.SalesReport{}, err
+	}
+
+	report := dto.SalesReport{
+		From:       filter.From,
+		To:         filter.To,
+		OrderCount: len(orders),
+		Rows:       []dto.SalesReportRow{},
+	}
+	for _, order := range orders {
+		for _, item := range order.Items {
+			lineTotal := item.Price*float64(item.Qty) - item.Discount
+			report.Rows = append(report.Rows, dto.SalesReportRow{
+				OrderRefNumber: order.OrderRefNumber,
+				OrderDate:      order.CreatedAt,
+				ProductID:      item.ProductID,
+				Name:           item.Name,
+				Qty:            item.Qty,
+				UnitPrice:      item.Price,
+				Discount:       item.Discount,
+				LineTotal:      lineTotal,
+				Status:         item.Status,
+			})
+			report.UnitsSold += item.Qty
+			report.Revenue += lineTotal
+		}
+	}
+	return report, nil
+}
+
 func (s UserService) GetCurrentUser(c *fiber.Ctx) *client.TokenUser {
 	user, ok := c.Locals("user").(*client.TokenUser)
 	if !ok {
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"reflect"
+	"testing"
+)
+
+// subOrderTotals is the money of a sub-order, the fields splitBySeller fills in
+type subOrderTotals struct {
+	SellerId     uint
+	Subtotal     float64
+	Discount     float64
+	ShippingCost float64
+	TotalExclTax float64
+	Tax          float64
+	Total        float64
+}
+
+func orderLine(sellerID uint, price float64, qty uint, discount float64, tax float64) domain.OrderItem {
+	totalExclTax := roundCents(price*float64(qty) - discount)
+	return domain.OrderItem{SellerId: sellerID, Price: price, Qty: qty, Discount: discount, Tax: tax, TotalExclTax: totalExclTax, TotalInclTax: roundCents(totalExclTax + tax)}
+}
+
+func TestSplitBySeller(t *testing.T) {
+	tests := []struct {
+		name     string
+		items    []domain.OrderItem
+		shipping float64
+		// shippingTax is the tax on the shipping, the order tax is that plus the tax on the items
+		shippingTax float64
+		want        []subOrderTotals
+	}{
+		{
+			name:        "one seller takes all the shipping",
+			items:       []domain.OrderItem{orderLine(1, 10, 2, 2, 3.42)},
+			shipping:    5,
+			shippingTax: 0.95,
+			want:        []subOrderTotals{{SellerId: 1, Subtotal: 20, Discount: 2, ShippingCost: 5, TotalExclTax: 23, Tax: 4.37, Total: 27.37}},
+		},
+		{
+			name:     "lines of a seller are grouped",
+			items:    []domain.OrderItem{orderLine(1, 10, 1, 0, 0), orderLine(2, 10, 1, 0, 0), orderLine(1, 20, 1, 0, 0)},
+			shipping: 4,
+			want: []subOrderTotals{
+				{SellerId: 1, Subtotal: 30, ShippingCost: 3, TotalExclTax: 33, Total: 33},
+				{SellerId: 2, Subtotal: 10, ShippingCost: 1, TotalExclTax: 11, Total: 11},
+			},
+		},
+		{
+			name:        "the last sub-order takes the rounding remainder",
+			items:       []domain.OrderItem{orderLine(1, 10, 1, 0, 0), orderLine(2, 10, 1, 0, 0), orderLine(3, 10, 1, 0, 0)},
+			shipping:    10,
+			shippingTax: 1,
+			want: []subOrderTotals{
+				{SellerId: 1, Subtotal: 10, ShippingCost: 3.33, TotalExclTax: 13.33, Tax: 0.33, Total: 13.66},
+				{SellerId: 2, Subtotal: 10, ShippingCost: 3.33, TotalExclTax: 13.33, Tax: 0.33, Total: 13.66},
+				{SellerId: 3, Subtotal: 10, ShippingCost: 3.34, TotalExclTax: 13.34, Tax: 0.34, Total: 13.68},
+			},
+		},
+		{
+			name:     "fully discounted sub-orders share the shipping equally",
+			items:    []domain.OrderItem{orderLine(1, 10, 1, 10, 0), orderLine(2, 5, 1, 5, 0)},
+			shipping: 5,
+			want: []subOrderTotals{
+				{SellerId: 1, Subtotal: 10, Discount: 10, ShippingCost: 2.5, TotalExclTax: 2.5, Total: 2.5},
+				{SellerId: 2, Subtotal: 5, Discount: 5, ShippingCost: 2.5, TotalExclTax: 2.5, Total: 2.5},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := domain.Order{Items: tt.items, ShippingCost: tt.shipping, Tax: tt.shippingTax}
+			var orderTotal float64
+			for _, item := range tt.items {
+				order.Tax += item.Tax
+				orderTotal += item.TotalInclTax
+			}
+			orderTotal = roundCents(orderTotal + tt.shipping + tt.shippingTax)
+
+			var got []subOrderTotals
+			var total float64
+			for _, subOrder := range splitBySeller(order) {
+				if subOrder.Status != domain.ITEM_PENDING {
+					t.Errorf("sub-order of seller %d status = %q, want %q", subOrder.SellerId, subOrder.Status, domain.ITEM_PENDING)
+				}
+				got = append(got, subOrderTotals{subOrder.SellerId, subOrder.Subtotal, subOrder.Discount, subOrder.ShippingCost, subOrder.TotalExclTax, subOrder.Tax, subOrder.Total})
+				total += subOrder.Total
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("sub-orders = %+v, want %+v", got, tt.want)
+			}
+			if roundCents(total) != orderTotal {
+				t.Errorf("sub-orders add up to %v, want the order total %v", roundCents(total), orderTotal)
+			}
+		})
+	}
+}
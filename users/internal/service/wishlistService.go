@@ -0,0 +1,300 @@
+package service
+
+import (
+	"errors"
+	"github.com/sharat789/zamazon-be-ms/users/internal/client"
+	"github.com/sharat789/zamazon-be-ms/users/internal/domain"
+	"github.com/sharat789/zamazon-be-ms/users/internal/dto"
+	"github.com/sharat789/zamazon-be-ms/users/internal/repository"
+	"log"
+	"strings"
+)
+
+type WishlistService struct {
+	Repo          repository.WishlistRepository
+	CatalogClient *client.CatalogClient
+	// Cart is used to move items between wishlists and the cart
+	Cart UserService
+}
+
+func (s WishlistService) CreateWishlist(userID uint, input dto.WishlistInput) (*dto.WishlistResponse, error) {
+	name := strings.TrimSpace(input.Name)
+	if name == "" {
+		return nil, errors.New("wishlist name is required")
+	}
+
+	wishlist := domain.Wishlist{
+		UserID: userID,
+		Name:   name,
+	}
+	if input.IsPublic != nil && *input.IsPublic {
+		token, err := newRandomToken()
+		if err != nil {
+			return nil, errors.New("unable to create share link")
+		}
+		wishlist.IsPublic = true
+		wishlist.ShareToken = token
+	}
+
+	err := s.Repo.CreateWishlist(&wishlist)
+	if err != nil {
+		return nil, err
+	}
+	return s.enrichWishlist(wishlist, true), nil
+}
+
+func (s WishlistService) GetWishlists(userID uint) ([]dto.WishlistResponse, error) {
+	wishlists, err := s.Repo.FindWishlists(userID)
+	if err != nil {
+		return nil, err
+	}
+
+	response := make([]dto.WishlistResponse, 0, len(wishlists))
+	for _, wishlist := range wishlists {
+		response = append(response, *s.enrichWishlist(wishlist, true))
+	}
+	return response, nil
+}
+
+func (s WishlistService) GetWishlist(id uint, userID uint) (*dto.WishlistResponse, error) {
+	wishlist, err := s.Repo.FindWishlistByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+	return s.enrichWishlist(wishlist, true), nil
+}
+
+// GetSharedWishlist returns a public wishlist by its share token, the share token itself is not exposed
+func (s WishlistService) GetSharedWishlist(token string) (*dto.WishlistResponse, error) {
+	if token == "" {
+		return nil, errors.New("could not find wishlist")
+	}
+	wishlist, err := s.Repo.FindWishlistByShareToken(token)
+	if err != nil {
+		return nil, err
+	}
+	return s.enrichWishlist(wishlist, false), nil
+}
+
+// UpdateWishlist renames a wishlist and toggles its share link, a new link is issued every time sharing is enabled
+func (s WishlistService) UpdateWishlist(id uint, userID uint, input dto.WishlistInput) (*dto.WishlistResponse, error) {
+	wishlist, err := s.Repo.FindWishlistByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	if name := strings.TrimSpace(input.Name); name != "" {
+		if wishlist.IsSaveForLater {
+			return nil, errors.New("the save for later list cannot be renamed")
+		}
+		wishlist.Name = name
+	}
+
+	if input.IsPublic != nil && *input.IsPublic != wishlist.IsPublic {
+		wishlist.IsPublic = *input.IsPublic
+		wishlist.ShareToken = ""
+		if wishlist.IsPublic {
+			token, err := newRandomToken()
+			if err != nil {
+				return nil, errors.New("unable to create share link")
+			}
+			wishlist.ShareToken = token
+		}
+	}
+
+	err = s.Repo.UpdateWishlist(wishlist)
+	if err != nil {
+		return nil, err
+	}
+	return s.enrichWishlist(wishlist, true), nil
+}
+
+func (s WishlistService) DeleteWishlist(id uint, userID uint) error {
+	wishlist, err := s.Repo.FindWishlistByID(id, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repo.DeleteWishlist(wishlist.ID)
+	if err != nil {
+		log.Printf("Error while deleting wishlist %v", err)
+		return errors.New("unable to delete wishlist")
+	}
+	return nil
+}
+
+func (s WishlistService) AddItem(id uint, userID uint, input dto.WishlistItemInput) (*dto.WishlistResponse, error) {
+	if input.ProductID == 0 {
+		return nil, errors.New("product id is required")
+	}
+
+	wishlist, err := s.Repo.FindWishlistByID(id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	existing, _ := s.Repo.FindWishlistItem(wishlist.ID, input.ProductID)
+	if existing.ID != 0 {
+		return nil, errors.New("product is already in this wishlist")
+	}
+
+	product, err := s.CatalogClient.GetProductByID(input.ProductID)
+	if err != nil {
+		return nil, errors.New("product not found")
+	}
+
+	qty := input.Qty
+	if qty < 1 {
+		qty = 1
+	}
+
+	err = s.Repo.CreateWishlistItem(&domain.WishlistItem{
+		WishlistID:     wishlist.ID,
+		ProductID:      input.ProductID,
+		Name:           product.Name,
+		ImageURL:       product.ImageURL,
+		SellerId:       product.UserID,
+		PriceWhenAdded: product.Price,
+		Qty:            qty,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.GetWishlist(wishlist.ID, userID)
+}
+
+func (s WishlistService) RemoveItem(id uint, userID uint, productID uint) error {
+	wishlist, err := s.Repo.FindWishlistByID(id, userID)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repo.DeleteWishlistItem(wishlist.ID, productID)
+	if err != nil {
+		log.Printf("Error while removing wishlist item %v", err)
+		return errors.New("unable to remove item from wishlist")
+	}
+	return nil
+}
+
+// MoveItemToCart adds a wishlist item to the cart, on top of any quantity already there, and removes it from the wishlist
+func (s WishlistService) MoveItemToCart(id uint, productID uint, u *client.TokenUser) ([]domain.Cart, error) {
+	wishlist, err := s.Repo.FindWishlistByID(id, u.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	item, err := s.Repo.FindWishlistItem(wishlist.ID, productID)
+	if err != nil {
+		return nil, errors.New("product is not in this wishlist")
+	}
+
+	qty := item.Qty
+	if qty < 1 {
+		qty = 1
+	}
+	cartItem, _ := s.Cart.Repo.FindCartItem(u.ID, productID)
+	qty += cartItem.Qty
+
+	cart, err := s.Cart.CreateCart(dto.CreateCartRequest{ProductID: productID, Qty: qty}, u)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.Repo.DeleteWishlistItem(wishlist.ID, productID)
+	if err != nil {
+		log.Printf("Error while removing moved wishlist item %v", err)
+	}
+	return cart, nil
+}
+
+// SaveForLater moves a cart line to the user's save for later list, creating the list on first use
+func (s WishlistService) SaveForLater(userID uint, productID uint) (*dto.WishlistResponse, error) {
+	cartItem, err := s.Cart.Repo.FindCartItem(userID, productID)
+	if err != nil || cartItem.ID == 0 {
+		return nil, errors.New("product is not in the cart")
+	}
+
+	wishlist, err := s.Repo.FindSaveForLaterList(userID)
+	if err != nil {
+		wishlist = domain.Wishlist{
+			UserID:         userID,
+			Name:           domain.SAVE_FOR_LATER_LIST,
+			IsSaveForLater: true,
+		}
+		err = s.Repo.CreateWishlist(&wishlist)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	existing, _ := s.Repo.FindWishlistItem(wishlist.ID, productID)
+	if existing.ID != 0 {
+		existing.Qty += cartItem.Qty
+		err = s.Repo.UpdateWishlistItem(existing)
+	} else {
+		err = s.Repo.CreateWishlistItem(&domain.WishlistItem{
+			WishlistID:     wishlist.ID,
+			ProductID:      cartItem.ProductID,
+			Name:           cartItem.Name,
+			ImageURL:       cartItem.ImageURL,
+			SellerId:       cartItem.SellerId,
+			PriceWhenAdded: cartItem.Price,
+			Qty:            cartItem.Qty,
+		})
+	}
+	if err != nil {
+		log.Printf("Error while saving item for later %v", err)
+		return nil, errors.New("unable to save item for later")
+	}
+
+	err = s.Cart.RemoveProductFromCart(userID, productID)
+	if err != nil {
+		return nil, err
+	}
+	return s.GetWishlist(wishlist.ID, userID)
+}
+
+// enrichWishlist adds the live catalog price and stock to every item and flags price drops since it was added
+func (s WishlistService) enrichWishlist(wishlist domain.Wishlist, includeToken bool) *dto.WishlistResponse {
+	response := &dto.WishlistResponse{
+		ID:             wishlist.ID,
+		Name:           wishlist.Name,
+		IsSaveForLater: wishlist.IsSaveForLater,
+		IsPublic:       wishlist.IsPublic,
+		Items:          make([]dto.WishlistItemResponse, 0, len(wishlist.Items)),
+	}
+	if includeToken {
+		response.ShareToken = wishlist.ShareToken
+	}
+
+	for _, item := range wishlist.Items {
+		line := dto.WishlistItemResponse{
+			ProductID:      item.ProductID,
+			Name:           item.Name,
+			ImageURL:       item.ImageURL,
+			Qty:            item.Qty,
+			PriceWhenAdded: item.PriceWhenAdded,
+			CurrentPrice:   item.PriceWhenAdded,
+		}
+
+		product, err := s.CatalogClient.GetProductByID(item.ProductID)
+		if err != nil {
+			if !errors.Is(err, client.ErrProductNotFound) {
+				log.Printf("Error while enriching wishlist product %d: %v", item.ProductID, err)
+			}
+			line.Unavailable = true
+		} else {
+			line.Name = product.Name
+			line.ImageURL = product.ImageURL
+			line.CurrentPrice = product.Price
+			line.InStock = product.Stock > 0
+			if product.Price < item.PriceWhenAdded {
+				line.PriceDropped = true
+				line.PriceDrop = item.PriceWhenAdded - product.Price
+			}
+		}
+		response.Items = append(response.Items, line)
+	}
+	return response
+}
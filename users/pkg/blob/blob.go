@@ -0,0 +1,66 @@
+package blob
+
+import (
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore stores generated documents by key, implementations can be swapped for object storage
+type BlobStore interface {
+	Put(key string, data []byte) error
+	Get(key string) ([]byte, error)
+}
+
+type localStore struct {
+	dir string
+}
+
+func (s localStore) Put(key string, data []byte) error {
+	path, err := s.path(key)
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		log.Printf("Error while creating blob directory %v", err)
+		return errors.New("could not store blob")
+	}
+	err = os.WriteFile(path, data, 0o644)
+	if err != nil {
+		log.Printf("Error while writing blob %v", err)
+		return errors.New("could not store blob")
+	}
+	return nil
+}
+
+func (s localStore) Get(key string) ([]byte, error) {
+	path, err := s.path(key)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s localStore) path(key string) (string, error) {
+	clean := filepath.Clean("/" + key)
+	if strings.Contains(key, "..") || clean == "/" {
+		return "", errors.New("invalid blob key")
+	}
+	return filepath.Join(s.dir, clean), nil
+}
+
+// NewLocalBlobStore stores blobs as files under dir
+func NewLocalBlobStore(dir string) BlobStore {
+	return &localStore{
+		dir: dir,
+	}
+}
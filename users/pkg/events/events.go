@@ -0,0 +1,77 @@
+package events
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/sharat789/zamazon-be-ms/common/auth"
+	"net/http"
+	"time"
+)
+
+const (
+	USER_DELETED = "user.deleted"
+)
+
+type Event struct {
+	Type       string    `json:"type"`
+	UserID     uint      `json:"user_id"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Publisher notifies the other services of something that happened in this one, events are sent to
+// each subscriber on its own so a failed delivery can be retried without repeating the others
+type Publisher interface {
+	Subscribers() []string
+	Deliver(subscriber string, event Event) error
+}
+
+type httpPublisher struct {
+	subscribers []string
+	client      *http.Client
+	// tokens authenticate this service on the subscribers' internal routes
+	tokens *auth.ServiceTokenSource
+}
+
+func (p httpPublisher) Subscribers() []string {
+	return p.subscribers
+}
+
+// Deliver posts the event to the subscriber url, anything but a 200 counts as not delivered
+func (p httpPublisher) Deliver(url string, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	token, err := p.tokens.Token()
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", token)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("subscriber responded with %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// NewHTTPPublisher posts events as JSON to each of the subscriber urls with a service token
+func NewHTTPPublisher(subscribers []string, tokens *auth.ServiceTokenSource) Publisher {
+	return httpPublisher{
+		subscribers: subscribers,
+		client:      &http.Client{Timeout: 10 * time.Second},
+		tokens:      tokens,
+	}
+}
@@ -0,0 +1,143 @@
+package invoice
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/go-pdf/fpdf"
+	"time"
+)
+
+// Document is everything printed on an invoice or credit note, amounts are in EUR
+type Document struct {
+	Title            string
+	Number           string
+	IssuedAt         time.Time
+	OrderRefNumber   string
+	PaymentReference string
+	// RelatedNumber is the invoice a credit note corrects
+	RelatedNumber string
+	Seller        []string
+	Customer      []string
+	Lines         []Line
+	Discount      float64
+	Shipping      float64
+	TotalExclTax  float64
+	Tax           float64
+	Total         float64
+	Note          string
+}
+
+type Line struct {
+	Description string
+	Qty         uint
+	UnitPrice   float64
+	TaxRate     float64
+	Amount      float64
+}
+
+// Render lays the document out on an A4 page and returns the PDF bytes
+func Render(doc Document) ([]byte, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+	pdf.SetTitle(doc.Title+" "+doc.Number, false)
+	pdf.AddPage()
+
+	pdf.SetFont("Helvetica", "B", 18)
+	pdf.Cell(0, 10, tr(doc.Title))
+	pdf.Ln(12)
+
+	pdf.SetFont("Helvetica", "", 10)
+	details := [][2]string{
+		{"Number", doc.Number},
+		{"Date", doc.IssuedAt.Format("02 Jan 2006")},
+		{"Order", doc.OrderRefNumber},
+		{"Payment reference", doc.PaymentReference},
+	}
+	if doc.RelatedNumber != "" {
+		details = append(details, [2]string{"Corrects invoice", doc.RelatedNumber})
+	}
+	for _, d := range details {
+		pdf.CellFormat(40, 5, d[0], "", 0, "L", false, 0, "")
+		pdf.CellFormat(0, 5, tr(d[1]), "", 1, "L", false, 0, "")
+	}
+	pdf.Ln(5)
+
+	top := pdf.GetY()
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.Cell(95, 5, "From")
+	pdf.Cell(95, 5, "Bill to")
+	pdf.Ln(6)
+	pdf.SetFont("Helvetica", "", 10)
+	for i := 0; i < len(doc.Seller) || i < len(doc.Customer); i++ {
+		pdf.Cell(95, 5, tr(lineAt(doc.Seller, i)))
+		pdf.Cell(95, 5, tr(lineAt(doc.Customer, i)))
+		pdf.Ln(5)
+	}
+	pdf.SetY(top + 40)
+
+	widths := []float64{85, 15, 30, 20, 40}
+	pdf.SetFont("Helvetica", "B", 10)
+	pdf.SetFillColor(230, 230, 230)
+	for i, h := range []string{"Description", "Qty", "Unit price", "VAT", "Amount"} {
+		align := "R"
+		if i == 0 {
+			align = "L"
+		}
+		pdf.CellFormat(widths[i], 7, h, "B", 0, align, true, 0, "")
+	}
+	pdf.Ln(-1)
+
+	pdf.SetFont("Helvetica", "", 10)
+	for _, line := range doc.Lines {
+		pdf.CellFormat(widths[0], 6, tr(line.Description), "", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[1], 6, fmt.Sprintf("%d", line.Qty), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[2], 6, money(line.UnitPrice), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[3], 6, fmt.Sprintf("%g%%", line.TaxRate), "", 0, "R", false, 0, "")
+		pdf.CellFormat(widths[4], 6, money(line.Amount), "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(4)
+
+	totals := [][2]string{}
+	if doc.Discount != 0 {
+		totals = append(totals, [2]string{"Discount", money(-doc.Discount)})
+	}
+	if doc.Shipping != 0 {
+		totals = append(totals, [2]string{"Shipping", money(doc.Shipping)})
+	}
+	totals = append(totals,
+		[2]string{"Total excl. VAT", money(doc.TotalExclTax)},
+		[2]string{"VAT", money(doc.Tax)},
+		[2]string{"Total", money(doc.Total)},
+	)
+	for i, t := range totals {
+		if i == len(totals)-1 {
+			pdf.SetFont("Helvetica", "B", 11)
+		}
+		pdf.CellFormat(150, 6, t[0], "", 0, "R", false, 0, "")
+		pdf.CellFormat(40, 6, t[1], "", 1, "R", false, 0, "")
+	}
+
+	if doc.Note != "" {
+		pdf.Ln(8)
+		pdf.SetFont("Helvetica", "I", 9)
+		pdf.MultiCell(0, 5, tr(doc.Note), "", "L", false)
+	}
+
+	var buf bytes.Buffer
+	err := pdf.Output(&buf)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func lineAt(lines []string, i int) string {
+	if i < len(lines) {
+		return lines[i]
+	}
+	return ""
+}
+
+func money(amount float64) string {
+	return fmt.Sprintf("EUR %.2f", amount)
+}
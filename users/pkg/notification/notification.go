@@ -0,0 +1,59 @@
+package notification
+
+import (
+	"errors"
+	"log"
+	"net/smtp"
+	"strings"
+)
+
+// Notifier tells a user about something that happened to their account
+type Notifier interface {
+	Notify(to string, subject string, message string) error
+}
+
+type smtpNotifier struct {
+	addr string
+	from string
+	auth smtp.Auth
+}
+
+func (n smtpNotifier) Notify(to string, subject string, message string) error {
+	body := strings.Join([]string{
+		"From: " + n.from,
+		"To: " + to,
+		"Subject: " + subject,
+		"Content-Type: text/plain; charset=UTF-8",
+		"",
+		message,
+	}, "\r\n")
+
+	err := smtp.SendMail(n.addr, n.auth, n.from, []string{to}, []byte(body))
+	if err != nil {
+		log.Printf("Error while sending notification %v", err)
+		return errors.New("could not send notification")
+	}
+	return nil
+}
+
+type logNotifier struct{}
+
+func (n logNotifier) Notify(to string, subject string, message string) error {
+	log.Printf("notification for user: %s", subject)
+	return nil
+}
+
+// NewSMTPNotifier sends notifications as plain text emails through the server at addr (host:port)
+func NewSMTPNotifier(addr string, from string, username string, password string) Notifier {
+	var auth smtp.Auth
+	if username != "" {
+		host := strings.Split(addr, ":")[0]
+		auth = smtp.PlainAuth("", username, password, host)
+	}
+	return smtpNotifier{addr: addr, from: from, auth: auth}
+}
+
+// NewLogNotifier only logs the subject of each notification, for environments without a mail server
+func NewLogNotifier() Notifier {
+	return logNotifier{}
+}
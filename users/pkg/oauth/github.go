@@ -0,0 +1,89 @@
+package oauth
+
+import (
+	"context"
+	"errors"
+	"golang.org/x/oauth2"
+	"log"
+	"strconv"
+	"strings"
+)
+
+type githubProvider struct {
+	name   string
+	apiURL string
+	config *oauth2.Config
+}
+
+func (p githubProvider) Name() string {
+	return p.name
+}
+
+func (p githubProvider) AuthCodeURL(state string, verifier string) (string, error) {
+	return p.config.AuthCodeURL(state, oauth2.S256ChallengeOption(verifier)), nil
+}
+
+// Exchange reads the profile and the primary email, GitHub reports verification per email address
+func (p githubProvider) Exchange(ctx context.Context, code string, verifier string) (Identity, error) {
+	token, err := exchange(ctx, p.config, code, verifier)
+	if err != nil {
+		return Identity{}, err
+	}
+
+	var profile struct {
+		ID    int64  `json:"id"`
+		Name  string `json:"name"`
+		Login string `json:"login"`
+	}
+	err = getJSON(ctx, token, p.apiURL+"/user", &profile)
+	if err != nil {
+		log.Printf("Error while fetching %s profile %v", p.name, err)
+		return Identity{}, errors.New("could not read the profile from the provider")
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	err = getJSON(ctx, token, p.apiURL+"/user/emails", &emails)
+	if err != nil {
+		log.Printf("Error while fetching %s emails %v", p.name, err)
+		return Identity{}, errors.New("could not read the email address from the provider")
+	}
+
+	identity := Identity{
+		Provider: p.name,
+		Subject:  strconv.FormatInt(profile.ID, 10),
+	}
+	identity.FirstName, identity.LastName, _ = strings.Cut(profile.Name, " ")
+	if identity.FirstName == "" {
+		identity.FirstName = profile.Login
+	}
+	for _, email := range emails {
+		if email.Primary {
+			identity.Email = email.Email
+			identity.EmailVerified = email.Verified
+		}
+	}
+	return identity, nil
+}
+
+// NewGitHubProvider signs users in with GitHub, or a GitHub Enterprise server when given its urls
+func NewGitHubProvider(name string, baseURL string, apiURL string, clientID string, clientSecret string, redirectURL string) Provider {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	return githubProvider{
+		name:   name,
+		apiURL: strings.TrimSuffix(apiURL, "/"),
+		config: &oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			RedirectURL:  redirectURL,
+			Scopes:       []string{"read:user", "user:email"},
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  baseURL + "/login/oauth/authorize",
+				TokenURL: baseURL + "/login/oauth/access_token",
+			},
+		},
+	}
+}
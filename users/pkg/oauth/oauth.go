@@ -0,0 +1,65 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"golang.org/x/oauth2"
+	"log"
+	"net/http"
+	"time"
+)
+
+var ErrUnverifiedEmail = errors.New("the provider has not verified the email address")
+
+// Identity is who the provider says signed in
+type Identity struct {
+	Provider      string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	FirstName     string
+	LastName      string
+}
+
+// Provider signs users in through an OAuth2 authorization code flow with PKCE
+type Provider interface {
+	Name() string
+	AuthCodeURL(state string, verifier string) (string, error)
+	Exchange(ctx context.Context, code string, verifier string) (Identity, error)
+}
+
+var httpClient = &http.Client{Timeout: 10 * time.Second}
+
+// getJSON fetches a provider resource with the user's access token
+func getJSON(ctx context.Context, token *oauth2.Token, url string, v interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	token.SetAuthHeader(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("provider responded with %d for %s", resp.StatusCode, url)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// exchange trades the authorization code for a token
+func exchange(ctx context.Context, config *oauth2.Config, code string, verifier string) (*oauth2.Token, error) {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, httpClient)
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(verifier))
+	if err != nil {
+		log.Printf("Error while exchanging authorization code %v", err)
+		return nil, errors.New("could not complete sign in with the provider")
+	}
+	return token, nil
+}
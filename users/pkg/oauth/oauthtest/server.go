@@ -0,0 +1,182 @@
+// Package oauthtest is an OpenID Connect provider that signs every authorization request in as the
+// configured user straight away, for tests and for trying social login without a real account
+package oauthtest
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// UserInfo is what the provider reports about the user who signs in
+type UserInfo struct {
+	Subject       string `json:"sub"`
+	Email         string `json:"email"`
+	EmailVerified bool   `json:"email_verified"`
+	GivenName     string `json:"given_name"`
+	FamilyName    string `json:"family_name"`
+}
+
+type grant struct {
+	challenge string
+	user      UserInfo
+}
+
+// Server serves discovery, authorization, token and userinfo endpoints, the issuer is the address it
+// is reached at. Codes are single use and are only exchanged with the matching PKCE verifier
+type Server struct {
+	mu     sync.Mutex
+	user   UserInfo
+	codes  map[string]grant
+	tokens map[string]UserInfo
+}
+
+func NewServer(user UserInfo) *Server {
+	return &Server{
+		user:   user,
+		codes:  map[string]grant{},
+		tokens: map[string]UserInfo{},
+	}
+}
+
+// SetUser changes who the next authorization request signs in as
+func (s *Server) SetUser(user UserInfo) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.user = user
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("/authorize", s.authorize)
+	mux.HandleFunc("/token", s.token)
+	mux.HandleFunc("/userinfo", s.userInfo)
+	return mux
+}
+
+func (s *Server) discovery(w http.ResponseWriter, r *http.Request) {
+	issuer := "http://" + r.Host
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"issuer":                           issuer,
+		"authorization_endpoint":           issuer + "/authorize",
+		"token_endpoint":                   issuer + "/token",
+		"userinfo_endpoint":                issuer + "/userinfo",
+		"code_challenge_methods_supported": []string{"S256"},
+	})
+}
+
+// authorize approves the request and sends the browser back with a code
+func (s *Server) authorize(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	redirect, err := url.Parse(query.Get("redirect_uri"))
+	if err != nil || redirect.String() == "" {
+		http.Error(w, "redirect_uri is required", http.StatusBadRequest)
+		return
+	}
+	if query.Get("code_challenge_method") != "S256" {
+		http.Error(w, "S256 code challenge is required", http.StatusBadRequest)
+		return
+	}
+
+	code := randomString()
+	s.mu.Lock()
+	s.codes[code] = grant{challenge: query.Get("code_challenge"), user: s.user}
+	s.mu.Unlock()
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", query.Get("state"))
+	redirect.RawQuery = values.Encode()
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+// token checks the code and its PKCE verifier
+func (s *Server) token(w http.ResponseWriter, r *http.Request) {
+	if err := r.ParseForm(); err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_request"})
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	code := r.PostForm.Get("code")
+	g, ok := s.codes[code]
+	delete(s.codes, code)
+	sum := sha256.Sum256([]byte(r.PostForm.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != g.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	accessToken := randomString()
+	s.tokens[accessToken] = g.user
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"access_token": accessToken,
+		"token_type":   "Bearer",
+		"expires_in":   3600,
+	})
+}
+
+func (s *Server) userInfo(w http.ResponseWriter, r *http.Request) {
+	auth := r.Header.Get("Authorization")
+	s.mu.Lock()
+	var user UserInfo
+	ok := false
+	if len(auth) > 7 {
+		user, ok = s.tokens[auth[7:]]
+	}
+	s.mu.Unlock()
+	if !ok {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_token"})
+		return
+	}
+	writeJSON(w, http.StatusOK, user)
+}
+
+// Authorize follows a sign in url the way a browser would up to the provider's redirect, and returns
+// the query the callback would be called with
+func Authorize(authURL string) (url.Values, error) {
+	client := &http.Client{
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	resp, err := client.Get(authURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusFound {
+		return nil, fmt.Errorf("provider responded with %d", resp.StatusCode)
+	}
+	callback, err := url.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return nil, err
+	}
+	values := callback.Query()
+	if values.Get("code") == "" {
+		return nil, errors.New("provider did not return a code")
+	}
+	return values, nil
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func randomString() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,80 @@
+package oauth
+
+import (
+	"context"
+	"github.com/sharat789/zamazon-be-ms/users/pkg/oauth/oauthtest"
+	"net/http/httptest"
+	"testing"
+)
+
+func newTestProvider(t *testing.T, user oauthtest.UserInfo) Provider {
+	t.Helper()
+	server := httptest.NewServer(oauthtest.NewServer(user).Handler())
+	t.Cleanup(server.Close)
+	return NewOIDCProvider("google", server.URL, "client-id", "client-secret", "http://localhost/users/oauth/google/callback")
+}
+
+func TestOIDCExchangeWithPKCE(t *testing.T) {
+	user := oauthtest.UserInfo{Subject: "subject-1", Email: "jane@example.com", EmailVerified: true, GivenName: "Jane", FamilyName: "Doe"}
+	provider := newTestProvider(t, user)
+
+	authURL, err := provider.AuthCodeURL("state-1", "verifier-with-enough-entropy-for-pkce-0001")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	callback, err := oauthtest.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+	if callback.Get("state") != "state-1" {
+		t.Fatalf("state = %q, want state-1", callback.Get("state"))
+	}
+
+	identity, err := provider.Exchange(context.Background(), callback.Get("code"), "verifier-with-enough-entropy-for-pkce-0001")
+	if err != nil {
+		t.Fatalf("Exchange: %v", err)
+	}
+	want := Identity{Provider: "google", Subject: "subject-1", Email: "jane@example.com", EmailVerified: true, FirstName: "Jane", LastName: "Doe"}
+	if identity != want {
+		t.Fatalf("identity = %+v, want %+v", identity, want)
+	}
+}
+
+func TestOIDCExchangeRejectsWrongVerifier(t *testing.T) {
+	provider := newTestProvider(t, oauthtest.UserInfo{Subject: "subject-1", Email: "jane@example.com", EmailVerified: true})
+
+	authURL, err := provider.AuthCodeURL("state-1", "verifier-with-enough-entropy-for-pkce-0001")
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	callback, err := oauthtest.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+
+	_, err = provider.Exchange(context.Background(), callback.Get("code"), "verifier-of-another-sign-in-attempt-00002")
+	if err == nil {
+		t.Fatal("Exchange accepted a code with the wrong PKCE verifier")
+	}
+}
+
+func TestOIDCExchangeRejectsReusedCode(t *testing.T) {
+	provider := newTestProvider(t, oauthtest.UserInfo{Subject: "subject-1", Email: "jane@example.com", EmailVerified: true})
+	verifier := "verifier-with-enough-entropy-for-pkce-0001"
+
+	authURL, err := provider.AuthCodeURL("state-1", verifier)
+	if err != nil {
+		t.Fatalf("AuthCodeURL: %v", err)
+	}
+	callback, err := oauthtest.Authorize(authURL)
+	if err != nil {
+		t.Fatalf("authorize: %v", err)
+	}
+
+	if _, err = provider.Exchange(context.Background(), callback.Get("code"), verifier); err != nil {
+		t.Fatalf("first Exchange: %v", err)
+	}
+	if _, err = provider.Exchange(context.Background(), callback.Get("code"), verifier); err == nil {
+		t.Fatal("Exchange accepted a code that was already used")
+	}
+}
@@ -0,0 +1,63 @@
+package tax
+
+// euVatRates are the standard, main reduced and super reduced VAT rates of the EU member states
+var euVatRates = map[string]Rates{
+	"AT": {Standard: 20, Reduced: 10},
+	"BE": {Standard: 21, Reduced: 6},
+	"BG": {Standard: 20, Reduced: 9},
+	"CY": {Standard: 19, Reduced: 5},
+	"CZ": {Standard: 21, Reduced: 12},
+	"DE": {Standard: 19, Reduced: 7},
+	"DK": {Standard: 25},
+	"EE": {Standard: 24, Reduced: 9},
+	"ES": {Standard: 21, Reduced: 10, SuperReduced: 4},
+	"FI": {Standard: 25.5, Reduced: 14},
+	"FR": {Standard: 20, Reduced: 5.5, SuperReduced: 2.1},
+	"GR": {Standard: 24, Reduced: 13},
+	"HR": {Standard: 25, Reduced: 13},
+	"HU": {Standard: 27, Reduced: 5},
+	"IE": {Standard: 23, Reduced: 13.5, SuperReduced: 4.8},
+	"IT": {Standard: 22, Reduced: 10, SuperReduced: 4},
+	"LT": {Standard: 21, Reduced: 9},
+	"LU": {Standard: 17, Reduced: 8, SuperReduced: 3},
+	"LV": {Standard: 21, Reduced: 12},
+	"MT": {Standard: 18, Reduced: 5},
+	"NL": {Standard: 21, Reduced: 9},
+	"PL": {Standard: 23, Reduced: 8},
+	"PT": {Standard: 23, Reduced: 6},
+	"RO": {Standard: 21, Reduced: 11},
+	"SE": {Standard: 25, Reduced: 12},
+	"SI": {Standard: 22, Reduced: 9.5},
+	"SK": {Standard: 23, Reduced: 19},
+}
+
+var countryNames = map[string]string{
+	"AUSTRIA":        "AT",
+	"BELGIUM":        "BE",
+	"BULGARIA":       "BG",
+	"CYPRUS":         "CY",
+	"CZECHIA":        "CZ",
+	"CZECH REPUBLIC": "CZ",
+	"GERMANY":        "DE",
+	"DENMARK":        "DK",
+	"ESTONIA":        "EE",
+	"SPAIN":          "ES",
+	"FINLAND":        "FI",
+	"FRANCE":         "FR",
+	"GREECE":         "GR",
+	"CROATIA":        "HR",
+	"HUNGARY":        "HU",
+	"IRELAND":        "IE",
+	"ITALY":          "IT",
+	"LITHUANIA":      "LT",
+	"LUXEMBOURG":     "LU",
+	"LATVIA":         "LV",
+	"MALTA":          "MT",
+	"NETHERLANDS":    "NL",
+	"POLAND":         "PL",
+	"PORTUGAL":       "PT",
+	"ROMANIA":        "RO",
+	"SWEDEN":         "SE",
+	"SLOVENIA":       "SI",
+	"SLOVAKIA":       "SK",
+}
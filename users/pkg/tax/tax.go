@@ -0,0 +1,97 @@
+package tax
+
+import (
+	"math"
+	"strings"
+)
+
+// Product tax categories, a product without a category is taxed at the standard rate
+const (
+	CategoryStandard     = "standard"
+	CategoryReduced      = "reduced"
+	CategorySuperReduced = "super_reduced"
+	CategoryZero         = "zero"
+)
+
+type TaxCalculator interface {
+	// Rate returns the tax rate, in percent, for a product tax category delivered to country
+	Rate(country string, category string) float64
+	// Calculate returns the tax due on a tax-exclusive amount, rounded to cents
+	Calculate(country string, category string, amount float64) float64
+}
+
+// Rates holds the rates of one country in percent. A country without a super reduced rate charges
+// its reduced rate, and one without a reduced rate charges its standard rate
+type Rates struct {
+	Standard     float64
+	Reduced      float64
+	SuperReduced float64
+}
+
+type rulesCalculator struct {
+	rules          map[string]Rates
+	defaultCountry string
+}
+
+func (c rulesCalculator) Rate(country string, category string) float64 {
+	country = c.countryCode(country)
+	rates, ok := c.rules[country]
+	if !ok {
+		// deliveries outside the configured countries are exports and are not taxed
+		return 0
+	}
+
+	switch category {
+	case CategoryZero:
+		return 0
+	case CategorySuperReduced:
+		if rates.SuperReduced > 0 {
+			return rates.SuperReduced
+		}
+		if rates.Reduced > 0 {
+			return rates.Reduced
+		}
+		return rates.Standard
+	case CategoryReduced:
+		if rates.Reduced > 0 {
+			return rates.Reduced
+		}
+		return rates.Standard
+	default:
+		return rates.Standard
+	}
+}
+
+func (c rulesCalculator) Calculate(country string, category string, amount float64) float64 {
+	return math.Round(amount*c.Rate(country, category)) / 100
+}
+
+// countryCode maps the delivery country to its ISO code, an empty country uses the default
+func (c rulesCalculator) countryCode(country string) string {
+	code := CountryCode(country)
+	if code == "" {
+		return c.defaultCountry
+	}
+	return code
+}
+
+// CountryCode maps a country name or ISO 3166 code to the upper case ISO code
+func CountryCode(country string) string {
+	country = strings.ToUpper(strings.TrimSpace(country))
+	if code, ok := countryNames[country]; ok {
+		return code
+	}
+	return country
+}
+
+// NewTaxCalculator returns a calculator using the EU VAT rates, defaultCountry is used when the delivery country is unknown
+func NewTaxCalculator(defaultCountry string) TaxCalculator {
+	return NewRulesTaxCalculator(euVatRates, defaultCountry)
+}
+
+func NewRulesTaxCalculator(rules map[string]Rates, defaultCountry string) TaxCalculator {
+	return &rulesCalculator{
+		rules:          rules,
+		defaultCountry: strings.ToUpper(defaultCountry),
+	}
+}
@@ -0,0 +1,59 @@
+package tax
+
+import "testing"
+
+func TestRate(t *testing.T) {
+	calculator := NewTaxCalculator("IE")
+	tests := []struct {
+		name     string
+		country  string
+		category string
+		want     float64
+	}{
+		{"standard", "DE", CategoryStandard, 19},
+		{"no category is standard", "DE", "", 19},
+		{"reduced", "DE", CategoryReduced, 7},
+		{"super reduced", "FR", CategorySuperReduced, 2.1},
+		{"super reduced falls back to reduced", "DE", CategorySuperReduced, 7},
+		{"reduced falls back to standard", "DK", CategoryReduced, 25},
+		{"super reduced falls back to standard", "DK", CategorySuperReduced, 25},
+		{"zero", "DE", CategoryZero, 0},
+		{"country name", "germany", CategoryStandard, 19},
+		{"lower case code", " fr ", CategoryReduced, 5.5},
+		{"empty country uses the default", "", CategoryStandard, 23},
+		{"exports are not taxed", "US", CategoryStandard, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calculator.Rate(tt.country, tt.category); got != tt.want {
+				t.Errorf("Rate(%q, %q) = %v, want %v", tt.country, tt.category, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	calculator := NewTaxCalculator("IE")
+	tests := []struct {
+		name     string
+		country  string
+		category string
+		amount   float64
+		want     float64
+	}{
+		{"whole cents", "DE", CategoryStandard, 100, 19},
+		{"rounds half up to the cent", "IE", CategoryStandard, 10.5, 2.42},
+		{"rounds down below half a cent", "DE", CategoryReduced, 9.99, 0.70},
+		{"reduced without a reduced rate", "DK", CategoryReduced, 10, 2.5},
+		{"zero rated", "DE", CategoryZero, 50, 0},
+		{"export", "US", CategoryStandard, 50, 0},
+		{"zero amount", "DE", CategoryStandard, 0, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := calculator.Calculate(tt.country, tt.category, tt.amount); got != tt.want {
+				t.Errorf("Calculate(%q, %q, %v) = %v, want %v", tt.country, tt.category, tt.amount, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,86 @@
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	digits = 6
+	period = 30
+	// codes from one step either side are accepted to allow for clock drift
+	skew = 1
+)
+
+var encoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// GenerateSecret returns a new random base32 encoded shared secret
+func GenerateSecret() (string, error) {
+	secret := make([]byte, 20)
+	_, err := rand.Read(secret)
+	if err != nil {
+		return "", err
+	}
+	return encoding.EncodeToString(secret), nil
+}
+
+// ProvisioningURI builds the otpauth uri authenticator apps read from a QR code
+func ProvisioningURI(issuer string, account string, secret string) string {
+	query := url.Values{}
+	query.Set("secret", secret)
+	query.Set("issuer", issuer)
+	query.Set("algorithm", "SHA1")
+	query.Set("digits", fmt.Sprint(digits))
+	query.Set("period", fmt.Sprint(period))
+
+	label := url.PathEscape(issuer + ":" + account)
+	return fmt.Sprintf("otpauth://totp/%s?%s", label, query.Encode())
+}
+
+// Step returns the time step a moment falls in
+func Step(at time.Time) int64 {
+	return at.Unix() / period
+}
+
+// Validate checks a code against the steps around the given time and returns the step it matched,
+// callers should reject steps they have already accepted so a code cannot be replayed
+func Validate(secret string, code string, at time.Time) (int64, bool) {
+	key, err := encoding.DecodeString(strings.ToUpper(strings.TrimSpace(secret)))
+	if err != nil {
+		return 0, false
+	}
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	if len(code) != digits {
+		return 0, false
+	}
+
+	current := Step(at)
+	for step := current - skew; step <= current+skew; step++ {
+		if subtle.ConstantTimeCompare([]byte(generate(key, step)), []byte(code)) == 1 {
+			return step, true
+		}
+	}
+	return 0, false
+}
+
+// generate computes the RFC 6238 code of a step
+func generate(key []byte, step int64) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(step))
+
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	return fmt.Sprintf("%0*d", digits, value%1000000)
+}
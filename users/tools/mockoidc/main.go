@@ -0,0 +1,35 @@
+// Command mockoidc is a local OpenID Connect provider for trying social login without a real account.
+// It signs in every authorization request as the configured user straight away.
+//
+//	MOCK_OIDC_ADDR=localhost:9090 MOCK_OIDC_EMAIL=jane@example.com go run ./tools/mockoidc
+//
+// then start the users service with OAUTH_GOOGLE_ISSUER=http://localhost:9090 and any client id and secret
+package main
+
+import (
+	"github.com/sharat789/zamazon-be-ms/users/pkg/oauth/oauthtest"
+	"log"
+	"net/http"
+	"os"
+)
+
+func main() {
+	addr := env("MOCK_OIDC_ADDR", "localhost:9090")
+	server := oauthtest.NewServer(oauthtest.UserInfo{
+		Subject:       env("MOCK_OIDC_SUBJECT", "mock-user-1"),
+		Email:         env("MOCK_OIDC_EMAIL", "mock.user@example.com"),
+		EmailVerified: env("MOCK_OIDC_EMAIL_VERIFIED", "true") == "true",
+		GivenName:     env("MOCK_OIDC_GIVEN_NAME", "Mock"),
+		FamilyName:    env("MOCK_OIDC_FAMILY_NAME", "User"),
+	})
+
+	log.Printf("mock oidc provider listening on http://%s", addr)
+	log.Fatal(http.ListenAndServe(addr, server.Handler()))
+}
+
+func env(key string, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}